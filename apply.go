@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/spec"
+
+	"github.com/spf13/cobra"
+)
+
+func newApplyCommand() *cobra.Command {
+	var file string
+	var atomic bool
+	var output string
+	var stateOut string
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Make the calendars match the rotations of a spec file",
+		Long: `Make the calendars match the rotations of a spec file.
+
+Every rotation of the spec is compared with the events already created for it,
+found by their extended properties, and events are created, updated or deleted
+so the calendar matches the spec. Rotations removed from the spec are left
+alone, use the delete command to remove them.
+
+Rotations listing several calendars are applied to each of them. A rotation
+or calendar failing doesn't stop the others from being applied. A summary of
+the rotations created, updated, skipped as already up to date, or failed in
+every calendar is printed at the end, followed by the number of events
+created, updated and deleted and the reason of each failure. With
+--output json only the latter are printed, as a JSON object.
+
+Rotations with a confluencePage then have their upcoming schedule published to
+that page of the Confluence site given with --confluence-url, as the report
+command renders it in HTML, so the wiki never lags behind the calendars.`,
+		Example: `  # rotations.yaml
+  defaults:
+    calendar: team-roles
+    members: [Cesar, Seth, Juan]
+    start: 2024-07-01
+  rotations:
+    - name: SRE Role
+      cadence: weekly
+    - name: Release Manager
+      cadence: biweekly
+      members: [Seth, Juan]
+      calendars: [team-roles, seth@example.com, juan@example.com]
+      confluencePage: "123456"
+
+  calendar apply -f rotations.yaml --confluence-url https://example.atlassian.net/wiki --confluence-user me@example.com`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			f, err := spec.Load(file)
+			if err != nil {
+				return err
+			}
+			if err := checkPublish(cmd, f); err != nil {
+				return err
+			}
+			var results []applyResult
+			var sum summary
+			err = syncSpec(cmd, file, provider.SyncOptions{Atomic: atomic}, func(s spec.Rotation, calendar string, existing int, changes []provider.Change, err error) {
+				for _, c := range changes {
+					slog.Info("Event "+c.Action, "rotation", s.Name, "calendar", calendar, "summary", c.Summary, "link", c.Link)
+				}
+				results = append(results, newApplyResult(s.Name, calendar, existing, changes, err))
+				sum.add(s.Name, calendar, changes, err)
+			})
+			if len(results) > 0 {
+				if output == "table" {
+					printApplyResults(cmd.OutOrStdout(), results)
+				}
+				sum.print(cmd.OutOrStdout(), output)
+			}
+			if len(results) > 0 && stateOut != "" {
+				writeState(cmd.Context(), stateOut, specProviders(cmd, f)...)
+			}
+			// The pages follow the calendars, whether the spec could be
+			// fully applied or not.
+			if len(results) > 0 && cmd.Context().Err() == nil {
+				err = errors.Join(err, publishSpec(cmd, f))
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "Spec file listing the rotations")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "Delete the events created for a rotation if applying it fails")
+	addSummaryFlag(cmd, &output)
+	addStateOutFlag(cmd, &stateOut)
+	addPublishFlags(cmd)
+	cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+// applyResult is the outcome of syncing a rotation with one of its
+// calendars.
+type applyResult struct {
+	rotation string
+	calendar string
+	// status is one of created, updated, skipped or failed.
+	status  string
+	changes int
+}
+
+// newApplyResult returns the outcome of a sync given the number of events
+// found before it, the changes made and its error.
+func newApplyResult(rotation, calendar string, existing int, changes []provider.Change, err error) applyResult {
+	result := applyResult{rotation: rotation, calendar: calendar, changes: len(changes)}
+	switch {
+	case err != nil:
+		result.status = "failed"
+	case len(changes) == 0:
+		result.status = "skipped"
+	case existing == 0:
+		result.status = "created"
+	default:
+		result.status = "updated"
+	}
+	return result
+}
+
+// printApplyResults renders the outcome of every rotation and calendar
+// followed by their count by status.
+func printApplyResults(w io.Writer, results []applyResult) {
+	counts := make(map[string]int)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROTATION\tCALENDAR\tSTATUS\tCHANGES")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", r.rotation, r.calendar, r.status, r.changes)
+		counts[r.status]++
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d created, %d updated, %d skipped, %d failed\n", counts["created"], counts["updated"], counts["skipped"], counts["failed"])
+}
+
+// syncSpec syncs every rotation of a spec file with each of its calendars,
+// reporting the number of events found in the calendar, the changes made
+// and the error, if any. Failures don't stop the other rotations and
+// calendars from being synced.
+func syncSpec(cmd *cobra.Command, file string, opts provider.SyncOptions, report func(s spec.Rotation, calendar string, existing int, changes []provider.Change, err error)) error {
+	f, err := spec.Load(file)
+	if err != nil {
+		return err
+	}
+	roster, err := loadRoster(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Every rotation is planned before touching any calendar, so an invalid
+	// spec doesn't leave half of it applied, and all of its problems are
+	// reported at once.
+	var problems []error
+	for _, s := range f.Rotations {
+		if _, err := planSpec(cmd, s, roster, nil); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	// The calendars are unlocked as soon as the spec is synced, as watch
+	// syncs it again on the next change.
+	defer releaseLocks(ctx)
+	providers := make(map[string]provider.CalendarProvider)
+	var errs []error
+	for _, s := range f.Rotations {
+		for _, calendarName := range specCalendars(cmd, s) {
+			existing, changes, err := func() ([]provider.Event, []provider.Change, error) {
+				cal, err := specProvider(cmd, calendarName, providers)
+				if err != nil {
+					return nil, nil, err
+				}
+				events, err := planSpec(cmd, s, roster, cal)
+				if err != nil {
+					return nil, nil, err
+				}
+				existing, err := cal.ManagedEvents(ctx, rotation.ID(s.Name))
+				if err != nil {
+					return nil, nil, err
+				}
+				opts := opts
+				if !opts.DryRun {
+					var finish func()
+					opts.Progress, finish = newProgress(cmd, fmt.Sprintf("Syncing %s with %s", s.Name, calendarName))
+					defer finish()
+				}
+				changes, err := cal.Sync(ctx, existing, events, opts)
+				return existing, changes, err
+			}()
+			report(s, calendarName, len(existing), changes, err)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to sync rotation %q with calendar %s: %w", s.Name, calendarName, err))
+			}
+			if ctx.Err() != nil {
+				return errors.Join(errs...)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// specCalendars returns the calendars of a rotation spec, the ones given
+// with --calendar when it has none.
+func specCalendars(cmd *cobra.Command, s spec.Rotation) []string {
+	switch {
+	case len(s.Calendars) > 0:
+		return s.Calendars
+	case s.Calendar != "":
+		return []string{s.Calendar}
+	default:
+		names, _ := cmd.Flags().GetStringArray("calendar")
+		return names
+	}
+}
+
+// specProviders returns the providers of every calendar of a spec file,
+// logging the ones that can't be reached.
+func specProviders(cmd *cobra.Command, f *spec.File) []provider.CalendarProvider {
+	providers := make(map[string]provider.CalendarProvider)
+	var cals []provider.CalendarProvider
+	for _, s := range f.Rotations {
+		for _, calendarName := range specCalendars(cmd, s) {
+			if _, ok := providers[calendarName]; ok {
+				continue
+			}
+			cal, err := specProvider(cmd, calendarName, providers)
+			if err != nil {
+				slog.Error("Unable to reach calendar", "calendar", calendarName, "error", err)
+				continue
+			}
+			cals = append(cals, cal)
+		}
+	}
+	return cals
+}
+
+// specProvider returns the provider of the named calendar, reusing the ones
+// already created.
+func specProvider(cmd *cobra.Command, calendarName string, providers map[string]provider.CalendarProvider) (provider.CalendarProvider, error) {
+	if cal, ok := providers[calendarName]; ok {
+		return cal, nil
+	}
+	cal, err := newCalendarProviderFor(cmd, calendarName)
+	if err != nil {
+		return nil, err
+	}
+	providers[calendarName] = cal
+	return cal, nil
+}
+
+// planSpec returns the events of a rotation spec. The time zone defaults to
+// the one of the calendar, or UTC when there is none.
+func planSpec(cmd *cobra.Command, s spec.Rotation, roster *rotation.Roster, cal provider.CalendarProvider) ([]rotation.Event, error) {
+	r, err := s.Rotation()
+	if err != nil {
+		return nil, err
+	}
+	if roster != nil {
+		if err := roster.Apply(&r); err != nil {
+			return nil, fmt.Errorf("rotation %q: %w", s.Name, err)
+		}
+	}
+	if r.TimeZone == "" && cal != nil {
+		r.TimeZone, err = cal.TimeZone(cmd.Context())
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Holidays only move slots, so checking the spec doesn't read them.
+	if s.HolidayCalendar != "" && cal != nil {
+		client, err := newGoogleCalendarClient(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if err := addHolidays(cmd.Context(), client, &r, s.HolidayCalendar); err != nil {
+			return nil, fmt.Errorf("rotation %q: %w", s.Name, err)
+		}
+	}
+	return rotation.Plan(r)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"calendar/pkg/audit"
+	"calendar/pkg/config"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newAuditCommand() *cobra.Command {
+	var eventName, action, userName string
+	var since, until string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show the events created, updated and deleted by the tool",
+		Long: `Show the events created, updated and deleted by the tool, read from the
+audit log, along with who made the change and with which command.
+
+Every change made to a calendar is appended to the audit log, given with
+--audit-log, as a JSON object per line.`,
+		Example: `  # What changed in the SRE Role last month?
+  calendar audit --event-name "SRE Role" --since 2024-05-01 --until 2024-05-31
+
+  # Every event deleted by alice, as JSON
+  calendar audit --action deleted --user alice --output json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %q, must be one of: table, json", output)
+			}
+			if action != "" && !slices.Contains([]string{audit.ActionCreated, audit.ActionUpdated, audit.ActionDeleted}, action) {
+				return fmt.Errorf("unknown action %q, must be one of: %s, %s, %s", action, audit.ActionCreated, audit.ActionUpdated, audit.ActionDeleted)
+			}
+			// Both days are included, in the local time zone.
+			var from, to time.Time
+			var err error
+			if since != "" {
+				if from, err = time.ParseInLocation(time.DateOnly, since, time.Local); err != nil {
+					return fmt.Errorf("unable to parse --since: %w", err)
+				}
+			}
+			if until != "" {
+				if to, err = time.ParseInLocation(time.DateOnly, until, time.Local); err != nil {
+					return fmt.Errorf("unable to parse --until: %w", err)
+				}
+				to = to.AddDate(0, 0, 1)
+			}
+
+			path, err := auditLogPath(cmd)
+			if err != nil {
+				return err
+			}
+			entries, err := audit.Read(path)
+			if err != nil {
+				return err
+			}
+			entries = slices.DeleteFunc(entries, func(e audit.Entry) bool {
+				return (eventName != "" && e.Rotation != rotation.ID(eventName)) ||
+					(action != "" && e.Action != action) ||
+					(userName != "" && e.User != userName) ||
+					(!from.IsZero() && e.Time.Before(from)) ||
+					(!to.IsZero() && !e.Time.Before(to))
+			})
+			return printAudit(os.Stdout, entries, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Only show the changes to this rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&action, "action", "", "Only show this kind of change: created, updated or deleted")
+	cmd.Flags().StringVar(&userName, "user", "", "Only show the changes made by this user of the system")
+	cmd.Flags().StringVar(&since, "since", "", "First day to show, e.g. 2024-01-01")
+	cmd.Flags().StringVar(&until, "until", "", "Last day to show, e.g. 2024-03-31")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+
+	return cmd
+}
+
+// printAudit renders audit log entries as a table or JSON.
+func printAudit(w io.Writer, entries []audit.Entry, output string) error {
+	if output == "json" {
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tUSER\tACTION\tCALENDAR\tROTATION\tSUMMARY\tEVENT\tCOMMAND")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.DateTime), e.User, e.Action, e.Calendar, e.Rotation, e.Summary, e.EventID, e.Command)
+	}
+	return tw.Flush()
+}
+
+// auditLogPath returns the path given with --audit-log, or audit.log in the
+// configuration directory.
+func auditLogPath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("audit-log"); path != "" {
+		return path, nil
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.log"), nil
+}
+
+// newAuditLog returns the audit log recording the changes made by cmd.
+func newAuditLog(cmd *cobra.Command) (*audit.Log, error) {
+	path, err := auditLogPath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &audit.Log{Path: path, User: currentUser(), Command: auditCommand(cmd)}, nil
+}
+
+// currentUser returns the name of the user of the system running the tool.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// secretFlags are the flags whose values are kept out of the audit log.
+var secretFlags = []string{"api-token", "caldav-password", "confluence-token", "github-token", "slack-webhook"}
+
+// auditCommand returns the command line of cmd as recorded in the audit log,
+// with the flags set and their values, secrets redacted.
+func auditCommand(cmd *cobra.Command) string {
+	parts := []string{cmd.CommandPath()}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if slices.Contains(secretFlags, f.Name) {
+			value = "REDACTED"
+		}
+		parts = append(parts, fmt.Sprintf("--%s=%s", f.Name, value))
+	})
+	return strings.Join(append(parts, cmd.Flags().Args()...), " ")
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"calendar/pkg/auth"
+	"calendar/pkg/config"
+	"calendar/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+// tokenNames are the files OAuth tokens are cached in, per API.
+var tokenNames = []string{"token.json", "gmail-token.json", "directory-token.json", "sheets-token.json", "outlook-token.json"}
+
+func newAuthCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage the accounts the tool is authorized with",
+		Long: `Manage the accounts the tool is authorized with, as profiles keeping their
+own credentials and tokens, e.g. to manage rotations in several Google
+Workspace domains.
+
+Every command uses the profile given with --profile, or the default one.`,
+		Example: `  # Authorize the work account, with the client secret of its domain
+  calendar auth login --profile work --credentials ~/Downloads/client_secret.json
+
+  # Create a rotation with it
+  calendar --profile work -c team-roles -n "SRE Role" -t Cesar,Seth -s 2024-07-01 -d 1`,
+	}
+	cmd.AddCommand(newAuthLoginCommand())
+	cmd.AddCommand(newAuthLogoutCommand())
+	cmd.AddCommand(newAuthListCommand())
+	return cmd
+}
+
+func newAuthLoginCommand() *cobra.Command {
+	var flow string
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Authorize the tool to access the calendars of a profile",
+		Long: `Authorize the tool to access the Google calendars of a profile, asking for a
+new token even when one is cached.
+
+The client secret given with --credentials is copied to the profile, so later
+runs with --profile don't need it.
+
+The web flow opens the authorization link in the default browser, unless
+--no-browser is set, and a local server on the machine running the tool, where
+the browser is sent back once authorized. On remote machines, e.g. over SSH, the
+device flow shows a code to enter on any other device instead. It needs an
+OAuth client of type "TVs and Limited Input devices".`,
+		Example: `  # Authorize from a machine without a browser
+  calendar auth login --flow device`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name, _ := cmd.Flags().GetString("provider"); name != provider.Google {
+				return fmt.Errorf("%s is only supported with the %s provider, others authorize on first use", cmd.CommandPath(), provider.Google)
+			}
+			if typ, _ := cmd.Flags().GetString("credentials-type"); typ != auth.TypeOAuth {
+				return fmt.Errorf("%s credentials need no login", typ)
+			}
+			if flow != auth.FlowWeb && flow != auth.FlowDevice {
+				return fmt.Errorf("unknown flow %q, must be one of: %s, %s", flow, auth.FlowWeb, auth.FlowDevice)
+			}
+			if err := importCredentials(cmd); err != nil {
+				return err
+			}
+			store, err := tokenStore(cmd, "token.json")
+			if err != nil {
+				return err
+			}
+			if err := store.Delete(); err != nil {
+				return err
+			}
+
+			client, err := newGoogleCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			calendars, err := client.Calendars(cmd.Context())
+			if err != nil {
+				return err
+			}
+			account := "unknown account"
+			for _, c := range calendars {
+				if c.Primary {
+					account = c.Id
+				}
+			}
+			fmt.Printf("Logged in as %s in profile %s, with access to %d calendars.\n", account, profileName(cmd), len(calendars))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&flow, "flow", auth.FlowWeb, "OAuth flow: web (through a browser on this machine) or device (entering a code on another device)")
+
+	return cmd
+}
+
+func newAuthLogoutCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Forget the tokens of a profile",
+		Long: `Forget the OAuth tokens cached for a profile, so the next command authorizes
+again. Its credentials are kept.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed := false
+			var stores []string
+			for _, name := range tokenNames {
+				store, err := tokenStore(cmd, name)
+				if err != nil {
+					return err
+				}
+				// --token makes every token share the same store.
+				if slices.Contains(stores, store.String()) {
+					continue
+				}
+				stores = append(stores, store.String())
+				if _, err := store.Load(); errors.Is(err, auth.ErrNoToken) {
+					continue
+				}
+				if err := store.Delete(); err != nil {
+					return err
+				}
+				slog.Debug("Token removed", "store", store.String())
+				removed = true
+			}
+			if !removed {
+				fmt.Printf("Profile %s has no tokens.\n", profileName(cmd))
+				return nil
+			}
+			fmt.Printf("Logged out of profile %s.\n", profileName(cmd))
+			return nil
+		},
+	}
+}
+
+func newAuthListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles and whether they are authorized",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profiles, err := config.Profiles()
+			if err != nil {
+				return err
+			}
+			kind, _ := cmd.Flags().GetString("token-store")
+			return printProfiles(os.Stdout, append([]string{config.DefaultProfile}, profiles...), profileName(cmd), kind)
+		},
+	}
+}
+
+// printProfiles renders the profiles along with their credentials and the
+// tokens in the given store, the current one marked with an asterisk.
+func printProfiles(w io.Writer, profiles []string, current, storeKind string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tPROFILE\tCREDENTIALS\tTOKENS")
+	for _, profile := range profiles {
+		dir, err := config.ProfileDir(profile)
+		if err != nil {
+			return err
+		}
+		credentials := "no"
+		if _, err := os.Stat(filepath.Join(dir, "credentials.json")); err == nil {
+			credentials = "yes"
+		}
+		var tokens []string
+		for _, name := range tokenNames {
+			store, err := auth.NewTokenStore(storeKind, filepath.Join(dir, name))
+			if err != nil {
+				return err
+			}
+			// Encrypted tokens are there even when they can't be read.
+			if _, err := store.Load(); !errors.Is(err, auth.ErrNoToken) {
+				tokens = append(tokens, name)
+			}
+		}
+		marker, cached := "", "-"
+		if profile == current {
+			marker = "*"
+		}
+		if len(tokens) > 0 {
+			cached = strings.Join(tokens, ", ")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", marker, profile, credentials, cached)
+	}
+	return tw.Flush()
+}
+
+// profileName returns the profile selected with --profile.
+func profileName(cmd *cobra.Command) string {
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		return profile
+	}
+	return config.DefaultProfile
+}
+
+// importCredentials copies the client secret given with --credentials to
+// the profile, checking the profile has one otherwise.
+func importCredentials(cmd *cobra.Command) error {
+	dst, err := profileFile(cmd, "credentials.json")
+	if err != nil {
+		return err
+	}
+	src, _ := cmd.Flags().GetString("credentials")
+	if src == "" {
+		if _, err := os.Stat(dst); err != nil {
+			return fmt.Errorf("profile %s has no credentials, pass the OAuth client secret file with --credentials", profileName(cmd))
+		}
+		return nil
+	}
+	if abs, _ := filepath.Abs(src); abs == dst {
+		return nil
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("unable to read credentials file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return fmt.Errorf("unable to create profile directory: %w", err)
+	}
+	if err := os.WriteFile(dst, b, 0o600); err != nil {
+		return fmt.Errorf("unable to save credentials: %w", err)
+	}
+	slog.Info("Credentials saved to profile", "profile", profileName(cmd), "path", dst)
+	return nil
+}
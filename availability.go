@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+// Ways to handle the conflicts found by --check-availability.
+const (
+	availabilityWarn   = "warn"
+	availabilityRotate = "rotate"
+)
+
+// availabilityWeeks is how far ahead out-of-office events are looked up.
+// They are rarely known further in advance.
+const availabilityWeeks = 26
+
+// checkAvailability looks up the out-of-office events of the members over
+// the upcoming slots of a rotation, in their primary Google calendars, and
+// returns the slots conflicting with them. When rotating, the out-of-office
+// periods are added to the rotation's unavailabilities so other members
+// cover those slots.
+func checkAvailability(cmd *cobra.Command, r *rotation.Rotation, mode string) ([]rotation.Conflict, error) {
+	if mode != availabilityWarn && mode != availabilityRotate {
+		return nil, fmt.Errorf("invalid --check-availability %q, must be one of: %s, %s", mode, availabilityWarn, availabilityRotate)
+	}
+	n, from, to, loc, err := upcomingSlots(*r)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+
+	client, err := newGoogleCalendarClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var unavailable []rotation.Unavailability
+	for _, member := range r.Members {
+		email, ok := r.Emails[member]
+		if !ok {
+			slog.Warn("Availability not checked, member has no email", "member", member)
+			continue
+		}
+		periods, err := client.OutOfOffice(cmd.Context(), email, from, to, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range periods {
+			p.Member = member
+			if p.From.Before(from) {
+				p.From = from
+			}
+			unavailable = append(unavailable, p)
+		}
+	}
+
+	conflicts, err := rotation.Conflicts(*r, unavailable, n)
+	if err != nil {
+		return nil, err
+	}
+	if mode == availabilityRotate {
+		r.Unavailable = append(r.Unavailable, unavailable...)
+	}
+	return conflicts, nil
+}
+
+// upcomingSlots returns the number of slots of a rotation starting before
+// the availability horizon, and the time range they cover from today in the
+// time zone of the rotation. It returns no slots when none are upcoming.
+func upcomingSlots(r rotation.Rotation) (n int, from, to time.Time, loc *time.Location, err error) {
+	timeZone := r.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	loc, err = time.LoadLocation(timeZone)
+	if err != nil {
+		return 0, from, to, nil, fmt.Errorf("invalid time zone: %w", err)
+	}
+
+	// Slots last a day at least, so previewing a slot per day is enough to
+	// reach the horizon.
+	horizon := time.Now()
+	if r.Start.After(horizon) {
+		horizon = r.Start
+	}
+	horizon = horizon.AddDate(0, 0, 7*availabilityWeeks)
+	slots, err := rotation.Preview(r, 7*availabilityWeeks)
+	if err != nil {
+		return 0, from, to, nil, err
+	}
+	for n < len(slots) && slots[n].Start.Before(horizon) {
+		n++
+	}
+	if n == 0 {
+		return 0, from, to, loc, nil
+	}
+	// Past slots are left as they were served.
+	from, to = slots[0].Start, slots[n-1].End
+	if today := rotation.InLocation(time.Now().In(loc), loc); today.After(from) {
+		from = today
+	}
+	if !from.Before(to) {
+		return 0, from, to, loc, nil
+	}
+	return n, from, to, loc, nil
+}
+
+// printConflicts renders the slots conflicting with out-of-office events.
+func printConflicts(w io.Writer, conflicts []rotation.Conflict) error {
+	if len(conflicts) == 0 {
+		_, err := fmt.Fprintln(w, "\nNo conflicts with out-of-office events.")
+		return err
+	}
+	fmt.Fprintln(w, "\nConflicts with out-of-office events:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MEMBER\tSLOT\tOUT OF OFFICE\tCOVERED BY")
+	for _, c := range conflicts {
+		coveredBy := c.CoveredBy
+		if coveredBy == "" {
+			coveredBy = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s..%s\t%s..%s\t%s\n", c.Slot.Member,
+			c.Slot.Start.Format(time.DateOnly), c.Slot.End.AddDate(0, 0, -1).Format(time.DateOnly),
+			c.Unavailable.From.Format(time.DateOnly), c.Unavailable.To.Format(time.DateOnly), coveredBy)
+	}
+	return tw.Flush()
+}
+
+// logConflicts warns about the slots conflicting with out-of-office events.
+func logConflicts(conflicts []rotation.Conflict, mode string) {
+	for _, c := range conflicts {
+		msg := "Member is out of office during their slot"
+		if mode == availabilityRotate {
+			msg = "Member is out of office during their slot, rotated to the next available member"
+		}
+		slog.Warn(msg, "member", c.Slot.Member, "start", c.Slot.Start.Format(time.DateOnly), "end", c.Slot.End.Format(time.DateOnly), "covered-by", c.CoveredBy)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"calendar/pkg/auth"
+	"calendar/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+// completionWeeks is how far ahead the rotations completing --event-name
+// are looked up, long enough to find every rotation still going on.
+const completionWeeks = 12
+
+// registerCompletions completes --calendar with the user's calendars and
+// --event-name with the rotations of the calendar, on every command having
+// them.
+func registerCompletions(root *cobra.Command) {
+	root.RegisterFlagCompletionFunc("calendar", completeCalendars)
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd.LocalNonPersistentFlags().Lookup("event-name") != nil {
+			cmd.RegisterFlagCompletionFunc("event-name", completeEventNames)
+		}
+		for _, c := range cmd.Commands() {
+			walk(c)
+		}
+	}
+	walk(root)
+}
+
+// completeCalendars lists the Google calendars of the user, described by
+// their ID.
+func completeCalendars(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !canComplete(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if name, _ := cmd.Flags().GetString("provider"); name != provider.Google {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	client, err := newGoogleCalendarClient(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	calendars, err := client.Calendars(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var completions []string
+	for _, c := range calendars {
+		if strings.HasPrefix(c.Summary, toComplete) {
+			completions = append(completions, c.Summary+"\t"+c.Id)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeEventNames lists the rotations with slots in the upcoming weeks in
+// the calendar selected with --calendar.
+func completeEventNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if !canComplete(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	cal, err := newCalendarProvider(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	now := time.Now()
+	slots, err := cal.Slots(cmd.Context(), now, now.AddDate(0, 0, 7*completionWeeks))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, s := range slots {
+		if strings.HasPrefix(s.Rotation, toComplete) && !slices.Contains(names, s.Rotation) {
+			names = append(names, s.Rotation)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// canComplete loads the config and returns whether the calendar can be read
+// without asking the user to authorize the tool, which is never done while
+// completing.
+func canComplete(cmd *cobra.Command) bool {
+	if err := loadConfig(cmd); err != nil {
+		return false
+	}
+	var tokenName string
+	switch name, _ := cmd.Flags().GetString("provider"); name {
+	case provider.Google:
+		if typ, _ := cmd.Flags().GetString("credentials-type"); typ != auth.TypeOAuth {
+			return true
+		}
+		tokenName = "token.json"
+	case provider.Outlook:
+		tokenName = "outlook-token.json"
+	default:
+		return true
+	}
+	store, err := tokenStore(cmd, tokenName)
+	if err != nil {
+		return false
+	}
+	_, err = store.Load()
+	return !errors.Is(err, auth.ErrNoToken)
+}
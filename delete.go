@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newDeleteCommand() *cobra.Command {
+	var eventName string
+	var yes bool
+	var managedOnly bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete all the events of a rotation",
+		Long: `Delete all the events of a rotation, found by their summary, including the
+events named like the rotation by people. With --managed-only, only the events
+created by the tool, as told by their extended properties, are deleted.`,
+		Example: `  calendar delete --event-name "SRE Role" --calendar team-roles
+
+  # Without asking, e.g. from a script.
+  calendar delete --event-name "SRE Role" --yes --output json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			cal, err := newCalendarProvider(cmd)
+			if err != nil {
+				return err
+			}
+
+			var events []provider.Event
+			if managedOnly {
+				events, err = cal.ManagedEvents(ctx, rotation.ID(eventName))
+			} else {
+				events, err = cal.RotationEvents(ctx, eventName)
+			}
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				slog.Info("No events found", "rotation", eventName)
+				return nil
+			}
+
+			// The JSON summary is the only thing written to stdout.
+			listing := cmd.OutOrStdout()
+			if output == "json" {
+				listing = os.Stderr
+			}
+			for _, event := range events {
+				fmt.Fprintf(listing, "%s\t%s\n", event.Start, event.Summary)
+			}
+			if !yes && !confirm(fmt.Sprintf("Delete %d events of rotation %q?", len(events), eventName)) {
+				slog.Info("Aborted, no events were deleted")
+				return nil
+			}
+
+			var changes []provider.Change
+			progress, finish := newProgress(cmd, "Deleting "+eventName)
+			for _, event := range events {
+				if err = cal.DeleteEvent(ctx, event); err != nil {
+					break
+				}
+				changes = append(changes, provider.Change{Action: "deleted", Summary: event.Summary})
+				if progress != nil {
+					progress(len(changes), len(events))
+				}
+			}
+			finish()
+			for _, c := range changes {
+				slog.Info("Event deleted", "summary", c.Summary)
+			}
+			var sum summary
+			sum.add(eventName, "", changes, err)
+			sum.print(cmd.OutOrStdout(), output)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation to delete, e.g. SRE Role")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	cmd.Flags().BoolVar(&managedOnly, "managed-only", false, "Only delete the events created by the tool, leaving the ones created by people alone")
+	addSummaryFlag(cmd, &output)
+	cmd.MarkFlagRequired("event-name")
+
+	return cmd
+}
+
+// confirm asks the user a yes/no question on stdin and returns the answer.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
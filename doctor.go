@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"calendar/pkg/auth"
+	"calendar/pkg/gcal"
+	"calendar/pkg/llm"
+	"calendar/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+// maxClockSkew is the clock difference with Google from which OAuth tokens
+// may be rejected as not yet valid or expired.
+const maxClockSkew = time.Minute
+
+// googleAPIs is requested to check the network and the clock, any answer
+// will do.
+const googleAPIs = "https://www.googleapis.com/"
+
+func newDoctorCommand() *cobra.Command {
+	var llmBackend, llmURL string
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment the tool runs in",
+		Long: `Check the environment the tool runs in, printing whether every check passed
+or failed, with a hint to fix the failed ones:
+
+  - network access to googleapis.com
+  - clock skew with Google, which invalidates tokens
+  - credentials file readable and well-formed
+  - token valid, refreshing it if needed
+  - calendar accessible with write permission
+  - LLM backend used with --prompt reachable
+
+Checks depending on a failed one are skipped. Only the LLM backend is
+checked with providers other than google.`,
+		Example: `  calendar doctor --calendar team-roles --llm-backend openai`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			w := cmd.OutOrStdout()
+			failed := 0
+			report := func(name, detail string, err error, hint string) bool {
+				if err != nil {
+					failed++
+					fmt.Fprintf(w, "FAIL  %s: %v\n      %s\n", name, err, hint)
+					return false
+				}
+				fmt.Fprintf(w, "PASS  %s: %s\n", name, detail)
+				return true
+			}
+			skip := func(name, reason string) {
+				fmt.Fprintf(w, "SKIP  %s: %s\n", name, reason)
+			}
+
+			if name, _ := cmd.Flags().GetString("provider"); name != provider.Google {
+				skip("Google Calendar", "not used with the "+name+" provider")
+			} else {
+				checkGoogle(cmd, report, skip)
+			}
+
+			err := llm.Reachable(ctx, llmBackend, llmURL)
+			report("LLM backend", llmBackend+" is reachable", err, "Start the LLM backend, e.g. ollama serve, or pick another one with --llm-backend and --llm-url. It is only needed with --prompt.")
+
+			if failed > 0 {
+				return fmt.Errorf("%d checks failed", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&llmBackend, "llm-backend", llm.BackendOllama, "LLM backend used with --prompt: ollama, openai or anthropic")
+	cmd.Flags().StringVar(&llmURL, "llm-url", "", "Base URL of the LLM API, e.g. an OpenAI compatible endpoint (default depends on the backend)")
+
+	return cmd
+}
+
+// checkGoogle runs the checks of the access to Google Calendar, each
+// depending on the previous one.
+func checkGoogle(cmd *cobra.Command, report func(name, detail string, err error, hint string) bool, skip func(name, reason string)) {
+	ctx := cmd.Context()
+
+	skew, err := googleClockSkew(ctx)
+	if !report("Network", "googleapis.com is reachable", err, "Check the network, proxy (HTTPS_PROXY) and firewall allow HTTPS requests to googleapis.com.") {
+		skip("Clock", "googleapis.com is unreachable")
+	} else {
+		if skew.Abs() > maxClockSkew {
+			err = fmt.Errorf("clock is %s off Google's", skew.Round(time.Second))
+		}
+		report("Clock", fmt.Sprintf("%s off Google's", skew.Round(time.Second)), err, "Synchronize the clock, e.g. enable NTP, tokens are rejected otherwise.")
+	}
+
+	opts, err := authOptions(cmd, "token.json")
+	if err == nil {
+		err = auth.CheckCredentials(opts)
+	}
+	if !report("Credentials", fmt.Sprintf("%s (%s)", opts.CredentialsFile, opts.Type), err, "Download the OAuth client secret or service account key from the Google Cloud console and pass it with --credentials, or check --credentials-type.") {
+		skip("Token", "the credentials are invalid")
+		skip("Calendar", "the credentials are invalid")
+		return
+	}
+
+	err = auth.CheckToken(ctx, opts, gcal.Scope)
+	if !report("Token", "valid", err, "Run calendar auth login to authorize the tool again.") {
+		skip("Calendar", "there is no valid token")
+		return
+	}
+
+	calendarName, err := singleCalendar(cmd)
+	if err != nil {
+		report("Calendar", "", err, "Pass the calendar to check with --calendar.")
+		return
+	}
+	role, err := calendarAccess(cmd, calendarName)
+	if err == nil && role != "owner" && role != "writer" {
+		err = fmt.Errorf("calendar %s is only shared with %s access", calendarName, role)
+	}
+	report("Calendar", calendarName+" is writable", err, `Ask the owner of the calendar to share it with "Make changes to events", or pick another one with --calendar.`)
+}
+
+// calendarAccess returns the access role of the user on the named
+// calendar, e.g. owner, writer or reader.
+func calendarAccess(cmd *cobra.Command, calendarName string) (string, error) {
+	client, err := newGoogleCalendarClient(cmd)
+	if err != nil {
+		return "", err
+	}
+	calendars, err := client.Calendars(cmd.Context())
+	if err != nil {
+		return "", err
+	}
+	for _, c := range calendars {
+		if c.Id == calendarName || c.Summary == calendarName || (calendarName == "primary" && c.Primary) {
+			return c.AccessRole, nil
+		}
+	}
+	return "", fmt.Errorf("calendar %s not found in the calendar list", calendarName)
+}
+
+// googleClockSkew returns how far the local clock is off the Date of a
+// response of googleapis.com, positive when ahead.
+func googleClockSkew(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, googleAPIs, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("googleapis.com answered without a valid Date: %w", err)
+	}
+	// The Date is truncated to the second, somewhere during the request.
+	now := start.Add(time.Since(start) / 2)
+	return now.Sub(date), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCommand() *cobra.Command {
+	var eventName string
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the events of a rotation to a file",
+		Long: `Export the events of a rotation to a JSON file, along with their IDs in the
+calendar, so the rotation can be recreated with the import command in another
+calendar or account. Slots modified in the calendar, e.g. swapped, are
+exported as they are.`,
+		Example: `  calendar export --event-name "SRE Role" -o sre-role.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			rotationID := rotation.ID(eventName)
+			events, err := client.Export(ctx, calendarID, rotationID)
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				return fmt.Errorf("rotation %q not found in calendar %s", eventName, calendarID)
+			}
+			x := rotation.Export{
+				Rotation:   eventName,
+				RotationID: rotationID,
+				Calendar:   calendarID,
+				ExportedAt: time.Now().UTC(),
+				Events:     events,
+			}
+
+			var w io.Writer = os.Stdout
+			if out != "-" {
+				f, err := os.Create(out)
+				if err != nil {
+					return fmt.Errorf("unable to create export file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(x); err != nil {
+				return fmt.Errorf("unable to write export file: %w", err)
+			}
+			slog.Info("Rotation exported", "rotation", eventName, "events", len(events))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation to export, e.g. SRE Role")
+	cmd.Flags().StringVarP(&out, "out", "o", "-", "File to write the export to, - for stdout")
+	cmd.MarkFlagRequired("event-name")
+
+	return cmd
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"calendar/pkg/rotation"
+	"calendar/pkg/sheets"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportSheetCommand() *cobra.Command {
+	var eventName string
+	var spreadsheetID, rng string
+	var months int
+
+	cmd := &cobra.Command{
+		Use:   "export-sheet",
+		Short: "Write the upcoming schedule of a rotation to a Google Sheet",
+		Long: `Write the upcoming schedule of a rotation to a Google Sheet, as the table of
+who is on rotation when rendered by the report command, with a header row.
+
+The table is written from the top left cell of --range, the columns below it
+being cleared first. The first run asks to authorize access to spreadsheets,
+with the same credentials, the token being stored in sheets-token.json.`,
+		Example: `  calendar export-sheet --event-name "SRE Role" --spreadsheet-id 1BxiMVs0XRA5nFMdKvBdBZjgmUUqptlbs74OgvE2upms --range Schedule!A1`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if months < 1 {
+				return fmt.Errorf("--months must be at least 1")
+			}
+
+			ctx := cmd.Context()
+			cal, err := newCalendarProvider(cmd)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			slots, err := cal.Slots(ctx, now, now.AddDate(0, months, 0))
+			if err != nil {
+				return err
+			}
+			var schedule []rotation.Slot
+			for _, s := range slots {
+				if s.Rotation == eventName {
+					schedule = append(schedule, s)
+				}
+			}
+			if len(schedule) == 0 {
+				return fmt.Errorf("rotation %q has no slots in the next %d months", eventName, months)
+			}
+			rows := [][]string{{"Member", "Start", "End"}}
+			for _, r := range reportRows(schedule) {
+				rows = append(rows, []string{r.Member, r.Start, r.End})
+			}
+
+			httpClient, err := newGoogleHTTPClient(cmd, "sheets-token.json", sheets.Scope)
+			if err != nil {
+				return err
+			}
+			client, err := sheets.New(ctx, httpClient)
+			if err != nil {
+				return err
+			}
+			if err := client.WriteTable(ctx, spreadsheetID, rng, rows); err != nil {
+				return err
+			}
+			slog.Info("Schedule exported", "rotation", eventName, "slots", len(schedule), "spreadsheet", spreadsheetID, "range", rng)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&spreadsheetID, "spreadsheet-id", "", "ID of the spreadsheet, as in its URL after /spreadsheets/d/")
+	cmd.Flags().StringVar(&rng, "range", "A1", "Range in A1 notation the table is written from, e.g. Schedule!A1")
+	cmd.Flags().IntVar(&months, "months", 3, "Number of months ahead to export")
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("spreadsheet-id")
+
+	return cmd
+}
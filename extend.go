@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newExtendCommand() *cobra.Command {
+	var eventName string
+	var until string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "extend",
+		Short: "Make a rotation created with --until or --count last longer",
+		Long: `Make a rotation created with --until or --count last longer.
+
+The recurring events of the rotation repeat until --until instead, the last
+day a slot can start on, so the rotation continues from where it ended with
+the same members, order and colors. Coverage events are left untouched, and
+unavailabilities after the previous end are not taken into account, use the
+update command to change the rotation instead.`,
+		Example: `  # Renew the SRE Role for another quarter.
+  calendar extend --event-name "SRE Role" --until 2026-01-01`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			untilParsed, err := time.Parse(time.DateOnly, until)
+			if err != nil {
+				return fmt.Errorf("unable to parse until %q, must be formatted as YYYY-MM-DD", until)
+			}
+
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			existing, err := client.ManagedEvents(ctx, calendarID, rotation.ID(eventName))
+			if err != nil {
+				return err
+			}
+			if len(existing) == 0 {
+				return fmt.Errorf("rotation %q not found in the calendar", eventName)
+			}
+			if !gcal.Finite(existing) {
+				return fmt.Errorf("rotation %q repeats forever, there is nothing to extend", eventName)
+			}
+
+			changes, err := client.Extend(ctx, calendarID, existing, untilParsed)
+			for _, c := range changes {
+				slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+			}
+			var sum summary
+			sum.add(eventName, "", changes, err)
+			sum.print(cmd.OutOrStdout(), output)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&until, "until", "", "New last day a slot can start on, e.g. 2026-01-01")
+	addSummaryFlag(cmd, &output)
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("until")
+
+	return cmd
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newFairnessCommand() *cobra.Command {
+	var rf rotationFlags
+	var since, through, from string
+	var rebalance bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "fairness",
+		Short: "Show whether members served their fair share of a rotation",
+		Long: `Show whether members served their fair share of a rotation over a period,
+overrides and swaps included, comparing the days each member served with the
+days they would have served had the period been shared by --weights.
+
+A positive balance is a member who covered extra days, a negative one a member
+who was covered for. With --rebalance, the upcoming turns are reordered from
+--from on so the members with the lowest balance serve first, the slot being
+served then being kept, like the members command does. This is a one-cycle
+reorder: every member still serves once per cycle, so the members who covered
+extra days get a longer break before their next turn, not fewer turns. The rotation is defined
+by the rotation flags, as when it was created. With -o json, the shares, the
+new order and the summary of the changes are written as a single object.`,
+		Example: `  # Who covered the most SRE Role days this year?
+  calendar fairness --event-name "SRE Role" --since 2024-01-01
+
+  # Give the members who covered extra slots a break
+  calendar fairness --event-name "SRE Role" --duration 1 --since 2024-01-01 --rebalance`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %q, must be one of: table, json", output)
+			}
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			// Dates are days of the calendar's time zone, like the events.
+			tz, err := client.TimeZone(ctx, calendarID)
+			if err != nil {
+				return err
+			}
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return fmt.Errorf("calendar has an unknown time zone: %w", err)
+			}
+			start, err := time.ParseInLocation(time.DateOnly, since, loc)
+			if err != nil {
+				return fmt.Errorf("unable to parse --since: %w", err)
+			}
+			today := rotation.InLocation(time.Now().In(loc), loc)
+			end := today
+			if through != "" {
+				end, err = time.ParseInLocation(time.DateOnly, through, loc)
+				if err != nil {
+					return fmt.Errorf("unable to parse --through: %w", err)
+				}
+			}
+			// Both days are included.
+			end = end.AddDate(0, 0, 1)
+			if !end.After(start) {
+				return fmt.Errorf("--through must not be before --since")
+			}
+
+			existing, err := client.ManagedEvents(ctx, calendarID, rotation.ID(rf.eventName))
+			if err != nil {
+				return err
+			}
+			if len(existing) == 0 {
+				return fmt.Errorf("rotation %q not found in the calendar", rf.eventName)
+			}
+			members := turnOrder(existing)
+			slots, err := client.Slots(ctx, calendarID, start, end)
+			if err != nil {
+				return err
+			}
+			shares := rotation.Fairness(slots, rf.eventName, members, rf.weights, start, end)
+			if !rebalance || output != "json" {
+				if err := printFairness(cmd.OutOrStdout(), shares, output); err != nil {
+					return err
+				}
+			}
+			if !rebalance {
+				return nil
+			}
+
+			day := today
+			if from != "" {
+				if day, err = time.ParseInLocation(time.DateOnly, from, loc); err != nil {
+					return fmt.Errorf("unable to parse --from %q, must be formatted as YYYY-MM-DD", from)
+				}
+			}
+			order := rotation.Rebalanced(shares, members)
+			if output == "json" {
+				// The shares and the changes are written as a single
+				// document, so it can be parsed.
+				out := rebalanceOutput{Shares: shares, Order: order}
+				var sum bytes.Buffer
+				if !slices.Equal(order, members) {
+					err = reflowRotation(cmd, &rf, client, calendarID, existing, order, day, func(string) string {
+						return order[0]
+					}, &sum, output)
+					out.Summary = sum.Bytes()
+				}
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.Join(err, enc.Encode(out))
+			}
+			if slices.Equal(order, members) {
+				fmt.Fprintf(cmd.OutOrStdout(), "Turns of %s are already balanced\n", rf.eventName)
+				return nil
+			}
+			return reflowRotation(cmd, &rf, client, calendarID, existing, order, day, func(string) string {
+				return order[0]
+			}, cmd.OutOrStdout(), output)
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&since, "since", "", "First day to count, e.g. 2024-01-01")
+	cmd.Flags().StringVar(&through, "through", "", "Last day to count, e.g. 2024-03-31 (default is today)")
+	cmd.Flags().BoolVar(&rebalance, "rebalance", false, "Reorder the upcoming turns so the members who served the least serve first, once, without changing how often anyone serves")
+	cmd.Flags().StringVar(&from, "from", "", "First day of the reordered turns with --rebalance, the slot being served then being kept (default is today)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("since")
+	// The members and the start of the rebalanced rotation come from the
+	// calendar.
+	cmd.Flags().MarkHidden("start-date")
+	cmd.Flags().MarkHidden("team-members")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
+
+// rebalanceOutput is the JSON written by fairness --rebalance: the shares of
+// the members, the order they take turns in from then on, and the summary of
+// the changes made, missing when the turns were already balanced.
+type rebalanceOutput struct {
+	Shares  []rotation.Share `json:"shares"`
+	Order   []string         `json:"order"`
+	Summary json.RawMessage  `json:"summary,omitempty"`
+}
+
+// printFairness renders the days served by every member compared with their
+// share as a table or JSON.
+func printFairness(w io.Writer, shares []rotation.Share, output string) error {
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(shares)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MEMBER\tSLOTS\tDAYS\tEXPECTED\tBALANCE")
+	for _, s := range shares {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%+.1f\n", s.Member, s.Slots, s.Days, s.Expected, s.Balance)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log/slog"
+
+	"calendar/pkg/github"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+// githubRoster returns the roster of the members of the GitHub team given
+// with --github-team, named by their logins and with the emails mapped in
+// --github-emails. Members also listed in the roster file keep the details
+// set there, so the team only decides who is in the rotation.
+func githubRoster(cmd *cobra.Command, team string, file *rotation.Roster) (*rotation.Roster, error) {
+	org, slug, err := github.ParseTeam(team)
+	if err != nil {
+		return nil, err
+	}
+	token, _ := cmd.Flags().GetString("github-token")
+	client := &github.Client{Token: token}
+	logins, err := client.TeamMembers(cmd.Context(), org, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make(map[string]string)
+	if path, _ := cmd.Flags().GetString("github-emails"); path != "" {
+		if emails, err = github.LoadEmails(path); err != nil {
+			return nil, err
+		}
+	}
+	members := make([]rotation.Member, 0, len(logins))
+	for _, login := range logins {
+		members = append(members, rotation.Member{Name: login, Email: emails[login]})
+	}
+	roster := mergeRoster(members, file)
+	for _, m := range roster.Members {
+		if m.Email == "" {
+			slog.Warn("GitHub team member has no email, they won't be invited to their events", "team", team, "login", m.Name)
+		}
+	}
+	slog.Debug("Roster read from GitHub team", "team", team, "members", logins)
+	return roster, nil
+}
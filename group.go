@@ -0,0 +1,31 @@
+package main
+
+import (
+	"calendar/pkg/directory"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+// groupRoster returns the roster of the members of the Google Workspace
+// group given with --group, named as in the directory and invited by their
+// email. Members also listed in the roster file keep the details set there.
+func groupRoster(cmd *cobra.Command, group string, file *rotation.Roster) (*rotation.Roster, error) {
+	httpClient, err := newGoogleHTTPClient(cmd, "directory-token.json", directory.Scopes...)
+	if err != nil {
+		return nil, err
+	}
+	client, err := directory.New(cmd.Context(), httpClient)
+	if err != nil {
+		return nil, err
+	}
+	people, err := client.GroupMembers(cmd.Context(), group)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]rotation.Member, 0, len(people))
+	for _, p := range people {
+		members = append(members, rotation.Member{Name: p.Name, Email: p.Email})
+	}
+	return mergeRoster(members, file), nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+
+	"calendar/pkg/api"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// serveGRPC serves the gRPC API of the rotations of the calendar on address
+// until the returned function is called.
+func (s *server) serveGRPC(address string) (func(), error) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen for gRPC requests: %w", err)
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(logRequest))
+	api.RegisterRotationServiceServer(srv, &rotationService{server: s})
+	// Reflection lets tools like grpcurl call the API without the proto
+	// file.
+	reflection.Register(srv)
+	go func() {
+		slog.Info("Listening for gRPC requests", "address", lis.Addr().String())
+		if err := srv.Serve(lis); err != nil {
+			slog.Error("Unable to serve gRPC requests", "error", err)
+		}
+	}()
+	return srv.GracefulStop, nil
+}
+
+// logRequest logs every gRPC request along with its outcome.
+func logRequest(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	if err != nil {
+		slog.Error("gRPC request failed", "method", info.FullMethod, "duration", time.Since(start), "error", err)
+		return nil, grpcError(err)
+	}
+	slog.Info("gRPC request served", "method", info.FullMethod, "duration", time.Since(start))
+	return resp, nil
+}
+
+// grpcError returns err with the gRPC code matching it, Unavailable when the
+// calendar can't be reached.
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if unreachable(err) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// rotationService implements the gRPC API with the calendar of the server.
+type rotationService struct {
+	api.UnimplementedRotationServiceServer
+	server *server
+}
+
+func (rs *rotationService) CreateRotation(ctx context.Context, req *api.CreateRotationRequest) (*api.CreateRotationResponse, error) {
+	s := rs.server
+	// The request is read as the flags of the same names, so rotations get
+	// the same defaults and checks as on the command line.
+	var rf rotationFlags
+	rf.addFlags(pflag.NewFlagSet("rotation", pflag.ContinueOnError))
+	rf.eventName = req.Name
+	rf.teamMembers = req.Members
+	rf.startDate = req.StartDate
+	rf.duration = int(req.DurationWeeks)
+	rf.cadence = req.Cadence
+	rf.until = req.Until
+	rf.count = int(req.Count)
+	rf.timezone = req.TimeZone
+	rf.emails = req.Emails
+	rf.handoffDay = req.HandoffDay
+	rf.handoffTime = req.HandoffTime
+	if req.Order != "" {
+		rf.order = req.Order
+	}
+	roster, err := s.roster()
+	if err != nil {
+		return nil, err
+	}
+	r, err := rf.rotation(roster)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.unlock(ctx)
+	existing, err := s.client.ManagedEvents(ctx, s.calendarID, rotation.ID(r.Name))
+	if err != nil {
+		return nil, err
+	}
+	// Creating twice must not duplicate the rotation, as when retrying.
+	if len(existing) > 0 && !req.Force {
+		return nil, status.Errorf(codes.AlreadyExists, "rotation %q already exists with %d events, set force to update them in place", r.Name, len(existing))
+	}
+	if r.TimeZone == "" {
+		if r.TimeZone, err = s.client.TimeZone(ctx, s.calendarID); err != nil {
+			return nil, err
+		}
+	}
+	// The start date is a day of the rotation's time zone, which may
+	// already be tomorrow or still be yesterday in UTC.
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown time zone %q: %v", r.TimeZone, err)
+	}
+	today := rotation.InLocation(time.Now().In(loc), loc)
+	if len(existing) == 0 && !req.Backfill && !r.Start.IsZero() && rotation.InLocation(r.Start, loc).Before(today) {
+		return nil, status.Errorf(codes.InvalidArgument, "start date %s is in the past, set backfill to create the slots already served too", r.Start.Format(time.DateOnly))
+	}
+	events, err := rotation.Plan(r)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	changes, err := s.client.Sync(ctx, s.calendarID, existing, events)
+	for _, c := range changes {
+		slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &api.CreateRotationResponse{Id: rotation.ID(r.Name), Changes: int32(len(changes))}, nil
+}
+
+func (rs *rotationService) GetCurrentOnCall(ctx context.Context, req *api.GetCurrentOnCallRequest) (*api.GetCurrentOnCallResponse, error) {
+	if req.Rotation == "" {
+		return nil, status.Error(codes.InvalidArgument, "rotation is required")
+	}
+	at := time.Now()
+	if req.At != nil {
+		at = req.At.AsTime()
+	}
+	slot, err := rs.server.client.SlotAt(ctx, rs.server.calendarID, req.Rotation, at)
+	if err != nil {
+		return nil, err
+	}
+	if slot == nil {
+		return nil, status.Errorf(codes.NotFound, "nobody is on rotation %q at %s", req.Rotation, at.Format(time.DateTime))
+	}
+	return &api.GetCurrentOnCallResponse{Slot: slotMessage(slot)}, nil
+}
+
+func (rs *rotationService) Swap(ctx context.Context, req *api.SwapRequest) (*api.SwapResponse, error) {
+	s := rs.server
+	if req.Rotation == "" || req.From == "" || req.To == "" {
+		return nil, status.Error(codes.InvalidArgument, "rotation, from and to are required")
+	}
+	date, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "unable to parse date: %v", err)
+	}
+	roster, err := s.roster()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMembers(roster, req.From, req.To); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.unlock(ctx)
+	slots, err := s.client.Swap(ctx, s.calendarID, req.Rotation, req.From, req.To, date)
+	if err != nil {
+		return nil, err
+	}
+	resp := &api.SwapResponse{}
+	for _, slot := range slots {
+		slog.Info("Slot swapped", "rotation", req.Rotation, "member", slot.Member, "start", slot.Start.Format(time.DateOnly), "end", slot.End.Format(time.DateOnly))
+		resp.Slots = append(resp.Slots, slotMessage(&slot))
+	}
+	return resp, nil
+}
+
+func (rs *rotationService) ListRotations(ctx context.Context, req *api.ListRotationsRequest) (*api.ListRotationsResponse, error) {
+	weeks := int(req.Weeks)
+	if weeks <= 0 {
+		weeks = 12
+	}
+	now := time.Now()
+	slots, err := rs.server.client.Slots(ctx, rs.server.calendarID, now, now.AddDate(0, 0, weeks*7))
+	if err != nil {
+		return nil, err
+	}
+	if req.ManagedOnly {
+		slots = slices.DeleteFunc(slots, func(s rotation.Slot) bool { return !s.Managed })
+	}
+	resp := &api.ListRotationsResponse{}
+	for _, st := range rotation.Statuses(slots, now) {
+		r := &api.Rotation{Name: st.Name, Current: slotMessage(st.Current)}
+		for _, slot := range st.Upcoming {
+			r.Upcoming = append(r.Upcoming, slotMessage(&slot))
+		}
+		resp.Rotations = append(resp.Rotations, r)
+	}
+	return resp, nil
+}
+
+// slotMessage returns the API message of the slot, nil when there is none.
+func slotMessage(s *rotation.Slot) *api.Slot {
+	if s == nil {
+		return nil
+	}
+	return &api.Slot{
+		Rotation: s.Rotation,
+		Member:   s.Member,
+		Start:    timestamppb.New(s.Start),
+		End:      timestamppb.New(s.End),
+		Link:     s.Link,
+		Managed:  s.Managed,
+	}
+}
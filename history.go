@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newHistoryCommand() *cobra.Command {
+	var eventName string
+	var since, until string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show how many slots and days each member served",
+		Long: `Show how many slots and days each member served in a rotation over a
+period, to audit how fairly it is shared.`,
+		Example: `  # Who served the SRE Role last quarter, as CSV?
+  calendar history --event-name "SRE Role" --since 2024-04-01 --until 2024-06-30 --output csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" && output != "csv" {
+				return fmt.Errorf("unknown output format %q, must be one of: table, json, csv", output)
+			}
+
+			ctx := cmd.Context()
+			cal, err := newReadProvider(cmd)
+			if err != nil {
+				return err
+			}
+			// Dates are days of the calendar's time zone, like the events.
+			tz, err := cal.TimeZone(ctx)
+			if err != nil {
+				return err
+			}
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return fmt.Errorf("calendar has an unknown time zone: %w", err)
+			}
+			from, err := time.ParseInLocation(time.DateOnly, since, loc)
+			if err != nil {
+				return fmt.Errorf("unable to parse --since: %w", err)
+			}
+			to := rotation.InLocation(time.Now().In(loc), loc)
+			if until != "" {
+				to, err = time.ParseInLocation(time.DateOnly, until, loc)
+				if err != nil {
+					return fmt.Errorf("unable to parse --until: %w", err)
+				}
+			}
+			// Both days are included.
+			to = to.AddDate(0, 0, 1)
+			if !to.After(from) {
+				return fmt.Errorf("--until must not be before --since")
+			}
+
+			slots, err := cal.Slots(ctx, from, to)
+			if err != nil {
+				return err
+			}
+			return printHistory(os.Stdout, rotation.History(slots, eventName, from, to), output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&since, "since", "", "First day to count, e.g. 2024-01-01")
+	cmd.Flags().StringVar(&until, "until", "", "Last day to count, e.g. 2024-03-31 (default is today)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table, json or csv")
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("since")
+
+	return cmd
+}
+
+// printHistory renders the slots and days served by every member as a
+// table, JSON or CSV.
+func printHistory(w io.Writer, history []rotation.Served, output string) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(history)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"member", "slots", "days"})
+		for _, s := range history {
+			cw.Write([]string{s.Member, strconv.Itoa(s.Slots), strconv.Itoa(s.Days)})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MEMBER\tSLOTS\tDAYS")
+		for _, s := range history {
+			fmt.Fprintf(tw, "%s\t%d\t%d\n", s.Member, s.Slots, s.Days)
+		}
+		return tw.Flush()
+	}
+}
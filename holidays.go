@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/rotation"
+)
+
+// holidayYears is how many years of holidays are looked up from the start
+// of rotations.
+const holidayYears = 2
+
+// addHolidays adds the holidays listed in a Google calendar, given by
+// summary or ID, to the ones of the rotation.
+func addHolidays(ctx context.Context, client *gcal.Client, r *rotation.Rotation, calendarName string) error {
+	// Public holiday calendars can be read by ID without subscribing to
+	// them, so only summaries are looked up.
+	id := calendarName
+	if !strings.Contains(calendarName, "@") {
+		var err error
+		if id, err = client.CalendarID(ctx, calendarName); err != nil {
+			return err
+		}
+	}
+	from, to := r.Start, r.Start.AddDate(holidayYears, 0, 0)
+	// The last slots start up to Until and may last a while after it.
+	if until := r.Until.AddDate(0, 1, 0); until.After(to) {
+		to = until
+	}
+	days, err := client.Holidays(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+	slog.Debug("Holidays found", "calendar", calendarName, "holidays", len(days))
+	r.Holidays = append(r.Holidays, days...)
+	return nil
+}
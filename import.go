@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newImportCommand() *cobra.Command {
+	var force bool
+	var atomic bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Recreate a rotation from a file written by export",
+		Long: `Recreate a rotation from a file written by the export command, in the
+calendar selected with --calendar, e.g. after moving the team to another
+calendar or account. The events are created as they were exported, with
+new IDs.`,
+		Example: `  calendar import sre-role.json --calendar "Team Calendar"`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			x, err := rotation.LoadExport(args[0])
+			if err != nil {
+				return err
+			}
+			events, err := x.Plan()
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			calendarName, err := singleCalendar(cmd)
+			if err != nil {
+				return err
+			}
+			cal, err := newCalendarProviderFor(cmd, calendarName)
+			if err != nil {
+				return err
+			}
+			existing, err := cal.ManagedEvents(ctx, x.RotationID)
+			if err != nil {
+				return err
+			}
+			if len(existing) > 0 && !force {
+				return fmt.Errorf("rotation %q already exists with %d events in calendar %s, use --force to replace them", x.Rotation, len(existing), calendarName)
+			}
+
+			changes, err := syncRotation(cmd, cal, calendarName, x.Rotation, existing, events, atomic)
+			if err == nil {
+				slog.Info("Rotation imported", "rotation", x.Rotation, "from", x.Calendar, "changes", len(changes))
+			}
+			var sum summary
+			sum.add(x.Rotation, calendarName, changes, err)
+			sum.print(cmd.OutOrStdout(), output)
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Replace the events of the rotation if it already exists")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "Delete the events created so far when the import fails")
+	addSummaryFlag(cmd, &output)
+
+	return cmd
+}
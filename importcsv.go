@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newImportCSVCommand() *cobra.Command {
+	var eventName string
+	var timezone string
+	var emails, colors map[string]string
+	var reminders string
+	var inviteTeam bool
+	var visibility, transparency, description, descriptionFile string
+	var runbooks []string
+	var guestsCanModify bool
+	var force bool
+	var atomic bool
+	var dryRun bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "import-csv FILE",
+		Short: "Create the events of a schedule computed elsewhere, from a CSV file",
+		Long: `Create the events of a schedule computed elsewhere, e.g. in a spreadsheet,
+from a CSV file with a row per slot: member,start,end and optionally role.
+
+Slots lasting whole days are written as their first and last day, and slots
+handing off at a time of day as RFC 3339 times, the end excluded. Every slot
+becomes a single event of the rotation named with --event-name, so it can be
+listed, swapped or deleted like the rotations created by the tool. Slots of a
+role are named after the role, e.g. SRE (primary).
+
+The members get the details given with the flags or found in the roster, e.g.
+their emails to be invited.`,
+		Example: `  # schedule.csv
+  member,start,end,role
+  Cesar,2024-07-01,2024-07-07,primary
+  Seth,2024-07-01,2024-07-07,secondary
+  Seth,2024-07-08,2024-07-14,primary
+  Juan,2024-07-08,2024-07-14,secondary
+
+  calendar import-csv schedule.csv -n SRE --roster roster.yaml`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+
+			var cal provider.CalendarProvider
+			var calendarName string
+			if !dryRun {
+				var err error
+				calendarName, err = singleCalendar(cmd)
+				if err != nil {
+					return err
+				}
+				cal, err = newCalendarProviderFor(cmd, calendarName)
+				if err != nil {
+					return err
+				}
+				// Days of the schedule are days of the calendar, unless
+				// told otherwise.
+				if timezone == "" {
+					timezone, err = cal.TimeZone(ctx)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			loc, err := time.LoadLocation(timezone)
+			if err != nil {
+				return fmt.Errorf("invalid time zone: %w", err)
+			}
+			slots, err := rotation.LoadCSV(args[0], loc)
+			if err != nil {
+				return err
+			}
+
+			r := rotation.Rotation{
+				Name:            eventName,
+				TimeZone:        timezone,
+				Emails:          emails,
+				Colors:          colors,
+				InviteTeam:      inviteTeam,
+				Visibility:      visibility,
+				GuestsCanModify: guestsCanModify,
+				Transparency:    transparency,
+				Description:     description,
+				Runbooks:        runbooks,
+			}
+			switch {
+			case descriptionFile != "" && description != "":
+				return errors.New("--description and --description-template can't be used together")
+			case descriptionFile != "":
+				if r.Description, err = rotation.LoadDescription(descriptionFile); err != nil {
+					return err
+				}
+			}
+			if reminders != "" {
+				if r.Reminders, err = rotation.ParseReminders(reminders); err != nil {
+					return err
+				}
+			}
+			for _, s := range slots {
+				if !slices.Contains(r.Members, s.Member) {
+					r.Members = append(r.Members, s.Member)
+				}
+			}
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			if roster != nil {
+				if err := roster.Apply(&r); err != nil {
+					return err
+				}
+			}
+			events, err := rotation.PlanCSV(r, slots)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				return printPlan(os.Stdout, events, output)
+			}
+
+			existing, err := cal.ManagedEvents(ctx, rotation.ID(r.Name))
+			if err != nil {
+				return err
+			}
+			if len(existing) > 0 && !force {
+				return fmt.Errorf("rotation %q already exists with %d events in calendar %s, use --force to replace them", r.Name, len(existing), calendarName)
+			}
+			changes, err := syncRotation(cmd, cal, calendarName, r.Name, existing, events, atomic)
+			if err == nil {
+				slog.Info("Schedule imported", "rotation", r.Name, "slots", len(slots), "changes", len(changes))
+			}
+			var sum summary
+			sum.add(r.Name, calendarName, changes, err)
+			sum.print(cmd.OutOrStdout(), output)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE")
+	cmd.Flags().StringVar(&timezone, "timezone", "", "Time zone of the days of the schedule and of the events, e.g. Europe/Madrid (default is the time zone of the calendar, or UTC with --dry-run)")
+	cmd.Flags().StringToStringVar(&emails, "emails", nil, "Emails of the members to invite to their events, e.g. Cesar=cesar@example.com,Seth=seth@example.com")
+	cmd.Flags().StringToStringVar(&colors, "colors", nil, "Colors of the members' events, as Calendar color IDs from 1 to 11 or names, e.g. Cesar=tomato,Seth=7 (default is by order of appearance)")
+	cmd.Flags().StringVar(&reminders, "reminders", "", "Reminders of every event as method:minutes before the slot starts, e.g. email:1440,popup:60 (default is the calendar's default reminders)")
+	cmd.Flags().BoolVar(&inviteTeam, "invite-team", false, "Invite the rest of the team as optional attendees of every event")
+	cmd.Flags().StringVar(&visibility, "visibility", "", "Visibility of the events: default, public or private (default is the calendar's default)")
+	cmd.Flags().BoolVar(&guestsCanModify, "guests-can-modify", false, "Let the attendees of the events modify them, on Google Calendar only")
+	cmd.Flags().StringVar(&transparency, "transparency", "", "Whether members show as busy or free while on rotation: busy or free (default is free, busy on Google Calendar)")
+	cmd.Flags().StringVar(&description, "description", "", "Template of the description of the events, with {{.Rotation}}, {{.Member}}, {{.Email}}, {{.SlotStart}}, {{.SlotEnd}}, {{.Previous}} and {{.Runbooks}}")
+	cmd.Flags().StringVar(&descriptionFile, "description-template", "", "File with the template of the description of the events instead of --description, e.g. a handoff checklist in handoff.md.tmpl")
+	cmd.Flags().StringArrayVar(&runbooks, "runbook", nil, "Link listed in the description of the events as {{.Runbooks}} (can be repeated)")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace the events of the rotation if it already exists")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "Delete the events created so far when the import fails")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the events that would be created without creating them")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format of the --dry-run plan or of the summary of the changes made: table or json")
+	cmd.MarkFlagRequired("event-name")
+
+	return cmd
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newImportExistingCommand() *cobra.Command {
+	var eventName string
+	var eventIDs []string
+	var member string
+	var dryRun bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "import-existing",
+		Short: "Bring recurring events created by hand under the management of the tool",
+		Long: `Bring recurring events created by hand under the management of the tool, as
+the events of the rotation named with --event-name, so calendars set up
+before the tool can be managed declaratively without recreating their
+events.
+
+Every rotation is identified by an ID derived from its name, e.g. sre-role
+for "SRE Role", shown by list -o json. The events given with --event-id, by
+ID or by link, get the ID stamped in their extended properties along with
+their member, read from their summary, e.g. Cesar for "SRE Role: Cesar", or
+given with --member. Summaries are renamed after the rotation when they
+aren't already.
+
+Adopted events are then updated in place by the commands changing the
+rotation, e.g. creating it again with --force. Only recurring all-day events
+can be adopted, and events already part of the rotation are left alone.`,
+		Example: `  calendar import-existing --event-name "SRE Role" --event-id 4k2n1c9q3v0b7d8e6f5g --event-id 1a2b3c4d5e6f7g8h9i0j
+
+  # The summary doesn't name the member
+  calendar import-existing --event-name "SRE Role" --event-id 4k2n1c9q3v0b7d8e6f5g --member Cesar`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			if member != "" && len(eventIDs) > 1 {
+				return errors.New("--member can only be used with a single --event-id")
+			}
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			if member != "" {
+				if err := checkMembers(roster, member); err != nil {
+					return err
+				}
+			}
+
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			client.DryRun = dryRun
+
+			ids := make([]string, 0, len(eventIDs))
+			members := make(map[string]string)
+			for _, e := range eventIDs {
+				id := gcal.EventID(e)
+				ids = append(ids, id)
+				if member != "" {
+					members[id] = member
+				}
+			}
+			changes, err := client.Adopt(ctx, calendarID, eventName, ids, members)
+			for _, c := range changes {
+				slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+			}
+			if err == nil {
+				slog.Info("Events adopted", "rotation", eventName, "id", rotation.ID(eventName), "events", len(changes))
+			}
+			var sum summary
+			sum.add(eventName, "", changes, err)
+			sum.print(cmd.OutOrStdout(), output)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation adopting the events, e.g. SRE Role")
+	cmd.Flags().StringArrayVar(&eventIDs, "event-id", nil, "ID or link of a recurring event to adopt (can be repeated)")
+	cmd.Flags().StringVar(&member, "member", "", "Member of the event, when its summary doesn't name them")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the events that would be adopted without changing them")
+	addSummaryFlag(cmd, &output)
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("event-id")
+
+	return cmd
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newListCommand() *cobra.Command {
+	var weeks int
+	var managedOnly bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the rotations of a calendar",
+		Example: `  calendar list --calendar team-roles --weeks 4`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %q, must be one of: table, json", output)
+			}
+
+			ctx := cmd.Context()
+			cal, err := newReadProvider(cmd)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			slots, err := cal.Slots(ctx, now, now.AddDate(0, 0, weeks*7))
+			if err != nil {
+				return err
+			}
+			if managedOnly {
+				slots = slices.DeleteFunc(slots, func(s rotation.Slot) bool { return !s.Managed })
+			}
+			return printRotations(os.Stdout, rotation.Statuses(slots, now), output)
+		},
+	}
+
+	cmd.Flags().IntVarP(&weeks, "weeks", "w", 12, "Number of weeks ahead to look for handoffs")
+	cmd.Flags().BoolVar(&managedOnly, "managed-only", false, "Only list the events created by the tool, leaving out the ones named like rotations by people")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+
+	return cmd
+}
+
+// printRotations renders the status of the rotations as a table or as JSON.
+func printRotations(w io.Writer, rotations []rotation.Status, output string) error {
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rotations)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROTATION\tID\tCURRENT\tUNTIL\tUPCOMING HANDOFFS")
+	for _, r := range rotations {
+		current, until := "-", "-"
+		if r.Current != nil {
+			current = r.Current.Member
+			until = r.Current.End.Format(time.DateOnly)
+		}
+		var upcoming []string
+		for _, s := range r.Upcoming {
+			upcoming = append(upcoming, fmt.Sprintf("%s (%s)", s.Member, s.Start.Format(time.DateOnly)))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.ID, current, until, strings.Join(upcoming, ", "))
+	}
+	return tw.Flush()
+}
@@ -3,258 +3,822 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
-	"sort"
-	"strconv"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"calendar/pkg/auth"
+	"calendar/pkg/caldav"
+	"calendar/pkg/config"
+	"calendar/pkg/gcal"
+	"calendar/pkg/gcal/gcaltest"
+	"calendar/pkg/ics"
+	"calendar/pkg/llm"
+	"calendar/pkg/outlook"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/telemetry"
+
 	"github.com/spf13/cobra"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/calendar/v3"
+	"golang.org/x/oauth2/microsoft"
 )
 
-func getClient(ctx context.Context, config *oauth2.Config) *http.Client {
-	tokFile := "token.json"
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(ctx, config)
-		saveToken(tokFile, tok)
-	}
-	return config.Client(ctx, tok)
-}
-
-func getTokenFromWeb(ctx context.Context, config *oauth2.Config) *oauth2.Token {
-	// Start a local web server to listen for the authorization response
-	state := "state-token"
-	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline)
-	log.Printf("Go to the following link in your browser: \n%v\n", authURL)
-
-	codeCh := make(chan string)
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query()
-		if query.Get("state") != state {
-			http.Error(w, "state did not match", http.StatusBadRequest)
-			return
-		}
-		code := query.Get("code")
-		codeCh <- code
-		log.Println(w, "Authorization completed, you can close this window.")
-	})
-	go http.ListenAndServe(":8080", nil)
-
-	// Wait for the authorization code from the web server
-	code := <-codeCh
+func main() {
+	cmd := newRootCommand()
 
-	tok, err := config.Exchange(ctx, code)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
-	}
-	return tok
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
-}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		fmt.Fprintln(os.Stderr, "\nAborting, cleaning up... (interrupt again to exit right away)")
+		cancel()
+		<-sigs
+		os.Exit(130)
+	}()
 
-func saveToken(path string, token *oauth2.Token) {
-	log.Printf("Saving credential file to: %s\n", path)
-	f, err := os.Create(path)
-	if err != nil {
-		log.Fatalf("Unable to create token file: %v", err)
+	if err := cmd.ExecuteContext(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
 }
 
-func createRotationalEvent(srv *calendar.Service, calendarId string, summary string, startDate, memberEndDate time.Time, recurrenceRule, colorID string) {
-	event := &calendar.Event{
-		Summary: summary,
-		Start: &calendar.EventDateTime{
-			Date:     startDate.Format(time.DateOnly),
-			TimeZone: "UTC",
-		},
+func newRootCommand() *cobra.Command {
+	var rf rotationFlags
+	var prompt string
+	var promptTemplate string
+	var dryRun bool
+	var output string
+	var llmBackend, llmModel, llmURL string
+	var llmRetries int
+	var out string
+	var force bool
+	var atomic bool
+	var yes bool
+	var availability string
+	var avoidOverlap bool
+	var backfill bool
+	var resume bool
+	var webhookURL string
+	var stateOut string
 
-		End: &calendar.EventDateTime{
-			Date:            memberEndDate.Format(time.DateOnly),
-			TimeZone:        "UTC",
-			ForceSendFields: []string{},
-			NullFields:      []string{},
+	cmd := &cobra.Command{
+		Use:           "calendar",
+		Short:         "A command-line calendar tool",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := loadConfig(cmd); err != nil {
+				return err
+			}
+			return setupLogging(cmd)
 		},
-		Recurrence: []string{recurrenceRule},
-		ColorId:    colorID,
-	}
-
-	event, err := srv.Events.Insert(calendarId, event).Do()
-	if err != nil {
-		log.Fatalf("Unable to create event. %v\n", err)
-	}
-	log.Printf("Event created: %s\n", event.HtmlLink)
-}
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 
-func main() {
-	var teamMembers []string
-	var startDate string
-	var duration int
-	var eventName string
-	var prompt string
+			if resume {
+				return resumeRun(cmd, rf.eventName, atomic, output)
+			}
 
-	fullPromt := func(actualPromt string) string {
-		return fmt.Sprintf(`
-I want to run a golang binary that creates a calendar event for a team rotation.
-The binary takes the following flags:
-  -t, --team-members: Comma-separated list of team members
-  -s, --start-date: Start date for the rotation
-  -d, --duration: Duration of each event in weeks, e.g. 3
-  -n, --event-name: Name of the event, e.g. SRE Role
-When I ask you to create an event I want you to return the binary flags with the values I should use.
-E.g if I tell you "Create and event called SRE-ROLE for Cesar and Seth that repeats every three weeks starting the first of july"
-You should return:
-	  -t Cesar,Seth -s 2024-07-01 -d 3 -n SRE-ROLE
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
 
-E.g if I tell you "Create and event called Interrupt-catcher for Mulham, Juan and Bryan that repeats every 1 week starting the second of july"
-You should return:
-	  -t Mulham,Juan,Bryan -s 2024-07-02 -d 1 -n Interrupt-catcher
+			var intent *promptIntent
+			if prompt != "" {
+				tmpl, err := loadPromptTemplate(promptTemplate)
+				if err != nil {
+					return err
+				}
+				provider, err := llm.New(llmBackend, llmModel, llmURL)
+				if err != nil {
+					return err
+				}
+				intent, err = parsePrompt(ctx, provider, tmpl, prompt, roster, llmRetries)
+				if err != nil {
+					return err
+				}
+				if intent.Intent != intentCreate {
+					return runIntent(cmd, intent, yes)
+				}
+				rf.teamMembers, rf.startDate, rf.duration, rf.eventName = intent.TeamMembers, intent.StartDate, intent.Duration, intent.EventName
+				slog.Info("Rotation parsed from prompt", "team-members", rf.teamMembers, "start-date", rf.startDate, "duration", rf.duration, "event-name", rf.eventName)
+			}
 
-Make sure to return only strictly necessary flags and values formatted as shown in the examples above.
-No additional information or text should be returned.	  
+			if prompt == "" && (rf.startDate == "" || rf.eventName == "") {
+				return fmt.Errorf("either --prompt or --start-date, --duration (or --cadence) and --event-name must be set")
+			}
 
-Now, this is the real ask: %s
-`, actualPromt)
-	}
+			// Every problem with the input is reported at once, before any
+			// event is written.
+			var problems []error
+			r, err := rf.rotation(roster)
+			if err != nil {
+				problems = append(problems, err)
+			}
 
-	cmd := &cobra.Command{
-		Use:   "calendar",
-		Short: "A command-line calendar tool",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			teamMembers, _ = cmd.Flags().GetStringSlice("team-members")
-			startDate, _ = cmd.Flags().GetString("start-date")
-			duration, _ = cmd.Flags().GetInt("duration")
-			eventName, _ = cmd.Flags().GetString("event-name")
-			prompt, _ = cmd.Flags().GetString("prompt")
-			ctx := cmd.Context()
+			// Exporting doesn't need to touch Google at all, otherwise the
+			// time zone defaults to the one of the first target calendar.
+			offline := dryRun || output == "ics"
+			calendarNames, _ := cmd.Flags().GetStringArray("calendar")
+			var cals []provider.CalendarProvider
+			var existing [][]provider.Event
+			if !offline {
+				if err := checkSummaryOutput(output); err != nil {
+					problems = append(problems, err)
+				}
+				// Running twice must not duplicate the rotation, so existing
+				// events are only updated in place when asked to.
+				exists := false
+				for _, name := range calendarNames {
+					cal, err := newCalendarProviderFor(cmd, name)
+					if err != nil {
+						problems = append(problems, err)
+						continue
+					}
+					events, err := cal.ManagedEvents(ctx, rotation.ID(rf.eventName))
+					if err != nil {
+						problems = append(problems, err)
+						continue
+					}
+					if len(events) > 0 && !force {
+						problems = append(problems, fmt.Errorf("rotation %q already exists with %d events in calendar %s, use --force to update them in place", rf.eventName, len(events), name))
+					}
+					exists = exists || len(events) > 0
+					cals = append(cals, cal)
+					existing = append(existing, events)
+				}
+				if !exists && !backfill && !r.Start.IsZero() && r.Start.Before(rotation.InLocation(time.Now(), time.UTC)) {
+					problems = append(problems, fmt.Errorf("start date %s is in the past, use --backfill to create the slots already served too", r.Start.Format(time.DateOnly)))
+				}
+			}
+			if err := errors.Join(problems...); err != nil {
+				return err
+			}
+			if !offline && r.TimeZone == "" {
+				r.TimeZone, err = cals[0].TimeZone(ctx)
+				if err != nil {
+					return err
+				}
+			}
 
-			if prompt != "" {
-				// get variables from llm run
-				llmOutput, err := exec.CommandContext(ctx, "ollama", "run", "llama3", fullPromt(prompt)).Output()
+			if rf.holidayCalendar != "" {
+				client, err := newGoogleCalendarClient(cmd)
 				if err != nil {
-					log.Fatalf("Failed to execute ollama: %v", string(llmOutput))
+					return err
 				}
+				if err := addHolidays(ctx, client, &r, rf.holidayCalendar); err != nil {
+					return err
+				}
+			}
 
-				// Sanitize llm output.
-				output := strings.ReplaceAll(strings.TrimSpace(string(llmOutput)), "\n", "")
-				log.Printf("Ollama output is: %v", output)
+			// Members already on another rotation of the calendars are
+			// warned about, or covered for, before planning.
+			if !offline {
+				conflicts, overlaps, err := checkOverlap(ctx, cals, &r, avoidOverlap)
+				if err != nil {
+					return err
+				}
+				logOverlaps(conflicts, overlaps, avoidOverlap)
+			}
 
-				// Parse the output from ollama into variables
-				var teamMembersFullString string
-				n, err := fmt.Sscanf(output, "-t %s -s %s -d %d -n %s", &teamMembersFullString, &startDate, &duration, &eventName)
+			var conflicts []rotation.Conflict
+			if availability != "" {
+				conflicts, err = checkAvailability(cmd, &r, availability)
 				if err != nil {
-					log.Fatalf("Unable to parse output from ollama %v: %v", n, err)
+					return err
 				}
-				teamMembers = strings.Split(teamMembersFullString, ",")
-				log.Printf("Variables parsed from llm are: Team members: %v, Start date: %v, Duration: %v, Event name: %v", teamMembers, startDate, duration, eventName)
 			}
 
-			startDateParsed, err := time.Parse("2006-01-02", startDate)
+			events, err := rotation.Plan(r)
 			if err != nil {
-				log.Fatalf("Unable to parse start date: %v", err)
+				return err
 			}
+			if offline {
+				w := os.Stdout
+				if out != "" && out != "-" {
+					f, err := os.Create(out)
+					if err != nil {
+						return fmt.Errorf("unable to create output file: %w", err)
+					}
+					defer f.Close()
+					w = f
+				}
+				if output == "table" {
+					printCycle(w, r)
+				}
+				if err := printPlan(w, events, output); err != nil {
+					return err
+				}
+				if availability != "" && output == "table" {
+					return printConflicts(w, conflicts)
+				}
+				logConflicts(conflicts, availability)
+				return nil
+			}
+			logConflicts(conflicts, availability)
 
-			createEvent(ctx, teamMembers, startDateParsed, duration, eventName)
-			return nil
+			// What the LLM understood is shown before changing anything.
+			if prompt != "" && !yes {
+				printIntent(os.Stdout, intent)
+				if err := printSchedule(os.Stdout, r); err != nil {
+					return err
+				}
+				if !confirm(fmt.Sprintf("Create rotation %q with %d events?", r.Name, len(events))) {
+					slog.Info("Aborted, no events were created")
+					return nil
+				}
+			}
+
+			var sum summary
+			if len(cals) == 1 {
+				changes, err := syncRotation(cmd, cals[0], calendarNames[0], r.Name, existing[0], events, atomic)
+				sum.add(r.Name, calendarNames[0], changes, err)
+				var failed []string
+				if err != nil {
+					failed = calendarNames
+				}
+				recordRun(r.Name, events, failed, doneMembers(nil, events, changes), err)
+				if err == nil && webhookURL != "" {
+					announceRotation(ctx, webhookURL, r, len(existing[0]) > 0)
+				}
+				writeState(ctx, stateOut, cals[0])
+				sum.print(os.Stdout, output)
+				return err
+			}
+
+			// Failing calendars don't stop the others from being synced.
+			var results []applyResult
+			var failed, done []string
+			var errs []error
+			for i, cal := range cals {
+				changes, err := syncRotation(cmd, cal, calendarNames[i], r.Name, existing[i], events, atomic)
+				sum.add(r.Name, calendarNames[i], changes, err)
+				results = append(results, newApplyResult(r.Name, calendarNames[i], len(existing[i]), changes, err))
+				done = doneMembers(done, events, changes)
+				if err != nil {
+					failed = append(failed, calendarNames[i])
+					errs = append(errs, fmt.Errorf("calendar %s: %w", calendarNames[i], err))
+				}
+				if ctx.Err() != nil {
+					failed = append(failed, calendarNames[i+1:]...)
+					break
+				}
+			}
+			recordRun(r.Name, events, failed, done, errors.Join(errs...))
+			if len(errs) == 0 && webhookURL != "" {
+				announceRotation(ctx, webhookURL, r, slices.ContainsFunc(existing, func(events []provider.Event) bool { return len(events) > 0 }))
+			}
+			writeState(ctx, stateOut, cals...)
+			if output == "table" {
+				printApplyResults(os.Stdout, results)
+			}
+			sum.print(os.Stdout, output)
+			return errors.Join(errs...)
 		},
 	}
 
 	// flags.
-	cmd.Flags().StringSliceVarP(&teamMembers, "team-members", "t", nil, "Comma-separated list of team members")
-	cmd.Flags().StringVarP(&startDate, "start-date", "s", "", "Start date for the rotation")
-	cmd.Flags().IntVarP(&duration, "duration", "d", 0, "Duration of each event in weeks, e.g. 3")
-	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the event, e.g. SRE Role")
-	cmd.Flags().StringVarP(&prompt, "prompt", "p", "", "Prompt to use to create an event")
-
-	// validations: either prompt or team-members and the other flags should be provided.
-	cmd.MarkFlagsRequiredTogether("team-members", "start-date", "duration", "event-name")
+	cmd.PersistentFlags().String("config", "", "Path to the config file (default is config.yaml in $HOME/.config/team-calendar)")
+	cmd.PersistentFlags().String("provider", provider.Google, "Calendar provider: google, outlook or caldav")
+	cmd.PersistentFlags().StringArrayP("calendar", "c", []string{"primary"}, "Summary or ID of the calendar holding the rotations, repeat it to create a rotation in several calendars")
+	cmd.PersistentFlags().String("profile", "", "Profile whose credentials and tokens are used, e.g. work, to manage the calendars of several accounts, or $TEAM_CALENDAR_PROFILE (default is the default profile, in $HOME/.config/team-calendar)")
+	cmd.PersistentFlags().String("credentials", "", "Path to the OAuth client secret or service account key file, or $TEAM_CALENDAR_CREDENTIALS (default is credentials.json in $HOME/.config/team-calendar)")
+	cmd.PersistentFlags().Bool("no-browser", false, "Only print the link to authorize the tool through the browser instead of also opening it")
+	cmd.PersistentFlags().String("token-store", auth.StoreFile, "Where OAuth tokens are cached: file (plain JSON files readable by the user only), keychain (the OS keychain) or age (files encrypted with the passphrase in $"+auth.PassphraseEnv+"), or $TEAM_CALENDAR_TOKEN_STORE")
+	cmd.PersistentFlags().String("credentials-type", auth.TypeOAuth, "Type of credentials: oauth or service-account")
+	cmd.PersistentFlags().String("token", "", "Path to the file caching the OAuth token, or $TEAM_CALENDAR_TOKEN (default is token.json, outlook-token.json with the outlook provider, gmail-token.json to send emails, directory-token.json to read --group or sheets-token.json to write spreadsheets, in $HOME/.config/team-calendar)")
+	cmd.PersistentFlags().String("outlook-client-id", "", "Application (client) ID of the Microsoft Entra app used with the outlook provider")
+	cmd.PersistentFlags().String("caldav-url", "", "URL of the calendar collection used with the caldav provider, e.g. https://cloud.example.com/remote.php/dav/calendars/me/rotations/")
+	cmd.PersistentFlags().String("caldav-user", "", "User of the CalDAV server")
+	cmd.PersistentFlags().String("caldav-password", "", "Password of the CalDAV user, e.g. a Nextcloud app password (default is $CALDAV_PASSWORD)")
+	cmd.PersistentFlags().String("outlook-tenant", "common", "Microsoft Entra tenant used with the outlook provider")
+	cmd.PersistentFlags().String("send-updates", "all", "Guests to notify about created or deleted events: all, externalOnly or none")
+	cmd.PersistentFlags().Float64("qps", 5, "Maximum Calendar API requests per second, 0 for no limit")
+	cmd.PersistentFlags().Int("concurrency", 4, "Maximum Calendar API requests in flight at once")
+	cmd.PersistentFlags().String("quota-project", "", "Google Cloud project billed for the quota of the API requests instead of the one of the credentials, e.g. to avoid 403 quota errors with an OAuth client shared across an organization, or $GOOGLE_CLOUD_QUOTA_PROJECT")
+	cmd.PersistentFlags().Int("max-retries", 5, "Maximum retries of rate limited or failed Calendar API requests")
+	cmd.PersistentFlags().Bool("offline", false, "Read the slots of the calendar from the local state copied by sync local instead of the calendar, with who, list, history and report")
+	cmd.PersistentFlags().String("state", "", "Path to the local state of the calendars read offline (default is state.db in $HOME/.config/team-calendar)")
+	cmd.PersistentFlags().Duration("lock-timeout", 10*time.Minute, "How long the lock taken on a calendar before changing it lasts without changes, after which an interrupted run no longer blocks the others, 0 to change calendars without locking them")
+	cmd.PersistentFlags().Bool("force-unlock", false, "Release the lock on the calendar left by an interrupted run before running, whoever holds it")
+	cmd.PersistentFlags().String("audit-log", "", "Path to the file recording every event created, updated or deleted (default is audit.log in $HOME/.config/team-calendar)")
+	cmd.PersistentFlags().String("roster", "", "YAML file describing the team members: name, aliases, email, color, weight, timezone and unavailability, and teams")
+	cmd.PersistentFlags().String("group", "", "Google Workspace group whose members make up the roster, e.g. sre-team@example.com, named as in the directory and with the details of the --roster members of the same name or email")
+	cmd.PersistentFlags().String("github-team", "", "GitHub team whose members make up the roster, as org/team-slug, named by their logins and with the details of the --roster members of the same name")
+	cmd.PersistentFlags().String("github-emails", "", "YAML file mapping the logins of the --github-team members to their emails, e.g. octocat: octocat@example.com")
+	cmd.PersistentFlags().String("github-token", "", "GitHub token allowed to read the members of --github-team, or $GITHUB_TOKEN")
+	cmd.PersistentFlags().String("impersonate", "", "User to impersonate with a service account using domain-wide delegation, e.g. user@domain")
+	cmd.PersistentFlags().BoolP("verbose", "v", false, "Log debug information, including Calendar API requests and responses")
+	cmd.PersistentFlags().BoolP("quiet", "q", false, "Only log warnings and errors")
+	cmd.PersistentFlags().String("log-format", "text", "Log format: text or json")
+	// Fixtures let the commands run against recorded Google Calendar API
+	// responses, without credentials.
+	cmd.PersistentFlags().String("record-fixture", "", "Record the Google Calendar API interactions to a fixture file")
+	cmd.PersistentFlags().String("replay-fixture", "", "Replay the Google Calendar API interactions of a fixture file instead of calling the API")
+	cmd.PersistentFlags().MarkHidden("record-fixture")
+	cmd.PersistentFlags().MarkHidden("replay-fixture")
+	cmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&prompt, "prompt", "p", "", "Request in natural language, in any language, e.g. to create a rotation, see who is on rotation, swap or override slots, or delete a rotation, naming members and teams of --roster as usual")
+	cmd.Flags().StringVar(&promptTemplate, "prompt-template", "", "File with the text/template of the request sent to the LLM for --prompt, e.g. with instructions and examples in the language of the team, given {{.Today}}, {{.Roster}} and {{.Prompt}} (default is the built-in one, understanding requests in any language)")
+	cmd.Flags().StringVar(&llmBackend, "llm-backend", llm.BackendOllama, "LLM backend used with --prompt: ollama, openai or anthropic")
+	cmd.Flags().StringVar(&llmModel, "llm-model", "", "LLM model used with --prompt (default depends on the backend, e.g. llama3 for ollama)")
+	cmd.Flags().StringVar(&llmURL, "llm-url", "", "Base URL of the LLM API, e.g. an OpenAI compatible endpoint (default depends on the backend)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation before acting on --prompt")
+	cmd.Flags().IntVar(&llmRetries, "llm-retries", 2, "Times the LLM is asked again when its answer to --prompt is invalid")
+	cmd.Flags().BoolVar(&force, "force", false, "Update the events of the rotation in place if it already exists")
+	cmd.Flags().BoolVar(&backfill, "backfill", false, "Allow a --start-date in the past, creating the slots already served too")
+	cmd.Flags().BoolVar(&atomic, "atomic", false, "Delete the events created so far if creating or updating any event fails")
+	cmd.Flags().BoolVar(&resume, "resume", false, "Resume the last failed run of --event-name with the same plan, only creating the events it didn't, the other rotation flags are ignored")
+	cmd.Flags().StringVar(&availability, "check-availability", "", "Look up the members' out-of-office events in their Google calendars, using --emails, and either warn about the slots they conflict with or rotate them to the next available member: warn or rotate")
+	cmd.Flags().Lookup("check-availability").NoOptDefVal = availabilityRotate
+	cmd.Flags().BoolVar(&avoidOverlap, "avoid-overlap", false, "Rotate the slots of members already on another rotation of the calendars at the same time to the next available member, instead of warning about them")
+	cmd.Flags().StringVar(&webhookURL, "webhook-url", "", "URL posted a JSON payload with the rotation and its first member and slot once the rotation is created or updated")
+	addStateOutFlag(cmd, &stateOut)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the events that would be created without creating them")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format of the --dry-run plan or of the summary of the changes made: table or json, or ics to export the rotation as an iCalendar file without creating any event")
+	cmd.Flags().StringVar(&out, "out", "-", "File to write the --dry-run or ics output to, - for stdout")
+
+	// validations: either prompt or the rotation flags should be provided. The
+	// team members may also come from the config file, so the remaining checks
+	// happen once it has been applied.
 	cmd.MarkFlagsMutuallyExclusive("prompt", "team-members")
-	cmd.MarkFlagsOneRequired("prompt", "team-members")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+	cmd.MarkFlagsMutuallyExclusive("resume", "prompt")
+	cmd.MarkFlagsMutuallyExclusive("resume", "dry-run")
+
+	cmd.AddCommand(newDeleteCommand())
+	cmd.AddCommand(newListCommand())
+	cmd.AddCommand(newSwapCommand())
+	cmd.AddCommand(newOverrideCommand())
+	cmd.AddCommand(newRotateNowCommand())
+	cmd.AddCommand(newUpdateCommand())
+	cmd.AddCommand(newMembersCommand())
+	cmd.AddCommand(newNotifyCommand())
+	cmd.AddCommand(newWhoCommand())
+	cmd.AddCommand(newApplyCommand())
+	cmd.AddCommand(newPlanCommand())
+	cmd.AddCommand(newVerifyCommand())
+	cmd.AddCommand(newWizardCommand())
+	cmd.AddCommand(newHistoryCommand())
+	cmd.AddCommand(newFairnessCommand())
+	cmd.AddCommand(newSyncCommand())
+	cmd.AddCommand(newServeCommand())
+	cmd.AddCommand(newSlackbotCommand())
+	cmd.AddCommand(newWatchCommand())
+	cmd.AddCommand(newExportCommand())
+	cmd.AddCommand(newExportSheetCommand())
+	cmd.AddCommand(newImportCommand())
+	cmd.AddCommand(newImportExistingCommand())
+	cmd.AddCommand(newImportCSVCommand())
+	cmd.AddCommand(newExtendCommand())
+	cmd.AddCommand(newAuditCommand())
+	cmd.AddCommand(newReportCommand())
+	cmd.AddCommand(newAuthCommand())
+	cmd.AddCommand(newDoctorCommand())
+	cmd.AddCommand(newTemplateCommand(cmd))
+	registerCompletions(cmd)
+
+	return cmd
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// loadConfig sets the flags not given on the command line from the
+// environment and the config file.
+func loadConfig(cmd *cobra.Command) error {
+	configFile, _ := cmd.Flags().GetString("config")
+	v, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	return config.ApplyToFlags(v, cmd.Flags())
+}
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT)
-	go func() {
-		<-sigs
-		fmt.Fprintln(os.Stderr, "\nAborted...")
-		cancel()
-	}()
+// setupLogging configures the default logger from the logging flags. Logs
+// go to stderr so they never mix with the command output.
+func setupLogging(cmd *cobra.Command) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	format, _ := cmd.Flags().GetString("log-format")
+
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	switch {
+	case verbose:
+		opts.Level = slog.LevelDebug
+	case quiet:
+		opts.Level = slog.LevelWarn
+	}
 
-	if err := cmd.ExecuteContext(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q, must be one of: text, json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// newCalendarProvider returns the provider selected with --provider, managing
+// the calendar selected with --calendar.
+func newCalendarProvider(cmd *cobra.Command) (provider.CalendarProvider, error) {
+	calendarName, err := singleCalendar(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return newCalendarProviderFor(cmd, calendarName)
+}
+
+// syncRotation syncs the events of a rotation with a calendar, showing its
+// progress and logging the changes made.
+func syncRotation(cmd *cobra.Command, cal provider.CalendarProvider, calendarName, name string, existing []provider.Event, events []rotation.Event, atomic bool) ([]provider.Change, error) {
+	ctx := cmd.Context()
+	progress, finish := newProgress(cmd, fmt.Sprintf("Syncing %s with %s", name, calendarName))
+	changes, err := cal.Sync(ctx, existing, events, provider.SyncOptions{Atomic: atomic, Progress: progress})
+	finish()
+	for _, c := range changes {
+		slog.Info("Event "+c.Action, "calendar", calendarName, "summary", c.Summary, "link", c.Link)
+	}
+	if err != nil && (atomic || ctx.Err() != nil) {
+		return changes, fmt.Errorf("unable to sync rotation %q, the events created were deleted: %w", name, err)
 	}
+	if err != nil && len(changes) > 0 {
+		// Events are tagged with the rotation, so running again picks up
+		// from what was already done.
+		return changes, fmt.Errorf("rotation %q was only partially synced, run again with --force to resume: %w", name, err)
+	}
+	return changes, err
+}
 
+// singleCalendar returns the calendar selected with --calendar, for the
+// commands working on a single calendar.
+func singleCalendar(cmd *cobra.Command) (string, error) {
+	names, _ := cmd.Flags().GetStringArray("calendar")
+	if len(names) != 1 {
+		return "", fmt.Errorf("%s works on a single calendar, --calendar must be given once", cmd.CommandPath())
+	}
+	return names[0], nil
 }
 
-func createEvent(ctx context.Context, teamMembers []string, startDate time.Time, weeks int, eventName string) {
-	b, err := ioutil.ReadFile("credentials.json")
+// newCalendarProviderFor returns the provider selected with --provider,
+// managing the named calendar. CalDAV calendars are given by --caldav-url
+// instead.
+func newCalendarProviderFor(cmd *cobra.Command, calendarName string) (provider.CalendarProvider, error) {
+	ctx := cmd.Context()
+	name, _ := cmd.Flags().GetString("provider")
+	switch name {
+	case provider.Google:
+		client, calendarID, err := newCalendarClientFor(cmd, calendarName)
+		if err != nil {
+			return nil, err
+		}
+		return client.Provider(calendarID), nil
+	case provider.Outlook:
+		clientID, _ := cmd.Flags().GetString("outlook-client-id")
+		if clientID == "" {
+			return nil, fmt.Errorf("--outlook-client-id must be set to use the outlook provider")
+		}
+		tenant, _ := cmd.Flags().GetString("outlook-tenant")
+		store, err := tokenStore(cmd, "outlook-token.json")
+		if err != nil {
+			return nil, err
+		}
+		oauthConfig := &oauth2.Config{
+			ClientID: clientID,
+			Endpoint: microsoft.AzureADEndpoint(tenant),
+			Scopes:   outlook.Scopes,
+		}
+		httpClient, err := auth.DeviceClient(ctx, oauthConfig, store)
+		if err != nil {
+			return nil, err
+		}
+		client, err := outlook.New(ctx, telemetry.WithInstrumentation(gcal.WithDebugLogging(httpClient, slog.Default())), calendarName)
+		if err != nil {
+			return nil, err
+		}
+		if client.Audit, err = newAuditLog(cmd); err != nil {
+			return nil, err
+		}
+		return client, nil
+	case provider.CalDAV:
+		auditLog, err := newAuditLog(cmd)
+		if err != nil {
+			return nil, err
+		}
+		client := &caldav.Client{HTTP: telemetry.WithInstrumentation(gcal.WithDebugLogging(http.DefaultClient, slog.Default())), Audit: auditLog}
+		client.URL, _ = cmd.Flags().GetString("caldav-url")
+		client.User, _ = cmd.Flags().GetString("caldav-user")
+		client.Password, _ = cmd.Flags().GetString("caldav-password")
+		if client.URL == "" {
+			return nil, fmt.Errorf("--caldav-url must be set to use the caldav provider")
+		}
+		if client.Password == "" {
+			client.Password = os.Getenv("CALDAV_PASSWORD")
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, must be one of: %s, %s, %s", name, provider.Google, provider.Outlook, provider.CalDAV)
+	}
+}
+
+// tokenPath returns the path given with --token, or the named file of the
+// profile.
+func tokenPath(cmd *cobra.Command, name string) (string, error) {
+	if path, _ := cmd.Flags().GetString("token"); path != "" {
+		return path, nil
+	}
+	return profileFile(cmd, name)
+}
+
+// tokenStore returns the store of the named token selected with
+// --token-store, moving the token cached as a plain file there if any.
+func tokenStore(cmd *cobra.Command, name string) (auth.TokenStore, error) {
+	path, err := tokenPath(cmd, name)
+	if err != nil {
+		return nil, err
+	}
+	kind, _ := cmd.Flags().GetString("token-store")
+	store, err := auth.NewTokenStore(kind, path)
 	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		return nil, err
+	}
+	if err := auth.Migrate(path, store); err != nil {
+		return nil, fmt.Errorf("unable to move token to the %s store: %w", kind, err)
 	}
+	return store, nil
+}
+
+// credentialsPath returns the path given with --credentials, or
+// credentials.json of the profile.
+func credentialsPath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("credentials"); path != "" {
+		return path, nil
+	}
+	return profileFile(cmd, "credentials.json")
+}
 
-	config, err := google.ConfigFromJSON(b, calendar.CalendarScope)
+// profileFile returns the path of the named file of the profile selected
+// with --profile, in the configuration directory.
+func profileFile(cmd *cobra.Command, name string) (string, error) {
+	profile, _ := cmd.Flags().GetString("profile")
+	if profile == "" || profile == config.DefaultProfile {
+		return config.File(name)
+	}
+	dir, err := config.ProfileDir(profile)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return "", err
 	}
-	client := getClient(ctx, config)
+	return filepath.Join(dir, name), nil
+}
 
-	srv, err := calendar.New(client)
+// newCalendarClient authorizes against Google with the credentials flags and
+// resolves the calendar selected with --calendar.
+func newCalendarClient(cmd *cobra.Command) (*gcal.Client, string, error) {
+	calendarName, err := singleCalendar(cmd)
 	if err != nil {
-		log.Fatalf("Unable to retrieve Calendar client: %v", err)
+		return nil, "", err
 	}
+	return newCalendarClientFor(cmd, calendarName)
+}
 
-	// Slice calendars by name and ID.
-	calendarList, err := srv.CalendarList.List().Do()
+// newCalendarClientFor authorizes against Google with the credentials flags
+// and resolves the named calendar.
+func newCalendarClientFor(cmd *cobra.Command, calendarName string) (*gcal.Client, string, error) {
+	if name, _ := cmd.Flags().GetString("provider"); name != provider.Google {
+		return nil, "", fmt.Errorf("%s is only supported with the %s provider", cmd.CommandPath(), provider.Google)
+	}
+	client, err := newGoogleCalendarClient(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	calendarID, err := client.CalendarID(cmd.Context(), calendarName)
 	if err != nil {
-		log.Fatal("ERROR %w", err)
+		return nil, "", err
 	}
-	nameId := make(map[string]string)
-	for _, v := range calendarList.Items {
-		// log.Printf("Name: %s, ID: %s\n", v.Summary, v.Id)
-		nameId[v.Summary] = v.Id
+	if forceUnlock, _ := cmd.Flags().GetBool("force-unlock"); forceUnlock {
+		holder, err := client.ForceUnlock(cmd.Context(), calendarID)
+		if err != nil {
+			return nil, "", err
+		}
+		if holder != "" {
+			slog.Warn("Lock released", "calendar", calendarName, "holder", holder)
+		}
+	}
+	return client, calendarID, nil
+}
+
+// newGoogleCalendarClient authorizes against Google Calendar with the
+// credentials flags, whatever the provider managing the rotations.
+func newGoogleCalendarClient(cmd *cobra.Command) (*gcal.Client, error) {
+	httpClient, err := newGoogleHTTPClient(cmd, "token.json", gcal.Scope)
+	if err != nil {
+		return nil, err
+	}
+	qps, _ := cmd.Flags().GetFloat64("qps")
+	maxRetries, _ := cmd.Flags().GetInt("max-retries")
+	httpClient = telemetry.WithInstrumentation(gcal.WithDebugLogging(httpClient, slog.Default()))
+	client, err := gcal.New(cmd.Context(), gcal.WithRetries(httpClient, qps, maxRetries))
+	if err != nil {
+		return nil, err
+	}
+	client.Concurrency, _ = cmd.Flags().GetInt("concurrency")
+	client.SendUpdates, _ = cmd.Flags().GetString("send-updates")
+	if !slices.Contains([]string{"all", "externalOnly", "none"}, client.SendUpdates) {
+		return nil, fmt.Errorf("invalid --send-updates %q, must be one of: all, externalOnly, none", client.SendUpdates)
+	}
+	// The lock wraps the API before the audit log does, so the lock itself
+	// isn't recorded as a change.
+	if timeout, _ := cmd.Flags().GetDuration("lock-timeout"); timeout > 0 {
+		client.Lock(lockHolder(cmd), timeout)
+		locks.Lock()
+		locks.clients = append(locks.clients, client)
+		locks.Unlock()
+		locks.finalize.Do(func() {
+			cobra.OnFinalize(func() { releaseLocks(cmd.Context()) })
+		})
+	}
+	auditLog, err := newAuditLog(cmd)
+	if err != nil {
+		return nil, err
+	}
+	client.Audit(auditLog)
+	return client, nil
+}
+
+// lockHolder returns who holds the lock on the calendars changed by cmd,
+// shown to the runs finding them locked.
+func lockHolder(cmd *cobra.Command) string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s (%s, pid %d)", currentUser(), host, cmd.CommandPath(), os.Getpid())
+}
+
+// locks holds the clients that may have locked calendars, whose leases are
+// released when the command finishes, or earlier by releaseLocks.
+var locks struct {
+	sync.Mutex
+	clients  []*gcal.Client
+	finalize sync.Once
+}
+
+// releaseLocks releases the leases taken by the clients created so far, so
+// commands syncing over and over, e.g. watch --reconcile, don't find the
+// calendars locked by their previous sync.
+func releaseLocks(ctx context.Context) {
+	locks.Lock()
+	clients := locks.clients
+	locks.clients = nil
+	locks.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	for _, client := range clients {
+		if err := client.Unlock(ctx); err != nil {
+			slog.Error("Unable to release the lock, use --force-unlock on the next run", "error", err)
+		}
+	}
+}
+
+// newGoogleHTTPClient returns an HTTP client authorized with the given
+// scopes, whose token is stored in the named file of the config directory
+// unless --token is set.
+func newGoogleHTTPClient(cmd *cobra.Command, tokenName string, scopes ...string) (*http.Client, error) {
+	if path, _ := cmd.Flags().GetString("replay-fixture"); path != "" {
+		recorder, err := gcaltest.Replay(path)
+		if err != nil {
+			return nil, err
+		}
+		return recorder.Client(), nil
 	}
 
-	// Define calendar ID (primary calendar)
-	calendarId := nameId["team-roles-test"]
+	opts, err := authOptions(cmd, tokenName)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := auth.Client(cmd.Context(), opts, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	if project, _ := cmd.Flags().GetString("quota-project"); project != "" {
+		httpClient = gcal.WithQuotaProject(httpClient, project)
+	}
+	if path, _ := cmd.Flags().GetString("record-fixture"); path != "" {
+		recorded := *httpClient
+		recorded.Transport = gcaltest.Record(path, httpClient.Transport)
+		httpClient = &recorded
+	}
+	return httpClient, nil
+}
 
-	// Order the team members slice deterministically
-	sort.Strings(teamMembers)
+// authOptions returns how to authorize against Google with the credentials
+// flags, the OAuth token being stored in the named file of the config
+// directory unless --token is set.
+func authOptions(cmd *cobra.Command, tokenName string) (auth.Options, error) {
+	var opts auth.Options
+	opts.Type, _ = cmd.Flags().GetString("credentials-type")
+	opts.Impersonate, _ = cmd.Flags().GetString("impersonate")
+	// Only auth login picks the flow, others authorize through the web.
+	opts.Flow, _ = cmd.Flags().GetString("flow")
+	opts.NoBrowser, _ = cmd.Flags().GetBool("no-browser")
+	var err error
+	opts.CredentialsFile, err = credentialsPath(cmd)
+	if err != nil {
+		return opts, err
+	}
+	opts.TokenStore, err = tokenStore(cmd, tokenName)
+	return opts, err
+}
+
+// printCycle documents the pattern a rotation repeats when some members
+// serve several slots per cycle.
+func printCycle(w io.Writer, r rotation.Rotation) {
+	cycle := r.Cycle()
+	if len(cycle) == len(r.Members) {
+		return
+	}
+	length := rotation.Cadence{Unit: r.Cadence.Unit, Count: r.Cadence.Count * len(cycle)}
+	fmt.Fprintf(w, "Every %s cycle (%d slots of %s): %s\n\n", length, len(cycle), r.Cadence, strings.Join(cycle, ", "))
+}
 
-	// Convert duration to weeks
-	durationInDays := int(weeks * 7)
-	// Define the recurrence rule for every 3 weeks
-	recurrenceRule := fmt.Sprintf("RRULE:FREQ=WEEKLY;INTERVAL=%v", weeks*len(teamMembers))
+// eventTime formats an event boundary with layout when the event is timed,
+// or as a date otherwise.
+func eventTime(t time.Time, timed bool, layout string) string {
+	if !timed {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(layout)
+}
 
-	// Create events for each team member
-	for i, member := range teamMembers {
-		memberStartDate := startDate.AddDate(0, 0, i*durationInDays)
-		memberEndDate := memberStartDate.AddDate(0, 0, durationInDays)
-		log.Printf("Creating event for %s starting on %v\n", member, memberStartDate)
-		color := strconv.Itoa(i + 1)
-		createRotationalEvent(srv, calendarId, fmt.Sprintf("%s: %s", eventName, member), memberStartDate, memberEndDate, recurrenceRule, color)
+// printPlan renders the planned events as a table or as JSON.
+func printPlan(w io.Writer, events []rotation.Event, output string) error {
+	switch output {
+	case "json":
+		type jsonEvent struct {
+			Member     string              `json:"member"`
+			Summary    string              `json:"summary"`
+			Start      string              `json:"start"`
+			End        string              `json:"end"`
+			Recurrence []string            `json:"recurrence,omitempty"`
+			ColorID    string              `json:"colorId"`
+			Attendees  []rotation.Attendee `json:"attendees,omitempty"`
+			Reminders  []rotation.Reminder `json:"reminders,omitempty"`
+		}
+		out := make([]jsonEvent, 0, len(events))
+		for _, e := range events {
+			out = append(out, jsonEvent{
+				Member:     e.Member,
+				Summary:    e.Summary,
+				Start:      eventTime(e.Start, e.Timed, time.RFC3339),
+				End:        eventTime(e.End, e.Timed, time.RFC3339),
+				Recurrence: e.Recurrence,
+				ColorID:    e.ColorID,
+				Attendees:  e.Attendees,
+				Reminders:  e.Reminders,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MEMBER\tSUMMARY\tSTART\tEND\tRECURRENCE\tCOLOR\tATTENDEES")
+		for _, e := range events {
+			recurrence := strings.Join(e.Recurrence, " ")
+			if recurrence == "" {
+				recurrence = "-"
+			}
+			var attendees []string
+			for _, a := range e.Attendees {
+				if a.Optional {
+					attendees = append(attendees, a.Email+" (optional)")
+					continue
+				}
+				attendees = append(attendees, a.Email)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Member, e.Summary, eventTime(e.Start, e.Timed, "2006-01-02 15:04 MST"), eventTime(e.End, e.Timed, "2006-01-02 15:04 MST"), recurrence, e.ColorID, strings.Join(attendees, ", "))
+		}
+		return tw.Flush()
+	case "ics":
+		return ics.Write(w, events, time.Now())
+	default:
+		return fmt.Errorf("unknown output format %q, must be one of: table, json, ics", output)
 	}
 }
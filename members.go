@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/calendar/v3"
+)
+
+func newMembersCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "members",
+		Short: "Add members to or remove them from a rotation from a date on",
+		Long: `Add members to or remove them from a rotation from a date on, reflowing the
+rest of the schedule.
+
+Slots served before --from are kept as history, and so is the slot being
+served on --from, if any, so the schedule changes on its next handoff. From
+then on, the events of the rotation are computed again with the new members,
+the turns going on from the member that served last, like the update command
+does. The rotation is defined by the rotation flags, as when it was created,
+its members being the ones of its events in the order they take turns.`,
+		Example: `  calendar members add --event-name "SRE Role" --duration 1 --member Priya --from 2024-10-01
+  calendar members remove --event-name "SRE Role" --duration 1 --member Seth --from 2024-10-01`,
+	}
+	cmd.AddCommand(newMembersChangeCommand("add"))
+	cmd.AddCommand(newMembersChangeCommand("remove"))
+	return cmd
+}
+
+// newMembersChangeCommand returns the command adding or removing members,
+// depending on action.
+func newMembersChangeCommand(action string) *cobra.Command {
+	var rf rotationFlags
+	var members []string
+	var from string
+	var output string
+
+	short := "Add members to a rotation from a date on"
+	if action == "remove" {
+		short = "Remove members from a rotation from a date on"
+	}
+	cmd := &cobra.Command{
+		Use:   action,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" || rf.eventName == "" || len(members) == 0 {
+				return fmt.Errorf("--event-name, --member and --from must be set")
+			}
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			timeZone, err := client.TimeZone(ctx, calendarID)
+			if err != nil {
+				return err
+			}
+			loc, err := time.LoadLocation(timeZone)
+			if err != nil {
+				return err
+			}
+			day, err := time.ParseInLocation(time.DateOnly, from, loc)
+			if err != nil {
+				return fmt.Errorf("unable to parse --from %q, must be formatted as YYYY-MM-DD", from)
+			}
+
+			existing, err := client.ManagedEvents(ctx, calendarID, rotation.ID(rf.eventName))
+			if err != nil {
+				return err
+			}
+			if len(existing) == 0 {
+				return fmt.Errorf("rotation %q not found in the calendar", rf.eventName)
+			}
+			current := turnOrder(existing)
+			updated := slices.Clone(current)
+			for _, m := range members {
+				i := slices.Index(updated, m)
+				switch {
+				case action == "add" && i >= 0:
+					return fmt.Errorf("%s is already a member of rotation %q", m, rf.eventName)
+				case action == "add":
+					updated = append(updated, m)
+				case i < 0:
+					return fmt.Errorf("%s isn't a member of rotation %q", m, rf.eventName)
+				default:
+					updated = slices.Delete(updated, i, i+1)
+				}
+			}
+			if len(updated) == 0 {
+				return fmt.Errorf("rotation %q would be left without members, delete it instead", rf.eventName)
+			}
+
+			return reflowRotation(cmd, &rf, client, calendarID, existing, updated, day, func(last string) string {
+				return nextMember(current, updated, last)
+			}, cmd.OutOrStdout(), output)
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringSliceVar(&members, "member", nil, "Members to "+action+", e.g. Priya (can be repeated)")
+	cmd.Flags().StringVar(&from, "from", "", "First day the members change, e.g. 2024-10-01, the slot being served then being kept")
+	addSummaryFlag(cmd, &output)
+	// The members and the start of the reflowed rotation come from the
+	// calendar.
+	cmd.Flags().MarkHidden("start-date")
+	cmd.Flags().MarkHidden("team-members")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
+
+// reflowRotation rewrites the events of a rotation from the first handoff on
+// or after day, as computed from the rotation flags with the members taking
+// turns in the given order, keeping the slot being served on day. The first
+// member to serve is the one next returns for the member who served last,
+// unless given with --start-with. The summary of the changes is written to w
+// in the given format.
+func reflowRotation(cmd *cobra.Command, rf *rotationFlags, client *gcal.Client, calendarID string, existing []*calendar.Event, members []string, day time.Time, next func(last string) string, w io.Writer, output string) error {
+	ctx := cmd.Context()
+	cutover := day
+	last, err := client.SlotAt(ctx, calendarID, rf.eventName, day)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Start.Before(day) {
+		cutover = rotation.InLocation(last.End, day.Location())
+	} else if last, err = client.SlotAt(ctx, calendarID, rf.eventName, day.Add(-time.Minute)); err != nil {
+		return err
+	}
+	if !cmd.Flags().Changed("start-with") && last != nil {
+		rf.startWith = next(last.Member)
+	}
+	if !cmd.Flags().Changed("order") {
+		rf.order = rotation.OrderGiven
+	}
+	rf.teamMembers = members
+	rf.startDate = cutover.Format(time.DateOnly)
+
+	roster, err := loadRoster(cmd)
+	if err != nil {
+		return err
+	}
+	r, err := rf.rotation(roster)
+	if err != nil {
+		return err
+	}
+	if r.TimeZone == "" {
+		r.TimeZone = day.Location().String()
+	}
+	// Planning first leaves the calendar untouched on invalid changes.
+	events, err := rotation.Plan(r)
+	if err != nil {
+		return err
+	}
+	slog.Info("Reflowing rotation", "rotation", r.Name, "members", members, "from", rf.startDate, "startWith", rf.startWith)
+
+	var sum summary
+	changes, err := client.Truncate(ctx, calendarID, existing, r.Start)
+	for _, c := range changes {
+		slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+	}
+	sum.add(r.Name, "", changes, err)
+	if err != nil {
+		sum.print(w, output)
+		return err
+	}
+
+	var finish func()
+	client.Progress, finish = newProgress(cmd, "Syncing "+r.Name)
+	changes, err = client.Sync(ctx, calendarID, nil, events)
+	finish()
+	for _, c := range changes {
+		slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+	}
+	sum.add(r.Name, "", changes, err)
+	sum.print(w, output)
+	return err
+}
+
+// turnOrder returns the members of the recurring events of a rotation in the
+// order they take turns, the one of the first occurrences of their events,
+// or of its single events when materialized.
+func turnOrder(existing []*calendar.Event) []string {
+	series := slices.DeleteFunc(slices.Clone(existing), func(e *calendar.Event) bool { return len(e.Recurrence) == 0 })
+	if len(series) == 0 {
+		series = slices.Clone(existing)
+	}
+	sort.SliceStable(series, func(i, j int) bool { return gcal.EventStart(series[i]) < gcal.EventStart(series[j]) })
+	var members []string
+	for _, event := range series {
+		member := ""
+		if event.ExtendedProperties != nil {
+			member = event.ExtendedProperties.Private[gcal.PropertyMember]
+		}
+		if member != "" && !slices.Contains(members, member) {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// nextMember returns the member taking over from last in the updated
+// members: the one after last when still a member, so added members come
+// after the current last one, or the first remaining one after last in the
+// current turns otherwise.
+func nextMember(current, updated []string, last string) string {
+	if i := slices.Index(updated, last); i >= 0 {
+		return updated[(i+1)%len(updated)]
+	}
+	i := slices.Index(current, last)
+	for _, m := range append(slices.Clone(current[i+1:]), current[:max(i, 0)]...) {
+		if slices.Contains(updated, m) {
+			return m
+		}
+	}
+	return updated[0]
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"calendar/pkg/notify"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/spec"
+
+	"github.com/spf13/cobra"
+)
+
+func newNotifyCommand() *cobra.Command {
+	var webhook string
+	var users map[string]string
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "notify",
+		Short: "Announce rotation handoffs on Slack, Teams, Matrix or by email",
+		Long: `Announce rotation handoffs on Slack, Teams, Matrix or by email.
+
+The member currently on rotation is looked up in the calendar and announced
+if their slot started today, so the command is meant to be run daily, e.g.
+from cron.
+
+With --filename, every rotation of the spec file is announced to the channels
+listed under its notify key instead, looked up in its first calendar, e.g.
+
+  rotations:
+    - name: SRE Role
+      cadence: weekly
+      notify:
+        - type: teams
+          url: https://example.webhook.office.com/webhookb2/...
+        - type: matrix
+          homeserver: https://matrix.example.com
+          room: "!abc123:example.com"
+          token: ${MATRIX_TOKEN}
+        - type: email
+          smtp: smtp.example.com:587
+          from: rotations@example.com
+          to: [sre@example.com]
+
+Channels are of type slack, teams, matrix, email (through an SMTP server) or
+webhook, and environment variables in their fields are expanded.`,
+		Example: `  calendar notify --event-name "SRE Role" --slack-webhook https://hooks.slack.com/services/... --slack-users Seth=U0123ABCD
+
+  # Announce the handoffs of every rotation of a spec file
+  calendar notify -f rotations.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			eventName, _ := cmd.Flags().GetString("event-name")
+			switch {
+			case file != "" && eventName != "":
+				return fmt.Errorf("--event-name and --filename can't be used together")
+			case file != "":
+				return notifySpec(cmd, file)
+			case eventName == "":
+				return fmt.Errorf("--event-name or --filename must be set")
+			}
+			// The webhook is a secret better kept in the config file, so it
+			// can't be marked as a required flag.
+			if webhook == "" {
+				return fmt.Errorf("--slack-webhook must be set")
+			}
+
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			for member := range users {
+				if err := checkMembers(roster, member); err != nil {
+					return err
+				}
+			}
+
+			slot, err := handoff(cmd)
+			if err != nil || slot == nil {
+				return err
+			}
+			slack := &notify.Slack{WebhookURL: webhook, Users: users}
+			if err := slack.Notify(cmd.Context(), *slot); err != nil {
+				return err
+			}
+			slog.Info("Notified handoff", "rotation", slot.Rotation, "member", slot.Member)
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().StringP("event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.PersistentFlags().Bool("always", false, "Announce the current member even if the handoff didn't happen today")
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "Spec file listing the rotations to announce and their notify channels")
+	cmd.Flags().StringVar(&webhook, "slack-webhook", "", "Slack incoming webhook URL of the channel to notify")
+	cmd.Flags().StringToStringVar(&users, "slack-users", nil, "Slack user IDs of the members to mention them, e.g. Seth=U0123ABCD")
+
+	cmd.AddCommand(newNotifyEmailCommand())
+
+	return cmd
+}
+
+func newNotifyEmailCommand() *cobra.Command {
+	var team []string
+	var emails map[string]string
+
+	cmd := &cobra.Command{
+		Use:   "email",
+		Short: "Email rotation handoffs with Gmail",
+		Long: `Email rotation handoffs with Gmail.
+
+The incoming member and the team get a summary of the slot with a link to its
+event, sent from the Google account the tool is authorized with. Sending
+needs its own authorization, stored next to the Calendar token.`,
+		Example: `  calendar notify email --event-name "SRE Role" --to sre@example.com --emails Seth=seth@example.com`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			for member := range emails {
+				if err := checkMembers(roster, member); err != nil {
+					return err
+				}
+			}
+			// Emails given on the command line take precedence over the
+			// roster ones.
+			r := rotation.Rotation{Emails: emails}
+			if roster != nil {
+				r.Members = roster.Names()
+				if err := roster.Apply(&r); err != nil {
+					return err
+				}
+			}
+
+			slot, err := handoff(cmd)
+			if err != nil || slot == nil {
+				return err
+			}
+
+			httpClient, err := newGoogleHTTPClient(cmd, "gmail-token.json", notify.GmailScope)
+			if err != nil {
+				return err
+			}
+			gmail, err := notify.NewGmail(cmd.Context(), httpClient)
+			if err != nil {
+				return err
+			}
+			gmail.Emails = r.Emails
+			gmail.Team = team
+			if err := gmail.Notify(cmd.Context(), *slot); err != nil {
+				return err
+			}
+			slog.Info("Emailed handoff", "rotation", slot.Rotation, "member", slot.Member)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&team, "to", nil, "Addresses copied on every handoff email, e.g. the team's mailing list")
+	cmd.Flags().StringToStringVar(&emails, "emails", nil, "Emails of the members, e.g. Seth=seth@example.com (default is the ones of the roster)")
+
+	return cmd
+}
+
+// handoff returns the slot of the member currently on the rotation given
+// with --event-name, or nil when the handoff didn't happen today, unless
+// --always is set.
+func handoff(cmd *cobra.Command) (*rotation.Slot, error) {
+	eventName, _ := cmd.Flags().GetString("event-name")
+	always, _ := cmd.Flags().GetBool("always")
+	if eventName == "" {
+		return nil, fmt.Errorf("--event-name must be set")
+	}
+
+	client, calendarID, err := newCalendarClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	slot, err := client.SlotAt(cmd.Context(), calendarID, eventName, now)
+	if err != nil {
+		return nil, err
+	}
+	if slot == nil {
+		return nil, fmt.Errorf("nobody is on rotation %q now", eventName)
+	}
+	if !always && now.Sub(slot.Start) >= 24*time.Hour {
+		slog.Info("No handoff today", "rotation", eventName, "member", slot.Member, "since", slot.Start.Format(time.DateOnly))
+		return nil, nil
+	}
+	return slot, nil
+}
+
+// notifySpec announces the handoffs of the rotations of a spec file that
+// happened today, unless --always is set, to their notify channels. Failures
+// don't stop the other rotations and channels from being notified.
+func notifySpec(cmd *cobra.Command, file string) error {
+	f, err := spec.Load(file)
+	if err != nil {
+		return err
+	}
+	roster, err := loadRoster(cmd)
+	if err != nil {
+		return err
+	}
+	always, _ := cmd.Flags().GetBool("always")
+
+	ctx := cmd.Context()
+	providers := make(map[string]provider.CalendarProvider)
+	var errs []error
+	for _, s := range f.Rotations {
+		if len(s.Notify) == 0 {
+			continue
+		}
+		r, err := s.Rotation()
+		if err == nil && roster != nil {
+			err = roster.Apply(&r)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q: %w", s.Name, err))
+			continue
+		}
+		cal, err := specProvider(cmd, specCalendars(cmd, s)[0], providers)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		now := time.Now()
+		slot, err := provider.SlotAt(ctx, cal, s.Name, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q: %w", s.Name, err))
+			continue
+		}
+		if slot == nil {
+			slog.Info("Nobody on rotation", "rotation", s.Name)
+			continue
+		}
+		if !always && now.Sub(slot.Start) >= 24*time.Hour {
+			slog.Info("No handoff today", "rotation", s.Name, "member", slot.Member, "since", slot.Start.Format(time.DateOnly))
+			continue
+		}
+		for _, n := range s.Notify {
+			notifier, err := n.Notifier(r.Emails)
+			if err == nil {
+				err = notifier.Notify(ctx, *slot)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to notify rotation %q on %s: %w", s.Name, n.Type, err))
+				continue
+			}
+			slog.Info("Notified handoff", "rotation", s.Name, "member", slot.Member, "channel", n.Type)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// announceRotation posts the first slot of a rotation just created, or
+// updated, to a webhook. Failures are only logged, as the events were
+// written already.
+func announceRotation(ctx context.Context, url string, r rotation.Rotation, updated bool) {
+	slots, err := rotation.Preview(r, 1)
+	if err != nil || len(slots) == 0 {
+		return
+	}
+	event := notify.WebhookCreated
+	if updated {
+		event = notify.WebhookUpdated
+	}
+	webhook := &notify.Webhook{URL: url}
+	if err := webhook.Post(ctx, event, slots[0]); err != nil {
+		slog.Warn("Unable to notify webhook", "rotation", r.Name, "error", err)
+		return
+	}
+	slog.Debug("Notified webhook", "rotation", r.Name, "event", event)
+}
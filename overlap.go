@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+)
+
+// overlap is a slot of another rotation served by a member of the rotation
+// being scheduled, as the period it makes them unavailable.
+type overlap struct {
+	rotation    string
+	unavailable rotation.Unavailability
+}
+
+// checkOverlap reads the other rotations of the calendars over the upcoming
+// slots of a rotation, and returns the slots of its members that overlap
+// their slots in the other rotations. With avoid set, those slots are added
+// to the rotation's unavailabilities so other members cover them.
+func checkOverlap(ctx context.Context, cals []provider.CalendarProvider, r *rotation.Rotation, avoid bool) ([]rotation.Conflict, []overlap, error) {
+	n, from, to, loc, err := upcomingSlots(*r)
+	if err != nil || n == 0 {
+		return nil, nil, err
+	}
+
+	// The roles of the rotation are the rotation itself.
+	own := []string{r.Name}
+	for _, role := range r.Roles {
+		own = append(own, rotation.RoleName(r.Name, role))
+	}
+	var overlaps []overlap
+	for _, cal := range cals {
+		slots, err := cal.Slots(ctx, from, to)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, s := range slots {
+			if slices.Contains(own, s.Rotation) || !slices.Contains(r.Members, s.Member) {
+				continue
+			}
+			o := overlap{rotation: s.Rotation, unavailable: busyDays(s, loc)}
+			if !slices.Contains(overlaps, o) {
+				overlaps = append(overlaps, o)
+			}
+		}
+	}
+	if len(overlaps) == 0 {
+		return nil, nil, nil
+	}
+
+	unavailable := make([]rotation.Unavailability, len(overlaps))
+	for i, o := range overlaps {
+		unavailable[i] = o.unavailable
+	}
+	conflicts, err := rotation.Conflicts(*r, unavailable, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	if avoid {
+		r.Unavailable = append(r.Unavailable, unavailable...)
+	}
+	return conflicts, overlaps, nil
+}
+
+// busyDays returns the days a slot keeps its member busy. Handoff days of
+// slots starting or ending at a time of day are shared with the member
+// before or after, so only the whole days in between count, unless the slot
+// has none.
+func busyDays(s rotation.Slot, loc *time.Location) rotation.Unavailability {
+	start, end := s.Start.In(loc), s.End.In(loc)
+	first, last := rotation.InLocation(start, loc), rotation.InLocation(end, loc).AddDate(0, 0, -1)
+	if !first.Equal(start) {
+		first = first.AddDate(0, 0, 1)
+	}
+	if last.Before(first) {
+		first, last = rotation.InLocation(start, loc), rotation.InLocation(end.Add(-time.Nanosecond), loc)
+	}
+	return rotation.Unavailability{Member: s.Member, From: first, To: last}
+}
+
+// logOverlaps warns about the slots overlapping the slots of their member in
+// other rotations.
+func logOverlaps(conflicts []rotation.Conflict, overlaps []overlap, avoid bool) {
+	for _, c := range conflicts {
+		var others []string
+		for _, o := range overlaps {
+			if o.unavailable == c.Unavailable && !slices.Contains(others, o.rotation) {
+				others = append(others, o.rotation)
+			}
+		}
+		msg := "Member is on another rotation during their slot, pass --avoid-overlap to rotate it to the next available member"
+		if avoid {
+			msg = "Member is on another rotation during their slot, rotated to the next available member"
+		}
+		slog.Warn(msg, "member", c.Slot.Member, "start", c.Slot.Start.Format(time.DateOnly), "end", c.Slot.End.Format(time.DateOnly), "other", strings.Join(others, ", "), "covered-by", c.CoveredBy)
+	}
+}
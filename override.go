@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newOverrideCommand() *cobra.Command {
+	var eventName string
+	var member string
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "override",
+		Short: "Have a member cover part of a rotation for someone else",
+		Long: `Have a member cover part of a rotation for someone else, e.g. during a
+day off. The slots overlapping the override are cut around it and a
+coverage event is created for the member, leaving the recurring series
+untouched. Coverage events are kept when the rotation is synced again.`,
+		Example: `  # Juan covers the SRE Role from Monday to Wednesday, whoever's slot it is.
+  calendar override --event-name "SRE Role" --member Juan --from 2024-08-12 --to 2024-08-14`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fromParsed, err := time.Parse(time.DateOnly, from)
+			if err != nil {
+				return fmt.Errorf("unable to parse from: %w", err)
+			}
+			toParsed := fromParsed
+			if to != "" {
+				if toParsed, err = time.Parse(time.DateOnly, to); err != nil {
+					return fmt.Errorf("unable to parse to: %w", err)
+				}
+			}
+
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			if err := checkMembers(roster, member); err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			slots, err := client.Override(ctx, calendarID, eventName, member, fromParsed, toParsed)
+			for _, s := range slots {
+				slog.Info("Slot overridden", "rotation", eventName, "member", s.Member, "start", s.Start.Format(time.DateOnly), "end", s.End.Format(time.DateOnly), "link", s.Link)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&member, "member", "", "Member covering the rotation")
+	cmd.Flags().StringVar(&from, "from", "", "First day of the override, e.g. 2024-08-12")
+	cmd.Flags().StringVar(&to, "to", "", "Last day of the override, --from when unset")
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("member")
+	cmd.MarkFlagRequired("from")
+
+	return cmd
+}
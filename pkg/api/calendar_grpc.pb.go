@@ -0,0 +1,257 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: calendar.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RotationService_CreateRotation_FullMethodName   = "/calendar.v1.RotationService/CreateRotation"
+	RotationService_GetCurrentOnCall_FullMethodName = "/calendar.v1.RotationService/GetCurrentOnCall"
+	RotationService_Swap_FullMethodName             = "/calendar.v1.RotationService/Swap"
+	RotationService_ListRotations_FullMethodName    = "/calendar.v1.RotationService/ListRotations"
+)
+
+// RotationServiceClient is the client API for RotationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RotationService manages the rotations of the calendar served by
+// "calendar serve --grpc-address".
+type RotationServiceClient interface {
+	// CreateRotation creates the events of a rotation, failing with
+	// ALREADY_EXISTS when the calendar has it already unless force is set.
+	CreateRotation(ctx context.Context, in *CreateRotationRequest, opts ...grpc.CallOption) (*CreateRotationResponse, error)
+	// GetCurrentOnCall returns the slot of a rotation covering a time, now by
+	// default, failing with NOT_FOUND when nobody is on rotation then.
+	GetCurrentOnCall(ctx context.Context, in *GetCurrentOnCallRequest, opts ...grpc.CallOption) (*GetCurrentOnCallResponse, error)
+	// Swap exchanges the slot of a member covering a date, or the first one
+	// after it, with the next slot of another member.
+	Swap(ctx context.Context, in *SwapRequest, opts ...grpc.CallOption) (*SwapResponse, error)
+	// ListRotations returns who is on every rotation of the calendar and the
+	// upcoming handoffs.
+	ListRotations(ctx context.Context, in *ListRotationsRequest, opts ...grpc.CallOption) (*ListRotationsResponse, error)
+}
+
+type rotationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRotationServiceClient(cc grpc.ClientConnInterface) RotationServiceClient {
+	return &rotationServiceClient{cc}
+}
+
+func (c *rotationServiceClient) CreateRotation(ctx context.Context, in *CreateRotationRequest, opts ...grpc.CallOption) (*CreateRotationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateRotationResponse)
+	err := c.cc.Invoke(ctx, RotationService_CreateRotation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rotationServiceClient) GetCurrentOnCall(ctx context.Context, in *GetCurrentOnCallRequest, opts ...grpc.CallOption) (*GetCurrentOnCallResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCurrentOnCallResponse)
+	err := c.cc.Invoke(ctx, RotationService_GetCurrentOnCall_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rotationServiceClient) Swap(ctx context.Context, in *SwapRequest, opts ...grpc.CallOption) (*SwapResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SwapResponse)
+	err := c.cc.Invoke(ctx, RotationService_Swap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rotationServiceClient) ListRotations(ctx context.Context, in *ListRotationsRequest, opts ...grpc.CallOption) (*ListRotationsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRotationsResponse)
+	err := c.cc.Invoke(ctx, RotationService_ListRotations_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RotationServiceServer is the server API for RotationService service.
+// All implementations must embed UnimplementedRotationServiceServer
+// for forward compatibility.
+//
+// RotationService manages the rotations of the calendar served by
+// "calendar serve --grpc-address".
+type RotationServiceServer interface {
+	// CreateRotation creates the events of a rotation, failing with
+	// ALREADY_EXISTS when the calendar has it already unless force is set.
+	CreateRotation(context.Context, *CreateRotationRequest) (*CreateRotationResponse, error)
+	// GetCurrentOnCall returns the slot of a rotation covering a time, now by
+	// default, failing with NOT_FOUND when nobody is on rotation then.
+	GetCurrentOnCall(context.Context, *GetCurrentOnCallRequest) (*GetCurrentOnCallResponse, error)
+	// Swap exchanges the slot of a member covering a date, or the first one
+	// after it, with the next slot of another member.
+	Swap(context.Context, *SwapRequest) (*SwapResponse, error)
+	// ListRotations returns who is on every rotation of the calendar and the
+	// upcoming handoffs.
+	ListRotations(context.Context, *ListRotationsRequest) (*ListRotationsResponse, error)
+	mustEmbedUnimplementedRotationServiceServer()
+}
+
+// UnimplementedRotationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRotationServiceServer struct{}
+
+func (UnimplementedRotationServiceServer) CreateRotation(context.Context, *CreateRotationRequest) (*CreateRotationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRotation not implemented")
+}
+func (UnimplementedRotationServiceServer) GetCurrentOnCall(context.Context, *GetCurrentOnCallRequest) (*GetCurrentOnCallResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrentOnCall not implemented")
+}
+func (UnimplementedRotationServiceServer) Swap(context.Context, *SwapRequest) (*SwapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Swap not implemented")
+}
+func (UnimplementedRotationServiceServer) ListRotations(context.Context, *ListRotationsRequest) (*ListRotationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRotations not implemented")
+}
+func (UnimplementedRotationServiceServer) mustEmbedUnimplementedRotationServiceServer() {}
+func (UnimplementedRotationServiceServer) testEmbeddedByValue()                         {}
+
+// UnsafeRotationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RotationServiceServer will
+// result in compilation errors.
+type UnsafeRotationServiceServer interface {
+	mustEmbedUnimplementedRotationServiceServer()
+}
+
+func RegisterRotationServiceServer(s grpc.ServiceRegistrar, srv RotationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRotationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RotationService_ServiceDesc, srv)
+}
+
+func _RotationService_CreateRotation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRotationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RotationServiceServer).CreateRotation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RotationService_CreateRotation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RotationServiceServer).CreateRotation(ctx, req.(*CreateRotationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RotationService_GetCurrentOnCall_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCurrentOnCallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RotationServiceServer).GetCurrentOnCall(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RotationService_GetCurrentOnCall_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RotationServiceServer).GetCurrentOnCall(ctx, req.(*GetCurrentOnCallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RotationService_Swap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RotationServiceServer).Swap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RotationService_Swap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RotationServiceServer).Swap(ctx, req.(*SwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RotationService_ListRotations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRotationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RotationServiceServer).ListRotations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RotationService_ListRotations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RotationServiceServer).ListRotations(ctx, req.(*ListRotationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RotationService_ServiceDesc is the grpc.ServiceDesc for RotationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RotationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "calendar.v1.RotationService",
+	HandlerType: (*RotationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateRotation",
+			Handler:    _RotationService_CreateRotation_Handler,
+		},
+		{
+			MethodName: "GetCurrentOnCall",
+			Handler:    _RotationService_GetCurrentOnCall_Handler,
+		},
+		{
+			MethodName: "Swap",
+			Handler:    _RotationService_Swap_Handler,
+		},
+		{
+			MethodName: "ListRotations",
+			Handler:    _RotationService_ListRotations_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "calendar.proto",
+}
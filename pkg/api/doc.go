@@ -0,0 +1,10 @@
+// Package api is the gRPC API served by "calendar serve --grpc-address" for
+// internal services, defined in calendar.proto, with the generated client
+// to call it, e.g.
+//
+//	conn, err := grpc.NewClient("oncall.internal:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	...
+//	resp, err := api.NewRotationServiceClient(conn).GetCurrentOnCall(ctx, &api.GetCurrentOnCallRequest{Rotation: "SRE Role"})
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative calendar.proto
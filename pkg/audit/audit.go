@@ -0,0 +1,109 @@
+// Package audit keeps an append-only log of the events created, updated and
+// deleted in the calendars, e.g. for compliance when the tool manages
+// production on-call calendars.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the log.
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// Entry is a change made to a calendar.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// User is the user of the system who ran the command.
+	User string `json:"user"`
+	// Command is the command line of the change, without secrets.
+	Command  string `json:"command"`
+	Provider string `json:"provider"`
+	Calendar string `json:"calendar"`
+	// Action is one of created, updated or deleted.
+	Action  string `json:"action"`
+	EventID string `json:"eventId"`
+	// Rotation is the ID of the rotation of the event, when known.
+	Rotation string `json:"rotation,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+}
+
+// Log appends entries to a file, one JSON object per line. The file is only
+// opened while writing, so long running commands don't hold it.
+type Log struct {
+	// Path is the file the entries are appended to, created along with its
+	// directory when missing.
+	Path string
+	// User and Command are recorded in every entry.
+	User    string
+	Command string
+
+	mu sync.Mutex
+}
+
+// Record appends an entry to the log, filling its time, user and command.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	e.User, e.Command = l.User, l.Command
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o700); err != nil {
+		return fmt.Errorf("unable to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Read returns the entries of a log, none when it doesn't exist yet.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid audit log entry at %s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read audit log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,284 @@
+// Package auth builds HTTP clients authorized against the calendar APIs,
+// through the interactive or device OAuth flows, or with a Google service
+// account key.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// TypeOAuth uses the three-legged OAuth flow with a client secret file.
+	TypeOAuth = "oauth"
+	// TypeServiceAccount uses a service account key, for headless usage.
+	TypeServiceAccount = "service-account"
+)
+
+// OAuth flows authorizing a new token.
+const (
+	// FlowWeb redirects the browser to a local server once authorized.
+	FlowWeb = "web"
+	// FlowDevice has the user enter a code on any device, for machines
+	// without a browser or reachable from it, e.g. over SSH.
+	FlowDevice = "device"
+)
+
+// Options describes how to authenticate against Google.
+type Options struct {
+	// Type is either TypeOAuth or TypeServiceAccount.
+	Type string
+	// CredentialsFile is the client secret or service account key file.
+	CredentialsFile string
+	// TokenStore is where the OAuth token is cached between runs.
+	TokenStore TokenStore
+	// Impersonate is the user a service account acts as through
+	// domain-wide delegation.
+	Impersonate string
+	// Flow is the OAuth flow authorizing a new token, FlowWeb when empty.
+	Flow string
+	// NoBrowser only prints the authorization link of the web flow instead
+	// of also opening it in the default browser.
+	NoBrowser bool
+}
+
+// Client returns an HTTP client authorized for the given scopes.
+func Client(ctx context.Context, opts Options, scopes ...string) (*http.Client, error) {
+	b, err := os.ReadFile(opts.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+	}
+
+	switch opts.Type {
+	case TypeOAuth:
+		if opts.Impersonate != "" {
+			return nil, fmt.Errorf("impersonation is only supported with service account credentials")
+		}
+		config, err := google.ConfigFromJSON(b, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse client secret file to config: %w", err)
+		}
+		switch opts.Flow {
+		case "", FlowWeb:
+			return oauthClient(ctx, config, opts.TokenStore, func(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+				return tokenFromWeb(ctx, config, !opts.NoBrowser)
+			})
+		case FlowDevice:
+			// Client secret files don't list the device endpoint.
+			if config.Endpoint.DeviceAuthURL == "" {
+				config.Endpoint.DeviceAuthURL = google.Endpoint.DeviceAuthURL
+			}
+			return oauthClient(ctx, config, opts.TokenStore, tokenFromDevice)
+		default:
+			return nil, fmt.Errorf("unknown OAuth flow %q, must be one of: %s, %s", opts.Flow, FlowWeb, FlowDevice)
+		}
+	case TypeServiceAccount:
+		config, err := google.JWTConfigFromJSON(b, scopes...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse service account key file to config: %w", err)
+		}
+		// With domain-wide delegation the service account acts on behalf of a user.
+		config.Subject = opts.Impersonate
+		return config.Client(ctx), nil
+	default:
+		return nil, fmt.Errorf("unknown credentials type %q, must be one of: %s, %s", opts.Type, TypeOAuth, TypeServiceAccount)
+	}
+}
+
+// DeviceClient returns an HTTP client authorized through the OAuth device
+// flow, where the user enters a code in a browser on any device. The token
+// is cached in store between runs.
+func DeviceClient(ctx context.Context, config *oauth2.Config, store TokenStore) (*http.Client, error) {
+	return oauthClient(ctx, config, store, tokenFromDevice)
+}
+
+// oauthClient returns an HTTP client using the token cached in store,
+// calling authorize to get a new one when there is no valid token.
+func oauthClient(ctx context.Context, config *oauth2.Config, store TokenStore, authorize func(context.Context, *oauth2.Config) (*oauth2.Token, error)) (*http.Client, error) {
+	tok, err := store.Load()
+	if err != nil && !errors.Is(err, ErrNoToken) {
+		return nil, err
+	}
+	if err == nil {
+		// Refreshing up front turns an expired or revoked refresh token into
+		// a new authorization rather than an opaque error on the first call.
+		tok, err = config.TokenSource(ctx, tok).Token()
+		if err != nil && !invalidGrant(err) {
+			return nil, fmt.Errorf("unable to refresh OAuth token: %w", err)
+		}
+		if err != nil {
+			slog.Warn("Stored OAuth token is no longer valid, authorizing again", "store", store.String())
+		}
+	}
+	if err != nil {
+		if !interactive() {
+			return nil, fmt.Errorf("no valid OAuth token in %s and no terminal to authorize from, run the command once interactively or use service account credentials", store)
+		}
+		tok, err = authorize(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := store.Save(tok); err != nil {
+		return nil, err
+	}
+
+	src := &savingTokenSource{
+		src:   oauth2.ReuseTokenSource(tok, config.TokenSource(ctx, tok)),
+		store: store,
+		last:  tok.AccessToken,
+	}
+	return oauth2.NewClient(ctx, src), nil
+}
+
+// invalidGrant reports whether a token refresh failed because the refresh
+// token expired or was revoked.
+func invalidGrant(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == "invalid_grant"
+}
+
+// interactive reports whether a user is around to complete the OAuth flow.
+func interactive() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// savingTokenSource stores the token every time it is refreshed, so the
+// refreshed token is used on the next run.
+type savingTokenSource struct {
+	src   oauth2.TokenSource
+	store TokenStore
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.AccessToken != s.last {
+		s.last = tok.AccessToken
+		if err := s.store.Save(tok); err != nil {
+			slog.Warn("Unable to save refreshed OAuth token", "error", err)
+		}
+	}
+	return tok, nil
+}
+
+// tokenFromWeb authorizes a new token through the browser, which is sent
+// back to a local server on a free port once authorized. The authorization
+// link is opened in the default browser when open is set, and printed in any
+// case. The server only lives until the code arrives or ctx is done.
+func tokenFromWeb(ctx context.Context, config *oauth2.Config, open bool) (*oauth2.Token, error) {
+	// Google accepts loopback redirects on any port for desktop clients.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to start OAuth callback server: %w", err)
+	}
+	cfg := *config
+	cfg.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d/callback", ln.Addr().(*net.TCPAddr).Port)
+	state, err := randomState()
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			writeCallbackPage(w, http.StatusBadRequest, "Authorization failed", "The request doesn't match the authorization started from the terminal, run the command again.")
+			return
+		}
+		if e := query.Get("error"); e != "" {
+			writeCallbackPage(w, http.StatusBadRequest, "Authorization failed", "Google answered "+e+". Go back to the terminal and run the command again.")
+			select {
+			case errCh <- fmt.Errorf("authorization failed: %s", e):
+			default:
+			}
+			return
+		}
+		writeCallbackPage(w, http.StatusOK, "Authorization completed", "You can close this window and go back to the terminal.")
+		select {
+		case codeCh <- query.Get("code"):
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	defer func() {
+		// The success page is still sent when the code arrives.
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Debug("Unable to shut down OAuth callback server", "error", err)
+		}
+	}()
+
+	authURL := cfg.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	// The link is printed regardless of the log level, as the user has to act on it.
+	fmt.Fprintf(os.Stderr, "Go to the following link in your browser: \n%v\n", authURL)
+	if open {
+		if err := openBrowser(authURL); err != nil {
+			slog.Debug("Unable to open the browser", "error", err)
+		}
+	}
+
+	var code string
+	select {
+	case code = <-codeCh:
+	case err := <-errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	tok, err := cfg.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from web: %w", err)
+	}
+	return tok, nil
+}
+
+// randomState returns an unguessable OAuth state, so the callback only
+// accepts the authorization started by the tool.
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate OAuth state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func tokenFromDevice(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	resp, err := config.DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start device authorization: %w", err)
+	}
+	// The code is printed regardless of the log level, as the user has to act on it.
+	fmt.Fprintf(os.Stderr, "Go to %s in your browser and enter the code %s\n", resp.VerificationURI, resp.UserCode)
+	tok, err := config.DeviceAccessToken(ctx, resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve token from device authorization: %w", err)
+	}
+	return tok, nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"html/template"
+	"net/http"
+	"os/exec"
+	"runtime"
+)
+
+// openBrowser opens url in the default browser of the user, without waiting
+// for it to be closed.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener once it exits, it usually hands over to the browser
+	// right away.
+	go cmd.Wait()
+	return nil
+}
+
+// callbackPage is shown in the browser once it is sent back to the OAuth
+// callback server, telling whether the authorization completed.
+var callbackPage = template.Must(template.New("callback").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+  body { margin: 0; min-height: 100vh; display: flex; align-items: center; justify-content: center; background: #f1f3f4; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; color: #202124; }
+  main { max-width: 28rem; padding: 2rem 2.5rem; background: #fff; border-radius: 8px; box-shadow: 0 1px 3px rgba(60, 64, 67, .3); text-align: center; }
+  .icon { font-size: 2.5rem; color: {{if .Failed}}#d93025{{else}}#188038{{end}}; }
+  h1 { font-size: 1.25rem; font-weight: 500; }
+  p { color: #5f6368; line-height: 1.5; }
+</style>
+</head>
+<body>
+<main>
+<div class="icon">{{if .Failed}}&#10007;{{else}}&#10003;{{end}}</div>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</main>
+</body>
+</html>
+`))
+
+// writeCallbackPage renders callbackPage with the given status, an error
+// page for any status but 200.
+func writeCallbackPage(w http.ResponseWriter, status int, title, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	callbackPage.Execute(w, struct {
+		Title, Message string
+		Failed         bool
+	}{title, message, status != http.StatusOK})
+}
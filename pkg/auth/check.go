@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/oauth2/google"
+)
+
+// CheckCredentials reports whether the credentials file of opts is readable
+// and well-formed for its type, without authorizing anything.
+func CheckCredentials(opts Options) error {
+	b, err := os.ReadFile(opts.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("unable to read credentials file: %w", err)
+	}
+	switch opts.Type {
+	case TypeOAuth:
+		if _, err := google.ConfigFromJSON(b); err != nil {
+			return fmt.Errorf("unable to parse client secret file to config: %w", err)
+		}
+	case TypeServiceAccount:
+		if _, err := google.JWTConfigFromJSON(b); err != nil {
+			return fmt.Errorf("unable to parse service account key file to config: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown credentials type %q, must be one of: %s, %s", opts.Type, TypeOAuth, TypeServiceAccount)
+	}
+	return nil
+}
+
+// CheckToken reports whether a valid token for the given scopes can be
+// obtained with opts without asking the user: the stored OAuth token is
+// refreshed, and saved again when it was, or the service account exchanges
+// its key for one.
+func CheckToken(ctx context.Context, opts Options, scopes ...string) error {
+	b, err := os.ReadFile(opts.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("unable to read credentials file: %w", err)
+	}
+	if opts.Type == TypeServiceAccount {
+		config, err := google.JWTConfigFromJSON(b, scopes...)
+		if err != nil {
+			return fmt.Errorf("unable to parse service account key file to config: %w", err)
+		}
+		config.Subject = opts.Impersonate
+		if _, err := config.TokenSource(ctx).Token(); err != nil {
+			return fmt.Errorf("unable to get a token for the service account: %w", err)
+		}
+		return nil
+	}
+
+	config, err := google.ConfigFromJSON(b, scopes...)
+	if err != nil {
+		return fmt.Errorf("unable to parse client secret file to config: %w", err)
+	}
+	tok, err := opts.TokenStore.Load()
+	if errors.Is(err, ErrNoToken) {
+		return fmt.Errorf("no OAuth token in %s", opts.TokenStore)
+	}
+	if err != nil {
+		return err
+	}
+	refreshed, err := config.TokenSource(ctx, tok).Token()
+	if err != nil {
+		return fmt.Errorf("unable to refresh OAuth token: %w", err)
+	}
+	if refreshed.AccessToken != tok.AccessToken {
+		return opts.TokenStore.Save(refreshed)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+)
+
+// Token stores selectable to keep OAuth tokens between runs.
+const (
+	// StoreFile keeps tokens as plain JSON files readable by the user only.
+	StoreFile = "file"
+	// StoreKeychain keeps tokens in the keychain of the OS: the macOS
+	// Keychain, the Secret Service on Linux or the Windows Credential
+	// Manager.
+	StoreKeychain = "keychain"
+	// StoreAge keeps tokens in files encrypted with age, with the passphrase
+	// given in PassphraseEnv.
+	StoreAge = "age"
+)
+
+// PassphraseEnv is the environment variable holding the passphrase of the
+// tokens encrypted with age.
+const PassphraseEnv = "TEAM_CALENDAR_TOKEN_PASSPHRASE"
+
+// keychainService is the service the tokens are stored under in the
+// keychain.
+const keychainService = "team-calendar"
+
+// ErrNoToken is returned when loading a token that was never stored.
+var ErrNoToken = errors.New("no token stored")
+
+// TokenStore keeps an OAuth token between runs.
+type TokenStore interface {
+	// Load returns the stored token, or an error wrapping ErrNoToken when
+	// there is none.
+	Load() (*oauth2.Token, error)
+	Save(tok *oauth2.Token) error
+	// Delete forgets the token, if any.
+	Delete() error
+	// String describes where the token is kept, for messages.
+	String() string
+}
+
+// NewTokenStore returns the store of the given kind for the token that
+// would be kept in the file at path, which keys it in the keychain and is
+// suffixed with .age when encrypted.
+func NewTokenStore(kind, path string) (TokenStore, error) {
+	switch kind {
+	case "", StoreFile:
+		return FileStore{Path: path}, nil
+	case StoreKeychain:
+		return KeychainStore{Key: path}, nil
+	case StoreAge:
+		return AgeStore{Path: path + ".age", Passphrase: os.Getenv(PassphraseEnv)}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q, must be one of: %s, %s, %s", kind, StoreFile, StoreKeychain, StoreAge)
+	}
+}
+
+// Migrate moves the token kept as a plain file at path into store, so
+// switching stores doesn't need authorizing again.
+func Migrate(path string, store TokenStore) error {
+	if fs, ok := store.(FileStore); ok && fs.Path == path {
+		return nil
+	}
+	from := FileStore{Path: path}
+	tok, err := from.Load()
+	if errors.Is(err, ErrNoToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := store.Load(); err == nil {
+		return nil
+	}
+	if err := store.Save(tok); err != nil {
+		return err
+	}
+	slog.Info("Moved OAuth token to the token store", "from", path, "to", store.String())
+	return from.Delete()
+}
+
+// FileStore keeps a token as a plain JSON file.
+type FileStore struct {
+	Path string
+}
+
+func (s FileStore) Load() (*oauth2.Token, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w in %s", ErrNoToken, s.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeToken(bytes.NewReader(b))
+}
+
+func (s FileStore) Save(tok *oauth2.Token) error {
+	b, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	return writePrivate(s.Path, b)
+}
+
+func (s FileStore) Delete() error {
+	if err := os.Remove(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to remove token file: %w", err)
+	}
+	return nil
+}
+
+func (s FileStore) String() string {
+	return s.Path
+}
+
+// KeychainStore keeps a token in the keychain of the OS.
+type KeychainStore struct {
+	Key string
+}
+
+func (s KeychainStore) Load() (*oauth2.Token, error) {
+	secret, err := keyring.Get(keychainService, s.Key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("%w in the keychain for %s", ErrNoToken, s.Key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token from the keychain: %w", err)
+	}
+	return decodeToken(bytes.NewReader([]byte(secret)))
+}
+
+func (s KeychainStore) Save(tok *oauth2.Token) error {
+	b, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(keychainService, s.Key, string(b)); err != nil {
+		return fmt.Errorf("unable to save token to the keychain: %w", err)
+	}
+	return nil
+}
+
+func (s KeychainStore) Delete() error {
+	if err := keyring.Delete(keychainService, s.Key); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("unable to remove token from the keychain: %w", err)
+	}
+	return nil
+}
+
+func (s KeychainStore) String() string {
+	return "keychain:" + s.Key
+}
+
+// AgeStore keeps a token in a file encrypted with age using a passphrase.
+type AgeStore struct {
+	Path       string
+	Passphrase string
+}
+
+func (s AgeStore) Load() (*oauth2.Token, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w in %s", ErrNoToken, s.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if s.Passphrase == "" {
+		return nil, fmt.Errorf("$%s must be set to decrypt %s", PassphraseEnv, s.Path)
+	}
+	identity, err := age.NewScryptIdentity(s.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(f, identity)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt %s: %w", s.Path, err)
+	}
+	return decodeToken(r)
+}
+
+func (s AgeStore) Save(tok *oauth2.Token) error {
+	if s.Passphrase == "" {
+		return fmt.Errorf("$%s must be set to encrypt %s", PassphraseEnv, s.Path)
+	}
+	recipient, err := age.NewScryptRecipient(s.Passphrase)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, recipient)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(tok); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return writePrivate(s.Path, b.Bytes())
+}
+
+func (s AgeStore) Delete() error {
+	return FileStore{Path: s.Path}.Delete()
+}
+
+func (s AgeStore) String() string {
+	return s.Path
+}
+
+func decodeToken(r io.Reader) (*oauth2.Token, error) {
+	tok := &oauth2.Token{}
+	if err := json.NewDecoder(r).Decode(tok); err != nil {
+		return nil, fmt.Errorf("unable to parse token: %w", err)
+	}
+	return tok, nil
+}
+
+// writePrivate writes a file readable by the current user only.
+func writePrivate(path string, b []byte) error {
+	slog.Debug("Saving credential file", "path", path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create token directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create token file: %w", err)
+	}
+	defer f.Close()
+	// Files created by older versions may be readable by others.
+	if err := f.Chmod(0o600); err != nil {
+		return fmt.Errorf("unable to restrict token file permissions: %w", err)
+	}
+	_, err = f.Write(b)
+	return err
+}
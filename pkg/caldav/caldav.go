@@ -0,0 +1,342 @@
+// Package caldav manages rotation events on CalDAV calendars, e.g. Nextcloud
+// or Radicale.
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"calendar/pkg/audit"
+	"calendar/pkg/ics"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+)
+
+// Client manages the rotation events of a CalDAV calendar collection. It
+// implements provider.CalendarProvider.
+type Client struct {
+	// URL is the URL of the calendar collection, e.g.
+	// https://cloud.example.com/remote.php/dav/calendars/me/rotations/.
+	URL      string
+	User     string
+	Password string
+	// HTTP is the client used for requests, http.DefaultClient when nil.
+	HTTP *http.Client
+	// Audit, when set, records every event created or deleted.
+	Audit *audit.Log
+}
+
+var _ provider.CalendarProvider = &Client{}
+
+// TimeZone returns the time zone of the calendar, UTC when it has none.
+func (c *Client) TimeZone(ctx context.Context) (string, error) {
+	body := `<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><C:calendar-timezone/></D:prop>
+</D:propfind>`
+	ms, err := c.multistatus(ctx, "PROPFIND", "0", body)
+	if err != nil {
+		return "", fmt.Errorf("unable to get calendar time zone: %w", err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			for _, line := range strings.Split(ps.Prop.CalendarTimezone, "\n") {
+				tz, ok := strings.CutPrefix(strings.TrimSpace(line), "TZID:")
+				if !ok {
+					continue
+				}
+				if _, err := time.LoadLocation(tz); err == nil {
+					return tz, nil
+				}
+			}
+		}
+	}
+	slog.Debug("Calendar has no IANA time zone, using UTC")
+	return "UTC", nil
+}
+
+// ManagedEvents returns the events created for the rotation with the given ID.
+func (c *Client) ManagedEvents(ctx context.Context, rotationID string) ([]provider.Event, error) {
+	events, err := c.events(ctx, func(e ics.VEvent) bool {
+		return e.Text(ics.PropertyRotationID) == rotationID
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events of rotation %s: %w", rotationID, err)
+	}
+	return events, nil
+}
+
+// RotationEvents returns the events of the named rotation, found by summary.
+func (c *Client) RotationEvents(ctx context.Context, name string) ([]provider.Event, error) {
+	prefix := rotation.Summary(name, "")
+	events, err := c.events(ctx, func(e ics.VEvent) bool {
+		return strings.HasPrefix(e.Text("SUMMARY"), prefix)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
+	}
+	return events, nil
+}
+
+// events returns the calendar objects whose event matches.
+func (c *Client) events(ctx context.Context, match func(ics.VEvent) bool) ([]provider.Event, error) {
+	ms, err := c.multistatus(ctx, "REPORT", "1", calendarQuery("", ""))
+	if err != nil {
+		return nil, err
+	}
+	var events []provider.Event
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			vevents, err := ics.ReadEvents(strings.NewReader(ps.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse %s: %w", r.Href, err)
+			}
+			// A calendar object holds a single event, along with the
+			// exceptions to its recurrence if any.
+			if len(vevents) == 0 || !match(vevents[0]) {
+				continue
+			}
+			start := vevents[0]["DTSTART"].Value
+			if t, err := vevents[0].Date("DTSTART", time.UTC); err == nil {
+				start = t.Format(time.DateOnly)
+			}
+			events = append(events, provider.Event{ID: r.Href, Summary: vevents[0].Text("SUMMARY"), Start: start})
+		}
+	}
+	return events, nil
+}
+
+// Sync makes the existing events of a rotation match the planned ones. The
+// existing events are deleted and the planned ones created again.
+func (c *Client) Sync(ctx context.Context, existing []provider.Event, planned []rotation.Event, opts provider.SyncOptions) ([]provider.Change, error) {
+	var changes []provider.Change
+	if opts.DryRun {
+		for _, e := range existing {
+			changes = append(changes, provider.Change{Action: "deleted", Summary: e.Summary})
+		}
+		for _, e := range planned {
+			changes = append(changes, provider.Change{Action: "created", Summary: e.Summary})
+		}
+		return changes, nil
+	}
+	var created []provider.Event
+	total := len(existing) + len(planned)
+	progress := func() {
+		if opts.Progress != nil {
+			opts.Progress(len(changes), total)
+		}
+	}
+	err := func() error {
+		for _, e := range existing {
+			if err := c.DeleteEvent(ctx, e); err != nil {
+				return err
+			}
+			changes = append(changes, provider.Change{Action: "deleted", Summary: e.Summary})
+			progress()
+		}
+		for _, e := range planned {
+			href, err := c.insertEvent(ctx, e)
+			if err != nil {
+				return err
+			}
+			created = append(created, provider.Event{ID: href, Summary: e.Summary})
+			changes = append(changes, provider.Change{Action: "created", Summary: e.Summary, Link: href})
+			progress()
+		}
+		return nil
+	}()
+	// Created events are also rolled back when the sync is interrupted.
+	if err == nil || (!opts.Atomic && ctx.Err() == nil) {
+		return changes, err
+	}
+
+	// The rollback must happen even when the sync was interrupted.
+	ctx = context.WithoutCancel(ctx)
+	for _, e := range created {
+		if rerr := c.DeleteEvent(ctx, e); rerr != nil {
+			return changes, fmt.Errorf("%w, and rolling back failed: %w", err, rerr)
+		}
+		changes = append(changes, provider.Change{Action: "rolled back", Summary: e.Summary})
+	}
+	return changes, err
+}
+
+// insertEvent stores the event as a new calendar object and returns its URL.
+func (c *Client) insertEvent(ctx context.Context, e rotation.Event) (string, error) {
+	var b bytes.Buffer
+	if err := ics.Write(&b, []rotation.Event{e}, time.Now()); err != nil {
+		return "", err
+	}
+	href := strings.TrimSuffix(c.URL, "/") + "/" + url.PathEscape(ics.UID(e)) + ".ics"
+	resp, err := c.do(ctx, http.MethodPut, href, "", "text/calendar; charset=utf-8", &b)
+	if err != nil {
+		return "", fmt.Errorf("unable to create event %q: %w", e.Summary, err)
+	}
+	resp.Body.Close()
+	c.record(audit.Entry{Action: audit.ActionCreated, EventID: href, Rotation: e.RotationID, Summary: e.Summary})
+	return href, nil
+}
+
+// DeleteEvent deletes a calendar object, including every occurrence of a
+// recurring event.
+func (c *Client) DeleteEvent(ctx context.Context, e provider.Event) error {
+	resp, err := c.do(ctx, http.MethodDelete, e.ID, "", "", nil)
+	if err != nil {
+		return fmt.Errorf("unable to delete event %s: %w", e.ID, err)
+	}
+	resp.Body.Close()
+	c.record(audit.Entry{Action: audit.ActionDeleted, EventID: e.ID, Summary: e.Summary})
+	return nil
+}
+
+// record adds a change to the audit log, if any.
+func (c *Client) record(e audit.Entry) {
+	if c.Audit == nil {
+		return
+	}
+	e.Provider, e.Calendar = provider.CalDAV, c.URL
+	if err := c.Audit.Record(e); err != nil {
+		slog.Error("Unable to record change in audit log", "event", e.EventID, "error", err)
+	}
+}
+
+// Slots returns the occurrences of every rotation overlapping the given time
+// range, expanded by the server.
+func (c *Client) Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	tz, err := c.TimeZone(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z")
+	ms, err := c.multistatus(ctx, "REPORT", "1", calendarQuery(start, end))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
+	}
+	var slots []rotation.Slot
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			vevents, err := ics.ReadEvents(strings.NewReader(ps.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse %s: %w", r.Href, err)
+			}
+			for _, e := range vevents {
+				// Rotations are made of all-day events.
+				if e["DTSTART"].Params["VALUE"] != "DATE" {
+					continue
+				}
+				name, member, ok := rotation.ParseSummary(e.Text("SUMMARY"))
+				if !ok {
+					continue
+				}
+				slotStart, err := e.Date("DTSTART", loc)
+				if err != nil {
+					return nil, err
+				}
+				slotEnd, err := e.Date("DTEND", loc)
+				if err != nil {
+					return nil, err
+				}
+				id := e.Text(ics.PropertyRotationID)
+				slot := rotation.Slot{Rotation: name, RotationID: id, Member: member, Start: slotStart, End: slotEnd, Managed: id != ""}
+				if slot.End.After(from) && slot.Start.Before(to) {
+					slots = append(slots, slot)
+				}
+			}
+		}
+	}
+	return slots, nil
+}
+
+// calendarQuery returns a calendar-query REPORT for events, expanded within
+// the given UTC time range when set.
+func calendarQuery(start, end string) string {
+	data, filter := "", ""
+	if start != "" {
+		data = fmt.Sprintf(`<C:expand start="%s" end="%s"/>`, start, end)
+		filter = fmt.Sprintf(`<C:time-range start="%s" end="%s"/>`, start, end)
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><C:calendar-data>%s</C:calendar-data></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">%s</C:comp-filter></C:comp-filter></C:filter>
+</C:calendar-query>`, data, filter)
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Prop struct {
+				CalendarData     string `xml:"calendar-data"`
+				CalendarTimezone string `xml:"calendar-timezone"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (c *Client) multistatus(ctx context.Context, method, depth, body string) (*multistatus, error) {
+	resp, err := c.do(ctx, method, c.URL, depth, "application/xml; charset=utf-8", strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("unable to parse %s response: %w", method, err)
+	}
+	return &ms, nil
+}
+
+// do sends a request to target, which can be relative to the calendar URL,
+// and returns the response when successful.
+func (c *Client) do(ctx context.Context, method, target, depth, contentType string, body io.Reader) (*http.Response, error) {
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CalDAV URL: %w", err)
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base.ResolveReference(ref).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s %s returned %s: %s", method, req.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
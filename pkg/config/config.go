@@ -0,0 +1,251 @@
+// Package config loads defaults for the command-line flags from a
+// configuration file, so common settings don't need to be typed every run.
+//
+// Every flag can be set in the file using its long name as the key, e.g.
+//
+//	calendar: team-roles
+//	credentials: /home/me/.config/team-calendar/credentials.json
+//	token: /home/me/.config/team-calendar/token.json
+//	timezone: Europe/Madrid
+//	roster: /home/me/.config/team-calendar/roster.yaml
+//	team-members: [Cesar, Seth, Juan]
+//	emails: [Cesar=cesar@example.com, Seth=seth@example.com]
+//	llm-backend: anthropic
+//
+// Flags given on the command line override the environment variables listed
+// in Env, which override the file, and the file overrides the flag defaults.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Dir returns the directory holding the configuration of the tool,
+// e.g. ~/.config/team-calendar.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the user configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "team-calendar"), nil
+}
+
+// DefaultProfile is the profile used when none is given, whose files are
+// kept in Dir itself.
+const DefaultProfile = "default"
+
+// ProfileDir returns the directory holding the credentials and tokens of a
+// profile, e.g. ~/.config/team-calendar/profiles/work, or Dir for the
+// default profile.
+func ProfileDir(profile string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	if profile == "" || profile == DefaultProfile {
+		return dir, nil
+	}
+	if profile != filepath.Base(profile) || profile == "." || profile == ".." {
+		return "", fmt.Errorf("invalid profile name %q", profile)
+	}
+	return filepath.Join(dir, "profiles", profile), nil
+}
+
+// Profiles returns the names of the profiles other than the default one,
+// sorted.
+func Profiles() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "profiles"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list profiles: %w", err)
+	}
+	var profiles []string
+	for _, e := range entries {
+		if e.IsDir() {
+			profiles = append(profiles, e.Name())
+		}
+	}
+	return profiles, nil
+}
+
+// Env maps the flags that can be set through the environment to their
+// variable, e.g. for cron jobs where passing flags is awkward.
+var Env = map[string]string{
+	"credentials":      "TEAM_CALENDAR_CREDENTIALS",
+	"token":            "TEAM_CALENDAR_TOKEN",
+	"github-token":     "GITHUB_TOKEN",
+	"confluence-token": "CONFLUENCE_TOKEN",
+	"profile":          "TEAM_CALENDAR_PROFILE",
+	"token-store":      "TEAM_CALENDAR_TOKEN_STORE",
+	"quota-project":    "GOOGLE_CLOUD_QUOTA_PROJECT",
+}
+
+// File returns the path of the named file in Dir. Older versions kept their
+// files in the working directory, so a file found there and not in Dir yet
+// is moved to Dir first.
+func File(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return path, nil
+	}
+	if info, err := os.Stat(name); err != nil || !info.Mode().IsRegular() {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("unable to create configuration directory: %w", err)
+	}
+	if err := move(name, path); err != nil {
+		return "", fmt.Errorf("unable to move %s to the configuration directory: %w", name, err)
+	}
+	slog.Info("Moved file to the configuration directory", "file", name, "path", path)
+	return path, nil
+}
+
+// move renames src to dst, copying it when they are on different file
+// systems. The copy is only readable by the user, as the files hold secrets.
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// Load reads the configuration file at path. When path is empty, a file named
+// config (with any extension supported by viper, e.g. yaml or toml) is looked
+// up in Dir, and it's not an error if there is none.
+func Load(path string) (*viper.Viper, error) {
+	v := viper.New()
+	for flag, env := range Env {
+		v.BindEnv(flag, env)
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		dir, err := Dir()
+		if err != nil {
+			return nil, err
+		}
+		v.SetConfigName("config")
+		v.AddConfigPath(dir)
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if path == "" && errors.As(err, &notFound) {
+			return v, nil
+		}
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+	return v, nil
+}
+
+// ApplyToFlags sets every flag that was not given on the command line to its
+// value in the configuration, if any.
+func ApplyToFlags(v *viper.Viper, flags *pflag.FlagSet) error {
+	var errs []error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || !v.IsSet(f.Name) {
+			return
+		}
+		var values []string
+		switch typ := f.Value.Type(); {
+		case typ == "stringArray":
+			// Every value is added on its own, as when repeating the flag.
+			values = v.GetStringSlice(f.Name)
+		case strings.HasSuffix(typ, "Slice"):
+			values = []string{strings.Join(v.GetStringSlice(f.Name), ",")}
+		case typ == "stringToString" || typ == "stringToInt":
+			// Maps keys are lowercased by viper, so a list of key=value
+			// entries is accepted too to preserve them as written.
+			pairs := v.GetStringSlice(f.Name)
+			if _, ok := v.Get(f.Name).([]any); !ok {
+				pairs = nil
+				for k, val := range v.GetStringMapString(f.Name) {
+					pairs = append(pairs, k+"="+val)
+				}
+			}
+			values = []string{strings.Join(pairs, ",")}
+		default:
+			values = []string{v.GetString(f.Name)}
+			// YAML turns unquoted dates into timestamps.
+			if t, ok := v.Get(f.Name).(time.Time); ok {
+				values = []string{t.Format(time.DateOnly)}
+			}
+		}
+		for _, value := range values {
+			if err := flags.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for %q in config file: %w", f.Name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Rotations returns the configuration of every rotation listed under the
+// rotations key, each of them using the flag names as keys, e.g.
+//
+//	rotations:
+//	  - event-name: SRE Role
+//	    team-members: [Cesar, Seth, Juan]
+//	    start-date: 2024-07-01
+//	    duration: 1
+func Rotations(v *viper.Viper) ([]*viper.Viper, error) {
+	if !v.IsSet("rotations") {
+		return nil, nil
+	}
+	entries, ok := v.Get("rotations").([]any)
+	if !ok {
+		return nil, fmt.Errorf("rotations in config file must be a list")
+	}
+	rotations := make([]*viper.Viper, 0, len(entries))
+	for i, entry := range entries {
+		m, ok := entry.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("rotation %d in config file must be a map", i+1)
+		}
+		sub := viper.New()
+		if err := sub.MergeConfigMap(m); err != nil {
+			return nil, fmt.Errorf("invalid rotation %d in config file: %w", i+1, err)
+		}
+		rotations = append(rotations, sub)
+	}
+	return rotations, nil
+}
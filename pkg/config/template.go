@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// templateExt is the extension of the template files.
+const templateExt = ".yaml"
+
+// templatePath returns the file of the named rotation template, e.g.
+// ~/.config/team-calendar/templates/weekly-interrupt.yaml.
+func templatePath(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid template name %q", name)
+	}
+	return filepath.Join(dir, "templates", name+templateExt), nil
+}
+
+// SaveTemplate saves the flags of a rotation as the named template, using
+// the flag names as keys like the rotations of the config file, e.g.
+//
+//	cadence: weekly
+//	handoff-day: monday
+//	roles: [primary, secondary]
+func SaveTemplate(name string, flags map[string]any) error {
+	path, err := templatePath(name)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(flags)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create templates directory: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("unable to save template %s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadTemplate reads the named template, to be applied to the flags with
+// ApplyToFlags.
+func LoadTemplate(name string) (*viper.Viper, error) {
+	path, err := templatePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no template named %s, see the saved ones with the template list command", name)
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read template %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// Templates returns the names of the saved templates, sorted.
+func Templates() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "templates"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list templates: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), templateExt); ok && !e.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
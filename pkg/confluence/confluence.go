@@ -0,0 +1,113 @@
+// Package confluence publishes pages to Confluence, so wiki pages showing the
+// rotations are updated along with the calendars.
+package confluence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client talks to the Confluence REST API.
+type Client struct {
+	// URL is the base URL of Confluence, e.g. https://example.atlassian.net/wiki
+	// for Confluence Cloud.
+	URL string
+	// User is the email of the user on Confluence Cloud, authenticating
+	// with Token as an API token. When empty, Token is a personal access
+	// token of Confluence Data Center.
+	User  string
+	Token string
+	// HTTP is the client used for requests, http.DefaultClient when nil.
+	HTTP *http.Client
+}
+
+// page is the part of a page read and written by the client.
+type page struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Version struct {
+		Number  int    `json:"number"`
+		Message string `json:"message,omitempty"`
+	} `json:"version"`
+	Body *body `json:"body,omitempty"`
+}
+
+type body struct {
+	Storage struct {
+		Value          string `json:"value"`
+		Representation string `json:"representation"`
+	} `json:"storage"`
+}
+
+// UpdatePage replaces the body of a page, given by its ID, with content in
+// the storage format, i.e. XHTML. The title is kept, and message describes
+// the new version in the page history.
+func (c *Client) UpdatePage(ctx context.Context, id, content, message string) error {
+	var current page
+	if err := c.do(ctx, http.MethodGet, "/rest/api/content/"+url.PathEscape(id)+"?expand=version", nil, &current); err != nil {
+		return fmt.Errorf("unable to get page %s: %w", id, err)
+	}
+
+	update := page{ID: id, Type: current.Type, Title: current.Title}
+	update.Version.Number = current.Version.Number + 1
+	update.Version.Message = message
+	update.Body = &body{}
+	update.Body.Storage.Value = content
+	update.Body.Storage.Representation = "storage"
+	if err := c.do(ctx, http.MethodPut, "/rest/api/content/"+url.PathEscape(id), update, nil); err != nil {
+		return fmt.Errorf("unable to update page %s: %w", id, err)
+	}
+	return nil
+}
+
+// do sends a request with a JSON body, if any, and decodes the JSON
+// response into out, if any.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var r io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Token)
+	} else if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("confluence returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
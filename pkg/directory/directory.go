@@ -0,0 +1,81 @@
+// Package directory reads group memberships from the Google Workspace
+// Directory, so rotations can follow the groups as people join and leave.
+package directory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admin "google.golang.org/api/admin/directory/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Scopes are the OAuth scopes needed to read the members of a group and
+// their names.
+var Scopes = []string{admin.AdminDirectoryGroupMemberReadonlyScope, admin.AdminDirectoryUserReadonlyScope}
+
+// Client reads groups through the Admin SDK Directory API.
+type Client struct {
+	srv *admin.Service
+}
+
+// Person is a member of a group.
+type Person struct {
+	// Name is the full name shown in the directory, or the part of the
+	// email before the @ for people outside of the domain.
+	Name  string
+	Email string
+}
+
+// New returns a Client using an already authorized HTTP client.
+func New(ctx context.Context, httpClient *http.Client) (*Client, error) {
+	srv, err := admin.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Directory client: %w", err)
+	}
+	return &Client{srv: srv}, nil
+}
+
+// GroupMembers returns the people in a group, given by its email, including
+// the members of nested groups. Suspended members are left out.
+func (c *Client) GroupMembers(ctx context.Context, group string) ([]Person, error) {
+	var people []Person
+	seen := make(map[string]bool)
+	err := c.srv.Members.List(group).IncludeDerivedMembership(true).Pages(ctx, func(page *admin.Members) error {
+		for _, m := range page.Members {
+			if m.Type != "USER" || m.Status == "SUSPENDED" || seen[m.Email] {
+				continue
+			}
+			seen[m.Email] = true
+			name, err := c.name(ctx, m.Email)
+			if err != nil {
+				return err
+			}
+			people = append(people, Person{Name: name, Email: m.Email})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list members of group %s: %w", group, err)
+	}
+	return people, nil
+}
+
+// name returns the full name of a user, falling back to their email for
+// people the directory doesn't know, e.g. external members.
+func (c *Client) name(ctx context.Context, email string) (string, error) {
+	user, err := c.srv.Users.Get(email).ViewType("domain_public").Fields("name/fullName").Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if err != nil && !(errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound) {
+		return "", fmt.Errorf("unable to get user %s: %w", email, err)
+	}
+	if err == nil && user.Name != nil && user.Name.FullName != "" {
+		return user.Name.FullName, nil
+	}
+	local, _, _ := strings.Cut(email, "@")
+	return local, nil
+}
@@ -0,0 +1,103 @@
+package gcal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Adopt brings recurring events created by hand under the management of the
+// tool as events of the named rotation, stamping them with the extended
+// properties of the events it creates, so later syncs update them in place
+// rather than recreating them. The member of an event is the one given in
+// members by event ID, or else the one named in its summary, e.g. Cesar for
+// "SRE Role: Cesar", and summaries are renamed after the rotation when they
+// aren't already. Events of the rotation already managed are left alone.
+func (c *Client) Adopt(ctx context.Context, calendarID, name string, eventIDs []string, members map[string]string) ([]Change, error) {
+	id := rotation.ID(name)
+	// Every event is checked before any is changed, so a typo in the last
+	// ID doesn't leave the rotation half adopted.
+	var adopted []*calendar.Event
+	for _, eventID := range eventIDs {
+		event, err := c.api.GetEvent(ctx, calendarID, eventID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get event %s: %w", eventID, err)
+		}
+		switch {
+		case event.RecurringEventId != "":
+			return nil, fmt.Errorf("event %s is an occurrence of event %s, give the ID of the recurring event instead", eventID, event.RecurringEventId)
+		case len(event.Recurrence) == 0:
+			return nil, fmt.Errorf("event %s (%q) isn't a recurring event", eventID, event.Summary)
+		case event.Start == nil || event.Start.Date == "":
+			return nil, fmt.Errorf("event %s (%q) isn't an all-day event", eventID, event.Summary)
+		}
+		if event.ExtendedProperties != nil {
+			switch managed := event.ExtendedProperties.Private[PropertyRotationID]; managed {
+			case "":
+			case id:
+				continue
+			default:
+				return nil, fmt.Errorf("event %s (%q) is managed already as part of rotation %s", eventID, event.Summary, managed)
+			}
+		}
+		member := members[eventID]
+		if member == "" {
+			_, member, _ = rotation.ParseSummary(event.Summary)
+		}
+		if member == "" {
+			return nil, fmt.Errorf("unable to tell the member of event %s from its summary %q, give it explicitly", eventID, event.Summary)
+		}
+
+		event.Summary = rotation.Summary(name, member)
+		if event.ExtendedProperties == nil {
+			event.ExtendedProperties = &calendar.EventExtendedProperties{}
+		}
+		if event.ExtendedProperties.Private == nil {
+			event.ExtendedProperties.Private = make(map[string]string)
+		}
+		event.ExtendedProperties.Private[PropertyManagedBy] = ManagedBy
+		event.ExtendedProperties.Private[PropertyRotationID] = id
+		event.ExtendedProperties.Private[PropertyRotation] = name
+		event.ExtendedProperties.Private[PropertyMember] = member
+		adopted = append(adopted, event)
+	}
+
+	var changes []Change
+	for _, event := range adopted {
+		if c.DryRun {
+			changes = append(changes, Change{Action: "adopted", Summary: event.Summary})
+			continue
+		}
+		updated, err := c.UpdateEvent(ctx, calendarID, event)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, Change{Action: "adopted", Summary: updated.Summary, Link: updated.HtmlLink})
+	}
+	return changes, nil
+}
+
+// EventID returns the ID of the event given either as an ID or as its link,
+// e.g. https://www.google.com/calendar/event?eid=..., whose eid encodes the
+// ID along with the calendar.
+func EventID(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Query().Get("eid") == "" {
+		return s
+	}
+	eid := strings.TrimRight(u.Query().Get("eid"), "=")
+	decoded, err := base64.RawURLEncoding.DecodeString(eid)
+	if err != nil {
+		if decoded, err = base64.RawStdEncoding.DecodeString(eid); err != nil {
+			return s
+		}
+	}
+	id, _, _ := strings.Cut(string(decoded), " ")
+	return id
+}
@@ -0,0 +1,187 @@
+package gcal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// API is the subset of the Google Calendar API used by Client, so it can be
+// replaced, e.g. by the in-memory fake of package gcaltest.
+type API interface {
+	// ListCalendars returns the calendars of the user's calendar list.
+	ListCalendars(ctx context.Context) ([]*calendar.CalendarListEntry, error)
+	// GetCalendar returns the metadata of a calendar.
+	GetCalendar(ctx context.Context, calendarID string) (*calendar.Calendar, error)
+	// GetColors returns the colors events can be given.
+	GetColors(ctx context.Context) (*calendar.Colors, error)
+	// ListEvents returns the events of a calendar matching the query.
+	ListEvents(ctx context.Context, calendarID string, q EventQuery) ([]*calendar.Event, error)
+	// GetEvent returns an event as currently stored.
+	GetEvent(ctx context.Context, calendarID, eventID string) (*calendar.Event, error)
+	// InsertEvent creates an event and returns it as stored.
+	InsertEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error)
+	// UpdateEvent replaces an event and returns it as stored. Events with
+	// an Etag are only replaced if unchanged since, failing with 412
+	// Precondition Failed otherwise.
+	UpdateEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error)
+	// DeleteEvent deletes an event, including every occurrence of a series,
+	// only if its etag is still etag when set.
+	DeleteEvent(ctx context.Context, calendarID, eventID, etag, sendUpdates string) error
+	// WatchEvents opens a channel notifying of the changes to the events of
+	// a calendar, and returns it as opened.
+	WatchEvents(ctx context.Context, calendarID string, channel *calendar.Channel) (*calendar.Channel, error)
+	// StopChannel stops the notifications of a channel.
+	StopChannel(ctx context.Context, channel *calendar.Channel) error
+}
+
+// EventQuery filters the events returned by API.ListEvents. Zero fields
+// don't filter anything.
+type EventQuery struct {
+	// Text is a free text search of the events.
+	Text string
+	// PrivateExtendedProperty restricts the events to the ones with the
+	// given private property, written as name=value.
+	PrivateExtendedProperty string
+	// SingleEvents expands recurring events into their occurrences, ordered
+	// by start time.
+	SingleEvents bool
+	// RecurringEventID returns the occurrences of that recurring event
+	// rather than the events of the calendar.
+	RecurringEventID string
+	// TimeMin and TimeMax restrict the events to the ones overlapping the
+	// time range.
+	TimeMin, TimeMax time.Time
+	// MaxResults is the number of events returned at most.
+	MaxResults int
+	// EventTypes restricts the events to the given types, e.g.
+	// outOfOffice.
+	EventTypes []string
+	// ShowDeleted includes cancelled events, and cancelled occurrences of
+	// recurring events unless expanded.
+	ShowDeleted bool
+}
+
+// service implements API with the Google Calendar API.
+type service struct {
+	srv *calendar.Service
+}
+
+func (s *service) ListCalendars(ctx context.Context) ([]*calendar.CalendarListEntry, error) {
+	var calendars []*calendar.CalendarListEntry
+	err := s.srv.CalendarList.List().Pages(ctx, func(page *calendar.CalendarList) error {
+		calendars = append(calendars, page.Items...)
+		return nil
+	})
+	return calendars, err
+}
+
+func (s *service) GetCalendar(ctx context.Context, calendarID string) (*calendar.Calendar, error) {
+	return s.srv.Calendars.Get(calendarID).Context(ctx).Do()
+}
+
+func (s *service) GetColors(ctx context.Context) (*calendar.Colors, error) {
+	return s.srv.Colors.Get().Context(ctx).Do()
+}
+
+func (s *service) ListEvents(ctx context.Context, calendarID string, q EventQuery) ([]*calendar.Event, error) {
+	var events []*calendar.Event
+	collect := func(items []*calendar.Event) error {
+		events = append(events, items...)
+		if q.MaxResults > 0 && len(events) >= q.MaxResults {
+			events = events[:q.MaxResults]
+			return errDone
+		}
+		return nil
+	}
+
+	var err error
+	if q.RecurringEventID != "" {
+		call := s.srv.Events.Instances(calendarID, q.RecurringEventID)
+		if !q.TimeMin.IsZero() {
+			call = call.TimeMin(q.TimeMin.Format(time.RFC3339))
+		}
+		if !q.TimeMax.IsZero() {
+			call = call.TimeMax(q.TimeMax.Format(time.RFC3339))
+		}
+		if q.MaxResults > 0 {
+			call = call.MaxResults(int64(q.MaxResults))
+		}
+		err = call.Pages(ctx, func(page *calendar.Events) error { return collect(page.Items) })
+	} else {
+		call := s.srv.Events.List(calendarID).ShowDeleted(q.ShowDeleted)
+		if q.Text != "" {
+			call = call.Q(q.Text)
+		}
+		if q.PrivateExtendedProperty != "" {
+			call = call.PrivateExtendedProperty(q.PrivateExtendedProperty)
+		}
+		if q.SingleEvents {
+			call = call.SingleEvents(true).OrderBy("startTime")
+		}
+		if len(q.EventTypes) > 0 {
+			call = call.EventTypes(q.EventTypes...)
+		}
+		if !q.TimeMin.IsZero() {
+			call = call.TimeMin(q.TimeMin.Format(time.RFC3339))
+		}
+		if !q.TimeMax.IsZero() {
+			call = call.TimeMax(q.TimeMax.Format(time.RFC3339))
+		}
+		if q.MaxResults > 0 {
+			call = call.MaxResults(int64(q.MaxResults))
+		}
+		err = call.Pages(ctx, func(page *calendar.Events) error { return collect(page.Items) })
+	}
+	if err == errDone {
+		err = nil
+	}
+	return events, err
+}
+
+func (s *service) GetEvent(ctx context.Context, calendarID, eventID string) (*calendar.Event, error) {
+	return s.srv.Events.Get(calendarID, eventID).Context(ctx).Do()
+}
+
+func (s *service) InsertEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	call := s.srv.Events.Insert(calendarID, event)
+	if sendUpdates != "" {
+		call = call.SendUpdates(sendUpdates)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (s *service) UpdateEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	call := s.srv.Events.Update(calendarID, event.Id, event)
+	if sendUpdates != "" {
+		call = call.SendUpdates(sendUpdates)
+	}
+	if event.Etag != "" {
+		call.Header().Set("If-Match", event.Etag)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (s *service) DeleteEvent(ctx context.Context, calendarID, eventID, etag, sendUpdates string) error {
+	call := s.srv.Events.Delete(calendarID, eventID)
+	if sendUpdates != "" {
+		call = call.SendUpdates(sendUpdates)
+	}
+	if etag != "" {
+		call.Header().Set("If-Match", etag)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (s *service) WatchEvents(ctx context.Context, calendarID string, channel *calendar.Channel) (*calendar.Channel, error) {
+	return s.srv.Events.Watch(calendarID, channel).Context(ctx).Do()
+}
+
+func (s *service) StopChannel(ctx context.Context, channel *calendar.Channel) error {
+	return s.srv.Channels.Stop(channel).Context(ctx).Do()
+}
+
+// errDone stops paging once enough events were collected.
+var errDone = errors.New("done")
@@ -0,0 +1,64 @@
+package gcal
+
+import (
+	"context"
+	"log/slog"
+
+	"calendar/pkg/audit"
+	"calendar/pkg/provider"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Audit records every event the client creates, updates or deletes in log
+// from now on.
+func (c *Client) Audit(log *audit.Log) {
+	c.api = &auditedAPI{API: c.api, log: log}
+}
+
+// auditedAPI records the changes made through API in an audit log. Failing
+// to record a change doesn't fail it, as it has been made already.
+type auditedAPI struct {
+	API
+	log *audit.Log
+}
+
+func (a *auditedAPI) InsertEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	created, err := a.API.InsertEvent(ctx, calendarID, event, sendUpdates)
+	if err == nil {
+		a.record(calendarID, audit.ActionCreated, created)
+	}
+	return created, err
+}
+
+func (a *auditedAPI) UpdateEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	updated, err := a.API.UpdateEvent(ctx, calendarID, event, sendUpdates)
+	if err == nil {
+		a.record(calendarID, audit.ActionUpdated, updated)
+	}
+	return updated, err
+}
+
+func (a *auditedAPI) DeleteEvent(ctx context.Context, calendarID, eventID, etag, sendUpdates string) error {
+	err := a.API.DeleteEvent(ctx, calendarID, eventID, etag, sendUpdates)
+	if err == nil {
+		a.record(calendarID, audit.ActionDeleted, &calendar.Event{Id: eventID})
+	}
+	return err
+}
+
+func (a *auditedAPI) record(calendarID, action string, event *calendar.Event) {
+	e := audit.Entry{
+		Provider: provider.Google,
+		Calendar: calendarID,
+		Action:   action,
+		EventID:  event.Id,
+		Summary:  event.Summary,
+	}
+	if p := event.ExtendedProperties; p != nil {
+		e.Rotation = p.Private[PropertyRotationID]
+	}
+	if err := a.log.Record(e); err != nil {
+		slog.Error("Unable to record change in audit log", "event", event.Id, "error", err)
+	}
+}
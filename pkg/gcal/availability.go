@@ -0,0 +1,37 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// OutOfOffice returns the out-of-office periods found in the primary
+// calendar of a user, given by email, over the given time range. Periods
+// are whole days of loc, including the ones only partly out of office.
+func (c *Client) OutOfOffice(ctx context.Context, email string, from, to time.Time, loc *time.Location) ([]rotation.Unavailability, error) {
+	events, err := c.api.ListEvents(ctx, email, EventQuery{EventTypes: []string{"outOfOffice"}, SingleEvents: true, TimeMin: from, TimeMax: to})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list out-of-office events of %s: %w", email, err)
+	}
+	var periods []rotation.Unavailability
+	for _, event := range events {
+		start, err := ParseEventDateTime(event.Start, loc)
+		if err != nil {
+			return nil, err
+		}
+		end, err := ParseEventDateTime(event.End, loc)
+		if err != nil {
+			return nil, err
+		}
+		// Events end right after their last day.
+		last := end.In(loc).Add(-time.Nanosecond)
+		periods = append(periods, rotation.Unavailability{
+			From: rotation.InLocation(start.In(loc), loc),
+			To:   rotation.InLocation(last, loc),
+		})
+	}
+	return periods, nil
+}
@@ -0,0 +1,76 @@
+package gcal
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxLoggedBody is the size after which request and response bodies are
+// truncated in debug logs.
+const maxLoggedBody = 4096
+
+// WithDebugLogging returns a copy of the HTTP client logging every request
+// and response, bodies included, when the logger has debug enabled. Headers
+// are never logged as they hold the credentials, but for the quota and rate
+// limit ones of responses, to diagnose quota errors.
+func WithDebugLogging(client *http.Client, logger *slog.Logger) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &debugTransport{base: base, logger: logger}
+	return &c
+}
+
+type debugTransport struct {
+	base   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !t.logger.Enabled(ctx, slog.LevelDebug) {
+		return t.base.RoundTrip(req)
+	}
+
+	attrs := []any{"method", req.Method, "url", req.URL.String()}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			attrs = append(attrs, "body", readTruncated(body))
+		}
+	}
+	t.logger.DebugContext(ctx, "API request", attrs...)
+
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.logger.DebugContext(ctx, "API request failed", "method", req.Method, "url", req.URL.String(), "error", err)
+		return nil, err
+	}
+
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return res, nil
+	}
+	if len(b) > maxLoggedBody {
+		b = b[:maxLoggedBody]
+	}
+	attrs = []any{"method", req.Method, "url", req.URL.String(), "status", res.StatusCode, "duration", time.Since(start), "body", string(b)}
+	if quota := quotaHeaders(res.Header); len(quota) > 0 {
+		attrs = append(attrs, slog.Group("quota", quota...))
+	}
+	t.logger.DebugContext(ctx, "API response", attrs...)
+	return res, nil
+}
+
+func readTruncated(r io.ReadCloser) string {
+	defer r.Close()
+	b, _ := io.ReadAll(io.LimitReader(r, maxLoggedBody))
+	return string(b)
+}
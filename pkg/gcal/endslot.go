@@ -0,0 +1,43 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// EndSlot ends the slot of the named rotation covering at, at that time, so
+// someone else can take over from then. The occurrence is shortened, or
+// cancelled when it starts at that time, leaving the recurring series
+// untouched. All-day slots end on the day of at. The slot is returned as it
+// was before being ended.
+func (c *Client) EndSlot(ctx context.Context, calendarID, name string, at time.Time) (*rotation.Slot, error) {
+	occurrences, err := c.occurrences(ctx, calendarID, at, at.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range occurrences {
+		if o.slot.Rotation != name || !o.slot.Covers(at) {
+			continue
+		}
+		end := at
+		if o.event.Start.Date != "" {
+			end = rotation.InLocation(at, o.slot.Start.Location())
+		}
+		if !end.After(o.slot.Start) {
+			// Deleting an instance only cancels that occurrence.
+			if err := c.DeleteEvent(ctx, calendarID, o.event.Id); err != nil {
+				return nil, err
+			}
+			return &o.slot, nil
+		}
+		setEventDates(o.event, o.slot.Start, end)
+		if _, err := c.UpdateEvent(ctx, calendarID, o.event); err != nil {
+			return nil, err
+		}
+		return &o.slot, nil
+	}
+	return nil, fmt.Errorf("nobody is on rotation %q at %s", name, at.Format(time.DateTime))
+}
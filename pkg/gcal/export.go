@@ -0,0 +1,143 @@
+package gcal
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Export returns the events of a rotation as they are in the calendar.
+// Occurrences of recurring events modified on their own, e.g. swapped, are
+// excluded from their series and exported as single events, and cancelled
+// ones are only excluded.
+func (c *Client) Export(ctx context.Context, calendarID, rotationID string) ([]rotation.ExportedEvent, error) {
+	tz, err := c.TimeZone(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.ManagedEvents(ctx, calendarID, rotationID)
+	if err != nil {
+		return nil, err
+	}
+	series := make(map[string]bool)
+	for _, event := range events {
+		if len(event.Recurrence) > 0 {
+			series[event.Id] = true
+		}
+	}
+	if len(series) > 0 {
+		// Cancelled occurrences carry none of the properties of their
+		// series, so they are looked up among all the events.
+		all, err := c.api.ListEvents(ctx, calendarID, EventQuery{ShowDeleted: true})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list events of rotation %s: %w", rotationID, err)
+		}
+		for _, event := range all {
+			if series[event.RecurringEventId] {
+				events = append(events, event)
+			}
+		}
+	}
+
+	var exported []rotation.ExportedEvent
+	exdates := make(map[string][]*calendar.EventDateTime)
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if seen[event.Id] {
+			continue
+		}
+		seen[event.Id] = true
+		if event.RecurringEventId != "" && event.OriginalStartTime != nil {
+			exdates[event.RecurringEventId] = append(exdates[event.RecurringEventId], event.OriginalStartTime)
+		}
+		if event.Status == "cancelled" || event.Start == nil {
+			continue
+		}
+		exported = append(exported, exportedEvent(event, tz))
+	}
+	for i, e := range exported {
+		if starts, ok := exdates[e.ID]; ok {
+			exdate, err := exdateOf(starts, e.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("event %s: %w", e.ID, err)
+			}
+			exported[i].Recurrence = append(exported[i].Recurrence, exdate)
+		}
+	}
+	return exported, nil
+}
+
+// exdateOf returns the EXDATE excluding the occurrences starting at starts
+// from a recurring event in time zone tz.
+func exdateOf(starts []*calendar.EventDateTime, tz string) (string, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return "", fmt.Errorf("unknown time zone: %w", err)
+	}
+	var dates, times []string
+	for _, start := range starts {
+		if start.Date != "" {
+			dates = append(dates, strings.ReplaceAll(start.Date, "-", ""))
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, start.DateTime)
+		if err != nil {
+			return "", fmt.Errorf("invalid occurrence start: %w", err)
+		}
+		times = append(times, t.In(loc).Format("20060102T150405"))
+	}
+	if len(times) > 0 {
+		return "EXDATE;TZID=" + tz + ":" + strings.Join(times, ","), nil
+	}
+	return "EXDATE;VALUE=DATE:" + strings.Join(dates, ","), nil
+}
+
+// exportedEvent returns the export of an event, in time zone tz unless it
+// has its own.
+func exportedEvent(event *calendar.Event, tz string) rotation.ExportedEvent {
+	e := rotation.ExportedEvent{
+		ID:              event.Id,
+		Summary:         event.Summary,
+		Start:           cmp.Or(event.Start.Date, event.Start.DateTime),
+		ColorID:         event.ColorId,
+		TimeZone:        tz,
+		Description:     event.Description,
+		GuestsCanModify: event.GuestsCanModify,
+	}
+	if event.End != nil {
+		e.End = cmp.Or(event.End.Date, event.End.DateTime)
+	}
+	if event.Start.TimeZone != "" {
+		e.TimeZone = event.Start.TimeZone
+	}
+	if event.RecurringEventId == "" {
+		e.Recurrence = event.Recurrence
+	}
+	if event.ExtendedProperties != nil {
+		e.Member = event.ExtendedProperties.Private[PropertyMember]
+	}
+	for _, a := range event.Attendees {
+		e.Attendees = append(e.Attendees, rotation.Attendee{Email: a.Email, Optional: a.Optional})
+	}
+	if event.Reminders != nil && !event.Reminders.UseDefault {
+		for _, r := range event.Reminders.Overrides {
+			e.Reminders = append(e.Reminders, rotation.Reminder{Method: r.Method, Minutes: int(r.Minutes)})
+		}
+	}
+	if event.Visibility != "default" {
+		e.Visibility = event.Visibility
+	}
+	switch event.Transparency {
+	case "transparent":
+		e.Transparency = rotation.TransparencyFree
+	case "opaque":
+		e.Transparency = rotation.TransparencyBusy
+	}
+	return e
+}
@@ -0,0 +1,84 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Extend makes the recurring events of a finite rotation repeat until the
+// given day, the last one a slot can start on. Every series keeps its member,
+// color and place in the cycle, so the rotation carries on seamlessly.
+// Series repeating forever are left alone, as are single events, and
+// unavailabilities after the previous end are not taken into account.
+func (c *Client) Extend(ctx context.Context, calendarID string, existing []*calendar.Event, until time.Time) ([]Change, error) {
+	var changes []Change
+	for _, event := range existing {
+		if !finite(event.Recurrence) {
+			continue
+		}
+		end, err := c.until(ctx, calendarID, event, until)
+		if err != nil {
+			return changes, err
+		}
+		recurrence := endRecurrence(event.Recurrence, end)
+		if slices.Equal(recurrence, event.Recurrence) {
+			continue
+		}
+		event.Recurrence = recurrence
+		event, err := c.UpdateEvent(ctx, calendarID, event)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, Change{Action: "updated", Summary: event.Summary, Link: event.HtmlLink})
+	}
+	return changes, nil
+}
+
+// Finite reports whether any of the events is a series ending with an UNTIL
+// or a COUNT, which Extend can make repeat for longer.
+func Finite(events []*calendar.Event) bool {
+	return slices.ContainsFunc(events, func(e *calendar.Event) bool { return finite(e.Recurrence) })
+}
+
+// finite reports whether the RRULE of a recurrence ends.
+func finite(recurrence []string) bool {
+	for _, line := range recurrence {
+		if rule, ok := strings.CutPrefix(line, "RRULE:"); ok {
+			return strings.Contains(rule, "UNTIL=") || strings.Contains(rule, "COUNT=")
+		}
+	}
+	return false
+}
+
+// until returns the UNTIL part ending a series with the slot starting on
+// the given day, in the form the event's start calls for.
+func (c *Client) until(ctx context.Context, calendarID string, event *calendar.Event, day time.Time) (string, error) {
+	switch {
+	case slices.ContainsFunc(event.Recurrence, func(line string) bool { return strings.Contains(line, "BYDAY=MO,TU,WE,TH,FR") }):
+		// Occurrences of weekday-only rotations are days of slots starting
+		// on Mondays, so the series ends on the Friday of that week.
+		weekday := (int(day.Weekday()) + 6) % 7
+		return "UNTIL=" + day.AddDate(0, 0, 4-weekday).Format("20060102"), nil
+	case event.Start != nil && event.Start.DateTime != "":
+		// UNTIL is a UTC time for events that aren't all-day, any time of
+		// the day in the time zone of the event.
+		loc, err := c.location(ctx, calendarID)
+		if err != nil {
+			return "", err
+		}
+		if event.Start.TimeZone != "" {
+			if loc, err = time.LoadLocation(event.Start.TimeZone); err != nil {
+				return "", fmt.Errorf("event %q has an invalid time zone: %w", event.Summary, err)
+			}
+		}
+		end := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc).Add(-time.Second)
+		return "UNTIL=" + end.UTC().Format("20060102T150405Z"), nil
+	default:
+		return "UNTIL=" + day.Format("20060102"), nil
+	}
+}
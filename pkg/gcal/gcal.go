@@ -0,0 +1,345 @@
+// Package gcal manages rotation events on Google Calendar.
+package gcal
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// Scope is the OAuth scope needed to manage rotation events.
+const Scope = calendar.CalendarScope
+
+// Client manages rotation events through the Google Calendar API.
+type Client struct {
+	// SendUpdates controls which guests are notified about changes to the
+	// events: all, externalOnly or none. Empty leaves the API default.
+	SendUpdates string
+	// Concurrency is the number of requests sent at once when syncing, one
+	// when unset.
+	Concurrency int
+	// Atomic deletes the events created by a failed sync, so it doesn't
+	// leave a partial rotation behind.
+	Atomic bool
+	// DryRun makes Sync return the changes it would make without making
+	// them.
+	DryRun bool
+	// Progress, when set, is called by Sync every time a planned or stale
+	// event has been handled, with the number handled so far and the total.
+	Progress func(done, total int)
+
+	api API
+	// locks takes the lease on the calendars changed, when locking them.
+	locks *lockedAPI
+}
+
+// New returns a Client using an already authorized HTTP client.
+func New(ctx context.Context, httpClient *http.Client) (*Client, error) {
+	srv, err := calendar.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Calendar client: %w", err)
+	}
+	return &Client{api: &service{srv: srv}}, nil
+}
+
+// NewWithAPI returns a Client sending its requests to api, e.g. a fake.
+func NewWithAPI(api API) *Client {
+	return &Client{api: api}
+}
+
+// Calendars returns the calendars in the user's calendar list.
+func (c *Client) Calendars(ctx context.Context) ([]*calendar.CalendarListEntry, error) {
+	calendars, err := c.api.ListCalendars(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list calendars: %w", err)
+	}
+	return calendars, nil
+}
+
+// CalendarID returns the ID of the calendar matching the given name, which
+// can be either the calendar summary or its ID.
+func (c *Client) CalendarID(ctx context.Context, name string) (string, error) {
+	// "primary" is an alias understood by the API for the user's main calendar.
+	if name == "primary" {
+		return name, nil
+	}
+
+	var id string
+	var available []string
+	calendars, err := c.api.ListCalendars(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to list calendars: %w", err)
+	}
+	for _, v := range calendars {
+		if id == "" && (v.Id == name || v.Summary == name) {
+			id = v.Id
+		}
+		available = append(available, fmt.Sprintf("%q (%s)", v.Summary, v.Id))
+	}
+	if id == "" {
+		return "", fmt.Errorf("calendar %q not found, available calendars are:\n  %s", name, strings.Join(available, "\n  "))
+	}
+	return id, nil
+}
+
+// TimeZone returns the time zone of a calendar.
+func (c *Client) TimeZone(ctx context.Context, calendarID string) (string, error) {
+	cal, err := c.api.GetCalendar(ctx, calendarID)
+	if err != nil {
+		return "", fmt.Errorf("unable to get calendar %s: %w", calendarID, err)
+	}
+	return cal.TimeZone, nil
+}
+
+// location returns the location of a calendar's time zone.
+func (c *Client) location(ctx context.Context, calendarID string) (*time.Location, error) {
+	tz, err := c.TimeZone(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("calendar %s has an unknown time zone: %w", calendarID, err)
+	}
+	return loc, nil
+}
+
+// Private extended properties set on the events of a rotation, so they can
+// be found again regardless of how they are renamed, and told apart from the
+// events created by people.
+const (
+	PropertyRotationID = "rotationId"
+	PropertyMember     = "member"
+	PropertyManagedBy  = "managedBy"
+	PropertyRotation   = "rotation"
+	PropertySlotIndex  = "slotIndex"
+)
+
+// ManagedBy is the value of PropertyManagedBy on the events created by this
+// tool.
+const ManagedBy = "team-calendar"
+
+// newEvent returns the Calendar event for a rotation event.
+func newEvent(e rotation.Event) *calendar.Event {
+	event := &calendar.Event{
+		Summary:         e.Summary,
+		Start:           eventDateTime(e.Start, e.Timed, e.TimeZone),
+		End:             eventDateTime(e.End, e.Timed, e.TimeZone),
+		Recurrence:      e.Recurrence,
+		ColorId:         e.ColorID,
+		Description:     e.Description,
+		Visibility:      e.Visibility,
+		GuestsCanModify: e.GuestsCanModify,
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{
+				PropertyManagedBy:  ManagedBy,
+				PropertyRotationID: e.RotationID,
+				PropertyRotation:   e.Rotation,
+				PropertyMember:     e.Member,
+			},
+		},
+	}
+	if e.Slot >= 0 {
+		event.ExtendedProperties.Private[PropertySlotIndex] = strconv.Itoa(e.Slot)
+	}
+	switch e.Transparency {
+	case rotation.TransparencyBusy:
+		event.Transparency = "opaque"
+	case rotation.TransparencyFree:
+		event.Transparency = "transparent"
+	}
+	for _, a := range e.Attendees {
+		event.Attendees = append(event.Attendees, &calendar.EventAttendee{Email: a.Email, Optional: a.Optional})
+	}
+	if len(e.Reminders) > 0 {
+		// UseDefault must be sent even though false, or the overrides are
+		// rejected.
+		event.Reminders = &calendar.EventReminders{ForceSendFields: []string{"UseDefault"}}
+		for _, r := range e.Reminders {
+			event.Reminders.Overrides = append(event.Reminders.Overrides, &calendar.EventReminder{Method: r.Method, Minutes: int64(r.Minutes), ForceSendFields: []string{"Minutes"}})
+		}
+	}
+	return event
+}
+
+// InsertEvent creates a planned event of a rotation member, recurring or
+// single and lasting whole days or timed. Sync uses it for new events.
+func (c *Client) InsertEvent(ctx context.Context, calendarID string, e rotation.Event) (*calendar.Event, error) {
+	event, err := c.api.InsertEvent(ctx, calendarID, newEvent(e), c.SendUpdates)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create event %q: %w", e.Summary, err)
+	}
+	return event, nil
+}
+
+// RotationEvents returns the events of the named rotation. Recurring events
+// are returned as a single series rather than as individual occurrences.
+func (c *Client) RotationEvents(ctx context.Context, calendarID, name string) ([]*calendar.Event, error) {
+	found, err := c.api.ListEvents(ctx, calendarID, EventQuery{Text: name})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
+	}
+	var events []*calendar.Event
+	prefix := rotation.Summary(name, "")
+	for _, event := range found {
+		if strings.HasPrefix(event.Summary, prefix) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// DeleteEvent deletes an event, including every occurrence of a series.
+func (c *Client) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	if err := c.api.DeleteEvent(ctx, calendarID, eventID, "", c.SendUpdates); err != nil {
+		return fmt.Errorf("unable to delete event %s: %w", eventID, err)
+	}
+	return nil
+}
+
+// Slots returns the occurrences of every rotation overlapping the given time range.
+func (c *Client) Slots(ctx context.Context, calendarID string, from, to time.Time) ([]rotation.Slot, error) {
+	occurrences, err := c.occurrences(ctx, calendarID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]rotation.Slot, 0, len(occurrences))
+	for _, o := range occurrences {
+		slots = append(slots, o.slot)
+	}
+	return slots, nil
+}
+
+// occurrence is a single rotation event, either an instance of a recurring
+// event or a standalone event.
+type occurrence struct {
+	event *calendar.Event
+	slot  rotation.Slot
+}
+
+// occurrences returns the rotation events overlapping the given time range,
+// ordered by start time.
+func (c *Client) occurrences(ctx context.Context, calendarID string, from, to time.Time) ([]occurrence, error) {
+	loc, err := c.location(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := c.api.ListEvents(ctx, calendarID, EventQuery{SingleEvents: true, TimeMin: from, TimeMax: to})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
+	}
+	var occurrences []occurrence
+	for _, event := range events {
+		// Rotations are made of all-day events, either occurrences of a
+		// recurring event or single events covering for someone, or of
+		// timed events tagged by this tool when handing off at a time of
+		// day.
+		if event.Start == nil || (event.Start.Date == "" && !tagged(event)) {
+			continue
+		}
+		name, member, ok := rotation.ParseSummary(event.Summary)
+		if !ok {
+			continue
+		}
+		start, err := ParseEventDateTime(event.Start, loc)
+		if err != nil {
+			return nil, err
+		}
+		end, err := ParseEventDateTime(event.End, loc)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, occurrence{
+			event: event,
+			slot:  rotation.Slot{Rotation: name, RotationID: rotationID(event), Member: member, Start: start.In(loc), End: end.In(loc), Link: event.HtmlLink, ColorID: event.ColorId, Managed: tagged(event)},
+		})
+	}
+	return occurrences, nil
+}
+
+// rotationID returns the ID of the rotation the event was created for, or
+// covers part of, if any.
+func rotationID(event *calendar.Event) string {
+	if event.ExtendedProperties == nil {
+		return ""
+	}
+	private := event.ExtendedProperties.Private
+	return cmp.Or(private[PropertyRotationID], private[PropertyOverrideOf])
+}
+
+// tagged reports whether the event was created by this tool for a rotation,
+// or to cover part of one.
+func tagged(event *calendar.Event) bool {
+	if event.ExtendedProperties == nil {
+		return false
+	}
+	private := event.ExtendedProperties.Private
+	return private[PropertyManagedBy] == ManagedBy || private[PropertyRotationID] != "" || private[PropertyOverrideOf] != ""
+}
+
+// UpdateEvent saves the changes made to an event. When the event is an
+// instance of a recurring event, only that occurrence is modified.
+func (c *Client) UpdateEvent(ctx context.Context, calendarID string, event *calendar.Event) (*calendar.Event, error) {
+	updated, err := c.api.UpdateEvent(ctx, calendarID, event, c.SendUpdates)
+	if err != nil {
+		return nil, fmt.Errorf("unable to update event %q: %w", event.Summary, err)
+	}
+	return updated, nil
+}
+
+// eventDateTime returns an event boundary, as a date-time when timed or as
+// the date of t otherwise.
+func eventDateTime(t time.Time, timed bool, timeZone string) *calendar.EventDateTime {
+	if timed {
+		return &calendar.EventDateTime{DateTime: t.Format(time.RFC3339), TimeZone: timeZone}
+	}
+	return &calendar.EventDateTime{Date: t.Format(time.DateOnly), TimeZone: timeZone}
+}
+
+// ParseEventDateTime returns the time of an event boundary, whether it is an
+// all-day date or a date-time. All-day dates start at midnight in loc.
+func ParseEventDateTime(edt *calendar.EventDateTime, loc *time.Location) (time.Time, error) {
+	if edt == nil {
+		return time.Time{}, fmt.Errorf("missing event date")
+	}
+	if edt.Date != "" {
+		return time.ParseInLocation(time.DateOnly, edt.Date, loc)
+	}
+	return time.Parse(time.RFC3339, edt.DateTime)
+}
+
+// EventStart returns the start of an event as written in the calendar.
+func EventStart(event *calendar.Event) string {
+	if event.Start == nil {
+		return ""
+	}
+	if event.Start.Date != "" {
+		return event.Start.Date
+	}
+	return event.Start.DateTime
+}
+
+// SlotAt returns the slot of the named rotation covering the given time, or
+// nil if nobody is on rotation then.
+func (c *Client) SlotAt(ctx context.Context, calendarID, name string, at time.Time) (*rotation.Slot, error) {
+	occurrences, err := c.occurrences(ctx, calendarID, at, at.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range occurrences {
+		if o.slot.Rotation == name && o.slot.Covers(at) {
+			return &o.slot, nil
+		}
+	}
+	return nil, nil
+}
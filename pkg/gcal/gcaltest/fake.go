@@ -0,0 +1,585 @@
+// Package gcaltest provides an in-memory fake of the Google Calendar API and
+// recorded HTTP fixtures, so rotations can be exercised without credentials.
+package gcaltest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"calendar/pkg/gcal"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// maxOccurrences bounds the expansion of recurring events without an end.
+const maxOccurrences = 1000
+
+// Fake is an in-memory gcal.API. Recurring events are expanded from the
+// RRULEs and EXDATEs written by rotation.Plan, and updating or deleting one
+// of their occurrences only affects that occurrence, like the real API.
+// Stored events get a new etag on every change, checked when updating or
+// deleting them with one.
+type Fake struct {
+	mu        sync.Mutex
+	calendars []*calendar.Calendar
+	events    map[string][]*calendar.Event
+	channels  map[string]*calendar.Channel
+	lastID    int
+	lastEtag  int
+}
+
+var _ gcal.API = &Fake{}
+
+// NewFake returns a Fake holding a primary calendar in the given time zone.
+func NewFake(timeZone string) *Fake {
+	f := &Fake{events: make(map[string][]*calendar.Event), channels: make(map[string]*calendar.Channel)}
+	f.AddCalendar("primary", "Primary", timeZone)
+	return f
+}
+
+// AddCalendar adds an empty calendar.
+func (f *Fake) AddCalendar(id, summary, timeZone string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calendars = append(f.calendars, &calendar.Calendar{Id: id, Summary: summary, TimeZone: timeZone})
+}
+
+// Events returns the events stored in a calendar, including the modified and
+// cancelled occurrences of recurring events.
+func (f *Fake) Events(calendarID string) []*calendar.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.events[calendarID])
+}
+
+func (f *Fake) ListCalendars(ctx context.Context) ([]*calendar.CalendarListEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var entries []*calendar.CalendarListEntry
+	for _, c := range f.calendars {
+		entries = append(entries, &calendar.CalendarListEntry{Id: c.Id, Summary: c.Summary, TimeZone: c.TimeZone})
+	}
+	return entries, nil
+}
+
+func (f *Fake) GetCalendar(ctx context.Context, calendarID string) (*calendar.Calendar, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.calendar(calendarID)
+	if err != nil {
+		return nil, err
+	}
+	copied := *c
+	return &copied, nil
+}
+
+// GetColors returns the 11 event colors of Google Calendar.
+func (f *Fake) GetColors(ctx context.Context) (*calendar.Colors, error) {
+	colors := &calendar.Colors{Event: make(map[string]calendar.ColorDefinition)}
+	for i := 1; i <= 11; i++ {
+		colors.Event[strconv.Itoa(i)] = calendar.ColorDefinition{}
+	}
+	return colors, nil
+}
+
+func (f *Fake) ListEvents(ctx context.Context, calendarID string, q gcal.EventQuery) ([]*calendar.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cal, err := f.calendar(calendarID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(cal.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*calendar.Event
+	for _, event := range f.events[calendarID] {
+		if event.RecurringEventId != "" {
+			// Modified occurrences are part of the expanded ones, and
+			// only listed on their own along with the cancelled ones.
+			if q.ShowDeleted && !q.SingleEvents && q.RecurringEventID == "" && matches(event, q) {
+				events = append(events, event)
+			}
+			continue
+		}
+		switch {
+		case q.RecurringEventID != "":
+			if event.Id != q.RecurringEventID {
+				continue
+			}
+			if len(event.Recurrence) == 0 {
+				return nil, notFound("event %s is not recurring", event.Id)
+			}
+			occurrences, err := f.expand(calendarID, event, loc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, occurrences...)
+		case q.SingleEvents && len(event.Recurrence) > 0:
+			if !matches(event, q) {
+				continue
+			}
+			occurrences, err := f.expand(calendarID, event, loc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, occurrences...)
+		default:
+			if matches(event, q) {
+				events = append(events, event)
+			}
+		}
+	}
+
+	var filtered []*calendar.Event
+	for _, event := range events {
+		// Recurring events are listed as a whole, whatever their time.
+		if len(event.Recurrence) == 0 && (event.Status != "cancelled" || !q.ShowDeleted) && !overlaps(event, q.TimeMin, q.TimeMax, loc) {
+			continue
+		}
+		copied := *event
+		filtered = append(filtered, &copied)
+	}
+	if q.SingleEvents || q.RecurringEventID != "" {
+		slices.SortStableFunc(filtered, func(a, b *calendar.Event) int {
+			return start(a, loc).Compare(start(b, loc))
+		})
+	}
+	if q.MaxResults > 0 && len(filtered) > q.MaxResults {
+		filtered = filtered[:q.MaxResults]
+	}
+	return filtered, nil
+}
+
+func (f *Fake) GetEvent(ctx context.Context, calendarID, eventID string) (*calendar.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.index(calendarID, eventID)
+	if i < 0 {
+		return nil, notFound("event %s not found", eventID)
+	}
+	copied := *f.events[calendarID][i]
+	return &copied, nil
+}
+
+func (f *Fake) InsertEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.calendar(calendarID); err != nil {
+		return nil, err
+	}
+	f.lastID++
+	stored := *event
+	stored.Id = fmt.Sprintf("event%d", f.lastID)
+	stored.Status = "confirmed"
+	stored.HtmlLink = "https://calendar.example.com/event?eid=" + stored.Id
+	stored.Etag = f.etag()
+	f.events[calendarID] = append(f.events[calendarID], &stored)
+	copied := stored
+	return &copied, nil
+}
+
+func (f *Fake) UpdateEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored := *event
+	stored.Etag = f.etag()
+	if i := f.index(calendarID, event.Id); i >= 0 {
+		if event.Etag != "" && event.Etag != f.events[calendarID][i].Etag {
+			return nil, preconditionFailed("event %s changed since etag %s", event.Id, event.Etag)
+		}
+		stored.HtmlLink = f.events[calendarID][i].HtmlLink
+		f.events[calendarID][i] = &stored
+	} else {
+		// Updating an occurrence stores it as an exception to its series.
+		series, original, err := f.occurrence(calendarID, event.Id)
+		if err != nil {
+			return nil, err
+		}
+		stored.RecurringEventId = series.Id
+		stored.OriginalStartTime = original
+		stored.Recurrence = nil
+		stored.HtmlLink = series.HtmlLink
+		f.events[calendarID] = append(f.events[calendarID], &stored)
+	}
+	copied := stored
+	return &copied, nil
+}
+
+func (f *Fake) DeleteEvent(ctx context.Context, calendarID, eventID, etag, sendUpdates string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := f.index(calendarID, eventID)
+	if i < 0 {
+		// Deleting an occurrence cancels it.
+		series, original, err := f.occurrence(calendarID, eventID)
+		if err != nil {
+			return err
+		}
+		f.events[calendarID] = append(f.events[calendarID], &calendar.Event{
+			Id:                eventID,
+			Status:            "cancelled",
+			RecurringEventId:  series.Id,
+			OriginalStartTime: original,
+		})
+		return nil
+	}
+	if f.events[calendarID][i].Status == "cancelled" {
+		return notFound("event %s was deleted", eventID)
+	}
+	if etag != "" && etag != f.events[calendarID][i].Etag {
+		return preconditionFailed("event %s changed since etag %s", eventID, etag)
+	}
+	// Deleting a series deletes its exceptions too.
+	f.events[calendarID] = slices.DeleteFunc(f.events[calendarID], func(e *calendar.Event) bool {
+		return e.Id == eventID || e.RecurringEventId == eventID
+	})
+	return nil
+}
+
+// WatchEvents opens the channel without ever notifying it.
+func (f *Fake) WatchEvents(ctx context.Context, calendarID string, channel *calendar.Channel) (*calendar.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.calendar(calendarID); err != nil {
+		return nil, err
+	}
+	f.lastID++
+	opened := *channel
+	opened.ResourceId = fmt.Sprintf("resource%d", f.lastID)
+	if opened.Expiration == 0 {
+		opened.Expiration = time.Now().Add(7 * 24 * time.Hour).UnixMilli()
+	}
+	f.channels[opened.Id] = &opened
+	copied := opened
+	return &copied, nil
+}
+
+// Channels returns the channels opened and not stopped.
+func (f *Fake) Channels() []*calendar.Channel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var channels []*calendar.Channel
+	for _, c := range f.channels {
+		copied := *c
+		channels = append(channels, &copied)
+	}
+	return channels
+}
+
+func (f *Fake) StopChannel(ctx context.Context, channel *calendar.Channel) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	opened, ok := f.channels[channel.Id]
+	if !ok || opened.ResourceId != channel.ResourceId {
+		return notFound("channel %s not found", channel.Id)
+	}
+	delete(f.channels, channel.Id)
+	return nil
+}
+
+func (f *Fake) calendar(id string) (*calendar.Calendar, error) {
+	for _, c := range f.calendars {
+		if c.Id == id {
+			return c, nil
+		}
+	}
+	return nil, notFound("calendar %s not found", id)
+}
+
+// index returns the position of the stored event with the given ID, or -1.
+func (f *Fake) index(calendarID, eventID string) int {
+	return slices.IndexFunc(f.events[calendarID], func(e *calendar.Event) bool { return e.Id == eventID })
+}
+
+// occurrence returns the series and the date of an occurrence ID, made of
+// the ID of the series and the date of the occurrence like eventID_20240701.
+func (f *Fake) occurrence(calendarID, eventID string) (*calendar.Event, *calendar.EventDateTime, error) {
+	id, date, ok := strings.Cut(eventID, "_")
+	i := f.index(calendarID, id)
+	if !ok || i < 0 {
+		return nil, nil, notFound("event %s not found", eventID)
+	}
+	d, err := time.Parse("20060102", date)
+	if err != nil {
+		return nil, nil, notFound("event %s not found", eventID)
+	}
+	series := f.events[calendarID][i]
+	if series.Start == nil || series.Start.DateTime == "" {
+		return series, &calendar.EventDateTime{Date: d.Format(time.DateOnly)}, nil
+	}
+	start, _, loc, err := seriesTimes(series)
+	if err != nil {
+		return nil, nil, err
+	}
+	original := time.Date(d.Year(), d.Month(), d.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+	return series, &calendar.EventDateTime{DateTime: original.Format(time.RFC3339), TimeZone: series.Start.TimeZone}, nil
+}
+
+// seriesTimes returns the start and end of the first occurrence of a timed
+// recurring event, in its time zone.
+func seriesTimes(series *calendar.Event) (time.Time, time.Time, *time.Location, error) {
+	loc, err := time.LoadLocation(series.Start.TimeZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+	start, err := time.Parse(time.RFC3339, series.Start.DateTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+	end, err := time.Parse(time.RFC3339, series.End.DateTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+	return start.In(loc), end.In(loc), loc, nil
+}
+
+// midnight returns midnight in loc of the calendar day of t.
+func midnight(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// originalDate returns the day an occurrence was scheduled on, in loc.
+func originalDate(original *calendar.EventDateTime, loc *time.Location) string {
+	if original.Date != "" {
+		return original.Date
+	}
+	t, _ := time.Parse(time.RFC3339, original.DateTime)
+	return t.In(loc).Format(time.DateOnly)
+}
+
+// expand returns the occurrences of a recurring event, with its exceptions
+// applied. Timed occurrences keep the wall-clock times of the first one in
+// the time zone of the event.
+func (f *Fake) expand(calendarID string, series *calendar.Event, loc *time.Location) ([]*calendar.Event, error) {
+	if series.Start == nil || series.End == nil {
+		return nil, fmt.Errorf("event %s has no start or end", series.Id)
+	}
+	var first, end time.Time
+	var err error
+	if series.Start.DateTime != "" {
+		first, end, loc, err = seriesTimes(series)
+	} else {
+		first, err = time.ParseInLocation(time.DateOnly, series.Start.Date, loc)
+		if err == nil {
+			end, err = time.ParseInLocation(time.DateOnly, series.End.Date, loc)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	days := int(midnight(end, time.UTC).Sub(midnight(first, time.UTC)).Hours() / 24)
+
+	dates, err := recurrenceDates(series.Recurrence, midnight(first, loc))
+	if err != nil {
+		return nil, fmt.Errorf("event %s: %w", series.Id, err)
+	}
+	exceptions := make(map[string]*calendar.Event)
+	for _, e := range f.events[calendarID] {
+		if e.RecurringEventId == series.Id && e.OriginalStartTime != nil {
+			exceptions[originalDate(e.OriginalStartTime, loc)] = e
+		}
+	}
+
+	var occurrences []*calendar.Event
+	for _, d := range dates {
+		date := d.Format(time.DateOnly)
+		if e, ok := exceptions[date]; ok {
+			if e.Status != "cancelled" {
+				occurrences = append(occurrences, e)
+			}
+			continue
+		}
+		o := *series
+		o.Id = series.Id + "_" + d.Format("20060102")
+		o.RecurringEventId = series.Id
+		o.Recurrence = nil
+		o.OriginalStartTime = &calendar.EventDateTime{Date: date}
+		o.Start = &calendar.EventDateTime{Date: date, TimeZone: series.Start.TimeZone}
+		o.End = &calendar.EventDateTime{Date: d.AddDate(0, 0, days).Format(time.DateOnly), TimeZone: series.End.TimeZone}
+		if series.Start.DateTime != "" {
+			e := d.AddDate(0, 0, days)
+			start := time.Date(d.Year(), d.Month(), d.Day(), first.Hour(), first.Minute(), first.Second(), 0, loc).Format(time.RFC3339)
+			o.OriginalStartTime = &calendar.EventDateTime{DateTime: start, TimeZone: series.Start.TimeZone}
+			o.Start = &calendar.EventDateTime{DateTime: start, TimeZone: series.Start.TimeZone}
+			o.End = &calendar.EventDateTime{DateTime: time.Date(e.Year(), e.Month(), e.Day(), end.Hour(), end.Minute(), end.Second(), 0, loc).Format(time.RFC3339), TimeZone: series.End.TimeZone}
+		}
+		occurrences = append(occurrences, &o)
+	}
+	return occurrences, nil
+}
+
+// recurrenceDates returns the dates of the occurrences of a recurrence
+// starting on first.
+func recurrenceDates(recurrence []string, first time.Time) ([]time.Time, error) {
+	freq, interval, count := "", 1, 0
+	var until time.Time
+	var byDay []time.Weekday
+	excluded := make(map[string]bool)
+	for _, line := range recurrence {
+		if dates, ok := strings.CutPrefix(line, "EXDATE;VALUE=DATE:"); ok {
+			for _, d := range strings.Split(dates, ",") {
+				excluded[d] = true
+			}
+			continue
+		}
+		// Timed occurrences are excluded by their start in the time zone
+		// of the event, which is on the day they were scheduled.
+		if strings.HasPrefix(line, "EXDATE;TZID=") {
+			_, times, _ := strings.Cut(line, ":")
+			for _, t := range strings.Split(times, ",") {
+				excluded[t[:min(len(t), 8)]] = true
+			}
+			continue
+		}
+		rule, ok := strings.CutPrefix(line, "RRULE:")
+		if !ok {
+			return nil, fmt.Errorf("unsupported recurrence %q", line)
+		}
+		for _, part := range strings.Split(rule, ";") {
+			key, value, _ := strings.Cut(part, "=")
+			var err error
+			switch key {
+			case "FREQ":
+				freq = value
+			case "INTERVAL":
+				interval, err = strconv.Atoi(value)
+			case "COUNT":
+				count, err = strconv.Atoi(value)
+			case "UNTIL":
+				until, err = time.ParseInLocation("20060102", value[:min(len(value), 8)], first.Location())
+			case "BYDAY":
+				for _, d := range strings.Split(value, ",") {
+					wd := slices.Index([]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}, d)
+					if wd < 0 {
+						return nil, fmt.Errorf("unsupported day %q", d)
+					}
+					byDay = append(byDay, time.Weekday(wd))
+				}
+			default:
+				return nil, fmt.Errorf("unsupported recurrence rule part %q", part)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+			}
+		}
+	}
+
+	// candidates returns the dates of the nth period of the recurrence.
+	candidates := func(n int) []time.Time {
+		switch freq {
+		case "DAILY":
+			return []time.Time{first.AddDate(0, 0, n*interval)}
+		case "WEEKLY":
+			if len(byDay) == 0 {
+				return []time.Time{first.AddDate(0, 0, 7*n*interval)}
+			}
+			monday := first.AddDate(0, 0, -(int(first.Weekday())+6)%7+7*n*interval)
+			var dates []time.Time
+			for _, wd := range byDay {
+				dates = append(dates, monday.AddDate(0, 0, (int(wd)+6)%7))
+			}
+			slices.SortFunc(dates, func(a, b time.Time) int { return a.Compare(b) })
+			return dates
+		case "MONTHLY":
+			d := first.AddDate(0, n*interval, 0)
+			// Months without the day are skipped.
+			if d.Day() != first.Day() {
+				return nil
+			}
+			return []time.Time{d}
+		}
+		return nil
+	}
+	if freq != "DAILY" && freq != "WEEKLY" && freq != "MONTHLY" {
+		return nil, fmt.Errorf("unsupported recurrence frequency %q", freq)
+	}
+
+	var dates []time.Time
+	generated := 0
+	for n := 0; generated < maxOccurrences; n++ {
+		for _, d := range candidates(n) {
+			if d.Before(first) {
+				continue
+			}
+			if !until.IsZero() && d.After(until) {
+				return dates, nil
+			}
+			generated++
+			if !excluded[d.Format("20060102")] {
+				dates = append(dates, d)
+			}
+			if generated == count || generated == maxOccurrences {
+				return dates, nil
+			}
+		}
+	}
+	return dates, nil
+}
+
+// matches reports whether an event matches the filters of a query other
+// than its time range.
+func matches(event *calendar.Event, q gcal.EventQuery) bool {
+	if event.Status == "cancelled" && !q.ShowDeleted {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(event.Summary), strings.ToLower(q.Text)) {
+		return false
+	}
+	if q.PrivateExtendedProperty != "" {
+		name, value, _ := strings.Cut(q.PrivateExtendedProperty, "=")
+		if event.ExtendedProperties == nil || event.ExtendedProperties.Private[name] != value {
+			return false
+		}
+	}
+	if len(q.EventTypes) > 0 {
+		eventType := event.EventType
+		if eventType == "" {
+			eventType = "default"
+		}
+		if !slices.Contains(q.EventTypes, eventType) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether a single event overlaps the time range.
+func overlaps(event *calendar.Event, from, to time.Time, loc *time.Location) bool {
+	if event.Status == "cancelled" {
+		return false
+	}
+	eventEnd, err := gcal.ParseEventDateTime(event.End, loc)
+	if err != nil {
+		return false
+	}
+	return (from.IsZero() || eventEnd.After(from)) && (to.IsZero() || start(event, loc).Before(to))
+}
+
+func start(event *calendar.Event, loc *time.Location) time.Time {
+	t, _ := gcal.ParseEventDateTime(event.Start, loc)
+	return t
+}
+
+func notFound(format string, args ...any) error {
+	return &googleapi.Error{Code: http.StatusNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func preconditionFailed(format string, args ...any) error {
+	return &googleapi.Error{Code: http.StatusPreconditionFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+// etag returns a new etag for a stored event.
+func (f *Fake) etag() string {
+	f.lastEtag++
+	return fmt.Sprintf(`"%d"`, f.lastEtag)
+}
@@ -0,0 +1,132 @@
+package gcaltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Interaction is a request sent to the API and the response it got.
+type Interaction struct {
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Body     string `json:"body,omitempty"`
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+// Recorder is an http.RoundTripper either recording the interactions with
+// the API to a fixture file, or replaying them from it. Only methods, URLs
+// and bodies are recorded, so fixtures hold no credentials.
+type Recorder struct {
+	path string
+	// next sends the requests when recording, nil when replaying.
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+// Record returns a Recorder sending requests with next, and writing every
+// interaction to the fixture file at path.
+func Record(path string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{path: path, next: next}
+}
+
+// Replay returns a Recorder answering requests with the responses recorded
+// in the fixture file at path. Each interaction is replayed once, in the
+// order they were recorded.
+func Replay(path string) (*Recorder, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read fixture: %w", err)
+	}
+	r := &Recorder{path: path}
+	if err := json.Unmarshal(b, &r.interactions); err != nil {
+		return nil, fmt.Errorf("unable to parse fixture %s: %w", path, err)
+	}
+	r.replayed = make([]bool, len(r.interactions))
+	return r, nil
+}
+
+// Client returns an HTTP client using the Recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if r.next == nil {
+		return r.replay(req, string(body))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, Interaction{
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Body:     string(body),
+		Status:   resp.StatusCode,
+		Response: string(respBody),
+	})
+	// The fixture is rewritten after every request, so it is complete
+	// whenever the program stops.
+	b, err := json.MarshalIndent(r.interactions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(r.path, b, 0o644); err != nil {
+		return nil, fmt.Errorf("unable to write fixture: %w", err)
+	}
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body string) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.replayed[i] || in.Method != req.Method || in.URL != req.URL.String() || in.Body != body {
+			continue
+		}
+		r.replayed[i] = true
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode: in.Status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte(in.Response))),
+			Request:    req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s in fixture %s", req.Method, req.URL, r.path)
+}
@@ -0,0 +1,38 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Holidays returns the days of the all-day events of a holiday calendar,
+// e.g. a Google public holiday calendar or the team's own, over the given
+// time range. Observances, which public holiday calendars list although
+// they aren't days off, are left out.
+func (c *Client) Holidays(ctx context.Context, calendarID string, from, to time.Time) ([]time.Time, error) {
+	events, err := c.api.ListEvents(ctx, calendarID, EventQuery{SingleEvents: true, TimeMin: from, TimeMax: to})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list holidays of %s: %w", calendarID, err)
+	}
+	var days []time.Time
+	for _, event := range events {
+		if event.Start == nil || event.Start.Date == "" || event.End == nil || strings.HasPrefix(event.Description, "Observance") {
+			continue
+		}
+		start, err := time.Parse(time.DateOnly, event.Start.Date)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse holiday %q: %w", event.Summary, err)
+		}
+		end, err := time.Parse(time.DateOnly, event.End.Date)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse holiday %q: %w", event.Summary, err)
+		}
+		// Events end right after their last day.
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
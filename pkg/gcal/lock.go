@@ -0,0 +1,228 @@
+package gcal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Private extended properties of the sentinel event holding the lease on a
+// calendar.
+const (
+	PropertyLock        = "lock"
+	PropertyLockHolder  = "lockHolder"
+	PropertyLockExpires = "lockExpires"
+)
+
+// lockDate is the day of the sentinel events, far from any rotation so they
+// don't get in the way.
+const lockDate = "1970-01-01"
+
+// LockedError is returned when changing a calendar whose lease is held by
+// someone else.
+type LockedError struct {
+	Calendar string
+	Holder   string
+	Expires  time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("calendar %s is locked by %s until %s, wait for it to finish or use --force-unlock if it was interrupted", e.Calendar, e.Holder, e.Expires.Local().Format(time.DateTime))
+}
+
+// Lock makes the client take a lease on a calendar before changing it for
+// the first time, so two runs, e.g. CI and someone at their desk, don't
+// interleave their changes. The lease is a sentinel event naming holder and
+// when the lease expires, ttl after the last change, so an interrupted run
+// doesn't lock the calendar forever. Changes fail with a LockedError while
+// someone else holds the lease, until Unlock releases it.
+func (c *Client) Lock(holder string, ttl time.Duration) {
+	c.locks = &lockedAPI{API: c.api, holder: holder, ttl: ttl, leases: make(map[string]*calendar.Event)}
+	c.api = c.locks
+}
+
+// Unlock releases the leases taken by the client, if any.
+func (c *Client) Unlock(ctx context.Context) error {
+	if c.locks == nil {
+		return nil
+	}
+	return c.locks.release(ctx)
+}
+
+// ForceUnlock releases the lease on a calendar whoever holds it, e.g. after
+// a run was killed, returning the holder of the lease released if any.
+func (c *Client) ForceUnlock(ctx context.Context, calendarID string) (string, error) {
+	api := c.api
+	if c.locks != nil {
+		api = c.locks.API
+	}
+	leases, err := api.ListEvents(ctx, calendarID, EventQuery{PrivateExtendedProperty: PropertyLock + "=" + ManagedBy})
+	if err != nil {
+		return "", fmt.Errorf("unable to list locks: %w", err)
+	}
+	var holder string
+	for _, lease := range leases {
+		if err := api.DeleteEvent(ctx, calendarID, lease.Id, "", "none"); err != nil && !isGone(err) {
+			return "", fmt.Errorf("unable to delete lock: %w", err)
+		}
+		holder = lease.ExtendedProperties.Private[PropertyLockHolder]
+	}
+	return holder, nil
+}
+
+// lockedAPI takes the lease on a calendar before its first change, and
+// renews it on the following ones.
+type lockedAPI struct {
+	API
+	holder string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// leases holds the sentinel event of the lease held per calendar.
+	leases map[string]*calendar.Event
+}
+
+func (l *lockedAPI) InsertEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	if err := l.acquire(ctx, calendarID); err != nil {
+		return nil, err
+	}
+	return l.API.InsertEvent(ctx, calendarID, event, sendUpdates)
+}
+
+func (l *lockedAPI) UpdateEvent(ctx context.Context, calendarID string, event *calendar.Event, sendUpdates string) (*calendar.Event, error) {
+	if err := l.acquire(ctx, calendarID); err != nil {
+		return nil, err
+	}
+	return l.API.UpdateEvent(ctx, calendarID, event, sendUpdates)
+}
+
+func (l *lockedAPI) DeleteEvent(ctx context.Context, calendarID, eventID, etag, sendUpdates string) error {
+	if err := l.acquire(ctx, calendarID); err != nil {
+		return err
+	}
+	return l.API.DeleteEvent(ctx, calendarID, eventID, etag, sendUpdates)
+}
+
+// acquire takes the lease on the calendar unless held already, renewing it
+// when it expires in less than half its time to live.
+func (l *lockedAPI) acquire(ctx context.Context, calendarID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := time.Now().Add(l.ttl)
+	if lease, ok := l.leases[calendarID]; ok {
+		if time.Until(leaseExpiry(lease)) > l.ttl/2 {
+			return nil
+		}
+		setLease(lease, l.holder, expires)
+		renewed, err := l.API.UpdateEvent(ctx, calendarID, lease, "none")
+		if err != nil {
+			delete(l.leases, calendarID)
+			if isConflict(err) || isGone(err) {
+				return fmt.Errorf("lost the lock on calendar %s, it was released or taken over by someone else", calendarID)
+			}
+			return fmt.Errorf("unable to renew lock: %w", err)
+		}
+		l.leases[calendarID] = renewed
+		return nil
+	}
+
+	leases, err := l.API.ListEvents(ctx, calendarID, EventQuery{PrivateExtendedProperty: PropertyLock + "=" + ManagedBy})
+	if err != nil {
+		return fmt.Errorf("unable to list locks: %w", err)
+	}
+	var lease *calendar.Event
+	for _, existing := range leases {
+		if leaseExpiry(existing).After(time.Now()) {
+			return &LockedError{Calendar: calendarID, Holder: existing.ExtendedProperties.Private[PropertyLockHolder], Expires: leaseExpiry(existing)}
+		}
+		// The lease of an interrupted run is taken over, only if nobody did
+		// since it was listed.
+		slog.Warn("Taking over expired lock", "calendar", calendarID, "holder", existing.ExtendedProperties.Private[PropertyLockHolder])
+		setLease(existing, l.holder, expires)
+		if lease, err = l.API.UpdateEvent(ctx, calendarID, existing, "none"); err != nil {
+			if isConflict(err) || isGone(err) {
+				return fmt.Errorf("calendar %s was locked by someone else meanwhile, try again", calendarID)
+			}
+			return fmt.Errorf("unable to take over lock: %w", err)
+		}
+		break
+	}
+	if lease == nil {
+		sentinel := &calendar.Event{
+			Start:        &calendar.EventDateTime{Date: lockDate},
+			End:          &calendar.EventDateTime{Date: "1970-01-02"},
+			Transparency: "transparent",
+			Visibility:   "private",
+		}
+		setLease(sentinel, l.holder, expires)
+		if lease, err = l.API.InsertEvent(ctx, calendarID, sentinel, "none"); err != nil {
+			return fmt.Errorf("unable to lock calendar: %w", err)
+		}
+		// Two runs may have created their lease at the same time, in which
+		// case both give up rather than both going on.
+		leases, err := l.API.ListEvents(ctx, calendarID, EventQuery{PrivateExtendedProperty: PropertyLock + "=" + ManagedBy})
+		if err != nil {
+			return errors.Join(fmt.Errorf("unable to list locks: %w", err), l.API.DeleteEvent(ctx, calendarID, lease.Id, "", "none"))
+		}
+		for _, other := range leases {
+			if other.Id != lease.Id && leaseExpiry(other).After(time.Now()) {
+				if err := l.API.DeleteEvent(ctx, calendarID, lease.Id, "", "none"); err != nil {
+					slog.Error("Unable to delete lock", "calendar", calendarID, "error", err)
+				}
+				return &LockedError{Calendar: calendarID, Holder: other.ExtendedProperties.Private[PropertyLockHolder], Expires: leaseExpiry(other)}
+			}
+		}
+	}
+	slog.Debug("Calendar locked", "calendar", calendarID, "holder", l.holder, "expires", expires)
+	l.leases[calendarID] = lease
+	return nil
+}
+
+// release deletes the sentinel events of the leases held.
+func (l *lockedAPI) release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for calendarID, lease := range l.leases {
+		// Only the lease as last written is deleted, so a lease taken over
+		// by someone else meanwhile is kept.
+		if err := l.API.DeleteEvent(ctx, calendarID, lease.Id, lease.Etag, "none"); err != nil && !isGone(err) && !isConflict(err) {
+			errs = append(errs, fmt.Errorf("unable to unlock calendar %s: %w", calendarID, err))
+			continue
+		}
+		delete(l.leases, calendarID)
+		slog.Debug("Calendar unlocked", "calendar", calendarID)
+	}
+	return errors.Join(errs...)
+}
+
+// setLease writes the holder and the expiry of a lease on its sentinel
+// event.
+func setLease(event *calendar.Event, holder string, expires time.Time) {
+	event.Summary = "Locked by " + holder
+	event.Description = fmt.Sprintf("Calendar locked by %s while changing its rotations, until %s at the latest.", holder, expires.Format(time.RFC3339))
+	event.ExtendedProperties = &calendar.EventExtendedProperties{
+		Private: map[string]string{
+			PropertyLock:        ManagedBy,
+			PropertyLockHolder:  holder,
+			PropertyLockExpires: expires.Format(time.RFC3339),
+		},
+	}
+}
+
+// leaseExpiry returns when the lease of a sentinel event expires, the zero
+// time when unreadable so it is taken over.
+func leaseExpiry(event *calendar.Event) time.Time {
+	if event.ExtendedProperties == nil {
+		return time.Time{}
+	}
+	expires, _ := time.Parse(time.RFC3339, event.ExtendedProperties.Private[PropertyLockExpires])
+	return expires
+}
@@ -0,0 +1,158 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// PropertyOverrideOf replaces PropertyRotationID on the events covering for
+// someone in a rotation, so syncing the rotation leaves them alone.
+const PropertyOverrideOf = "overrideOf"
+
+// Override has member cover the rotation from the start of from to the end
+// of to, both days in the calendar's time zone. The occurrences overlapping
+// those days are shortened, or cancelled when entirely covered, and the
+// days outside the override are kept as single events for the member
+// originally on rotation. The recurring series are left untouched. The
+// coverage events name member instead of the one covered for in their
+// descriptions, and the slots of the coverage events created are returned.
+func (c *Client) Override(ctx context.Context, calendarID, name, member string, from, to time.Time) ([]rotation.Slot, error) {
+	loc, err := c.location(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	from = rotation.InLocation(from, loc)
+	end := rotation.InLocation(to, loc).AddDate(0, 0, 1)
+	if !end.After(from) {
+		return nil, fmt.Errorf("override must end on or after %s", from.Format(time.DateOnly))
+	}
+
+	occurrences, err := c.occurrences(ctx, calendarID, from, end)
+	if err != nil {
+		return nil, err
+	}
+	var affected []occurrence
+	for _, o := range occurrences {
+		if o.slot.Rotation == name && o.slot.Member != member {
+			affected = append(affected, o)
+		}
+	}
+	if len(affected) == 0 {
+		return nil, fmt.Errorf("no slot of rotation %q to override between %s and %s", name, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+
+	// The coverage events look like the member's own events, when they have
+	// any in the rotation.
+	var colorID, email string
+	var attendees []*calendar.EventAttendee
+	managed, err := c.ManagedEvents(ctx, calendarID, rotation.ID(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range managed {
+		if event.ExtendedProperties != nil && event.ExtendedProperties.Private[PropertyMember] == member {
+			colorID, attendees, email = event.ColorId, event.Attendees, memberEmail(event)
+			break
+		}
+	}
+
+	var slots []rotation.Slot
+	for _, o := range affected {
+		start, stop := o.slot.Start, o.slot.End
+		coverStart, coverEnd := start, stop
+		if coverStart.Before(from) {
+			coverStart = from
+		}
+		if coverEnd.After(end) {
+			coverEnd = end
+		}
+
+		switch {
+		case start.Before(from):
+			// The member keeps the days before the override, and the ones
+			// after it as a separate event.
+			if stop.After(end) {
+				rest := overrideEvent(o.event, end, stop)
+				if _, err := c.api.InsertEvent(ctx, calendarID, rest, c.SendUpdates); err != nil {
+					return slots, fmt.Errorf("unable to create event %q: %w", rest.Summary, err)
+				}
+			}
+			setEventDates(o.event, start, from)
+			if _, err := c.UpdateEvent(ctx, calendarID, o.event); err != nil {
+				return slots, err
+			}
+		case stop.After(end):
+			setEventDates(o.event, end, stop)
+			if _, err := c.UpdateEvent(ctx, calendarID, o.event); err != nil {
+				return slots, err
+			}
+		default:
+			// Deleting an instance only cancels that occurrence.
+			if err := c.DeleteEvent(ctx, calendarID, o.event.Id); err != nil {
+				return slots, err
+			}
+		}
+
+		cover := overrideEvent(o.event, coverStart, coverEnd)
+		cover.Summary = rotation.Summary(name, member)
+		words := map[string]string{o.slot.Member: member, member: o.slot.Member}
+		if covered := memberEmail(o.event); covered != "" && email != "" && covered != email {
+			words[covered], words[email] = email, covered
+		}
+		cover.Description = exchangeWords(cover.Description, words)
+		cover.ColorId = colorID
+		cover.Attendees = attendees
+		cover.ExtendedProperties.Private[PropertyMember] = member
+		created, err := c.api.InsertEvent(ctx, calendarID, cover, c.SendUpdates)
+		if err != nil {
+			return slots, fmt.Errorf("unable to create event %q: %w", cover.Summary, err)
+		}
+		slots = append(slots, rotation.Slot{Rotation: name, RotationID: o.slot.RotationID, Member: member, Start: coverStart, End: coverEnd, Link: created.HtmlLink})
+	}
+	return slots, nil
+}
+
+// overrideEvent returns a single event copying occurrence over the given
+// days, tagged so syncing the rotation doesn't delete it.
+func overrideEvent(occurrence *calendar.Event, start, end time.Time) *calendar.Event {
+	private := make(map[string]string)
+	if occurrence.ExtendedProperties != nil {
+		for k, v := range occurrence.ExtendedProperties.Private {
+			private[k] = v
+		}
+	}
+	if id, ok := private[PropertyRotationID]; ok {
+		private[PropertyOverrideOf] = id
+		delete(private, PropertyRotationID)
+	}
+	event := &calendar.Event{
+		Summary:            occurrence.Summary,
+		ColorId:            occurrence.ColorId,
+		Attendees:          occurrence.Attendees,
+		Reminders:          occurrence.Reminders,
+		Description:        occurrence.Description,
+		Visibility:         occurrence.Visibility,
+		Transparency:       occurrence.Transparency,
+		GuestsCanModify:    occurrence.GuestsCanModify,
+		ExtendedProperties: &calendar.EventExtendedProperties{Private: private},
+		Start:              occurrence.Start,
+	}
+	setEventDates(event, start, end)
+	return event
+}
+
+// setEventDates makes event last from start until end, as an all-day event
+// until the day before end unless it is timed.
+func setEventDates(event *calendar.Event, start, end time.Time) {
+	tz, timed := "", false
+	if event.Start != nil {
+		tz, timed = event.Start.TimeZone, event.Start.DateTime != ""
+	}
+	event.Start = eventDateTime(start, timed, tz)
+	event.End = eventDateTime(end, timed, tz)
+}
@@ -0,0 +1,70 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Provider returns the CalendarProvider managing the events of a calendar.
+func (c *Client) Provider(calendarID string) provider.CalendarProvider {
+	return &calendarProvider{client: c, calendarID: calendarID}
+}
+
+type calendarProvider struct {
+	client     *Client
+	calendarID string
+}
+
+func (p *calendarProvider) TimeZone(ctx context.Context) (string, error) {
+	return p.client.TimeZone(ctx, p.calendarID)
+}
+
+func (p *calendarProvider) ManagedEvents(ctx context.Context, rotationID string) ([]provider.Event, error) {
+	events, err := p.client.ManagedEvents(ctx, p.calendarID, rotationID)
+	return toProviderEvents(events), err
+}
+
+func (p *calendarProvider) RotationEvents(ctx context.Context, name string) ([]provider.Event, error) {
+	events, err := p.client.RotationEvents(ctx, p.calendarID, name)
+	return toProviderEvents(events), err
+}
+
+func (p *calendarProvider) Sync(ctx context.Context, existing []provider.Event, planned []rotation.Event, opts provider.SyncOptions) ([]provider.Change, error) {
+	events := make([]*calendar.Event, 0, len(existing))
+	for _, e := range existing {
+		event, ok := e.Raw.(*calendar.Event)
+		if !ok {
+			return nil, fmt.Errorf("event %q is not a Google Calendar event", e.Summary)
+		}
+		events = append(events, event)
+	}
+	client := *p.client
+	client.Atomic = client.Atomic || opts.Atomic
+	client.DryRun = client.DryRun || opts.DryRun
+	if opts.Progress != nil {
+		client.Progress = opts.Progress
+	}
+	return client.Sync(ctx, p.calendarID, events, planned)
+}
+
+func (p *calendarProvider) DeleteEvent(ctx context.Context, event provider.Event) error {
+	return p.client.DeleteEvent(ctx, p.calendarID, event.ID)
+}
+
+func (p *calendarProvider) Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	return p.client.Slots(ctx, p.calendarID, from, to)
+}
+
+func toProviderEvents(events []*calendar.Event) []provider.Event {
+	out := make([]provider.Event, 0, len(events))
+	for _, event := range events {
+		out = append(out, provider.Event{ID: event.Id, Summary: event.Summary, Start: EventStart(event), Raw: event})
+	}
+	return out
+}
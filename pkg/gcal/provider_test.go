@@ -0,0 +1,66 @@
+package gcal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/gcal/gcaltest"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+)
+
+// TestProviderVerify checks that rotations synced through the provider
+// verify clean, as verify and watch compare the calendar with a dry run.
+func TestProviderVerify(t *testing.T) {
+	start := time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		r    rotation.Rotation
+	}{
+		{
+			name: "recurring",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Start: start, Cadence: rotation.Weeks(1)},
+		},
+		{
+			name: "materialized",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Start: start, Cadence: rotation.Weeks(1), Count: 6, Materialize: true},
+		},
+		{
+			name: "timed",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1), Count: 4, HandoffTime: "09:00", TimeZone: "Europe/Madrid"},
+		},
+		{
+			name: "windowed",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1), Count: 2, Window: "Mon-Fri 09:00-17:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := gcaltest.NewFake("UTC")
+			f.AddCalendar("team", "Team", "UTC")
+			cal := gcal.NewWithAPI(f).Provider("team")
+			events, err := rotation.Plan(tt.r)
+			if err != nil {
+				t.Fatalf("unable to plan rotation: %v", err)
+			}
+			if _, err := cal.Sync(ctx, nil, events, provider.SyncOptions{}); err != nil {
+				t.Fatalf("unable to sync rotation: %v", err)
+			}
+
+			existing, err := cal.ManagedEvents(ctx, rotation.ID(tt.r.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := cal.Sync(ctx, existing, events, provider.SyncOptions{DryRun: true})
+			if err != nil {
+				t.Fatalf("unable to verify rotation: %v", err)
+			}
+			if len(changes) != 0 {
+				t.Errorf("got drift %v, want none", changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package gcal
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithQuotaProject returns a copy of the HTTP client billing the quota of
+// its requests to the given Google Cloud project instead of the one of the
+// credentials, e.g. so users of an organization sharing an OAuth client
+// don't exhaust its quota. The caller must have the
+// serviceusage.services.use permission on the project.
+func WithQuotaProject(client *http.Client, project string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &quotaTransport{base: base, project: project}
+	return &c
+}
+
+type quotaTransport struct {
+	base    http.RoundTripper
+	project string
+}
+
+func (t *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request.
+	r := req.Clone(req.Context())
+	r.Header.Set("X-Goog-User-Project", t.project)
+	return t.base.RoundTrip(r)
+}
+
+// quotaHeaders returns the headers of a response about quotas and rate
+// limits, e.g. Retry-After or X-RateLimit-Remaining, as log attributes.
+func quotaHeaders(h http.Header) []any {
+	var attrs []any
+	for name, values := range h {
+		lower := strings.ToLower(name)
+		if lower == "retry-after" || strings.Contains(lower, "ratelimit") || strings.Contains(lower, "quota") {
+			attrs = append(attrs, name, strings.Join(values, ", "))
+		}
+	}
+	return attrs
+}
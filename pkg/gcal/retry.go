@@ -0,0 +1,157 @@
+package gcal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// baseBackoff is the wait before the first retry, doubled on every attempt.
+	baseBackoff = 500 * time.Millisecond
+	// maxBackoff caps the wait between two attempts.
+	maxBackoff = 30 * time.Second
+)
+
+// WithRetries returns a copy of the HTTP client limited to qps requests per
+// second, which retries rate limited and failed requests up to maxRetries
+// times with exponential backoff and jitter.
+func WithRetries(client *http.Client, qps float64, maxRetries int) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limit := rate.Inf
+	if qps > 0 {
+		limit = rate.Limit(qps)
+	}
+
+	c := *client
+	c.Transport = &retryTransport{
+		base:       base,
+		limiter:    rate.NewLimiter(limit, 1),
+		maxRetries: maxRetries,
+	}
+	return &c
+}
+
+type retryTransport struct {
+	base       http.RoundTripper
+	limiter    *rate.Limiter
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	maxRetries := t.maxRetries
+	if req.Body != nil && req.GetBody == nil {
+		// The body can't be sent again once consumed.
+		maxRetries = 0
+	}
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		r := req
+		if attempt > 0 && req.Body != nil {
+			// The body was consumed by the previous attempt.
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		res, err := t.base.RoundTrip(r)
+		// Requests cancelled by the caller must not be sent again.
+		if attempt >= maxRetries || ctx.Err() != nil || !retryable(res, err) {
+			return res, err
+		}
+
+		wait := backoff(attempt)
+		if res != nil {
+			if after, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && time.Duration(after)*time.Second > wait {
+				wait = time.Duration(after) * time.Second
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether a request should be attempted again: on network
+// errors, server errors and when rate limited. The Calendar API signals rate
+// limits either with a 429 or with a 403 and a rate limit reason.
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests, res.StatusCode >= 500:
+		return true
+	case res.StatusCode == http.StatusForbidden:
+		return rateLimited(res)
+	}
+	return false
+}
+
+// rateLimited inspects the error reasons of a response, leaving its body
+// readable by the caller.
+func rateLimited(res *http.Response) bool {
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return false
+	}
+
+	var body struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(b, &body) != nil {
+		return false
+	}
+	for _, e := range body.Error.Errors {
+		if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before retrying, with full jitter so concurrent
+// clients don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,158 @@
+package gcal
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Swap exchanges two members' slots in a rotation: the slot of from covering
+// date (or the first one starting after it) and the next slot of to after
+// that. Only those occurrences are modified, the recurring series are left
+// untouched. Summaries, colors, attendees and the member and slot properties
+// are exchanged so each event keeps matching the member serving it, and the
+// names and emails of the two members are exchanged in the descriptions,
+// rendered for the member serving the event. The swapped slots are returned
+// as they are after the swap.
+func (c *Client) Swap(ctx context.Context, calendarID, name, from, to string, date time.Time) ([]rotation.Slot, error) {
+	if from == to {
+		return nil, fmt.Errorf("cannot swap %s with themselves", from)
+	}
+	loc, err := c.location(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	date = rotation.InLocation(date, loc)
+
+	// Look for the slots up to a year ahead, which covers any sensible cycle.
+	occurrences, err := c.occurrences(ctx, calendarID, date, date.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	var a, b *occurrence
+	for i := range occurrences {
+		o := &occurrences[i]
+		if o.slot.Rotation != name {
+			continue
+		}
+		switch {
+		case a == nil && o.slot.Member == from && o.slot.End.After(date):
+			a = o
+		case a != nil && o.slot.Member == to && !o.slot.Start.Before(a.slot.End):
+			b = o
+		}
+		if b != nil {
+			break
+		}
+	}
+	if a == nil {
+		return nil, fmt.Errorf("no slot of %s found in rotation %q from %s", from, name, date.Format(time.DateOnly))
+	}
+	if b == nil {
+		return nil, fmt.Errorf("no slot of %s found in rotation %q after %s", to, name, a.slot.End.Format(time.DateOnly))
+	}
+
+	a.event.Summary, b.event.Summary = b.event.Summary, a.event.Summary
+	a.event.ColorId, b.event.ColorId = b.event.ColorId, a.event.ColorId
+	a.event.Attendees, b.event.Attendees = b.event.Attendees, a.event.Attendees
+	swapProperties(a.event, b.event, PropertyMember, PropertySlotIndex)
+	words := map[string]string{from: to, to: from}
+	if emailA, emailB := memberEmail(a.event), memberEmail(b.event); emailA != "" && emailB != "" && emailA != emailB {
+		words[emailA], words[emailB] = emailB, emailA
+	}
+	a.event.Description, b.event.Description = exchangeWords(a.event.Description, words), exchangeWords(b.event.Description, words)
+	if _, err := c.UpdateEvent(ctx, calendarID, a.event); err != nil {
+		return nil, err
+	}
+	if _, err := c.UpdateEvent(ctx, calendarID, b.event); err != nil {
+		return nil, err
+	}
+
+	a.slot.Member, b.slot.Member = b.slot.Member, a.slot.Member
+	return []rotation.Slot{a.slot, b.slot}, nil
+}
+
+// swapProperties exchanges the named private extended properties of two
+// events.
+func swapProperties(a, b *calendar.Event, names ...string) {
+	private := func(event *calendar.Event) map[string]string {
+		if event.ExtendedProperties == nil {
+			event.ExtendedProperties = &calendar.EventExtendedProperties{}
+		}
+		if event.ExtendedProperties.Private == nil {
+			event.ExtendedProperties.Private = make(map[string]string)
+		}
+		return event.ExtendedProperties.Private
+	}
+	for _, name := range names {
+		valueA, okA := private(a)[name]
+		valueB, okB := private(b)[name]
+		delete(private(a), name)
+		delete(private(b), name)
+		if okB {
+			private(a)[name] = valueB
+		}
+		if okA {
+			private(b)[name] = valueA
+		}
+	}
+}
+
+// memberEmail returns the address of the member serving an event, the one of
+// its only required attendee.
+func memberEmail(event *calendar.Event) string {
+	for _, a := range event.Attendees {
+		if !a.Optional {
+			return a.Email
+		}
+	}
+	return ""
+}
+
+// exchangeWords replaces the words of text that are keys of words with their
+// values, all at once, so two names can be exchanged. Only whole words are
+// replaced, so Al is left alone in Alice, and longer words are tried first.
+func exchangeWords(text string, words map[string]string) string {
+	keys := make([]string, 0, len(words))
+	for k := range words {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int { return cmp.Or(len(b)-len(a), strings.Compare(a, b)) })
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		replaced := false
+		for _, k := range keys {
+			if !strings.HasPrefix(text[i:], k) || (i > 0 && isWord(before)) {
+				continue
+			}
+			if after, _ := utf8.DecodeRuneInString(text[i+len(k):]); i+len(k) < len(text) && isWord(after) {
+				continue
+			}
+			b.WriteString(words[k])
+			i += len(k)
+			replaced = true
+			break
+		}
+		if !replaced {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			b.WriteString(text[i : i+size])
+			i += size
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package gcal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/gcal/gcaltest"
+	"calendar/pkg/rotation"
+)
+
+// newRotationClient returns a client of a fake calendar named team, holding
+// the weekly rotation of Alice, Bob and Cesar starting on start.
+func newRotationClient(t *testing.T) (*gcaltest.Fake, *gcal.Client, rotation.Rotation) {
+	t.Helper()
+	f := gcaltest.NewFake("UTC")
+	f.AddCalendar("team", "Team", "UTC")
+	client := gcal.NewWithAPI(f)
+	r := rotation.Rotation{
+		Name:    "SRE Role",
+		Members: []string{"Alice", "Bob", "Cesar"},
+		Start:   time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC),
+		Cadence: rotation.Weeks(1),
+		Count:   6,
+		Emails: map[string]string{
+			"Alice": "alice@example.com",
+			"Bob":   "bob@example.com",
+			"Cesar": "cesar@example.com",
+		},
+		Description: "{{.Member}} ({{.Email}}) is on call, {{.Escalation}} is the escalation contact.",
+	}
+	syncRotation(t, client, r)
+	return f, client, r
+}
+
+// members returns who serves the slots of the calendar between from and to,
+// in order.
+func members(t *testing.T, client *gcal.Client, from, to time.Time) []string {
+	t.Helper()
+	slots, err := client.Slots(context.Background(), "team", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []string
+	for _, s := range slots {
+		members = append(members, s.Member+" "+s.Start.Format(time.DateOnly))
+	}
+	return members
+}
+
+func TestSwap(t *testing.T) {
+	_, client, r := newRotationClient(t)
+
+	slots, err := client.Swap(context.Background(), "team", r.Name, "Alice", "Bob", r.Start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("Swap() error = %v", err)
+	}
+	if len(slots) != 2 || slots[0].Member != "Bob" || slots[1].Member != "Alice" {
+		t.Fatalf("Swap() = %v, want the slots of Bob then Alice", slots)
+	}
+
+	got := members(t, client, r.Start, r.Start.AddDate(0, 0, 21))
+	want := []string{"Bob 2030-01-07", "Alice 2030-01-14", "Cesar 2030-01-21"}
+	if len(got) != len(want) {
+		t.Fatalf("slots after swap = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("slots after swap = %v, want %v", got, want)
+			break
+		}
+	}
+
+	if _, err := client.Swap(context.Background(), "team", r.Name, "Alice", "Alice", r.Start); err == nil {
+		t.Error("Swap() of a member with themselves succeeded, want an error")
+	}
+	if _, err := client.Swap(context.Background(), "team", r.Name, "Dana", "Bob", r.Start); err == nil {
+		t.Error("Swap() of a member without slots succeeded, want an error")
+	}
+}
+
+func TestOverride(t *testing.T) {
+	f, client, r := newRotationClient(t)
+	from := r.Start.AddDate(0, 0, 2)
+
+	slots, err := client.Override(context.Background(), "team", r.Name, "Cesar", from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+	if len(slots) != 1 || slots[0].Member != "Cesar" || !slots[0].Start.Equal(from) || !slots[0].End.Equal(from.AddDate(0, 0, 2)) {
+		t.Fatalf("Override() = %v, want Cesar from %s for 2 days", slots, from.Format(time.DateOnly))
+	}
+
+	got := members(t, client, r.Start, r.Start.AddDate(0, 0, 7))
+	want := []string{"Alice 2030-01-07", "Cesar 2030-01-09", "Alice 2030-01-11"}
+	if len(got) != len(want) {
+		t.Fatalf("slots after override = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("slots after override = %v, want %v", got, want)
+			break
+		}
+	}
+
+	for _, event := range f.Events("team") {
+		if event.ExtendedProperties.Private[gcal.PropertyOverrideOf] == "" || event.ExtendedProperties.Private[gcal.PropertyMember] != "Cesar" {
+			continue
+		}
+		if want := "Cesar (cesar@example.com) is on call, Bob is the escalation contact."; event.Description != want {
+			t.Errorf("coverage event has description %q, want %q", event.Description, want)
+		}
+	}
+
+	if _, err := client.Override(context.Background(), "team", r.Name, "Cesar", from, from.AddDate(0, 0, -1)); err == nil {
+		t.Error("Override() ending before it starts succeeded, want an error")
+	}
+}
+
+func TestSwapDetails(t *testing.T) {
+	f := gcaltest.NewFake("UTC")
+	f.AddCalendar("team", "Team", "UTC")
+	client := gcal.NewWithAPI(f)
+	r := rotation.Rotation{
+		Name:        "SRE Role",
+		Members:     []string{"Al", "Alice", "Bob"},
+		Start:       time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC),
+		Cadence:     rotation.Weeks(1),
+		Count:       3,
+		Materialize: true,
+		Emails:      map[string]string{"Alice": "alice@example.com", "Bob": "bob@example.com"},
+		Description: "{{.Member}} ({{.Email}}) is on call, {{.Escalation}} is the escalation contact.",
+	}
+	syncRotation(t, client, r)
+
+	if _, err := client.Swap(context.Background(), "team", r.Name, "Alice", "Bob", r.Start.AddDate(0, 0, 7)); err != nil {
+		t.Fatalf("Swap() error = %v", err)
+	}
+	want := map[string]struct{ member, slot, description string }{
+		"2030-01-14": {"Bob", "2", "Bob (bob@example.com) is on call, Alice is the escalation contact."},
+		"2030-01-21": {"Alice", "1", "Alice (alice@example.com) is on call, Al is the escalation contact."},
+	}
+	for _, event := range f.Events("team") {
+		w, ok := want[event.Start.Date]
+		if !ok {
+			continue
+		}
+		private := event.ExtendedProperties.Private
+		if private[gcal.PropertyMember] != w.member || private[gcal.PropertySlotIndex] != w.slot {
+			t.Errorf("event of %s has member %q and slot %q, want %q and %q", event.Start.Date, private[gcal.PropertyMember], private[gcal.PropertySlotIndex], w.member, w.slot)
+		}
+		if event.Description != w.description {
+			t.Errorf("event of %s has description %q, want %q", event.Start.Date, event.Description, w.description)
+		}
+	}
+}
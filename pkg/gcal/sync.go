@@ -0,0 +1,396 @@
+package gcal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// maxConflicts is how many times an event changed by someone else during a
+// sync is fetched again before giving up on it.
+const maxConflicts = 3
+
+// Change is a modification made to the calendar when syncing a rotation.
+type Change = provider.Change
+
+// ManagedEvents returns the events created by this tool for the rotation
+// with the given ID. Recurring events are returned as a single series.
+func (c *Client) ManagedEvents(ctx context.Context, calendarID, rotationID string) ([]*calendar.Event, error) {
+	events, err := c.api.ListEvents(ctx, calendarID, EventQuery{PrivateExtendedProperty: PropertyRotationID + "=" + rotationID})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events of rotation %s: %w", rotationID, err)
+	}
+	return events, nil
+}
+
+// Sync makes the existing events of a rotation match the planned ones. The
+// recurring events of every member still in the rotation are updated in
+// place, the ones of members who left are deleted and new members get their
+// events created. Single events, e.g. the slots of materialized rotations or
+// the ones drifting with daylight saving time, are matched to the plan by
+// their slot and member and updated in place too, and only the ones no
+// longer planned are deleted. Events already matching the plan are left
+// alone.
+//
+// Events are only updated or deleted if unchanged since they were listed, so
+// concurrent syncs or edits aren't silently overwritten: changed events are
+// fetched again and synced over their latest version.
+//
+// Up to Concurrency requests are sent at once, and Progress is called as they
+// complete. When Atomic is set and a request fails, or when ctx is cancelled,
+// the events created so far are deleted again.
+func (c *Client) Sync(ctx context.Context, calendarID string, existing []*calendar.Event, planned []rotation.Event) ([]Change, error) {
+	if err := c.checkColors(ctx, planned); err != nil {
+		return nil, err
+	}
+
+	// Members weighing more than one have a recurring event per turn.
+	turns := make(map[string]int)
+	for _, e := range planned {
+		if len(e.Recurrence) > 0 {
+			turns[e.Member]++
+		}
+	}
+
+	series := make(map[string][]*calendar.Event)
+	var singles []*calendar.Event
+	kept := make(map[*calendar.Event]bool)
+	for _, event := range existing {
+		member := ""
+		if event.ExtendedProperties != nil {
+			member = event.ExtendedProperties.Private[PropertyMember]
+		}
+		switch {
+		case len(event.Recurrence) > 0 && len(series[member]) < turns[member]:
+			series[member] = append(series[member], event)
+			kept[event] = true
+		case len(event.Recurrence) == 0:
+			singles = append(singles, event)
+		}
+	}
+
+	// Single events are matched to the ones planned for the same slot and
+	// member first, so unchanged slots are left alone, and then to the ones
+	// left in the same slot, so slots changing hands are updated in place.
+	// Either way they are paired in the order they start, as windowed
+	// rotations have an event per day.
+	events := make([]*calendar.Event, len(planned))
+	for i, e := range planned {
+		events[i] = newEvent(e)
+	}
+	matched := make(map[int]*calendar.Event)
+	for _, byMember := range []bool{true, false} {
+		current := make(map[string][]*calendar.Event)
+		for _, event := range singles {
+			if !kept[event] {
+				key := singleKey(event, byMember)
+				current[key] = append(current[key], event)
+			}
+		}
+		indexes := make(map[string][]int)
+		for i, e := range planned {
+			if len(e.Recurrence) == 0 && matched[i] == nil {
+				key := singleKey(events[i], byMember)
+				indexes[key] = append(indexes[key], i)
+			}
+		}
+		for key, planned := range indexes {
+			slices.SortStableFunc(planned, func(a, b int) int { return byStart(events[a], events[b]) })
+			slices.SortStableFunc(current[key], byStart)
+			for n := range min(len(planned), len(current[key])) {
+				matched[planned[n]] = current[key][n]
+				kept[current[key][n]] = true
+			}
+		}
+	}
+	var stale []*calendar.Event
+	for _, event := range existing {
+		if !kept[event] {
+			stale = append(stale, event)
+		}
+	}
+
+	// Every request fills its own change, so they are reported in order
+	// regardless of which one completes first.
+	results := make([]*Change, len(stale)+len(planned))
+	var mu sync.Mutex
+	var created []*calendar.Event
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(c.Concurrency, 1))
+	done := 0
+	// run sends a request, reporting the progress once it succeeded.
+	run := func(request func() error) {
+		g.Go(func() error {
+			if err := request(); err != nil {
+				return err
+			}
+			if c.Progress != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				done++
+				c.Progress(done, len(results))
+			}
+			return nil
+		})
+	}
+	for i, event := range stale {
+		run(func() error {
+			if c.DryRun {
+				results[i] = &Change{Action: "deleted", Summary: event.Summary}
+				return nil
+			}
+			if err := c.deleteUnchanged(gctx, calendarID, event); err != nil {
+				return err
+			}
+			results[i] = &Change{Action: "deleted", Summary: event.Summary}
+			return nil
+		})
+	}
+	for i, e := range planned {
+		event, current := events[i], matched[i]
+		if len(e.Recurrence) > 0 && len(series[e.Member]) > 0 {
+			current = series[e.Member][0]
+			series[e.Member] = series[e.Member][1:]
+		}
+		i += len(stale)
+		if current == nil {
+			run(func() error {
+				if c.DryRun {
+					results[i] = &Change{Action: "created", Summary: e.Summary}
+					return nil
+				}
+				event, err := c.InsertEvent(gctx, calendarID, e)
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				created = append(created, event)
+				mu.Unlock()
+				results[i] = &Change{Action: "created", Summary: event.Summary, Link: event.HtmlLink}
+				return nil
+			})
+			continue
+		}
+
+		run(func() error {
+			if sameEvent(current, event) {
+				return nil
+			}
+			if c.DryRun {
+				results[i] = &Change{Action: "updated", Summary: e.Summary, Link: current.HtmlLink}
+				return nil
+			}
+			event, err := c.updateUnchanged(gctx, calendarID, current, event)
+			if err != nil || event == nil {
+				return err
+			}
+			results[i] = &Change{Action: "updated", Summary: event.Summary, Link: event.HtmlLink}
+			return nil
+		})
+	}
+	err := g.Wait()
+
+	var changes []Change
+	for _, r := range results {
+		if r != nil {
+			changes = append(changes, *r)
+		}
+	}
+	// Created events are also rolled back when the sync is interrupted, so
+	// aborting doesn't leave half a rotation behind.
+	if err == nil || (!c.Atomic && ctx.Err() == nil) {
+		return changes, err
+	}
+
+	// The rollback must happen even when the sync was interrupted.
+	ctx = context.WithoutCancel(ctx)
+	for _, event := range created {
+		if rerr := c.DeleteEvent(ctx, calendarID, event.Id); rerr != nil {
+			return changes, fmt.Errorf("%w, and rolling back failed: %w", err, rerr)
+		}
+		changes = append(changes, Change{Action: "rolled back", Summary: event.Summary})
+	}
+	return changes, err
+}
+
+// singleKey returns the slot of a single event, along with its member when
+// byMember is set, matching it to the one planned for the same slot.
+func singleKey(event *calendar.Event, byMember bool) string {
+	if event.ExtendedProperties == nil {
+		return ""
+	}
+	private := event.ExtendedProperties.Private
+	if byMember {
+		return private[PropertySlotIndex] + "/" + private[PropertyMember]
+	}
+	return private[PropertySlotIndex]
+}
+
+// byStart orders events by start, then by summary, so the single events of
+// a slot are paired the same way on every sync.
+func byStart(a, b *calendar.Event) int {
+	startA, _ := ParseEventDateTime(a.Start, time.UTC)
+	startB, _ := ParseEventDateTime(b.Start, time.UTC)
+	return cmp.Or(startA.Compare(startB), cmp.Compare(a.Summary, b.Summary))
+}
+
+// updateUnchanged replaces the current event with the planned one if nobody
+// changed it since it was read. Otherwise it is fetched again and replaced
+// over its latest version, unless that already matches the plan, in which
+// case nil is returned.
+func (c *Client) updateUnchanged(ctx context.Context, calendarID string, current, planned *calendar.Event) (*calendar.Event, error) {
+	for attempt := 0; ; attempt++ {
+		planned.Id, planned.Etag = current.Id, current.Etag
+		updated, err := c.UpdateEvent(ctx, calendarID, planned)
+		if !isConflict(err) || attempt >= maxConflicts {
+			return updated, err
+		}
+		slog.Warn("Event changed during sync, fetching it again", "event", current.Id, "summary", current.Summary)
+		if current, err = c.api.GetEvent(ctx, calendarID, current.Id); err != nil {
+			return nil, fmt.Errorf("unable to fetch event %q again: %w", planned.Summary, err)
+		}
+		if sameEvent(current, planned) {
+			return nil, nil
+		}
+	}
+}
+
+// deleteUnchanged deletes a stale event if nobody changed it since it was
+// read. Otherwise it is fetched again and deleted in its latest version.
+// Events somebody deleted already count as deleted.
+func (c *Client) deleteUnchanged(ctx context.Context, calendarID string, event *calendar.Event) error {
+	for attempt := 0; ; attempt++ {
+		err := c.api.DeleteEvent(ctx, calendarID, event.Id, event.Etag, c.SendUpdates)
+		if err == nil || isGone(err) {
+			return nil
+		}
+		if !isConflict(err) || attempt >= maxConflicts {
+			return fmt.Errorf("unable to delete event %s: %w", event.Id, err)
+		}
+		slog.Warn("Event changed during sync, fetching it again", "event", event.Id, "summary", event.Summary)
+		latest, err := c.api.GetEvent(ctx, calendarID, event.Id)
+		if isGone(err) || (err == nil && latest.Status == "cancelled") {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to fetch event %s again: %w", event.Id, err)
+		}
+		event = latest
+	}
+}
+
+// isConflict reports whether a request failed because the event changed
+// since its etag was read.
+func isConflict(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
+
+// isGone reports whether a request failed because the event doesn't exist
+// anymore.
+func isGone(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone)
+}
+
+// sameEvent reports whether an existing event already matches the one
+// planned for it.
+func sameEvent(current, planned *calendar.Event) bool {
+	if current.Summary != planned.Summary || current.ColorId != planned.ColorId || current.Description != planned.Description {
+		return false
+	}
+	// Defaults are left out of the events returned.
+	if cmp.Or(current.Visibility, "default") != cmp.Or(planned.Visibility, "default") ||
+		cmp.Or(current.Transparency, "opaque") != cmp.Or(planned.Transparency, "opaque") ||
+		current.GuestsCanModify != planned.GuestsCanModify {
+		return false
+	}
+	if !sameDateTime(current.Start, planned.Start) || !sameDateTime(current.End, planned.End) {
+		return false
+	}
+	if !slices.Equal(current.Recurrence, planned.Recurrence) || len(current.Attendees) != len(planned.Attendees) {
+		return false
+	}
+	for i, a := range current.Attendees {
+		if a.Email != planned.Attendees[i].Email || a.Optional != planned.Attendees[i].Optional {
+			return false
+		}
+	}
+	return reminders(current) == reminders(planned) && sameProperties(current, planned)
+}
+
+// sameProperties reports whether an existing event has the private extended
+// properties planned for it, so events created before the tool set some of
+// them get them on the next sync. Properties added by people are ignored.
+func sameProperties(current, planned *calendar.Event) bool {
+	if current.ExtendedProperties == nil {
+		return false
+	}
+	for name, value := range planned.ExtendedProperties.Private {
+		if current.ExtendedProperties.Private[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDateTime reports whether two event boundaries are the same date, or the
+// same time in the same time zone. Times are compared as instants, as the
+// calendar may return them with another offset than they were sent with.
+func sameDateTime(current, planned *calendar.EventDateTime) bool {
+	if current == nil || current.Date != planned.Date {
+		return false
+	}
+	if planned.Date != "" {
+		return true
+	}
+	a, errA := time.Parse(time.RFC3339, current.DateTime)
+	b, errB := time.Parse(time.RFC3339, planned.DateTime)
+	return errA == nil && errB == nil && a.Equal(b) && current.TimeZone == planned.TimeZone
+}
+
+// reminders returns the reminder overrides of an event as a comparable
+// string, empty when it uses the default reminders.
+func reminders(event *calendar.Event) string {
+	if event.Reminders == nil || event.Reminders.UseDefault {
+		return ""
+	}
+	var overrides []string
+	for _, r := range event.Reminders.Overrides {
+		overrides = append(overrides, fmt.Sprintf("%s:%d", r.Method, r.Minutes))
+	}
+	slices.Sort(overrides)
+	return strings.Join(overrides, ",")
+}
+
+// checkColors returns an error if any of the events has a color the calendar
+// doesn't support.
+func (c *Client) checkColors(ctx context.Context, events []rotation.Event) error {
+	if !slices.ContainsFunc(events, func(e rotation.Event) bool { return e.ColorID != "" }) {
+		return nil
+	}
+	colors, err := c.api.GetColors(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get calendar colors: %w", err)
+	}
+	for _, e := range events {
+		if _, ok := colors.Event[e.ColorID]; e.ColorID != "" && !ok {
+			return fmt.Errorf("event %q has color %s, which is not a Calendar event color", e.Summary, e.ColorID)
+		}
+	}
+	return nil
+}
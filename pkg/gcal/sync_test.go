@@ -0,0 +1,166 @@
+package gcal_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/gcal/gcaltest"
+	"calendar/pkg/rotation"
+)
+
+// syncRotation plans the rotation and syncs it over its existing events.
+func syncRotation(t *testing.T, client *gcal.Client, r rotation.Rotation) []gcal.Change {
+	t.Helper()
+	ctx := context.Background()
+	events, err := rotation.Plan(r)
+	if err != nil {
+		t.Fatalf("unable to plan rotation: %v", err)
+	}
+	existing, err := client.ManagedEvents(ctx, "team", rotation.ID(r.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := client.Sync(ctx, "team", existing, events)
+	if err != nil {
+		t.Fatalf("unable to sync rotation: %v", err)
+	}
+	return changes
+}
+
+// countActions returns how many changes there are of every action.
+func countActions(changes []gcal.Change) map[string]int {
+	actions := make(map[string]int)
+	for _, c := range changes {
+		actions[c.Action]++
+	}
+	return actions
+}
+
+func TestSyncMaterialized(t *testing.T) {
+	f := gcaltest.NewFake("UTC")
+	f.AddCalendar("team", "Team", "UTC")
+	client := gcal.NewWithAPI(f)
+	r := rotation.Rotation{
+		Name:        "SRE Role",
+		Members:     []string{"Alice", "Bob", "Cesar"},
+		Start:       time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC),
+		Cadence:     rotation.Weeks(1),
+		Count:       6,
+		Materialize: true,
+	}
+
+	if got := countActions(syncRotation(t, client, r)); got["created"] != 6 || len(got) != 1 {
+		t.Fatalf("first sync: got changes %v, want 6 created", got)
+	}
+	ids := make(map[string]bool)
+	for _, event := range f.Events("team") {
+		ids[event.Id] = true
+	}
+
+	if changes := syncRotation(t, client, r); len(changes) != 0 {
+		t.Errorf("second sync: got changes %v, want none", countActions(changes))
+	}
+
+	// Dropping a member updates the slots in place and deletes none.
+	r.Members = []string{"Alice", "Bob"}
+	delete(r.Emails, "Cesar")
+	if got := countActions(syncRotation(t, client, r)); got["deleted"] != 0 || got["created"] != 0 {
+		t.Errorf("sync without Cesar: got changes %v, want updates only", got)
+	}
+	for _, event := range f.Events("team") {
+		if !ids[event.Id] {
+			t.Errorf("event %s (%q) was recreated", event.Id, event.Summary)
+		}
+	}
+
+	// Shortening the rotation deletes the slots no longer planned.
+	r.Count = 4
+	if got := countActions(syncRotation(t, client, r)); got["deleted"] != 2 || len(got) != 1 {
+		t.Errorf("sync of 4 slots: got changes %v, want 2 deleted", got)
+	}
+}
+
+func TestSyncWindowed(t *testing.T) {
+	f := gcaltest.NewFake("UTC")
+	f.AddCalendar("team", "Team", "UTC")
+	client := gcal.NewWithAPI(f)
+	r := rotation.Rotation{
+		Name:    "Support",
+		Members: []string{"Alice", "Bob"},
+		Start:   time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC),
+		Cadence: rotation.Weeks(1),
+		Count:   2,
+		Window:  "Mon-Fri 09:00-17:00",
+	}
+
+	if got := countActions(syncRotation(t, client, r)); got["created"] == 0 || len(got) != 1 {
+		t.Fatalf("first sync: got changes %v, want only created", got)
+	}
+	if changes := syncRotation(t, client, r); len(changes) != 0 {
+		t.Errorf("second sync: got changes %v, want none", countActions(changes))
+	}
+}
+
+func TestSyncHandoffTimeAcrossDST(t *testing.T) {
+	f := gcaltest.NewFake("UTC")
+	f.AddCalendar("team", "Team", "UTC")
+	client := gcal.NewWithAPI(f)
+	// Tokyo has no daylight saving time, so the handoffs in New York drift
+	// by an hour twice a year and most slots are single events.
+	r := rotation.Rotation{
+		Name:        "Follow the Sun",
+		Members:     []string{"Alice", "Kenji"},
+		Start:       time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC),
+		Cadence:     rotation.Weeks(1),
+		Until:       time.Date(2031, time.December, 31, 0, 0, 0, 0, time.UTC),
+		HandoffTime: "09:00",
+		TimeZone:    "America/New_York",
+		TimeZones:   map[string]string{"Alice": "America/New_York", "Kenji": "Asia/Tokyo"},
+	}
+	events, err := rotation.Plan(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	singles := 0
+	for _, e := range events {
+		if len(e.Recurrence) == 0 {
+			singles++
+		}
+	}
+	if singles == 0 {
+		t.Fatalf("planned %d events, none single, want slots drifting with DST", len(events))
+	}
+
+	if got := countActions(syncRotation(t, client, r)); got["created"] != len(events) {
+		t.Fatalf("first sync: got changes %v, want %d created", got, len(events))
+	}
+	if changes := syncRotation(t, client, r); len(changes) != 0 {
+		t.Errorf("second sync: got changes %v, want none", countActions(changes))
+	}
+}
+
+func TestSyncRecurring(t *testing.T) {
+	f, client, r := newRotationClient(t)
+	if n := len(f.Events("team")); n != 3 {
+		t.Fatalf("got %d events, want a series per member", n)
+	}
+
+	if changes := syncRotation(t, client, r); len(changes) != 0 {
+		t.Errorf("second sync: got changes %v, want none", countActions(changes))
+	}
+
+	// Members who left lose their series, the others are updated in place.
+	r.Members = []string{"Alice", "Bob"}
+	delete(r.Emails, "Cesar")
+	if got := countActions(syncRotation(t, client, r)); got["deleted"] != 1 || got["updated"] != 2 || got["created"] != 0 {
+		t.Errorf("sync without Cesar: got changes %v, want 1 deleted and 2 updated", got)
+	}
+
+	// Newcomers get their series created.
+	r.Members = []string{"Alice", "Bob", "Dana"}
+	if got := countActions(syncRotation(t, client, r)); got["created"] != 1 || got["deleted"] != 0 {
+		t.Errorf("sync with Dana: got changes %v, want 1 created", got)
+	}
+}
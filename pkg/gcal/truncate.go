@@ -0,0 +1,87 @@
+package gcal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// PropertyArchivedRotationID replaces PropertyRotationID on the events of a
+// rotation that were truncated, so they are kept as history rather than
+// synced again.
+const PropertyArchivedRotationID = "archivedRotationId"
+
+// Truncate ends the existing events of a rotation the day before from, so a
+// new version of the rotation can take over from then. Recurring events
+// stop repeating, events starting from then on are deleted, and the
+// remaining ones are archived.
+func (c *Client) Truncate(ctx context.Context, calendarID string, existing []*calendar.Event, from time.Time) ([]Change, error) {
+	loc, err := c.location(ctx, calendarID)
+	if err != nil {
+		return nil, err
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	until := "UNTIL=" + from.AddDate(0, 0, -1).Format("20060102")
+
+	var changes []Change
+	for _, event := range existing {
+		start, err := ParseEventDateTime(event.Start, loc)
+		if err != nil {
+			return changes, fmt.Errorf("unable to parse start of event %q: %w", event.Summary, err)
+		}
+		if !start.Before(from) {
+			if err := c.DeleteEvent(ctx, calendarID, event.Id); err != nil {
+				return changes, err
+			}
+			changes = append(changes, Change{Action: "deleted", Summary: event.Summary, Link: event.HtmlLink})
+			continue
+		}
+
+		if len(event.Recurrence) > 0 {
+			// Series that already end before the cut-over are left as is,
+			// whether they end with an UNTIL or a COUNT.
+			upcoming, err := c.api.ListEvents(ctx, calendarID, EventQuery{RecurringEventID: event.Id, TimeMin: from, MaxResults: 1})
+			if err != nil {
+				return changes, fmt.Errorf("unable to list occurrences of event %q: %w", event.Summary, err)
+			}
+			if len(upcoming) > 0 {
+				event.Recurrence = endRecurrence(event.Recurrence, until)
+			}
+		}
+		if event.ExtendedProperties != nil && event.ExtendedProperties.Private != nil {
+			private := event.ExtendedProperties.Private
+			private[PropertyArchivedRotationID] = private[PropertyRotationID]
+			delete(private, PropertyRotationID)
+		}
+		event, err := c.UpdateEvent(ctx, calendarID, event)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, Change{Action: "truncated", Summary: event.Summary, Link: event.HtmlLink})
+	}
+	return changes, nil
+}
+
+// endRecurrence replaces the end of the RRULEs with the given UNTIL part.
+func endRecurrence(recurrence []string, until string) []string {
+	truncated := make([]string, 0, len(recurrence))
+	for _, line := range recurrence {
+		rule, ok := strings.CutPrefix(line, "RRULE:")
+		if !ok {
+			truncated = append(truncated, line)
+			continue
+		}
+		parts := []string{}
+		for _, part := range strings.Split(rule, ";") {
+			if strings.HasPrefix(part, "UNTIL=") || strings.HasPrefix(part, "COUNT=") {
+				continue
+			}
+			parts = append(parts, part)
+		}
+		truncated = append(truncated, "RRULE:"+strings.Join(append(parts, until), ";"))
+	}
+	return truncated
+}
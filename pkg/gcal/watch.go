@@ -0,0 +1,60 @@
+package gcal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Headers of the push notifications sent to a watch channel.
+const (
+	HeaderChannelID     = "X-Goog-Channel-Id"
+	HeaderChannelToken  = "X-Goog-Channel-Token"
+	HeaderResourceState = "X-Goog-Resource-State"
+)
+
+// ResourceStateSync is the resource state of the first notification of a
+// channel, sent once it is opened rather than on a change.
+const ResourceStateSync = "sync"
+
+// Watch opens a channel notifying address, an HTTPS URL, of the changes to
+// the events of a calendar. The token is sent along every notification so
+// they can be told from forged ones. The API picks the time to live of the
+// channel when ttl is zero.
+func (c *Client) Watch(ctx context.Context, calendarID, address, token string, ttl time.Duration) (*calendar.Channel, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	channel := &calendar.Channel{Id: hex.EncodeToString(id), Type: "web_hook", Address: address, Token: token}
+	if ttl > 0 {
+		channel.Params = map[string]string{"ttl": strconv.Itoa(int(ttl.Seconds()))}
+	}
+	opened, err := c.api.WatchEvents(ctx, calendarID, channel)
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch calendar %s: %w", calendarID, err)
+	}
+	return opened, nil
+}
+
+// StopWatch stops the notifications of a channel opened by Watch.
+func (c *Client) StopWatch(ctx context.Context, channel *calendar.Channel) error {
+	if err := c.api.StopChannel(ctx, channel); err != nil {
+		return fmt.Errorf("unable to stop channel %s: %w", channel.Id, err)
+	}
+	return nil
+}
+
+// ChannelExpiration returns when a channel stops sending notifications, or
+// the zero time if it doesn't expire.
+func ChannelExpiration(channel *calendar.Channel) time.Time {
+	if channel.Expiration == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(channel.Expiration)
+}
@@ -0,0 +1,122 @@
+// Package github reads team memberships from GitHub, so rotations can follow
+// the teams as people join and leave.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultURL is the base URL of the GitHub REST API.
+const DefaultURL = "https://api.github.com"
+
+// Client talks to the GitHub REST API.
+type Client struct {
+	// Token is a GitHub token allowed to read the organization's teams,
+	// e.g. a fine-grained token with the members read permission.
+	Token string
+	// URL is the base URL of the API, DefaultURL when empty, e.g. for
+	// GitHub Enterprise Server.
+	URL string
+	// HTTP is the client used for requests, http.DefaultClient when nil.
+	HTTP *http.Client
+}
+
+// ParseTeam splits a team given as org/team-slug.
+func ParseTeam(s string) (org, team string, err error) {
+	org, team, ok := strings.Cut(s, "/")
+	if !ok || org == "" || team == "" || strings.Contains(team, "/") {
+		return "", "", fmt.Errorf("invalid GitHub team %q, expected org/team-slug", s)
+	}
+	return org, team, nil
+}
+
+// TeamMembers returns the logins of the members of a team, including the
+// members of its child teams.
+func (c *Client) TeamMembers(ctx context.Context, org, team string) ([]string, error) {
+	base := strings.TrimSuffix(c.URL, "/")
+	if base == "" {
+		base = DefaultURL
+	}
+	var logins []string
+	next := base + "/orgs/" + url.PathEscape(org) + "/teams/" + url.PathEscape(team) + "/members?per_page=100"
+	for next != "" {
+		var page []struct {
+			Login string `json:"login"`
+		}
+		var err error
+		next, err = c.get(ctx, next, &page)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list members of team %s/%s: %w", org, team, err)
+		}
+		for _, m := range page {
+			logins = append(logins, m.Login)
+		}
+	}
+	return logins, nil
+}
+
+// LoadEmails reads a YAML file mapping GitHub logins to emails, e.g.
+//
+//	octocat: octocat@example.com
+//	hubot: hubot@example.com
+func LoadEmails(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read GitHub emails file: %w", err)
+	}
+	var emails map[string]string
+	if err := yaml.Unmarshal(b, &emails); err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub emails file %s: %w", path, err)
+	}
+	return emails, nil
+}
+
+// nextLink matches the URL of the next page in a Link header.
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// get decodes the JSON response to a GET request into out, returning the
+// URL of the next page, if any.
+func (c *Client) get(ctx context.Context, rawURL string, out any) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("github returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return "", err
+	}
+	if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		return m[1], nil
+	}
+	return "", nil
+}
@@ -0,0 +1,145 @@
+// Package ics writes rotations as iCalendar (RFC 5545) files, so they can be
+// imported into calendar applications other than Google Calendar.
+package ics
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// Write writes the events as an iCalendar file. Timestamps are set to now.
+func Write(w io.Writer, events []rotation.Event, now time.Time) error {
+	iw := &writer{w: w}
+	iw.line("BEGIN:VCALENDAR")
+	iw.line("VERSION:2.0")
+	iw.line("PRODID:-//team-calendar//rotation//EN")
+	iw.line("CALSCALE:GREGORIAN")
+	for _, e := range events {
+		iw.line("BEGIN:VEVENT")
+		iw.line("UID:" + UID(e))
+		iw.line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		if e.Timed {
+			// Recurrences follow the daylight saving time changes of the
+			// zone, so times are local to it rather than UTC.
+			iw.line("DTSTART;TZID=" + e.TimeZone + ":" + e.Start.Format("20060102T150405"))
+			iw.line("DTEND;TZID=" + e.TimeZone + ":" + e.End.Format("20060102T150405"))
+		} else {
+			iw.line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+			iw.line("DTEND;VALUE=DATE:" + e.End.Format("20060102"))
+		}
+		iw.line("SUMMARY:" + escape(e.Summary))
+		// The recurrence lines of the events are already in iCalendar format.
+		for _, r := range e.Recurrence {
+			iw.line(r)
+		}
+		for _, a := range e.Attendees {
+			role := "REQ-PARTICIPANT"
+			if a.Optional {
+				role = "OPT-PARTICIPANT"
+			}
+			iw.line(fmt.Sprintf("ATTENDEE;ROLE=%s:mailto:%s", role, a.Email))
+		}
+		if e.Description != "" {
+			iw.line("DESCRIPTION:" + escape(e.Description))
+		}
+		switch e.Visibility {
+		case rotation.VisibilityPublic:
+			iw.line("CLASS:PUBLIC")
+		case rotation.VisibilityPrivate:
+			iw.line("CLASS:PRIVATE")
+		}
+		// iCalendar has no way to let attendees modify events, so
+		// GuestsCanModify is left out.
+		if e.Transparency == rotation.TransparencyBusy {
+			iw.line("TRANSP:OPAQUE")
+		} else {
+			iw.line("TRANSP:TRANSPARENT")
+		}
+		for _, r := range e.Reminders {
+			iw.alarm(e, r)
+		}
+		if e.RotationID != "" {
+			iw.line(PropertyRotationID + ":" + escape(e.RotationID))
+			iw.line(PropertyMember + ":" + escape(e.Member))
+		}
+		iw.line("END:VEVENT")
+	}
+	iw.line("END:VCALENDAR")
+	return iw.err
+}
+
+// Non-standard properties set on the events of a rotation, so they can be
+// found again regardless of how they are renamed.
+const (
+	PropertyRotationID = "X-TEAM-CALENDAR-ROTATION-ID"
+	PropertyMember     = "X-TEAM-CALENDAR-MEMBER"
+)
+
+// UID returns a stable identifier for the event, so re-importing an updated
+// file replaces the events instead of duplicating them.
+func UID(e rotation.Event) string {
+	sum := sha1.Sum([]byte(e.Summary + "/" + e.Start.Format(time.DateOnly)))
+	return fmt.Sprintf("%x@team-calendar", sum)
+}
+
+// alarm writes the VALARM of a reminder. Email alarms are sent to the
+// required attendees, so without any they are shown as popups instead.
+func (w *writer) alarm(e rotation.Event, r rotation.Reminder) {
+	var to []string
+	for _, a := range e.Attendees {
+		if !a.Optional {
+			to = append(to, a.Email)
+		}
+	}
+	w.line("BEGIN:VALARM")
+	w.line(fmt.Sprintf("TRIGGER:-PT%dM", r.Minutes))
+	if r.Method == rotation.ReminderEmail && len(to) > 0 {
+		w.line("ACTION:EMAIL")
+		w.line("SUMMARY:" + escape(e.Summary))
+		for _, email := range to {
+			w.line("ATTENDEE:mailto:" + email)
+		}
+	} else {
+		w.line("ACTION:DISPLAY")
+	}
+	w.line("DESCRIPTION:" + escape(e.Summary))
+	w.line("END:VALARM")
+}
+
+// escape escapes the characters that have a special meaning in text values.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// writer writes content lines, folding them at 75 octets and terminating
+// them with CRLF as required by the RFC.
+type writer struct {
+	w   io.Writer
+	err error
+}
+
+func (w *writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+	limit := 75
+	var b strings.Builder
+	for len(s) > limit {
+		// Don't split multi-byte UTF-8 sequences.
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space.
+		limit = 74
+	}
+	b.WriteString(s + "\r\n")
+	_, w.err = io.WriteString(w.w, b.String())
+}
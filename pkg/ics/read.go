@@ -0,0 +1,111 @@
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Property is a content line of an iCalendar component, e.g.
+// DTSTART;VALUE=DATE:20240701.
+type Property struct {
+	Params map[string]string
+	Value  string
+}
+
+// VEvent holds the properties of an event by name. Only the first of the
+// properties that can be repeated is kept.
+type VEvent map[string]Property
+
+// Text returns the unescaped value of a text property.
+func (e VEvent) Text(name string) string {
+	return unescape(e[name].Value)
+}
+
+// Date returns the day of a DTSTART or DTEND property in loc, whether it is
+// a date or a date-time.
+func (e VEvent) Date(name string, loc *time.Location) (time.Time, error) {
+	p, ok := e[name]
+	if !ok {
+		return time.Time{}, fmt.Errorf("missing %s", name)
+	}
+	if len(p.Value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid %s %q", name, p.Value)
+	}
+	return time.ParseInLocation("20060102", p.Value[:8], loc)
+}
+
+// ReadEvents returns the events of an iCalendar file, including the ones of
+// every calendar it holds.
+func ReadEvents(r io.Reader) ([]VEvent, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Folded lines continue with a space or a tab.
+		if n := len(lines); n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[n-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var events []VEvent
+	var current VEvent
+	depth := 0
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			current = make(VEvent)
+			depth = 0
+			continue
+		case "END:VEVENT":
+			if current != nil {
+				events = append(events, current)
+			}
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		// Properties of nested components, e.g. alarms, are skipped.
+		if strings.HasPrefix(line, "BEGIN:") {
+			depth++
+			continue
+		}
+		if strings.HasPrefix(line, "END:") {
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+
+		nameParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid content line %q", line)
+		}
+		parts := strings.Split(nameParams, ";")
+		name := strings.ToUpper(parts[0])
+		if _, ok := current[name]; ok {
+			continue
+		}
+		p := Property{Value: value, Params: make(map[string]string)}
+		for _, param := range parts[1:] {
+			k, v, _ := strings.Cut(param, "=")
+			p.Params[strings.ToUpper(k)] = v
+		}
+		current[name] = p
+	}
+	return events, nil
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Anthropic is a Provider using the Anthropic Messages API.
+type Anthropic struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+// Complete implements Provider.
+func (a *Anthropic) Complete(ctx context.Context, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	req := struct {
+		Model     string    `json:"model"`
+		MaxTokens int       `json:"max_tokens"`
+		Messages  []message `json:"messages"`
+	}{Model: a.Model, MaxTokens: 1024, Messages: []message{{Role: "user", Content: prompt}}}
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+
+	headers := map[string]string{
+		"x-api-key":         a.APIKey,
+		"anthropic-version": "2023-06-01",
+	}
+	if err := postJSON(ctx, a.URL+"/messages", headers, req, &resp); err != nil {
+		return "", fmt.Errorf("anthropic request failed: %w", err)
+	}
+	var text strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String(), nil
+}
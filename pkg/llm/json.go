@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Validator is implemented by the answers requested with CompleteJSON, to
+// reject the ones that parse but make no sense.
+type Validator interface {
+	Validate() error
+}
+
+// CompleteJSON asks the model for a JSON object and decodes it into out,
+// which must be a pointer. Models often wrap the object in text or code
+// fences, so only the outermost object is decoded, and unknown fields are
+// rejected. Every answer is decoded over the value out had when called, so
+// it can hold what Validate needs, e.g. in unexported fields. When the
+// answer can't be decoded or fails validation, the prompt is sent again
+// along with the error, up to retries more times.
+func CompleteJSON(ctx context.Context, p Provider, prompt string, out Validator, retries int) error {
+	ask := prompt
+	initial := reflect.ValueOf(reflect.ValueOf(out).Elem().Interface())
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		var answer string
+		answer, err = p.Complete(ctx, ask)
+		if err != nil {
+			return err
+		}
+		slog.Debug("LLM answered", "attempt", attempt+1, "output", answer)
+
+		// Fields of a rejected answer must not leak into the next one.
+		reflect.ValueOf(out).Elem().Set(initial)
+		if err = decodeJSON(answer, out); err == nil {
+			if err = out.Validate(); err == nil {
+				return nil
+			}
+		}
+		slog.Debug("LLM answer rejected", "attempt", attempt+1, "err", err)
+		ask = fmt.Sprintf(`%s
+
+Your previous answer was:
+%s
+
+It was rejected because: %v
+Answer again with only the corrected JSON object.`, prompt, strings.TrimSpace(answer), err)
+	}
+	return fmt.Errorf("invalid answer from LLM after %d attempts: %w", retries+1, err)
+}
+
+// decodeJSON decodes the outermost JSON object of answer into out.
+func decodeJSON(answer string, out any) error {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return errors.New("no JSON object found")
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(answer[start : end+1])))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("invalid JSON object: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package llm talks to large language models used to turn natural language
+// requests into rotations.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Supported backends.
+const (
+	BackendOllama    = "ollama"
+	BackendOpenAI    = "openai"
+	BackendAnthropic = "anthropic"
+)
+
+// Provider is a large language model backend.
+type Provider interface {
+	// Complete sends the prompt to the model and returns its answer.
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// New returns the Provider for the given backend. An empty model or URL picks
+// the backend default. API keys are read from OPENAI_API_KEY and
+// ANTHROPIC_API_KEY respectively.
+func New(backend, model, url string) (Provider, error) {
+	switch backend {
+	case BackendOllama:
+		return &Ollama{URL: orDefault(url, DefaultURL(backend)), Model: orDefault(model, "llama3")}, nil
+	case BackendOpenAI:
+		return &OpenAI{URL: orDefault(url, DefaultURL(backend)), Model: orDefault(model, "gpt-4o-mini"), APIKey: os.Getenv("OPENAI_API_KEY")}, nil
+	case BackendAnthropic:
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("ANTHROPIC_API_KEY must be set to use the %s backend", backend)
+		}
+		return &Anthropic{URL: orDefault(url, DefaultURL(backend)), Model: orDefault(model, "claude-3-5-sonnet-20240620"), APIKey: apiKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM backend %q, must be one of: %s, %s, %s", backend, BackendOllama, BackendOpenAI, BackendAnthropic)
+	}
+}
+
+// DefaultURL returns the base URL of the API of a backend used when none is
+// given, or "" for unknown backends.
+func DefaultURL(backend string) string {
+	switch backend {
+	case BackendOllama:
+		return "http://localhost:11434"
+	case BackendOpenAI:
+		return "https://api.openai.com/v1"
+	case BackendAnthropic:
+		return "https://api.anthropic.com/v1"
+	}
+	return ""
+}
+
+// Reachable reports whether the API of a backend at url, or at its default
+// URL when empty, answers HTTP requests, whatever the answer, e.g. an
+// authentication error.
+func Reachable(ctx context.Context, backend, url string) error {
+	url = orDefault(url, DefaultURL(backend))
+	if url == "" {
+		return fmt.Errorf("unknown LLM backend %q, must be one of: %s, %s, %s", backend, BackendOllama, BackendOpenAI, BackendAnthropic)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s is unreachable: %w", url, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// postJSON sends body as JSON to url and decodes the JSON response into out.
+func postJSON(ctx context.Context, url string, headers map[string]string, body, out any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ollama is a Provider using the HTTP API of an ollama server.
+type Ollama struct {
+	URL   string
+	Model string
+}
+
+// Complete implements Provider.
+func (o *Ollama) Complete(ctx context.Context, prompt string) (string, error) {
+	req := struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+		Stream bool   `json:"stream"`
+	}{Model: o.Model, Prompt: prompt}
+	var resp struct {
+		Response string `json:"response"`
+	}
+	if err := postJSON(ctx, o.URL+"/api/generate", nil, req, &resp); err != nil {
+		return "", fmt.Errorf("ollama request failed: %w", err)
+	}
+	return resp.Response, nil
+}
@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// OpenAI is a Provider for OpenAI and any endpoint compatible with its chat
+// completions API, e.g. vLLM or LocalAI.
+type OpenAI struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+// Complete implements Provider.
+func (o *OpenAI) Complete(ctx context.Context, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	req := struct {
+		Model    string    `json:"model"`
+		Messages []message `json:"messages"`
+	}{Model: o.Model, Messages: []message{{Role: "user", Content: prompt}}}
+	var resp struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+
+	var headers map[string]string
+	if o.APIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + o.APIKey}
+	}
+	if err := postJSON(ctx, o.URL+"/chat/completions", headers, req, &resp); err != nil {
+		return "", fmt.Errorf("openai request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("openai returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
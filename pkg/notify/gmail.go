@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// GmailScope is the OAuth scope needed to send handoff emails.
+const GmailScope = gmail.GmailSendScope
+
+// Gmail emails handoff summaries from the authorized Google account.
+type Gmail struct {
+	// Emails maps members to their email address, so the incoming member
+	// gets the summary.
+	Emails map[string]string
+	// Team lists the addresses copied on every summary, e.g. the team's
+	// mailing list.
+	Team []string
+
+	srv *gmail.Service
+}
+
+// NewGmail returns a Gmail notifier using an already authorized HTTP client.
+func NewGmail(ctx context.Context, httpClient *http.Client) (*Gmail, error) {
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Gmail client: %w", err)
+	}
+	return &Gmail{srv: srv}, nil
+}
+
+// Notify emails the member of the slot and the team that the member is now
+// on rotation.
+func (g *Gmail) Notify(ctx context.Context, slot rotation.Slot) error {
+	var to []string
+	if email, ok := g.Emails[slot.Member]; ok {
+		to = append(to, email)
+	}
+	if len(to) == 0 && len(g.Team) == 0 {
+		return fmt.Errorf("no email address for %s and no team to notify", slot.Member)
+	}
+
+	msg := &gmail.Message{Raw: base64.URLEncoding.EncodeToString(HandoffEmail(slot, to, g.Team))}
+	if _, err := g.srv.Users.Messages.Send("me", msg).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+	return nil
+}
+
+// HandoffEmail returns the RFC 2822 message summarizing a handoff, sent to
+// the given addresses and copied to cc.
+func HandoffEmail(slot rotation.Slot, to, cc []string) []byte {
+	var b bytes.Buffer
+	if len(to) == 0 {
+		to, cc = cc, nil
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	if len(cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\r\n", strings.Join(cc, ", "))
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", fmt.Sprintf("%s handoff: %s", slot.Rotation, slot.Member)))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "%s.\r\n\r\n", HandoffMessage(slot, slot.Member))
+	// Slots end at midnight, so the last day is the one before.
+	fmt.Fprintf(&b, "Slot: %s to %s\r\n", slot.Start.Format(time.DateOnly), slot.End.AddDate(0, 0, -1).Format(time.DateOnly))
+	if slot.Link != "" {
+		fmt.Fprintf(&b, "Event: %s\r\n", slot.Link)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"calendar/pkg/rotation"
+)
+
+// Matrix posts handoff messages to a Matrix room.
+type Matrix struct {
+	// Homeserver is the base URL of the homeserver, e.g.
+	// https://matrix.example.com.
+	Homeserver string
+	// Room is the ID of the room to post to, e.g. !abc123:example.com,
+	// which the user of Token must have joined.
+	Room string
+	// Token is the access token of the user posting.
+	Token string
+	// Users maps members to their Matrix user IDs, so they get mentioned,
+	// e.g. @seth:example.com.
+	Users map[string]string
+}
+
+// Notify announces that the member of the slot is now on rotation. The
+// message of a handoff is only posted once, however many times it's sent.
+func (m *Matrix) Notify(ctx context.Context, slot rotation.Slot) error {
+	member := slot.Member
+	if id, ok := m.Users[member]; ok {
+		member = id
+	}
+	// The transaction ID makes the homeserver ignore the retries of a
+	// message already posted.
+	txn := fmt.Sprintf("%s-%s-%d", rotation.ID(slot.Rotation), rotation.ID(slot.Member), slot.Start.Unix())
+	u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", strings.TrimSuffix(m.Homeserver, "/"), url.PathEscape(m.Room), url.PathEscape(txn))
+	header := http.Header{"Authorization": {"Bearer " + m.Token}}
+	return sendJSON(ctx, "matrix", http.MethodPut, u, header, map[string]string{
+		"msgtype": "m.text",
+		"body":    HandoffMessage(slot, member),
+	})
+}
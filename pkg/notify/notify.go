@@ -0,0 +1,65 @@
+// Package notify announces rotation handoffs to the team.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"calendar/pkg/rotation"
+)
+
+// Notifier announces that the member of a slot is now on rotation, e.g. on
+// a chat channel or by email.
+type Notifier interface {
+	Notify(ctx context.Context, slot rotation.Slot) error
+}
+
+// EmergencyNotifier also announces handoffs made ahead of schedule, e.g.
+// when the member on rotation is sick, with their reason.
+type EmergencyNotifier interface {
+	NotifyEmergency(ctx context.Context, slot rotation.Slot, reason string) error
+}
+
+var (
+	_ EmergencyNotifier = (*Slack)(nil)
+	_ EmergencyNotifier = (*Webhook)(nil)
+	_ Notifier          = (*Slack)(nil)
+	_ Notifier          = (*Teams)(nil)
+	_ Notifier          = (*Matrix)(nil)
+	_ Notifier          = (*SMTP)(nil)
+	_ Notifier          = (*Gmail)(nil)
+	_ Notifier          = (*Webhook)(nil)
+)
+
+// sendJSON sends v as JSON to the URL of a service, with the given headers,
+// returning an error naming the service unless it answers with a 2xx.
+func sendJSON(ctx context.Context, service, method, url string, header http.Header, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to post to %s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	// Endpoints commonly answer 202 Accepted or 204 No Content.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %s: %s", service, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// Slack posts handoff messages to a Slack incoming webhook.
+type Slack struct {
+	// WebhookURL is the incoming webhook of the channel to post to.
+	WebhookURL string
+	// Users maps members to their Slack user IDs, so they get mentioned.
+	Users map[string]string
+}
+
+// Notify announces that the member of the slot is now on rotation.
+func (s *Slack) Notify(ctx context.Context, slot rotation.Slot) error {
+	member := slot.Member
+	if id, ok := s.Users[member]; ok {
+		member = fmt.Sprintf("<@%s>", id)
+	}
+	return s.post(ctx, HandoffMessage(slot, member))
+}
+
+// NotifyEmergency announces that the member of the slot took over ahead of
+// schedule, and why.
+func (s *Slack) NotifyEmergency(ctx context.Context, slot rotation.Slot, reason string) error {
+	member := slot.Member
+	if id, ok := s.Users[member]; ok {
+		member = fmt.Sprintf("<@%s>", id)
+	}
+	return s.post(ctx, fmt.Sprintf(":rotating_light: Emergency handoff: %s (%s)", HandoffMessage(slot, member), reason))
+}
+
+func (s *Slack) post(ctx context.Context, text string) error {
+	return sendJSON(ctx, "slack", http.MethodPost, s.WebhookURL, nil, map[string]string{"text": text})
+}
+
+// HandoffMessage returns the text announcing a handoff, with the member
+// written as given, e.g. a mention.
+func HandoffMessage(slot rotation.Slot, member string) string {
+	return fmt.Sprintf("%s is now %s until %s", member, slot.Rotation, slot.End.Format(time.DateOnly))
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+
+	"calendar/pkg/rotation"
+)
+
+// SMTP emails handoff summaries through an SMTP server, e.g. the company
+// relay, for teams not on Gmail.
+type SMTP struct {
+	// Address is the host and port of the server, e.g. smtp.example.com:587.
+	// STARTTLS is used when the server offers it.
+	Address string
+	// Username and Password authenticate with the server, unless empty.
+	Username string
+	Password string
+	// From is the address the emails are sent from.
+	From string
+	// Emails maps members to their email address, so the incoming member
+	// gets the summary.
+	Emails map[string]string
+	// Team lists the addresses copied on every summary, e.g. the team's
+	// mailing list.
+	Team []string
+}
+
+// Notify emails the member of the slot and the team that the member is now
+// on rotation.
+func (s *SMTP) Notify(ctx context.Context, slot rotation.Slot) error {
+	var to []string
+	if email, ok := s.Emails[slot.Member]; ok {
+		to = append(to, email)
+	}
+	if len(to) == 0 && len(s.Team) == 0 {
+		return fmt.Errorf("no email address for %s and no team to notify", slot.Member)
+	}
+
+	msg := append([]byte("From: "+s.From+"\r\n"), HandoffEmail(slot, to, s.Team)...)
+	if err := s.send(ctx, append(to, s.Team...), msg); err != nil {
+		return fmt.Errorf("unable to send email: %w", err)
+	}
+	return nil
+}
+
+// send mails msg to the recipients like smtp.SendMail, over a connection
+// closed when ctx is done so a slow server doesn't hold up shutting down.
+func (s *SMTP) send(ctx context.Context, recipients []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.Address)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", s.Address, err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return cmp.Or(ctx.Err(), err)
+	}
+	defer c.Close()
+	if err := s.converse(c, host, recipients, msg); err != nil {
+		return cmp.Or(ctx.Err(), err)
+	}
+	return nil
+}
+
+// converse sends msg to the recipients over an SMTP session, upgrading to
+// TLS and authenticating first when possible.
+func (s *SMTP) converse(c *smtp.Client, host string, recipients []string, msg []byte) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
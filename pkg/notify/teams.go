@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+
+	"calendar/pkg/rotation"
+)
+
+// Teams posts handoff messages to a Microsoft Teams incoming webhook.
+type Teams struct {
+	// WebhookURL is the incoming webhook of the channel to post to.
+	WebhookURL string
+}
+
+// Notify announces that the member of the slot is now on rotation.
+func (t *Teams) Notify(ctx context.Context, slot rotation.Slot) error {
+	text := HandoffMessage(slot, slot.Member)
+	if slot.Link != "" {
+		text += " ([event](" + slot.Link + "))"
+	}
+	card := map[string]string{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  slot.Rotation + " handoff",
+		"text":     text,
+	}
+	return sendJSON(ctx, "teams", http.MethodPost, t.WebhookURL, nil, card)
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+
+	"calendar/pkg/rotation"
+)
+
+// Events posted to a webhook.
+const (
+	// WebhookCreated is posted once a rotation is created, with its first
+	// slot.
+	WebhookCreated = "created"
+	// WebhookUpdated is posted once an existing rotation is updated in
+	// place, with its first slot.
+	WebhookUpdated = "updated"
+	// WebhookHandoff is posted when a member's slot starts.
+	WebhookHandoff = "handoff"
+	// WebhookEmergency is posted when a member takes over ahead of
+	// schedule, with the reason.
+	WebhookEmergency = "emergency"
+)
+
+// WebhookPayload is the JSON body posted to a webhook, e.g.
+//
+//	{
+//	  "event": "handoff",
+//	  "rotation": "SRE Role",
+//	  "member": "Seth",
+//	  "slot": {"rotation": "SRE Role", "member": "Seth", "start": "2024-07-08T00:00:00+02:00", "end": "2024-07-15T00:00:00+02:00"}
+//	}
+type WebhookPayload struct {
+	Event    string        `json:"event"`
+	Rotation string        `json:"rotation"`
+	Member   string        `json:"member"`
+	Slot     rotation.Slot `json:"slot"`
+	// Reason is why the handoff happened, for emergency ones.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Webhook posts rotation changes and handoffs to a generic HTTP endpoint, so
+// downstream automation, e.g. status pages or bots, can react without
+// polling the calendar.
+type Webhook struct {
+	// URL is the endpoint the payloads are posted to.
+	URL string
+}
+
+// Notify posts that the member of the slot is now on rotation.
+func (w *Webhook) Notify(ctx context.Context, slot rotation.Slot) error {
+	return w.Post(ctx, WebhookHandoff, slot)
+}
+
+// NotifyEmergency posts that the member of the slot took over ahead of
+// schedule, and why.
+func (w *Webhook) NotifyEmergency(ctx context.Context, slot rotation.Slot, reason string) error {
+	return w.send(ctx, WebhookPayload{Event: WebhookEmergency, Rotation: slot.Rotation, Member: slot.Member, Slot: slot, Reason: reason})
+}
+
+// Post posts an event of the rotation of the slot.
+func (w *Webhook) Post(ctx context.Context, event string, slot rotation.Slot) error {
+	return w.send(ctx, WebhookPayload{Event: event, Rotation: slot.Rotation, Member: slot.Member, Slot: slot})
+}
+
+func (w *Webhook) send(ctx context.Context, payload WebhookPayload) error {
+	return sendJSON(ctx, "webhook", http.MethodPost, w.URL, nil, payload)
+}
@@ -0,0 +1,180 @@
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// grafanaTime is the layout of the start of Grafana OnCall shifts, in the
+// time zone of the shift.
+const grafanaTime = "2006-01-02T15:04:05"
+
+// Grafana pushes rotations to Grafana OnCall schedules of type calendar, as
+// single event shifts named after the rotation.
+type Grafana struct {
+	// URL is the base URL of the OnCall API of the Grafana stack, e.g.
+	// https://oncall-prod-us-central-0.grafana.net/oncall.
+	URL string
+	// Token is an OnCall API token.
+	Token string
+	// HTTP is the client used for requests, http.DefaultClient when nil.
+	HTTP *http.Client
+}
+
+type grafanaSchedule struct {
+	ID       string   `json:"id"`
+	TeamID   string   `json:"team_id,omitempty"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	TimeZone string   `json:"time_zone,omitempty"`
+	Shifts   []string `json:"shifts"`
+}
+
+type grafanaShift struct {
+	ID       string   `json:"id,omitempty"`
+	TeamID   string   `json:"team_id,omitempty"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	TimeZone string   `json:"time_zone"`
+	Level    int      `json:"level"`
+	Start    string   `json:"start"`
+	Duration int      `json:"duration"`
+	Users    []string `json:"users"`
+}
+
+func (g *Grafana) client() client {
+	return client{
+		name: "grafana oncall",
+		base: g.URL,
+		header: func(h http.Header) {
+			h.Set("Authorization", g.Token)
+		},
+		http: g.HTTP,
+	}
+}
+
+// UserID returns the ID of the OnCall user with the given email.
+func (g *Grafana) UserID(ctx context.Context, email string) (string, error) {
+	// Users can't be filtered by email, so every page is searched.
+	path := "/api/v1/users/"
+	for path != "" {
+		var out struct {
+			Next    string `json:"next"`
+			Results []struct {
+				ID    string `json:"id"`
+				Email string `json:"email"`
+			} `json:"results"`
+		}
+		if err := g.client().do(ctx, http.MethodGet, path, nil, &out); err != nil {
+			return "", fmt.Errorf("unable to find user %s: %w", email, err)
+		}
+		for _, u := range out.Results {
+			if strings.EqualFold(u.Email, email) {
+				return u.ID, nil
+			}
+		}
+		path = out.Next
+	}
+	return "", fmt.Errorf("no Grafana OnCall user with email %s", email)
+}
+
+// Sync replaces the shifts of the rotation in a schedule, leaving its other
+// shifts alone. The shifts of the rotation are told apart by their name,
+// prefixed with the ID of the rotation. Shifts already over are kept as the
+// history of the rotation.
+func (g *Grafana) Sync(ctx context.Context, scheduleID, name string, shifts []Shift) (Result, error) {
+	c := g.client()
+	var schedule grafanaSchedule
+	if err := c.do(ctx, http.MethodGet, "/api/v1/schedules/"+url.PathEscape(scheduleID)+"/", nil, &schedule); err != nil {
+		return Result{}, fmt.Errorf("unable to get schedule %s: %w", scheduleID, err)
+	}
+	if schedule.Type != "calendar" {
+		return Result{}, fmt.Errorf("schedule %s is of type %s, only calendar schedules can have their shifts managed through the API", schedule.Name, schedule.Type)
+	}
+
+	prefix := rotation.ID(name) + "/"
+	var kept []string
+	var existing []Shift
+	var stale []string
+	now := time.Now()
+	for _, id := range schedule.Shifts {
+		var s grafanaShift
+		if err := c.do(ctx, http.MethodGet, "/api/v1/on_call_shifts/"+url.PathEscape(id)+"/", nil, &s); err != nil {
+			return Result{}, fmt.Errorf("unable to get shift %s: %w", id, err)
+		}
+		if !strings.HasPrefix(s.Name, prefix) {
+			kept = append(kept, id)
+			continue
+		}
+		shift, ok := s.shift()
+		if ok && !shift.End.After(now) {
+			kept = append(kept, id)
+			continue
+		}
+		if !ok || !contains(shifts, shift) || contains(existing, shift) {
+			stale = append(stale, id)
+			continue
+		}
+		kept = append(kept, id)
+		existing = append(existing, shift)
+	}
+
+	var result Result
+	for _, s := range shifts {
+		if contains(existing, s) {
+			continue
+		}
+		start := s.Start.UTC()
+		created := grafanaShift{
+			TeamID:   schedule.TeamID,
+			Name:     prefix + start.Format(time.DateOnly),
+			Type:     "single_event",
+			TimeZone: "UTC",
+			Level:    1,
+			Start:    start.Format(grafanaTime),
+			Duration: int(s.End.Sub(s.Start).Seconds()),
+			Users:    []string{s.User},
+		}
+		if err := c.do(ctx, http.MethodPost, "/api/v1/on_call_shifts/", created, &created); err != nil {
+			return result, fmt.Errorf("unable to create shift %s: %w", created.Name, err)
+		}
+		kept = append(kept, created.ID)
+		result.Created++
+	}
+
+	// The schedule stops using the stale shifts before they are deleted.
+	schedule.Shifts = kept
+	if err := c.do(ctx, http.MethodPut, "/api/v1/schedules/"+url.PathEscape(scheduleID)+"/", schedule, nil); err != nil {
+		return result, fmt.Errorf("unable to update schedule %s: %w", schedule.Name, err)
+	}
+	for _, id := range stale {
+		if err := c.do(ctx, http.MethodDelete, "/api/v1/on_call_shifts/"+url.PathEscape(id)+"/", nil, nil); err != nil {
+			return result, fmt.Errorf("unable to delete shift %s: %w", id, err)
+		}
+		result.Deleted++
+	}
+	return result, nil
+}
+
+// shift returns the period and user of a single event shift, false for
+// shifts of any other kind.
+func (s grafanaShift) shift() (Shift, bool) {
+	if s.Type != "single_event" || len(s.Users) != 1 {
+		return Shift{}, false
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return Shift{}, false
+	}
+	start, err := time.ParseInLocation(grafanaTime, s.Start, loc)
+	if err != nil {
+		return Shift{}, false
+	}
+	return Shift{User: s.Users[0], Start: start, End: start.Add(time.Duration(s.Duration) * time.Second)}, true
+}
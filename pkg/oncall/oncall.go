@@ -0,0 +1,126 @@
+// Package oncall pushes the slots of rotations to incident management tools,
+// so they page whoever is on rotation in the calendar.
+package oncall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// Shift is a period a user of an incident management tool is on call.
+type Shift struct {
+	// User is the ID of the user in the tool.
+	User  string
+	Start time.Time
+	End   time.Time
+}
+
+// Result counts the changes made to a schedule by a sync.
+type Result struct {
+	Created int
+	Deleted int
+}
+
+// Provider is an incident management tool the shifts of rotations are pushed
+// to, each laying them out in its own kind of schedule.
+type Provider interface {
+	// UserID returns the ID in the tool of the user with the given email.
+	UserID(ctx context.Context, email string) (string, error)
+	// Sync makes the schedule with the given ID page the users of the
+	// shifts of the named rotation. Shifts it pushed before that are no
+	// longer planned are removed, and the ones already there are left
+	// alone, so syncing twice doesn't change anything.
+	Sync(ctx context.Context, schedule, name string, shifts []Shift) (Result, error)
+}
+
+// Shifts returns the shifts of the given slots, with the ID in the tool of
+// every member. Consecutive slots of the same member make a single shift.
+func Shifts(slots []rotation.Slot, users map[string]string) ([]Shift, error) {
+	var shifts []Shift
+	for _, s := range slots {
+		id, ok := users[s.Member]
+		if !ok {
+			return nil, fmt.Errorf("no user ID for member %q", s.Member)
+		}
+		if n := len(shifts); n > 0 && shifts[n-1].User == id && shifts[n-1].End.Equal(s.Start) {
+			shifts[n-1].End = s.End
+			continue
+		}
+		shifts = append(shifts, Shift{User: id, Start: s.Start, End: s.End})
+	}
+	return shifts, nil
+}
+
+// contains reports whether shifts has one for the same user and period as s.
+func contains(shifts []Shift, s Shift) bool {
+	for _, e := range shifts {
+		if e.User == s.User && e.Start.Equal(s.Start) && e.End.Equal(s.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// client sends JSON requests to the REST API of a tool.
+type client struct {
+	// name is the name of the tool, for errors.
+	name string
+	base string
+	// header sets the authentication headers of a request.
+	header func(http.Header)
+	http   *http.Client
+}
+
+// do sends body as JSON to the API and decodes the JSON response into out,
+// unless it is nil.
+func (c client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	// Paginated APIs return absolute URLs to the next page.
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = strings.TrimSuffix(c.base, "/") + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.header(req.Header)
+
+	hc := c.http
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s returned %s: %s", c.name, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
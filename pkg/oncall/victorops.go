@@ -0,0 +1,159 @@
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultVictorOpsURL is the base URL of the Splunk On-Call REST API.
+const DefaultVictorOpsURL = "https://api.victorops.com"
+
+// VictorOps pushes rotations to Splunk On-Call (formerly VictorOps), whose
+// rotations can't be managed through its API. The escalation policy synced
+// instead pages a rotation made of a placeholder user, and every shift is a
+// scheduled override of the placeholder assigned to the member on call.
+type VictorOps struct {
+	// APIID and APIKey authenticate with the API.
+	APIID  string
+	APIKey string
+	// Placeholder is the username of the user paged by the policy when not
+	// overridden.
+	Placeholder string
+	// URL is the base URL of the API, DefaultVictorOpsURL when empty.
+	URL string
+	// HTTP is the client used for requests, http.DefaultClient when nil.
+	HTTP *http.Client
+}
+
+type victorOpsOverride struct {
+	PublicID string `json:"publicId,omitempty"`
+	User     struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Assignments []struct {
+		Policy       string `json:"policy"`
+		AssignedUser *struct {
+			Username string `json:"username"`
+		} `json:"assignedUser"`
+	} `json:"assignments"`
+}
+
+func (v *VictorOps) client() client {
+	base := v.URL
+	if base == "" {
+		base = DefaultVictorOpsURL
+	}
+	return client{
+		name: "splunk on-call",
+		base: base,
+		header: func(h http.Header) {
+			h.Set("X-VO-Api-Id", v.APIID)
+			h.Set("X-VO-Api-Key", v.APIKey)
+		},
+		http: v.HTTP,
+	}
+}
+
+// UserID returns the username of the user with the given email.
+func (v *VictorOps) UserID(ctx context.Context, email string) (string, error) {
+	var out struct {
+		Users []struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+		} `json:"users"`
+	}
+	if err := v.client().do(ctx, http.MethodGet, "/api-public/v2/user", nil, &out); err != nil {
+		return "", fmt.Errorf("unable to find user %s: %w", email, err)
+	}
+	for _, u := range out.Users {
+		if strings.EqualFold(u.Email, email) {
+			return u.Username, nil
+		}
+	}
+	return "", fmt.Errorf("no Splunk On-Call user with email %s", email)
+}
+
+// Sync makes the overrides of the placeholder on the escalation policy with
+// the given slug match the shifts. Overrides already over are left alone,
+// as they are the history of the rotation.
+func (v *VictorOps) Sync(ctx context.Context, policy, name string, shifts []Shift) (Result, error) {
+	if v.Placeholder == "" {
+		return Result{}, fmt.Errorf("a placeholder user must be set to sync rotation %q", name)
+	}
+	c := v.client()
+	var out struct {
+		Overrides []victorOpsOverride `json:"overrides"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api-public/v1/overrides", nil, &out); err != nil {
+		return Result{}, fmt.Errorf("unable to list overrides: %w", err)
+	}
+
+	var result Result
+	var existing []Shift
+	now := time.Now()
+	for _, o := range out.Overrides {
+		shift, ok := o.shift(v.Placeholder, policy)
+		if !ok {
+			continue
+		}
+		if contains(shifts, shift) && !contains(existing, shift) {
+			existing = append(existing, shift)
+			continue
+		}
+		if !o.End.After(now) {
+			continue
+		}
+		if err := c.do(ctx, http.MethodDelete, "/api-public/v1/overrides/"+url.PathEscape(o.PublicID), nil, nil); err != nil {
+			return result, fmt.Errorf("unable to delete override %s: %w", o.PublicID, err)
+		}
+		result.Deleted++
+	}
+
+	for _, s := range shifts {
+		if contains(existing, s) {
+			continue
+		}
+		body := map[string]string{
+			"username": v.Placeholder,
+			"timezone": "Etc/UTC",
+			"start":    s.Start.UTC().Format(time.RFC3339),
+			"end":      s.End.UTC().Format(time.RFC3339),
+		}
+		var created victorOpsOverride
+		if err := c.do(ctx, http.MethodPost, "/api-public/v1/overrides", body, &created); err != nil {
+			return result, fmt.Errorf("unable to create override for %s: %w", s.User, err)
+		}
+		assignment := map[string]string{"policy": policy, "username": s.User}
+		path := "/api-public/v1/overrides/" + url.PathEscape(created.PublicID) + "/assignments/" + url.PathEscape(policy)
+		if err := c.do(ctx, http.MethodPut, path, assignment, nil); err != nil {
+			return result, fmt.Errorf("unable to assign override %s to %s: %w", created.PublicID, s.User, err)
+		}
+		result.Created++
+	}
+	return result, nil
+}
+
+// shift returns the period and assignee of an override of the placeholder
+// on the policy, false for any other override.
+func (o victorOpsOverride) shift(placeholder, policy string) (Shift, bool) {
+	if o.User.Username != placeholder {
+		return Shift{}, false
+	}
+	for _, a := range o.Assignments {
+		if a.Policy != policy {
+			continue
+		}
+		shift := Shift{Start: o.Start, End: o.End}
+		if a.AssignedUser != nil {
+			shift.User = a.AssignedUser.Username
+		}
+		return shift, true
+	}
+	return Shift{}, false
+}
@@ -0,0 +1,245 @@
+package outlook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// event is a Graph event, with the fields used by rotations only.
+type event struct {
+	ID                            string             `json:"id,omitempty"`
+	Subject                       string             `json:"subject"`
+	IsAllDay                      bool               `json:"isAllDay"`
+	ShowAs                        string             `json:"showAs,omitempty"`
+	Sensitivity                   string             `json:"sensitivity,omitempty"`
+	Body                          *itemBody          `json:"body,omitempty"`
+	Start                         dateTimeTimeZone   `json:"start"`
+	End                           dateTimeTimeZone   `json:"end"`
+	Recurrence                    *recurrence        `json:"recurrence,omitempty"`
+	Attendees                     []attendee         `json:"attendees,omitempty"`
+	IsReminderOn                  *bool              `json:"isReminderOn,omitempty"`
+	ReminderMinutesBeforeStart    int                `json:"reminderMinutesBeforeStart,omitempty"`
+	SingleValueExtendedProperties []extendedProperty `json:"singleValueExtendedProperties,omitempty"`
+	WebLink                       string             `json:"webLink,omitempty"`
+}
+
+type itemBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type dateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+// date returns the day of an all-day event boundary, e.g. 2024-07-01.
+func (d dateTimeTimeZone) date() string {
+	date, _, _ := strings.Cut(d.DateTime, "T")
+	return date
+}
+
+type recurrence struct {
+	Pattern recurrencePattern `json:"pattern"`
+	Range   recurrenceRange   `json:"range"`
+}
+
+type recurrencePattern struct {
+	Type           string   `json:"type"`
+	Interval       int      `json:"interval"`
+	DaysOfWeek     []string `json:"daysOfWeek,omitempty"`
+	DayOfMonth     int      `json:"dayOfMonth,omitempty"`
+	FirstDayOfWeek string   `json:"firstDayOfWeek,omitempty"`
+}
+
+type recurrenceRange struct {
+	Type                string `json:"type"`
+	StartDate           string `json:"startDate"`
+	EndDate             string `json:"endDate,omitempty"`
+	NumberOfOccurrences int    `json:"numberOfOccurrences,omitempty"`
+}
+
+type attendee struct {
+	EmailAddress struct {
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+	Type string `json:"type"`
+}
+
+type extendedProperty struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+var weekdays = map[string]string{
+	"MO": "monday",
+	"TU": "tuesday",
+	"WE": "wednesday",
+	"TH": "thursday",
+	"FR": "friday",
+	"SA": "saturday",
+	"SU": "sunday",
+}
+
+// newEvent returns the Graph event for a rotation event, and the dates of
+// the occurrences to remove once created, which Graph can't exclude upfront.
+func newEvent(e rotation.Event) (*event, []time.Time, error) {
+	ev := &event{
+		Subject:  e.Summary,
+		IsAllDay: true,
+		ShowAs:   "free",
+		Start:    dateTimeTimeZone{DateTime: e.Start.Format(time.DateOnly) + "T00:00:00", TimeZone: e.TimeZone},
+		End:      dateTimeTimeZone{DateTime: e.End.Format(time.DateOnly) + "T00:00:00", TimeZone: e.TimeZone},
+		SingleValueExtendedProperties: []extendedProperty{
+			{ID: propertyRotationID, Value: e.RotationID},
+			{ID: propertyMember, Value: e.Member},
+		},
+	}
+	if e.Transparency == rotation.TransparencyBusy {
+		ev.ShowAs = "busy"
+	}
+	switch e.Visibility {
+	case rotation.VisibilityPublic:
+		ev.Sensitivity = "normal"
+	case rotation.VisibilityPrivate:
+		ev.Sensitivity = "private"
+	}
+	if e.Description != "" {
+		ev.Body = &itemBody{ContentType: "text", Content: e.Description}
+	}
+	// Graph has no way to let attendees modify events, so GuestsCanModify
+	// is left out.
+	if e.Timed {
+		ev.IsAllDay = false
+		ev.Start.DateTime = e.Start.Format("2006-01-02T15:04:05")
+		ev.End.DateTime = e.End.Format("2006-01-02T15:04:05")
+	}
+	for _, a := range e.Attendees {
+		at := attendee{Type: "required"}
+		if a.Optional {
+			at.Type = "optional"
+		}
+		at.EmailAddress.Address = a.Email
+		ev.Attendees = append(ev.Attendees, at)
+	}
+	// Outlook events have a single reminder, so the first popup one is
+	// used, or the first one when all of them are emails.
+	for i, r := range e.Reminders {
+		if i == 0 || r.Method == rotation.ReminderPopup {
+			on := true
+			ev.IsReminderOn = &on
+			ev.ReminderMinutesBeforeStart = r.Minutes
+		}
+		if r.Method == rotation.ReminderPopup {
+			break
+		}
+	}
+
+	var exdates []time.Time
+	for _, line := range e.Recurrence {
+		if rule, ok := strings.CutPrefix(line, "RRULE:"); ok {
+			r, err := newRecurrence(rule, e.Start)
+			if err != nil {
+				return nil, nil, fmt.Errorf("event %q: %w", e.Summary, err)
+			}
+			ev.Recurrence = r
+			continue
+		}
+		if dates, ok := strings.CutPrefix(line, "EXDATE;VALUE=DATE:"); ok {
+			for _, d := range strings.Split(dates, ",") {
+				date, err := time.ParseInLocation("20060102", d, e.Start.Location())
+				if err != nil {
+					return nil, nil, fmt.Errorf("event %q has an invalid excluded date: %w", e.Summary, err)
+				}
+				exdates = append(exdates, date)
+			}
+			continue
+		}
+		// Timed events exclude their occurrences by start time, in the
+		// time zone of the event.
+		if strings.HasPrefix(line, "EXDATE;TZID=") {
+			_, times, _ := strings.Cut(line, ":")
+			for _, t := range strings.Split(times, ",") {
+				date, err := time.ParseInLocation("20060102T150405", t, e.Start.Location())
+				if err != nil {
+					return nil, nil, fmt.Errorf("event %q has an invalid excluded time: %w", e.Summary, err)
+				}
+				exdates = append(exdates, date)
+			}
+			continue
+		}
+		return nil, nil, fmt.Errorf("event %q has an unsupported recurrence %q", e.Summary, line)
+	}
+	return ev, exdates, nil
+}
+
+// newRecurrence converts the RRULEs written by rotation.Plan into a Graph
+// recurrence starting on start.
+func newRecurrence(rule string, start time.Time) (*recurrence, error) {
+	r := &recurrence{Range: recurrenceRange{Type: "noEnd", StartDate: start.Format(time.DateOnly)}}
+	var freq string
+	var days []string
+	for _, part := range strings.Split(rule, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "FREQ":
+			freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval %q", value)
+			}
+			r.Pattern.Interval = n
+		case "BYDAY":
+			days = strings.Split(value, ",")
+		case "UNTIL":
+			// Timed events end at a UTC time rather than on a date.
+			until, err := time.Parse("20060102", value)
+			if err != nil {
+				until, err = time.Parse("20060102T150405Z", value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid until %q", value)
+			}
+			r.Range.Type = "endDate"
+			r.Range.EndDate = until.Format(time.DateOnly)
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid count %q", value)
+			}
+			r.Range.Type = "numbered"
+			r.Range.NumberOfOccurrences = n
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %q", part)
+		}
+	}
+
+	switch freq {
+	case "DAILY":
+		r.Pattern.Type = "daily"
+	case "WEEKLY":
+		r.Pattern.Type = "weekly"
+		r.Pattern.FirstDayOfWeek = "monday"
+		if len(days) == 0 {
+			days = []string{strings.ToUpper(start.Weekday().String()[:2])}
+		}
+		for _, d := range days {
+			day, ok := weekdays[d]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q", d)
+			}
+			r.Pattern.DaysOfWeek = append(r.Pattern.DaysOfWeek, day)
+		}
+	case "MONTHLY":
+		r.Pattern.Type = "absoluteMonthly"
+		r.Pattern.DayOfMonth = start.Day()
+	default:
+		return nil, fmt.Errorf("unsupported recurrence frequency %q", freq)
+	}
+	return r, nil
+}
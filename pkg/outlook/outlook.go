@@ -0,0 +1,376 @@
+// Package outlook manages rotation events on Microsoft 365 calendars through
+// the Microsoft Graph API.
+package outlook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"calendar/pkg/audit"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+)
+
+// DefaultURL is the base URL of the Microsoft Graph API.
+const DefaultURL = "https://graph.microsoft.com/v1.0"
+
+// Scopes are the OAuth scopes needed to manage rotation events.
+var Scopes = []string{
+	"https://graph.microsoft.com/Calendars.ReadWrite",
+	"https://graph.microsoft.com/MailboxSettings.Read",
+	"offline_access",
+}
+
+// Extended properties set on the events of a rotation, in the public strings
+// property set, so they can be found again regardless of how they are renamed.
+const (
+	propertyRotationID = "String {00020329-0000-0000-C000-000000000046} Name rotationId"
+	propertyMember     = "String {00020329-0000-0000-C000-000000000046} Name member"
+)
+
+// Client manages the rotation events of an Outlook calendar. It implements
+// provider.CalendarProvider.
+type Client struct {
+	// URL is the base URL of the API, DefaultURL when empty.
+	URL string
+	// Audit, when set, records every event created or deleted.
+	Audit *audit.Log
+
+	http       *http.Client
+	calendarID string
+}
+
+var _ provider.CalendarProvider = &Client{}
+
+// New returns a Client for the calendar matching the given name, which can
+// be either the calendar name or its ID, or primary for the default one. The
+// HTTP client must already be authorized.
+func New(ctx context.Context, httpClient *http.Client, name string) (*Client, error) {
+	c := &Client{http: httpClient}
+
+	if name == "primary" {
+		var cal struct {
+			ID string `json:"id"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/me/calendar?$select=id", nil, &cal); err != nil {
+			return nil, fmt.Errorf("unable to get default calendar: %w", err)
+		}
+		c.calendarID = cal.ID
+		return c, nil
+	}
+
+	var available []string
+	next := "/me/calendars?$select=id,name"
+	for next != "" {
+		var page struct {
+			Value []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := c.do(ctx, http.MethodGet, next, nil, &page); err != nil {
+			return nil, fmt.Errorf("unable to list calendars: %w", err)
+		}
+		for _, cal := range page.Value {
+			if c.calendarID == "" && (cal.ID == name || cal.Name == name) {
+				c.calendarID = cal.ID
+			}
+			available = append(available, fmt.Sprintf("%q (%s)", cal.Name, cal.ID))
+		}
+		next = page.NextLink
+	}
+	if c.calendarID == "" {
+		return nil, fmt.Errorf("calendar %q not found, available calendars are:\n  %s", name, strings.Join(available, "\n  "))
+	}
+	return c, nil
+}
+
+// TimeZone returns the time zone of the mailbox. Mailboxes may use Windows
+// time zone names, in which case UTC is used instead.
+func (c *Client) TimeZone(ctx context.Context) (string, error) {
+	var settings struct {
+		Value string `json:"value"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/me/mailboxSettings/timeZone", nil, &settings); err != nil {
+		return "", fmt.Errorf("unable to get mailbox time zone: %w", err)
+	}
+	if _, err := time.LoadLocation(settings.Value); err != nil {
+		slog.Warn("Mailbox time zone is not an IANA time zone, using UTC, set --timezone to change it", "timezone", settings.Value)
+		return "UTC", nil
+	}
+	return settings.Value, nil
+}
+
+// ManagedEvents returns the events created for the rotation with the given
+// ID. Recurring events are returned as a single series.
+func (c *Client) ManagedEvents(ctx context.Context, rotationID string) ([]provider.Event, error) {
+	filter := fmt.Sprintf("singleValueExtendedProperties/Any(ep: ep/id eq '%s' and ep/value eq '%s')", propertyRotationID, odataString(rotationID))
+	events, err := c.events(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events of rotation %s: %w", rotationID, err)
+	}
+	return events, nil
+}
+
+// RotationEvents returns the events of the named rotation, found by summary.
+func (c *Client) RotationEvents(ctx context.Context, name string) ([]provider.Event, error) {
+	filter := fmt.Sprintf("startswith(subject,'%s')", odataString(rotation.Summary(name, "")))
+	events, err := c.events(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list events: %w", err)
+	}
+	return events, nil
+}
+
+func (c *Client) events(ctx context.Context, filter string) ([]provider.Event, error) {
+	query := url.Values{
+		"$filter": {filter},
+		"$select": {"id,subject,start,webLink"},
+	}
+	var events []provider.Event
+	next := "/me/calendars/" + url.PathEscape(c.calendarID) + "/events?" + query.Encode()
+	for next != "" {
+		var page struct {
+			Value    []event `json:"value"`
+			NextLink string  `json:"@odata.nextLink"`
+		}
+		if err := c.do(ctx, http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, e := range page.Value {
+			events = append(events, provider.Event{ID: e.ID, Summary: e.Subject, Start: e.Start.date(), Raw: e})
+		}
+		next = page.NextLink
+	}
+	return events, nil
+}
+
+// Sync makes the existing events of a rotation match the planned ones. The
+// existing events are deleted and the planned ones created again, as Outlook
+// can't skip occurrences of a recurring event when updating it.
+func (c *Client) Sync(ctx context.Context, existing []provider.Event, planned []rotation.Event, opts provider.SyncOptions) ([]provider.Change, error) {
+	var changes []provider.Change
+	if opts.DryRun {
+		for _, e := range existing {
+			changes = append(changes, provider.Change{Action: "deleted", Summary: e.Summary})
+		}
+		for _, e := range planned {
+			changes = append(changes, provider.Change{Action: "created", Summary: e.Summary})
+		}
+		return changes, nil
+	}
+	var created []*event
+	total := len(existing) + len(planned)
+	progress := func() {
+		if opts.Progress != nil {
+			opts.Progress(len(changes), total)
+		}
+	}
+	err := func() error {
+		for _, e := range existing {
+			if err := c.DeleteEvent(ctx, e); err != nil {
+				return err
+			}
+			changes = append(changes, provider.Change{Action: "deleted", Summary: e.Summary})
+			progress()
+		}
+		for _, e := range planned {
+			ev, err := c.insertEvent(ctx, e)
+			if err != nil {
+				return err
+			}
+			created = append(created, ev)
+			changes = append(changes, provider.Change{Action: "created", Summary: ev.Subject, Link: ev.WebLink})
+			progress()
+		}
+		return nil
+	}()
+	// Created events are also rolled back when the sync is interrupted.
+	if err == nil || (!opts.Atomic && ctx.Err() == nil) {
+		return changes, err
+	}
+
+	// The rollback must happen even when the sync was interrupted.
+	ctx = context.WithoutCancel(ctx)
+	for _, ev := range created {
+		if rerr := c.DeleteEvent(ctx, provider.Event{ID: ev.ID}); rerr != nil {
+			return changes, fmt.Errorf("%w, and rolling back failed: %w", err, rerr)
+		}
+		changes = append(changes, provider.Change{Action: "rolled back", Summary: ev.Subject})
+	}
+	return changes, err
+}
+
+// insertEvent creates the all-day event of a rotation member, removing the
+// occurrences excluded from its recurrence.
+func (c *Client) insertEvent(ctx context.Context, e rotation.Event) (*event, error) {
+	body, exdates, err := newEvent(e)
+	if err != nil {
+		return nil, err
+	}
+	var created event
+	if err := c.do(ctx, http.MethodPost, "/me/calendars/"+url.PathEscape(c.calendarID)+"/events", body, &created); err != nil {
+		return nil, fmt.Errorf("unable to create event %q: %w", e.Summary, err)
+	}
+	c.record(audit.Entry{Action: audit.ActionCreated, EventID: created.ID, Rotation: e.RotationID, Summary: e.Summary})
+
+	for _, date := range exdates {
+		query := url.Values{
+			"startDateTime": {date.Format(time.DateOnly) + "T00:00:00"},
+			"endDateTime":   {date.AddDate(0, 0, 1).Format(time.DateOnly) + "T00:00:00"},
+			"$select":       {"id,start"},
+		}
+		var instances struct {
+			Value []event `json:"value"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/me/events/"+url.PathEscape(created.ID)+"/instances?"+query.Encode(), nil, &instances); err != nil {
+			return nil, fmt.Errorf("unable to list occurrences of event %q: %w", e.Summary, err)
+		}
+		for _, instance := range instances.Value {
+			if instance.Start.date() != date.Format(time.DateOnly) {
+				continue
+			}
+			if err := c.DeleteEvent(ctx, provider.Event{ID: instance.ID}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &created, nil
+}
+
+// DeleteEvent deletes an event, including every occurrence of a series.
+func (c *Client) DeleteEvent(ctx context.Context, e provider.Event) error {
+	if err := c.do(ctx, http.MethodDelete, "/me/events/"+url.PathEscape(e.ID), nil, nil); err != nil {
+		return fmt.Errorf("unable to delete event %s: %w", e.ID, err)
+	}
+	c.record(audit.Entry{Action: audit.ActionDeleted, EventID: e.ID, Summary: e.Summary})
+	return nil
+}
+
+// record adds a change to the audit log, if any.
+func (c *Client) record(e audit.Entry) {
+	if c.Audit == nil {
+		return
+	}
+	e.Provider, e.Calendar = provider.Outlook, c.calendarID
+	if err := c.Audit.Record(e); err != nil {
+		slog.Error("Unable to record change in audit log", "event", e.EventID, "error", err)
+	}
+}
+
+// Slots returns the occurrences of every rotation overlapping the given time
+// range.
+func (c *Client) Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	tz, err := c.TimeZone(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"startDateTime": {from.UTC().Format(time.RFC3339)},
+		"endDateTime":   {to.UTC().Format(time.RFC3339)},
+		"$select":       {"id,subject,start,end,isAllDay,webLink"},
+		"$orderby":      {"start/dateTime"},
+		// The rotation ID tells the events created by this tool.
+		"$expand": {fmt.Sprintf("singleValueExtendedProperties($filter=id eq '%s')", propertyRotationID)},
+	}
+	var slots []rotation.Slot
+	next := "/me/calendars/" + url.PathEscape(c.calendarID) + "/calendarView?" + query.Encode()
+	for next != "" {
+		var page struct {
+			Value    []event `json:"value"`
+			NextLink string  `json:"@odata.nextLink"`
+		}
+		if err := c.do(ctx, http.MethodGet, next, nil, &page); err != nil {
+			return nil, fmt.Errorf("unable to list events: %w", err)
+		}
+		for _, e := range page.Value {
+			// Rotations are made of all-day events.
+			if !e.IsAllDay {
+				continue
+			}
+			name, member, ok := rotation.ParseSummary(e.Subject)
+			if !ok {
+				continue
+			}
+			// All-day events are dates, whatever time zone they are written in.
+			start, err := time.ParseInLocation(time.DateOnly, e.Start.date(), loc)
+			if err != nil {
+				return nil, err
+			}
+			end, err := time.ParseInLocation(time.DateOnly, e.End.date(), loc)
+			if err != nil {
+				return nil, err
+			}
+			// Only the rotation ID is expanded, if the event has one.
+			var rotationID string
+			if len(e.SingleValueExtendedProperties) > 0 {
+				rotationID = e.SingleValueExtendedProperties[0].Value
+			}
+			slots = append(slots, rotation.Slot{Rotation: name, RotationID: rotationID, Member: member, Start: start, End: end, Link: e.WebLink, Managed: rotationID != ""})
+		}
+		next = page.NextLink
+	}
+	return slots, nil
+}
+
+// do sends body as JSON to the API and decodes the JSON response into out,
+// unless it is nil. Paths may also be the absolute next links of pages.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	target := path
+	if !strings.HasPrefix(path, "https://") {
+		base := c.URL
+		if base == "" {
+			base = DefaultURL
+		}
+		target = strings.TrimSuffix(base, "/") + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("graph returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// odataString escapes a string literal of an OData filter.
+func odataString(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
@@ -0,0 +1,183 @@
+// Package pagerduty keeps PagerDuty schedules in sync with rotations.
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the base URL of the PagerDuty REST API.
+const DefaultURL = "https://api.pagerduty.com"
+
+// Client talks to the PagerDuty REST API.
+type Client struct {
+	// Token is a PagerDuty REST API key.
+	Token string
+	// URL is the base URL of the API, DefaultURL when empty.
+	URL string
+	// HTTP is the client used for requests, http.DefaultClient when nil.
+	HTTP *http.Client
+}
+
+// Schedule is a PagerDuty on-call schedule.
+type Schedule struct {
+	ID          string  `json:"id,omitempty"`
+	Type        string  `json:"type"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	TimeZone    string  `json:"time_zone"`
+	Layers      []Layer `json:"schedule_layers"`
+}
+
+// Layer is a schedule layer, where users take turns of a fixed length.
+type Layer struct {
+	ID                        string          `json:"id,omitempty"`
+	Name                      string          `json:"name"`
+	Start                     time.Time       `json:"start"`
+	End                       *time.Time      `json:"end,omitempty"`
+	RotationVirtualStart      time.Time       `json:"rotation_virtual_start"`
+	RotationTurnLengthSeconds int             `json:"rotation_turn_length_seconds"`
+	Users                     []LayerUser     `json:"users"`
+	Restrictions              json.RawMessage `json:"restrictions,omitempty"`
+}
+
+// LayerUser is a user taking turns in a layer.
+type LayerUser struct {
+	User Reference `json:"user"`
+}
+
+// Reference points to another PagerDuty object.
+type Reference struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// UserReference returns a reference to the user with the given ID.
+func UserReference(id string) Reference {
+	return Reference{ID: id, Type: "user_reference"}
+}
+
+// Override puts a user on call instead of the layers for a period.
+type Override struct {
+	ID    string    `json:"id,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	User  Reference `json:"user"`
+}
+
+// Schedule returns the schedule with the given ID.
+func (c *Client) Schedule(ctx context.Context, id string) (*Schedule, error) {
+	var out struct {
+		Schedule Schedule `json:"schedule"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/schedules/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, fmt.Errorf("unable to get schedule %s: %w", id, err)
+	}
+	return &out.Schedule, nil
+}
+
+// UpdateSchedule saves the changes made to a schedule, including its layers.
+func (c *Client) UpdateSchedule(ctx context.Context, schedule *Schedule) error {
+	body := map[string]*Schedule{"schedule": schedule}
+	if err := c.do(ctx, http.MethodPut, "/schedules/"+url.PathEscape(schedule.ID), body, nil); err != nil {
+		return fmt.Errorf("unable to update schedule %s: %w", schedule.ID, err)
+	}
+	return nil
+}
+
+// Overrides returns the overrides of a schedule in the given time range.
+func (c *Client) Overrides(ctx context.Context, scheduleID string, since, until time.Time) ([]Override, error) {
+	query := url.Values{
+		"since": {since.Format(time.RFC3339)},
+		"until": {until.Format(time.RFC3339)},
+	}
+	var out struct {
+		Overrides []Override `json:"overrides"`
+	}
+	path := "/schedules/" + url.PathEscape(scheduleID) + "/overrides?" + query.Encode()
+	if err := c.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return nil, fmt.Errorf("unable to list overrides of schedule %s: %w", scheduleID, err)
+	}
+	return out.Overrides, nil
+}
+
+// CreateOverrides adds overrides to a schedule.
+func (c *Client) CreateOverrides(ctx context.Context, scheduleID string, overrides []Override) error {
+	body := map[string][]Override{"overrides": overrides}
+	if err := c.do(ctx, http.MethodPost, "/schedules/"+url.PathEscape(scheduleID)+"/overrides", body, nil); err != nil {
+		return fmt.Errorf("unable to create overrides in schedule %s: %w", scheduleID, err)
+	}
+	return nil
+}
+
+// UserID returns the ID of the user with the given email.
+func (c *Client) UserID(ctx context.Context, email string) (string, error) {
+	var out struct {
+		Users []struct {
+			ID    string `json:"id"`
+			Email string `json:"email"`
+		} `json:"users"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/users?"+url.Values{"query": {email}}.Encode(), nil, &out); err != nil {
+		return "", fmt.Errorf("unable to find user %s: %w", email, err)
+	}
+	// The query also matches names, so only an exact email is accepted.
+	for _, u := range out.Users {
+		if strings.EqualFold(u.Email, email) {
+			return u.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no PagerDuty user with email %s", email)
+}
+
+// do sends body as JSON to the API and decodes the JSON response into out,
+// unless it is nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	base := c.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	req.Header.Set("Authorization", "Token token="+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("pagerduty returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
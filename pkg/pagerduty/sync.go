@@ -0,0 +1,153 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// NewLayer returns the schedule layer of a rotation, given its planned events
+// and the PagerDuty user ID of every member. Members take turns in the order
+// of their recurring events.
+//
+// PagerDuty turns have a fixed length, so monthly cadences are not supported.
+func NewLayer(r rotation.Rotation, events []rotation.Event, users map[string]string) (Layer, error) {
+	days, ok := r.Cadence.Days()
+	if !ok {
+		return Layer{}, fmt.Errorf("rotation %q has a %s cadence, PagerDuty turns must have a fixed length in days or weeks", r.Name, r.Cadence)
+	}
+
+	layer := Layer{
+		Name:                      r.Name,
+		RotationTurnLengthSeconds: days * 24 * 60 * 60,
+	}
+	for _, e := range events {
+		if len(e.Recurrence) == 0 {
+			continue
+		}
+		id, ok := users[e.Member]
+		if !ok {
+			return Layer{}, fmt.Errorf("no PagerDuty user for member %q", e.Member)
+		}
+		if len(layer.Users) == 0 {
+			layer.Start = e.Start
+		}
+		layer.Users = append(layer.Users, LayerUser{User: UserReference(id)})
+	}
+	if len(layer.Users) == 0 {
+		return Layer{}, fmt.Errorf("rotation %q has no slots", r.Name)
+	}
+	layer.RotationVirtualStart = layer.Start
+	if r.WeekdaysOnly {
+		// Turns still last whole weeks from Monday, with the weekends
+		// restricted out.
+		layer.RotationVirtualStart = layer.Start.AddDate(0, 0, -int(layer.Start.Weekday()-time.Monday))
+		restrictions, err := json.Marshal([]map[string]any{{
+			"type":              "weekly_restriction",
+			"start_day_of_week": 1,
+			"start_time_of_day": "00:00:00",
+			"duration_seconds":  5 * 24 * 60 * 60,
+		}})
+		if err != nil {
+			return Layer{}, err
+		}
+		layer.Restrictions = restrictions
+	}
+
+	// The layer ends with the last slot, Until being the last day a slot
+	// can start on.
+	switch {
+	case r.Count > 0:
+		end := layer.RotationVirtualStart.AddDate(0, 0, r.Count*days)
+		layer.End = &end
+	case !r.Until.IsZero():
+		until := rotation.InLocation(r.Until, layer.Start.Location())
+		end := layer.RotationVirtualStart
+		for !end.After(until) {
+			end = end.AddDate(0, 0, days)
+		}
+		layer.End = &end
+	}
+	return layer, nil
+}
+
+// NewOverrides returns the overrides for the slots of a rotation covered by
+// somebody else than its regular member.
+func NewOverrides(events []rotation.Event, users map[string]string) ([]Override, error) {
+	var overrides []Override
+	for _, e := range events {
+		if len(e.Recurrence) > 0 {
+			continue
+		}
+		id, ok := users[e.Member]
+		if !ok {
+			return nil, fmt.Errorf("no PagerDuty user for member %q", e.Member)
+		}
+		overrides = append(overrides, Override{Start: e.Start, End: e.End, User: UserReference(id)})
+	}
+	return overrides, nil
+}
+
+// Sync pushes a layer and its overrides to a schedule. The layer replaces the
+// one with the same name, if any, leaving the other layers of the schedule
+// alone. Overrides already in the schedule are not created again, so syncing
+// twice doesn't change anything.
+func (c *Client) Sync(ctx context.Context, schedule *Schedule, layer Layer, overrides []Override) (created int, err error) {
+	replaced := false
+	for i, l := range schedule.Layers {
+		if l.Name == layer.Name {
+			layer.ID = l.ID
+			schedule.Layers[i] = layer
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		schedule.Layers = append(schedule.Layers, layer)
+	}
+	if err := c.UpdateSchedule(ctx, schedule); err != nil {
+		return 0, err
+	}
+
+	if len(overrides) == 0 {
+		return 0, nil
+	}
+	since, until := overrides[0].Start, overrides[0].End
+	for _, o := range overrides {
+		if o.Start.Before(since) {
+			since = o.Start
+		}
+		if o.End.After(until) {
+			until = o.End
+		}
+	}
+	existing, err := c.Overrides(ctx, schedule.ID, since, until)
+	if err != nil {
+		return 0, err
+	}
+	var missing []Override
+	for _, o := range overrides {
+		if !containsOverride(existing, o) {
+			missing = append(missing, o)
+		}
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+	if err := c.CreateOverrides(ctx, schedule.ID, missing); err != nil {
+		return 0, err
+	}
+	return len(missing), nil
+}
+
+func containsOverride(overrides []Override, o Override) bool {
+	for _, e := range overrides {
+		if e.User.ID == o.User.ID && e.Start.Equal(o.Start) && e.End.Equal(o.End) {
+			return true
+		}
+	}
+	return false
+}
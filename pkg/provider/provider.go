@@ -0,0 +1,84 @@
+// Package provider abstracts the calendar backends rotations are written to,
+// so the same rotation definitions can be used with any of them.
+package provider
+
+import (
+	"context"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// Supported providers.
+const (
+	Google  = "google"
+	Outlook = "outlook"
+	CalDAV  = "caldav"
+)
+
+// CalendarProvider manages the events of rotations in a single calendar.
+type CalendarProvider interface {
+	// TimeZone returns the IANA time zone of the calendar.
+	TimeZone(ctx context.Context) (string, error)
+	// ManagedEvents returns the events created for the rotation with the
+	// given ID. Recurring events are returned as a single series.
+	ManagedEvents(ctx context.Context, rotationID string) ([]Event, error)
+	// RotationEvents returns the events of the named rotation, including
+	// the ones not created by this tool, found by their summary.
+	RotationEvents(ctx context.Context, name string) ([]Event, error)
+	// Sync makes the existing events of a rotation match the planned ones.
+	// The events created are deleted again when it fails with Atomic set,
+	// or when ctx is cancelled.
+	Sync(ctx context.Context, existing []Event, planned []rotation.Event, opts SyncOptions) ([]Change, error)
+	// DeleteEvent deletes an event, including every occurrence of a series.
+	DeleteEvent(ctx context.Context, event Event) error
+	// Slots returns the occurrences of every rotation overlapping the given
+	// time range.
+	Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error)
+}
+
+// Event is an event stored in a calendar.
+type Event struct {
+	ID      string
+	Summary string
+	// Start is the start of the event as written in the calendar.
+	Start string
+	// Raw is the event as returned by the provider API.
+	Raw any
+}
+
+// Change is a modification made to the calendar when syncing a rotation.
+type Change struct {
+	// Action is one of created, updated, deleted or rolled back.
+	Action  string
+	Summary string
+	Link    string
+}
+
+// SyncOptions tunes how a rotation is synced.
+type SyncOptions struct {
+	// Atomic deletes the events created by a failed sync, so it doesn't
+	// leave a partial rotation behind.
+	Atomic bool
+	// DryRun returns the changes a sync would make without making them.
+	DryRun bool
+	// Progress, when set, is called every time an event has been handled,
+	// with the number of events handled so far and the total. It may be
+	// called from several goroutines, one at a time.
+	Progress func(done, total int)
+}
+
+// SlotAt returns the slot of the named rotation covering the given time, or
+// nil if nobody is on rotation then.
+func SlotAt(ctx context.Context, p CalendarProvider, name string, at time.Time) (*rotation.Slot, error) {
+	slots, err := p.Slots(ctx, at, at.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range slots {
+		if s.Rotation == name && s.Covers(at) {
+			return &s, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,115 @@
+package rotation
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Unavailability is a period, both days included, when a member can't be on
+// rotation, e.g. holidays or PTO.
+type Unavailability struct {
+	Member string    `yaml:"member"`
+	From   time.Time `yaml:"from"`
+	To     time.Time `yaml:"to"`
+}
+
+// Overlaps reports whether the unavailability overlaps the [start, end) range.
+func (u Unavailability) Overlaps(start, end time.Time) bool {
+	return u.From.Before(end) && u.To.AddDate(0, 0, 1).After(start)
+}
+
+// ParseUnavailability parses an unavailability written as
+// member=2024-08-01..2024-08-15. A single day can be given as member=2024-08-01.
+func ParseUnavailability(s string) (Unavailability, error) {
+	member, dates, ok := strings.Cut(s, "=")
+	if !ok || member == "" {
+		return Unavailability{}, fmt.Errorf("invalid unavailability %q, expected member=YYYY-MM-DD..YYYY-MM-DD", s)
+	}
+	from, to, ok := strings.Cut(dates, "..")
+	if !ok {
+		to = from
+	}
+
+	u := Unavailability{Member: member}
+	var err error
+	if u.From, err = time.Parse(time.DateOnly, from); err != nil {
+		return Unavailability{}, fmt.Errorf("invalid unavailability %q: %w", s, err)
+	}
+	if u.To, err = time.Parse(time.DateOnly, to); err != nil {
+		return Unavailability{}, fmt.Errorf("invalid unavailability %q: %w", s, err)
+	}
+	if u.To.Before(u.From) {
+		return Unavailability{}, fmt.Errorf("invalid unavailability %q: end is before start", s)
+	}
+	return u, nil
+}
+
+// LoadUnavailabilities reads a YAML file listing unavailabilities, e.g.
+//
+//   - member: Cesar
+//     from: 2024-08-01
+//     to: 2024-08-15
+func LoadUnavailabilities(path string) ([]Unavailability, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read availability file: %w", err)
+	}
+	var unavailable []Unavailability
+	if err := yaml.Unmarshal(b, &unavailable); err != nil {
+		return nil, fmt.Errorf("unable to parse availability file %s: %w", path, err)
+	}
+	return unavailable, nil
+}
+
+// Conflict is a slot planned for a member who turns out to be unavailable.
+type Conflict struct {
+	Slot        Slot
+	Unavailable Unavailability
+	// CoveredBy is the member serving the slot once the unavailability is
+	// accounted for, empty when nobody can.
+	CoveredBy string
+}
+
+// Conflicts returns the slots among the first n of a rotation overlapping
+// one of the given unavailabilities of their member, along with who would
+// cover them if these were added to the rotation's own.
+func Conflicts(r Rotation, unavailable []Unavailability, n int) ([]Conflict, error) {
+	slots, err := Preview(r, n)
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []Conflict
+	for _, s := range slots {
+		for _, u := range unavailable {
+			if u.Member == s.Member && u.Overlaps(s.Start, s.End) {
+				conflicts = append(conflicts, Conflict{Slot: s, Unavailable: u})
+				break
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	r.Unavailable = append(slices.Clone(r.Unavailable), unavailable...)
+	// Rescheduling fails when nobody is available for a slot, which leaves
+	// the conflicts uncovered.
+	covered, err := Preview(r, n)
+	if err != nil {
+		return conflicts, nil
+	}
+	for i, c := range conflicts {
+		for _, s := range covered {
+			if s.Rotation == c.Slot.Rotation && s.Start.Equal(c.Slot.Start) {
+				conflicts[i].CoveredBy = s.Member
+				break
+			}
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,146 @@
+package rotation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit is the calendar unit slots are measured in.
+type Unit string
+
+// Supported units, named after their RRULE frequency.
+const (
+	Daily   Unit = "DAILY"
+	Weekly  Unit = "WEEKLY"
+	Monthly Unit = "MONTHLY"
+)
+
+// Cadence is the length of each member's slot, e.g. 2 weeks.
+type Cadence struct {
+	Unit  Unit
+	Count int
+}
+
+// Weeks returns a cadence of the given number of weeks.
+func Weeks(n int) Cadence {
+	return Cadence{Unit: Weekly, Count: n}
+}
+
+// String returns the cadence as an ISO-8601 period, e.g. P2W.
+func (c Cadence) String() string {
+	switch c.Unit {
+	case Daily:
+		return fmt.Sprintf("P%dD", c.Count)
+	case Monthly:
+		return fmt.Sprintf("P%dM", c.Count)
+	default:
+		return fmt.Sprintf("P%dW", c.Count)
+	}
+}
+
+// add returns t moved forward by n slots of the cadence. Dates are moved in
+// calendar days, so slots keep starting at midnight across DST changes.
+func (c Cadence) add(t time.Time, n int) time.Time {
+	switch c.Unit {
+	case Daily:
+		return t.AddDate(0, 0, n*c.Count)
+	case Monthly:
+		return t.AddDate(0, n*c.Count, 0)
+	default:
+		return t.AddDate(0, 0, 7*n*c.Count)
+	}
+}
+
+// Days returns the length of a slot in days, or false for monthly cadences,
+// whose length varies.
+func (c Cadence) Days() (int, bool) {
+	switch c.Unit {
+	case Daily:
+		return c.Count, true
+	case Monthly:
+		return 0, false
+	default:
+		return 7 * c.Count, true
+	}
+}
+
+// rrule returns the recurrence rule repeating a slot every cycle of a
+// rotation with the given number of members.
+func (c Cadence) rrule(members int) string {
+	return fmt.Sprintf("RRULE:FREQ=%s;INTERVAL=%d", c.Unit, c.Count*members)
+}
+
+var isoPeriod = regexp.MustCompile(`^P(\d+)([DWMY])$`)
+
+// ParseCadence parses a cadence written as one of daily, weekly, biweekly or
+// monthly, as an ISO-8601 period with a single unit (e.g. P3D, P2W, P1M), or
+// as a Go duration of whole days (e.g. 72h).
+func ParseCadence(s string) (Cadence, error) {
+	switch strings.ToLower(s) {
+	case "daily":
+		return Cadence{Unit: Daily, Count: 1}, nil
+	case "weekly":
+		return Cadence{Unit: Weekly, Count: 1}, nil
+	case "biweekly":
+		return Cadence{Unit: Weekly, Count: 2}, nil
+	case "monthly":
+		return Cadence{Unit: Monthly, Count: 1}, nil
+	}
+
+	if m := isoPeriod.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n <= 0 {
+			return Cadence{}, fmt.Errorf("invalid cadence %q: period must be positive", s)
+		}
+		switch m[2] {
+		case "D":
+			return Cadence{Unit: Daily, Count: n}, nil
+		case "W":
+			return Cadence{Unit: Weekly, Count: n}, nil
+		case "M":
+			return Cadence{Unit: Monthly, Count: n}, nil
+		default:
+			return Cadence{Unit: Monthly, Count: 12 * n}, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return Cadence{}, fmt.Errorf("invalid cadence %q, must be daily, weekly, biweekly, monthly, an ISO-8601 period like P2W or a duration like 72h", s)
+	}
+	const day = 24 * time.Hour
+	if d <= 0 || d%day != 0 {
+		return Cadence{}, fmt.Errorf("invalid cadence %q: duration must be a positive number of whole days", s)
+	}
+	days := int(d / day)
+	if days%7 == 0 {
+		return Cadence{Unit: Weekly, Count: days / 7}, nil
+	}
+	return Cadence{Unit: Daily, Count: days}, nil
+}
+
+// After returns t moved forward by the cadence, e.g. the end of a horizon
+// starting at t.
+func (c Cadence) After(t time.Time) time.Time {
+	return c.add(t, 1)
+}
+
+var shortPeriod = regexp.MustCompile(`^\d+[dwmyDWMY]$`)
+
+// ParseHorizon parses how far ahead the slots of a materialized rotation are
+// created, written as a number of days, weeks, months or years (e.g. 90d,
+// 26w, 6m or 1y) or as a cadence.
+func ParseHorizon(s string) (Cadence, error) {
+	period := s
+	if shortPeriod.MatchString(s) {
+		period = "P" + s
+	}
+	c, err := ParseCadence(period)
+	if err != nil {
+		return Cadence{}, fmt.Errorf("invalid horizon %q, must be a number of days, weeks, months or years like 90d, 26w, 6m or 1y", s)
+	}
+	return c, nil
+}
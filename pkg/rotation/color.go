@@ -0,0 +1,46 @@
+package rotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// colorNames are the names Google Calendar shows for the event color IDs,
+// from 1 to 11.
+var colorNames = []string{"lavender", "sage", "grape", "flamingo", "banana", "tangerine", "peacock", "graphite", "blueberry", "basil", "tomato"}
+
+// colorHex are the RGB colors Google Calendar shows for the event color IDs,
+// from 1 to 11.
+var colorHex = []string{"#7986cb", "#33b679", "#8e24aa", "#e67c73", "#f6bf26", "#f4511e", "#039be5", "#616161", "#3f51b5", "#0b8043", "#d50000"}
+
+// ColorID returns the Calendar color ID of a color given either as an ID
+// from 1 to 11 or by its name, e.g. tomato.
+func ColorID(color string) (string, error) {
+	if id, err := strconv.Atoi(color); err == nil && id >= 1 && id <= len(colorNames) {
+		return color, nil
+	}
+	for i, name := range colorNames {
+		if strings.EqualFold(color, name) {
+			return strconv.Itoa(i + 1), nil
+		}
+	}
+	return "", fmt.Errorf("invalid color %q, must be a Calendar color ID from 1 to %d or one of: %s", color, len(colorNames), strings.Join(colorNames, ", "))
+}
+
+// ColorHex returns the RGB color of a Calendar color ID as #rrggbb, empty
+// for an unknown ID.
+func ColorHex(id string) string {
+	if i, err := strconv.Atoi(id); err == nil && i >= 1 && i <= len(colorHex) {
+		return colorHex[i-1]
+	}
+	return ""
+}
+
+// DefaultColor returns the color ID of the member at the given position,
+// wrapping around once every color is used, so members of teams larger than
+// the 11 colors share theirs with the member 11 positions apart, whatever
+// the size of the team.
+func DefaultColor(position int) string {
+	return strconv.Itoa(position%len(colorNames) + 1)
+}
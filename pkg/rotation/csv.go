@@ -0,0 +1,210 @@
+package rotation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoadCSV reads a schedule computed elsewhere, e.g. in a spreadsheet, with a
+// row per slot of a member and optionally the role they serve, e.g.
+//
+//	member,start,end,role
+//	Cesar,2024-07-01,2024-07-07,primary
+//	Seth,2024-07-01,2024-07-07,secondary
+//
+// Slots lasting whole days are written as their first and last day, and
+// slots handing off at a time of day as RFC 3339 times, the end excluded.
+// Days are in loc. The header row is optional.
+func LoadCSV(path string, loc *time.Location) ([]Slot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schedule file: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var slots []Slot
+	var errs []error
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse schedule file %s: %w", path, err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "member") {
+			continue
+		}
+		s, err := parseCSVSlot(record, loc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", path, line, err))
+			continue
+		}
+		slots = append(slots, s)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("schedule file %s has no slots", path)
+	}
+	return slots, nil
+}
+
+// parseCSVSlot parses a row of a schedule file, leaving the role of the slot
+// in its Rotation.
+func parseCSVSlot(record []string, loc *time.Location) (Slot, error) {
+	if len(record) < 3 || len(record) > 4 {
+		return Slot{}, fmt.Errorf("expected member,start,end[,role], got %d columns", len(record))
+	}
+	s := Slot{Member: strings.TrimSpace(record[0])}
+	if s.Member == "" {
+		return Slot{}, errors.New("member is empty")
+	}
+	if len(record) == 4 {
+		s.Rotation = strings.TrimSpace(record[3])
+	}
+	start, end := strings.TrimSpace(record[1]), strings.TrimSpace(record[2])
+	var err error
+	if len(start) == len(time.DateOnly) && len(end) == len(time.DateOnly) {
+		if s.Start, err = time.ParseInLocation(time.DateOnly, start, loc); err != nil {
+			return Slot{}, fmt.Errorf("invalid start: %w", err)
+		}
+		if s.End, err = time.ParseInLocation(time.DateOnly, end, loc); err != nil {
+			return Slot{}, fmt.Errorf("invalid end: %w", err)
+		}
+		// The last day is served too.
+		s.End = s.End.AddDate(0, 0, 1)
+	} else {
+		if s.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return Slot{}, fmt.Errorf("invalid start, must be a day like 2024-07-01 or a time like 2024-07-01T09:00:00+02:00: %w", err)
+		}
+		if s.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return Slot{}, fmt.Errorf("invalid end, must be a day like 2024-07-07 or a time like 2024-07-08T09:00:00+02:00: %w", err)
+		}
+		s.Start, s.End = s.Start.In(loc), s.End.In(loc)
+	}
+	if !s.End.After(s.Start) {
+		return Slot{}, errors.New("end is before start")
+	}
+	return s, nil
+}
+
+// PlanCSV returns a single event per slot of a schedule loaded with LoadCSV,
+// with the details of the rotation, e.g. its emails and reminders. Slots of
+// a role are summarized as the role of the rotation and, as in Plan, the
+// roles after the first one aren't colored by member.
+func PlanCSV(r Rotation, slots []Slot) ([]Event, error) {
+	// The members and roles are the ones of the schedule, in order of
+	// appearance, and its slots don't recur, so any cadence checks.
+	r.Members, r.Roles = nil, nil
+	for _, s := range slots {
+		if !slices.Contains(r.Members, s.Member) {
+			r.Members = append(r.Members, s.Member)
+		}
+		if s.Rotation != "" && !slices.Contains(r.Roles, s.Rotation) {
+			r.Roles = append(r.Roles, s.Rotation)
+		}
+	}
+	r.Cadence = Weeks(1)
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	if len(r.Roles) > 0 && slices.ContainsFunc(slots, func(s Slot) bool { return s.Rotation == "" }) {
+		return nil, fmt.Errorf("rotation %q has slots with and without a role, every slot needs one", r.Name)
+	}
+	if err := checkCSVOverlaps(slots); err != nil {
+		return nil, fmt.Errorf("rotation %q: %w", r.Name, err)
+	}
+
+	timeZone := r.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	colors := make(map[string]string)
+	for i, member := range r.Members {
+		colors[member] = DefaultColor(i)
+		if c, ok := r.Colors[member]; ok {
+			colors[member], _ = ColorID(c)
+		}
+	}
+	id := ID(r.Name)
+	events := make([]Event, 0, len(slots))
+	for i, s := range slots {
+		name, color := r.Name, colors[s.Member]
+		if s.Rotation != "" {
+			name = RoleName(r.Name, s.Rotation)
+			if s.Rotation != r.Roles[0] {
+				color = backupColor
+			}
+		}
+		// The slot handed off is the latest one of the role starting
+		// before.
+		var previous Slot
+		for _, p := range slots {
+			if p.Rotation == s.Rotation && p.Start.Before(s.Start) && p.Start.After(previous.Start) {
+				previous = p
+			}
+		}
+		// The slot after is the earliest one of the role starting after.
+		var next Slot
+		for _, n := range slots {
+			if n.Rotation == s.Rotation && n.Start.After(s.Start) && (next.Member == "" || n.Start.Before(next.Start)) {
+				next = n
+			}
+		}
+		escalation := r.escalation(s.Member, next.Member)
+		// Slots of whole days start and end at midnight.
+		timed := !s.Start.Equal(InLocation(s.Start, s.Start.Location())) || !s.End.Equal(InLocation(s.End, s.End.Location()))
+		events = append(events, Event{
+			RotationID:      id,
+			Rotation:        name,
+			Member:          s.Member,
+			Slot:            i,
+			Summary:         Summary(name, s.Member),
+			Start:           s.Start,
+			End:             s.End,
+			Timed:           timed,
+			ColorID:         color,
+			TimeZone:        timeZone,
+			Attendees:       r.attendees(s.Member, escalation, r.Members),
+			Reminders:       r.Reminders,
+			Description:     Rotation{Name: name, Emails: r.Emails, Description: r.Description, Runbooks: r.Runbooks}.description(s.Member, previous.Member, escalation, s.Start, s.End, timed),
+			Visibility:      r.Visibility,
+			GuestsCanModify: r.GuestsCanModify,
+			Transparency:    r.Transparency,
+		})
+	}
+	return events, nil
+}
+
+// checkCSVOverlaps returns an error listing the slots of a role overlapping
+// another slot of the same role, a mistake in the schedule as only a member
+// can serve a role at a time.
+func checkCSVOverlaps(slots []Slot) error {
+	sorted := slices.Clone(slots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Rotation != sorted[j].Rotation {
+			return sorted[i].Rotation < sorted[j].Rotation
+		}
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+	var errs []error
+	for i := 1; i < len(sorted); i++ {
+		prev, s := sorted[i-1], sorted[i]
+		if prev.Rotation == s.Rotation && s.Start.Before(prev.End) {
+			errs = append(errs, fmt.Errorf("slot of %s from %s overlaps the slot of %s from %s", s.Member, s.Start.Format(time.DateOnly), prev.Member, prev.Start.Format(time.DateOnly)))
+		}
+	}
+	return errors.Join(errs...)
+}
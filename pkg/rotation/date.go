@@ -0,0 +1,152 @@
+package rotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ordinals are the days of a month that can be written as words, e.g. "first
+// of july".
+var ordinals = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+	"sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+}
+
+// ParseDate parses a day either formatted as YYYY-MM-DD or written the way
+// people do, relative to today, the day of now:
+//
+//   - today, tomorrow or yesterday
+//   - a weekday, e.g. monday or this monday for the coming one, today
+//     included, next monday for the one after today and last monday for the
+//     one before
+//   - next week or next month, for their first day, weeks starting on Monday
+//   - in 3 days, in 2 weeks, in 1 month or in 1 year
+//   - a day of a month, e.g. july 1, 1 july, july 1st, first of july or
+//     1 july 2025, the coming one when the year is left out
+//
+// Days are returned as midnight UTC, like time.Parse with time.DateOnly.
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(s, ",", " ")))
+	invalid := fmt.Errorf("unable to parse date %q, must be formatted as YYYY-MM-DD or be like today, next monday, in 2 weeks or july 1", s)
+	if len(words) == 0 {
+		return time.Time{}, invalid
+	}
+
+	switch strings.Join(words, " ") {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "next week":
+		return today.AddDate(0, 0, 7-(int(today.Weekday())+6)%7), nil
+	case "next month":
+		return time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	if day, err := ParseWeekday(words[len(words)-1]); err == nil && len(words) <= 2 {
+		ahead := (int(day) - int(today.Weekday()) + 7) % 7
+		switch strings.Join(words[:len(words)-1], " ") {
+		case "", "this":
+		case "next":
+			if ahead == 0 {
+				ahead = 7
+			}
+		case "last":
+			ahead -= 7
+			if ahead == 0 {
+				ahead = -7
+			}
+		default:
+			return time.Time{}, invalid
+		}
+		return today.AddDate(0, 0, ahead), nil
+	}
+
+	if len(words) == 3 && words[0] == "in" {
+		n, err := strconv.Atoi(words[1])
+		if err != nil || n < 0 {
+			return time.Time{}, invalid
+		}
+		switch strings.TrimSuffix(words[2], "s") {
+		case "day":
+			return today.AddDate(0, 0, n), nil
+		case "week":
+			return today.AddDate(0, 0, 7*n), nil
+		case "month":
+			return today.AddDate(0, n, 0), nil
+		case "year":
+			return today.AddDate(n, 0, 0), nil
+		}
+		return time.Time{}, invalid
+	}
+
+	return parseMonthDay(words, today, invalid)
+}
+
+// parseMonthDay parses a day of a month with an optional year, the month
+// and the day in any order, e.g. july 1st 2025 or first of july.
+func parseMonthDay(words []string, today time.Time, invalid error) (time.Time, error) {
+	var month time.Month
+	day, year := 0, 0
+	for _, w := range words {
+		if w == "of" || w == "the" {
+			continue
+		}
+		if m, ok := parseMonth(w); ok && month == 0 {
+			month = m
+			continue
+		}
+		if d, ok := ordinals[w]; ok && day == 0 {
+			day = d
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimRight(w, "stndrh"))
+		switch {
+		case err != nil:
+			return time.Time{}, invalid
+		case day == 0 && n >= 1 && n <= 31:
+			day = n
+		case year == 0 && n >= 1000:
+			year = n
+		default:
+			return time.Time{}, invalid
+		}
+	}
+	if month == 0 || day == 0 {
+		return time.Time{}, invalid
+	}
+
+	// The coming one is this year's unless already past, whether or not
+	// that year has the day, e.g. February 29.
+	if year == 0 {
+		year = today.Year()
+		if month < today.Month() || (month == today.Month() && day < today.Day()) {
+			year++
+		}
+	}
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day {
+		return time.Time{}, fmt.Errorf("%s has no day %d in %d", month, day, year)
+	}
+	return t, nil
+}
+
+// parseMonth parses a month name, in full or abbreviated to its first three
+// letters.
+func parseMonth(s string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if s == name || (len(s) >= 3 && strings.HasPrefix(name, s)) {
+			return m, true
+		}
+	}
+	return 0, false
+}
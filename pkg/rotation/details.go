@@ -0,0 +1,131 @@
+package rotation
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Visibilities of the events of a rotation.
+const (
+	VisibilityDefault = "default"
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// Transparencies of the events of a rotation, whether members show as busy
+// while on rotation.
+const (
+	TransparencyBusy = "busy"
+	TransparencyFree = "free"
+)
+
+// Escalation contacts of a rotation, when not a member.
+const (
+	// EscalationNext escalates to the member serving the next slot.
+	EscalationNext = "next"
+	// EscalationNone doesn't name an escalation contact.
+	EscalationNone = "none"
+)
+
+// descriptionData is what the description template of a rotation is
+// executed with, for every event.
+type descriptionData struct {
+	Rotation string
+	Member   string
+	Email    string
+	// SlotStart and SlotEnd are the first and last day of the slot of the
+	// event, the first one of recurring events, with their times when
+	// handing off at a time of day.
+	SlotStart string
+	SlotEnd   string
+	// Previous is the member serving the slot before the first one of the
+	// event, who hands it off, empty for the first slot of the rotation.
+	Previous string
+	// Escalation is the member to call when the one on rotation is
+	// unreachable, empty when the rotation has none.
+	Escalation string
+	// Runbooks are the links of the rotation, e.g. to its runbooks.
+	Runbooks []string
+}
+
+// parseDescription parses the description template of a rotation, e.g.
+// "{{.Member}} is on call until {{.SlotEnd}}".
+func parseDescription(text string) (*template.Template, error) {
+	t, err := template.New("description").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid description template: %w", err)
+	}
+	// Unknown fields only fail when executed.
+	if err := t.Execute(io.Discard, descriptionData{}); err != nil {
+		return nil, fmt.Errorf("invalid description template: %w", err)
+	}
+	return t, nil
+}
+
+// LoadDescription reads the description template of a rotation from a file,
+// e.g. a handoff checklist written in Markdown.
+func LoadDescription(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read description template: %w", err)
+	}
+	if _, err := parseDescription(string(b)); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return string(b), nil
+}
+
+// description returns the description of the event of a member serving the
+// slot from start to end, after previous and with escalation as contact. The
+// template was validated already. Rotations without one describe the
+// escalation contact, if any.
+func (r Rotation) description(member, previous, escalation string, start, end time.Time, timed bool) string {
+	if r.Description == "" {
+		if escalation == "" {
+			return ""
+		}
+		if email, ok := r.Emails[escalation]; ok {
+			return fmt.Sprintf("Escalation contact: %s (%s)", escalation, email)
+		}
+		return "Escalation contact: " + escalation
+	}
+	t, _ := parseDescription(r.Description)
+	data := descriptionData{
+		Rotation:   r.Name,
+		Member:     member,
+		Email:      r.Emails[member],
+		SlotStart:  start.Format(time.DateOnly),
+		SlotEnd:    end.AddDate(0, 0, -1).Format(time.DateOnly),
+		Previous:   previous,
+		Escalation: escalation,
+		Runbooks:   r.Runbooks,
+	}
+	if timed {
+		data.SlotStart, data.SlotEnd = start.Format("2006-01-02 15:04 MST"), end.Format("2006-01-02 15:04 MST")
+	}
+	var b strings.Builder
+	t.Execute(&b, data)
+	return b.String()
+}
+
+// escalation returns the escalation contact of a member serving a slot
+// before next: next by default, the member given as Escalation, or nobody
+// when it's none or the member on rotation themselves.
+func (r Rotation) escalation(member, next string) string {
+	contact := next
+	switch r.Escalation {
+	case "", EscalationNext:
+	case EscalationNone:
+		return ""
+	default:
+		contact = r.Escalation
+	}
+	if contact == member {
+		return ""
+	}
+	return contact
+}
@@ -0,0 +1,104 @@
+package rotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Export is a snapshot of the events of a rotation, to create them again in
+// another calendar, e.g. when migrating to a new team calendar.
+type Export struct {
+	Rotation   string `json:"rotation"`
+	RotationID string `json:"rotationId"`
+	// Calendar is the ID of the calendar the rotation was exported from.
+	Calendar   string          `json:"calendar"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Events     []ExportedEvent `json:"events"`
+}
+
+// ExportedEvent is an event of an Export, along with its ID in the calendar
+// it was exported from. Dates are written as YYYY-MM-DD, and the times of
+// timed events in RFC 3339 format.
+type ExportedEvent struct {
+	ID              string     `json:"id"`
+	Member          string     `json:"member"`
+	Summary         string     `json:"summary"`
+	Start           string     `json:"start"`
+	End             string     `json:"end"`
+	Recurrence      []string   `json:"recurrence,omitempty"`
+	ColorID         string     `json:"colorId,omitempty"`
+	TimeZone        string     `json:"timeZone"`
+	Attendees       []Attendee `json:"attendees,omitempty"`
+	Reminders       []Reminder `json:"reminders,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	Visibility      string     `json:"visibility,omitempty"`
+	GuestsCanModify bool       `json:"guestsCanModify,omitempty"`
+	Transparency    string     `json:"transparency,omitempty"`
+}
+
+// LoadExport reads an export file.
+func LoadExport(path string) (*Export, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read export file: %w", err)
+	}
+	var x Export
+	if err := json.Unmarshal(b, &x); err != nil {
+		return nil, fmt.Errorf("unable to parse export file %s: %w", path, err)
+	}
+	if x.Rotation == "" || x.RotationID == "" {
+		return nil, fmt.Errorf("export file %s has no rotation", path)
+	}
+	return &x, nil
+}
+
+// Plan returns the events to create to restore the exported rotation.
+func (x *Export) Plan() ([]Event, error) {
+	events := make([]Event, 0, len(x.Events))
+	for _, e := range x.Events {
+		loc, err := time.LoadLocation(e.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("event %s has an invalid time zone: %w", e.ID, err)
+		}
+		timed := len(e.Start) > len(time.DateOnly)
+		start, err := parseExportedTime(e.Start, timed, loc)
+		if err != nil {
+			return nil, fmt.Errorf("event %s has an invalid start: %w", e.ID, err)
+		}
+		end, err := parseExportedTime(e.End, timed, loc)
+		if err != nil {
+			return nil, fmt.Errorf("event %s has an invalid end: %w", e.ID, err)
+		}
+		events = append(events, Event{
+			RotationID:      x.RotationID,
+			Rotation:        x.Rotation,
+			Member:          e.Member,
+			Slot:            -1,
+			Summary:         e.Summary,
+			Start:           start,
+			End:             end,
+			Timed:           timed,
+			Recurrence:      e.Recurrence,
+			ColorID:         e.ColorID,
+			TimeZone:        e.TimeZone,
+			Attendees:       e.Attendees,
+			Reminders:       e.Reminders,
+			Description:     e.Description,
+			Visibility:      e.Visibility,
+			GuestsCanModify: e.GuestsCanModify,
+			Transparency:    e.Transparency,
+		})
+	}
+	return events, nil
+}
+
+// parseExportedTime parses the start or end of an exported event in loc.
+func parseExportedTime(s string, timed bool, loc *time.Location) (time.Time, error) {
+	if !timed {
+		return time.ParseInLocation(time.DateOnly, s, loc)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.In(loc), err
+}
@@ -0,0 +1,78 @@
+package rotation
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Share is how much a member served a rotation over a period compared with
+// their fair share of it.
+type Share struct {
+	Served
+	// Expected is the number of days the member would have served if the
+	// days of the period had been shared by weight among the members.
+	Expected float64 `json:"expected"`
+	// Balance is Days minus Expected: positive for members who served more
+	// than their share, e.g. covering for others, negative for members who
+	// were covered for.
+	Balance float64 `json:"balance"`
+}
+
+// Fairness tallies the slots of the named rotation overlapping the [from,
+// to) range like History, overrides and swaps included as served, and
+// compares the days served with the share of the members, by their weights,
+// 1 when unset. Members who served without being one of members anymore
+// have no share. Shares are sorted by member name.
+func Fairness(slots []Slot, name string, members []string, weights map[string]int, from, to time.Time) []Share {
+	history := History(slots, name, from, to)
+	for _, m := range members {
+		if !slices.ContainsFunc(history, func(s Served) bool { return s.Member == m }) {
+			history = append(history, Served{Member: m})
+		}
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Member < history[j].Member })
+
+	days, total := 0, 0
+	for _, s := range history {
+		days += s.Days
+	}
+	weight := func(member string) int {
+		if w, ok := weights[member]; ok && w > 0 {
+			return w
+		}
+		return 1
+	}
+	for _, m := range members {
+		total += weight(m)
+	}
+
+	shares := make([]Share, 0, len(history))
+	for _, s := range history {
+		share := Share{Served: s}
+		if slices.Contains(members, s.Member) {
+			share.Expected = float64(days*weight(s.Member)) / float64(total)
+		}
+		share.Balance = float64(s.Days) - share.Expected
+		shares = append(shares, share)
+	}
+	return shares
+}
+
+// Rebalanced returns the members in the order their next turns should come
+// in so the rotation becomes fairer: the ones who served the least compared
+// with their share first, members balanced alike keeping their order. It
+// only reorders the cycle, every member keeping one turn per cycle, so the
+// members who served more than their share get a longer break once rather
+// than fewer turns until they are even.
+func Rebalanced(shares []Share, order []string) []string {
+	balance := make(map[string]float64)
+	for _, s := range shares {
+		balance[s.Member] = s.Balance
+	}
+	rebalanced := slices.Clone(order)
+	sort.SliceStable(rebalanced, func(i, j int) bool {
+		return balance[rebalanced[i]] < balance[rebalanced[j]]
+	})
+	return rebalanced
+}
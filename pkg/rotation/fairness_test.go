@@ -0,0 +1,119 @@
+package rotation_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+func TestFairness(t *testing.T) {
+	day := func(n int) time.Time { return start.AddDate(0, 0, n) }
+	slot := func(member string, from, to int) rotation.Slot {
+		return rotation.Slot{Rotation: "SRE Role", Member: member, Start: day(from), End: day(to)}
+	}
+	// Alice covered Bob's second week, and Dana, who left, served before.
+	slots := []rotation.Slot{
+		slot("Dana", -6, 0),
+		slot("Alice", 0, 7),
+		slot("Bob", 7, 14),
+		slot("Cesar", 14, 21),
+		slot("Alice", 21, 28),
+		slot("Alice", 28, 35),
+		slot("Cesar", 35, 42),
+		{Rotation: "Support", Member: "Bob", Start: day(0), End: day(42)},
+	}
+	tests := []struct {
+		name    string
+		weights map[string]int
+		from    time.Time
+		want    []rotation.Share
+	}{
+		{
+			name: "even weights",
+			from: day(0),
+			want: []rotation.Share{
+				{Served: rotation.Served{Member: "Alice", Slots: 2, Days: 21}, Expected: 14, Balance: 7},
+				{Served: rotation.Served{Member: "Bob", Slots: 1, Days: 7}, Expected: 14, Balance: -7},
+				{Served: rotation.Served{Member: "Cesar", Slots: 2, Days: 14}, Expected: 14, Balance: 0},
+			},
+		},
+		{
+			name:    "weighted",
+			weights: map[string]int{"Alice": 2},
+			from:    day(0),
+			want: []rotation.Share{
+				{Served: rotation.Served{Member: "Alice", Slots: 2, Days: 21}, Expected: 21, Balance: 0},
+				{Served: rotation.Served{Member: "Bob", Slots: 1, Days: 7}, Expected: 10.5, Balance: -3.5},
+				{Served: rotation.Served{Member: "Cesar", Slots: 2, Days: 14}, Expected: 10.5, Balance: 3.5},
+			},
+		},
+		{
+			name: "former member without a share",
+			from: day(-6),
+			want: []rotation.Share{
+				{Served: rotation.Served{Member: "Alice", Slots: 2, Days: 21}, Expected: 16, Balance: 5},
+				{Served: rotation.Served{Member: "Bob", Slots: 1, Days: 7}, Expected: 16, Balance: -9},
+				{Served: rotation.Served{Member: "Cesar", Slots: 2, Days: 14}, Expected: 16, Balance: -2},
+				{Served: rotation.Served{Member: "Dana", Slots: 1, Days: 6}, Balance: 6},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rotation.Fairness(slots, "SRE Role", []string{"Bob", "Alice", "Cesar"}, tt.weights, tt.from, day(42))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Fairness() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebalanced(t *testing.T) {
+	share := func(member string, balance float64) rotation.Share {
+		return rotation.Share{Served: rotation.Served{Member: member}, Balance: balance}
+	}
+	tests := []struct {
+		name   string
+		shares []rotation.Share
+		order  []string
+		want   []string
+	}{
+		{
+			name:   "least served first",
+			shares: []rotation.Share{share("Alice", 7), share("Bob", -7), share("Cesar", 0)},
+			order:  []string{"Alice", "Bob", "Cesar"},
+			want:   []string{"Bob", "Cesar", "Alice"},
+		},
+		{
+			name:   "balanced alike keep their order",
+			shares: []rotation.Share{share("Alice", 0), share("Bob", 0), share("Cesar", -1)},
+			order:  []string{"Bob", "Alice", "Cesar"},
+			want:   []string{"Cesar", "Bob", "Alice"},
+		},
+		{
+			name:   "members without a share are even",
+			shares: []rotation.Share{share("Alice", 2), share("Bob", -2)},
+			order:  []string{"Alice", "Bob", "Dana"},
+			want:   []string{"Bob", "Dana", "Alice"},
+		},
+		{
+			name:   "already balanced",
+			shares: []rotation.Share{share("Alice", 0), share("Bob", 0)},
+			order:  []string{"Alice", "Bob"},
+			want:   []string{"Alice", "Bob"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := slices.Clone(tt.order)
+			if got := rotation.Rebalanced(tt.shares, order); !slices.Equal(got, tt.want) {
+				t.Errorf("Rebalanced() = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(order, tt.order) {
+				t.Errorf("Rebalanced() changed the order it was given to %v", order)
+			}
+		})
+	}
+}
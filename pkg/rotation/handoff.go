@@ -0,0 +1,64 @@
+package rotation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ways the first slot is laid out when a rotation doesn't start on its
+// handoff day.
+const (
+	// FirstSlotShorten ends the first slot on the next handoff day.
+	FirstSlotShorten = "shorten"
+	// FirstSlotExtend ends the first slot a full slot after the next
+	// handoff day.
+	FirstSlotExtend = "extend"
+)
+
+// ParseWeekday parses the English name of a day of the week, e.g. monday
+// or Mon.
+func ParseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q, must be a day name like monday", s)
+}
+
+// rruleDay returns the RRULE code of a weekday, e.g. MO.
+func rruleDay(d time.Weekday) string {
+	return strings.ToUpper(d.String()[:2])
+}
+
+// anchor returns the day the slots of a rotation starting on start and
+// handing off on day are laid out from: the handoff day before start when
+// shortening the first slot, or after it when extending it.
+func anchor(start time.Time, day time.Weekday, firstSlot string) time.Time {
+	offset := (int(day) - int(start.Weekday()) + 7) % 7
+	if offset == 0 || firstSlot == FirstSlotExtend {
+		return start.AddDate(0, 0, offset)
+	}
+	return start.AddDate(0, 0, offset-7)
+}
+
+// clock is a time of day.
+type clock struct {
+	hour, minute int
+}
+
+// parseClock parses a time of day written as HH:MM, e.g. 09:00.
+func parseClock(s string) (clock, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return clock{}, fmt.Errorf("invalid time of day %q, must be written as HH:MM like 09:00", s)
+	}
+	return clock{hour: t.Hour(), minute: t.Minute()}, nil
+}
+
+// on returns the time of day on the calendar day of t, in loc.
+func (c clock) on(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), c.hour, c.minute, 0, 0, loc)
+}
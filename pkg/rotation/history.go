@@ -0,0 +1,59 @@
+package rotation
+
+import (
+	"sort"
+	"time"
+)
+
+// Served is how much a member served a rotation over a period.
+type Served struct {
+	Member string `json:"member"`
+	// Slots is the number of slots the member served, consecutive ones
+	// counting as one as there is no handoff between them.
+	Slots int `json:"slots"`
+	// Days is the number of days the member was on rotation.
+	Days int `json:"days"`
+}
+
+// History tallies the slots of the named rotation overlapping the [from, to)
+// range by member, sorted by member name. Only the days within the range
+// are counted.
+func History(slots []Slot, name string, from, to time.Time) []Served {
+	byMember := make(map[string]*Served)
+	var rotation []Slot
+	for _, s := range slots {
+		if s.Rotation != name || !s.End.After(from) || !s.Start.Before(to) {
+			continue
+		}
+		rotation = append(rotation, s)
+		served, ok := byMember[s.Member]
+		if !ok {
+			served = &Served{Member: s.Member}
+			byMember[s.Member] = served
+		}
+		// Days are counted on the slot, rather than on the merged ones, so
+		// the weekends of weekday only rotations aren't counted.
+		start, end := s.Start, s.End
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			served.Days++
+		}
+	}
+	for _, s := range merge(rotation) {
+		byMember[s.Member].Slots++
+	}
+
+	history := make([]Served, 0, len(byMember))
+	for _, served := range byMember {
+		history = append(history, *served)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Member < history[j].Member
+	})
+	return history
+}
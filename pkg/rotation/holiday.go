@@ -0,0 +1,85 @@
+package rotation
+
+import "time"
+
+// holiday reports whether a day is a day off: a weekend or a holiday of the
+// rotation.
+func (s *schedule) holiday(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || s.holidays[civil(day)]
+}
+
+// handoffOn returns the first day of a slot: the start of its occurrence or,
+// when that's a holiday, the next business day, unless the slot would be
+// over by then.
+func (s *schedule) handoffOn(slot int) time.Time {
+	start := s.series(slot)
+	if !s.holidays[civil(start)] {
+		return start
+	}
+	day := start
+	for s.holiday(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	if !day.Before(s.series(slot + 1)) {
+		return start
+	}
+	return day
+}
+
+// lastHoliday returns the last holiday of the rotation, zero when it has
+// none.
+func (s *schedule) lastHoliday() time.Time {
+	var last time.Time
+	for day := range s.holidays {
+		if day.After(last) {
+			last = day
+		}
+	}
+	return last
+}
+
+// moved returns the slots lasting whole days whose bounds differ from their
+// occurrence in the recurring events because of holidays, so they are
+// replaced like the ones covered by someone else.
+func (s *schedule) moved() []int {
+	var slots []int
+	last := s.lastHoliday()
+	for slot := 0; (s.slots == 0 || slot < s.slots) && !civil(s.series(slot)).After(last); slot++ {
+		if _, ok := s.overrides[slot]; ok || s.skipped[slot] || (slot == 0 && s.partial()) {
+			continue
+		}
+		start, end := s.bounds(slot)
+		if !start.Equal(s.series(slot)) || !end.Equal(s.series(slot+1)) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// skip leaves out the slots whose business days are all holidays. Their
+// members serve an extra slot each at the end of finite rotations instead,
+// in the order they were skipped.
+func (s *schedule) skip() {
+	s.skipped = make(map[int]bool)
+	last := s.lastHoliday()
+	slots := s.slots
+	for slot := 0; (slots == 0 || slot < slots) && !civil(s.series(slot)).After(last); slot++ {
+		start, end := s.bounds(slot)
+		// Slots only covering a weekend, e.g. daily ones, are still served.
+		business, holidays := false, false
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			business = business || !s.holiday(day)
+			holidays = holidays || s.holidays[civil(day)]
+		}
+		if business || !holidays {
+			continue
+		}
+		s.skipped[slot] = true
+		member := s.member(slot)
+		delete(s.overrides, slot)
+		if s.slots > 0 {
+			s.overrides[s.slots] = member
+			s.slots++
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package rotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reminder methods.
+const (
+	ReminderEmail = "email"
+	ReminderPopup = "popup"
+)
+
+// maxReminderMinutes is the furthest ahead of an event Google Calendar can
+// remind of it, 4 weeks.
+const maxReminderMinutes = 40320
+
+// Reminder notifies the member some minutes before their slot starts.
+type Reminder struct {
+	Method  string `yaml:"method" json:"method"`
+	Minutes int    `yaml:"minutes" json:"minutes"`
+}
+
+// ParseReminders parses reminders written as a comma-separated list of
+// method:minutes, e.g. email:1440,popup:60.
+func ParseReminders(s string) ([]Reminder, error) {
+	var reminders []Reminder
+	for _, part := range strings.Split(s, ",") {
+		method, minutes, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid reminder %q, expected method:minutes, e.g. popup:60", part)
+		}
+		n, err := strconv.Atoi(minutes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminder %q: minutes must be a number", part)
+		}
+		reminders = append(reminders, Reminder{Method: method, Minutes: n})
+	}
+	return reminders, nil
+}
+
+func (r Reminder) validate() error {
+	if r.Method != ReminderEmail && r.Method != ReminderPopup {
+		return fmt.Errorf("unknown reminder method %q, must be one of: %s, %s", r.Method, ReminderEmail, ReminderPopup)
+	}
+	if r.Minutes < 0 || r.Minutes > maxReminderMinutes {
+		return fmt.Errorf("reminder minutes must be between 0 and %d, got %d", maxReminderMinutes, r.Minutes)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package rotation
+
+import (
+	"fmt"
+	"time"
+)
+
+// backupColor is the color ID of the events of the roles after the first,
+// graphite, so they stand apart from the first role's, colored by member.
+const backupColor = "8"
+
+// RoleName returns the name of a role of a rotation, e.g. SRE Role
+// (secondary), used in the summaries of the role's events.
+func RoleName(name, role string) string {
+	return fmt.Sprintf("%s (%s)", name, role)
+}
+
+// roleSchedule is the schedule of a single role of a rotation, named after
+// the role.
+type roleSchedule struct {
+	Rotation
+	s          *schedule
+	occurrence func(slot int) (time.Time, time.Time)
+}
+
+// roles schedules every role of the rotation, or the rotation itself when it
+// has none. The members of each role are the ones serving the previous role
+// on the next slot, so members are on duty for a slot per role in a row, and
+// covering for unavailable members takes all of them into account.
+func (r Rotation) roles() ([]roleSchedule, error) {
+	s, _, err := r.schedule(max(len(r.Roles), 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Roles) == 0 {
+		return []roleSchedule{{Rotation: r, s: s, occurrence: s.occurrence}}, nil
+	}
+
+	var roles []roleSchedule
+	for i, role := range r.Roles {
+		sub := r
+		sub.Name = RoleName(r.Name, role)
+		sub.Roles = nil
+		shifted := s.shifted(i)
+		roles = append(roles, roleSchedule{Rotation: sub, s: shifted, occurrence: shifted.occurrence})
+	}
+
+	// Members weighing more than one, or covering for unavailable ones, may
+	// end up serving two roles of a slot. Slots after the last one covered
+	// repeat the first cycle.
+	last := len(s.members)
+	for slot := range s.overrides {
+		last = max(last, slot+1)
+	}
+	if s.slots > 0 {
+		last = min(last, s.slots)
+	}
+	for slot := 0; slot < last; slot++ {
+		served := make(map[string]string)
+		for i, role := range roles {
+			member := role.s.member(slot)
+			if other, ok := served[member]; ok {
+				start, _ := s.bounds(slot)
+				return nil, fmt.Errorf("rotation %q has %s as both %s and %s on the slot starting %s", r.Name, member, other, r.Roles[i], start.Format(time.DateOnly))
+			}
+			served[member] = r.Roles[i]
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,221 @@
+package rotation
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Roster describes the members of a team, so their details don't need to be
+// repeated on every command.
+type Roster struct {
+	Members []Member `yaml:"members"`
+	// Teams maps team names to their members, e.g. platform, so rotations
+	// can be described by team.
+	Teams map[string][]string `yaml:"teams"`
+}
+
+// Member is a member of a team.
+type Member struct {
+	Name string `yaml:"name"`
+	// Aliases are other names the member goes by, e.g. nicknames.
+	Aliases []string `yaml:"aliases"`
+	// Email is the address invited to the member's events.
+	Email string `yaml:"email"`
+	// Color is the Calendar color of the member's events, as an ID from 1
+	// to 11 or a color name, e.g. tomato.
+	Color string `yaml:"color"`
+	// Weight is the number of slots the member serves in every cycle of the
+	// rotation, 1 when unset.
+	Weight int `yaml:"weight"`
+	// TimeZone is the IANA time zone the member works from, where they take
+	// over rotations handing off at a time of day.
+	TimeZone string `yaml:"timezone"`
+	// Unavailable lists the periods when the member can't be on rotation.
+	Unavailable []Unavailability `yaml:"unavailable"`
+}
+
+// LoadRoster reads a YAML roster file, e.g.
+//
+//	members:
+//	  - name: Cesar
+//	    aliases: [Ces]
+//	    email: cesar@example.com
+//	    color: banana
+//	    weight: 2
+//	    timezone: Europe/Madrid
+//	    unavailable:
+//	      - from: 2024-08-01
+//	        to: 2024-08-15
+//	  - name: Seth
+//	    email: seth@example.com
+//	teams:
+//	  platform: [Cesar, Seth]
+func LoadRoster(path string) (*Roster, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read roster file: %w", err)
+	}
+	var roster Roster
+	if err := yaml.Unmarshal(b, &roster); err != nil {
+		return nil, fmt.Errorf("unable to parse roster file %s: %w", path, err)
+	}
+	if err := roster.validate(); err != nil {
+		return nil, fmt.Errorf("invalid roster file %s: %w", path, err)
+	}
+	return &roster, nil
+}
+
+func (r *Roster) validate() error {
+	if len(r.Members) == 0 {
+		return fmt.Errorf("no members")
+	}
+	seen := make(map[string]bool)
+	for i := range r.Members {
+		m := &r.Members[i]
+		if m.Name == "" {
+			return fmt.Errorf("member %d has no name", i+1)
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("member %q is listed twice", m.Name)
+		}
+		seen[m.Name] = true
+		if m.Color != "" {
+			if _, err := ColorID(m.Color); err != nil {
+				return fmt.Errorf("member %q: %w", m.Name, err)
+			}
+		}
+		if m.Weight < 0 {
+			return fmt.Errorf("member %q weight must be positive, got %d", m.Name, m.Weight)
+		}
+		if _, err := time.LoadLocation(m.TimeZone); err != nil {
+			return fmt.Errorf("member %q has an invalid time zone: %w", m.Name, err)
+		}
+		for j := range m.Unavailable {
+			m.Unavailable[j].Member = m.Name
+		}
+	}
+	// Aliases can't be mistaken for another member.
+	names := make(map[string]string)
+	for _, m := range r.Members {
+		names[strings.ToLower(m.Name)] = m.Name
+	}
+	for _, m := range r.Members {
+		for _, alias := range m.Aliases {
+			if other, ok := names[strings.ToLower(alias)]; ok && other != m.Name {
+				return fmt.Errorf("member %q has alias %q, already the name or an alias of %q", m.Name, alias, other)
+			}
+			names[strings.ToLower(alias)] = m.Name
+		}
+	}
+	for team, members := range r.Teams {
+		if len(members) == 0 {
+			return fmt.Errorf("team %q has no members", team)
+		}
+		for i, name := range members {
+			member, err := r.Resolve(name)
+			if err != nil {
+				return fmt.Errorf("team %q: %w", team, err)
+			}
+			members[i] = member
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the members, in the order of the roster.
+func (r *Roster) Names() []string {
+	names := make([]string, 0, len(r.Members))
+	for _, m := range r.Members {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// Resolve returns the name of the member going by the given name or alias,
+// ignoring case, or an error if nobody does.
+func (r *Roster) Resolve(name string) (string, error) {
+	for _, m := range r.Members {
+		if strings.EqualFold(m.Name, name) {
+			return m.Name, nil
+		}
+	}
+	for _, m := range r.Members {
+		for _, alias := range m.Aliases {
+			if strings.EqualFold(alias, name) {
+				return m.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unknown member %q, must be one of: %s", name, strings.Join(r.Names(), ", "))
+}
+
+// Team returns the members of the named team, ignoring case.
+func (r *Roster) Team(name string) ([]string, bool) {
+	for team, members := range r.Teams {
+		if strings.EqualFold(team, name) {
+			return members, true
+		}
+	}
+	return nil, false
+}
+
+// Check returns an error if the roster has no member with the given name,
+// catching typos in member names.
+func (r *Roster) Check(name string) error {
+	names := r.Names()
+	if !slices.Contains(names, name) {
+		return fmt.Errorf("unknown member %q, must be one of: %s", name, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Apply fills the rotation with the details of its members. The rotation
+// takes every member of the roster when it has none, and details already set
+// on the rotation take precedence.
+func (r *Roster) Apply(rot *Rotation) error {
+	if len(rot.Members) == 0 {
+		rot.Members = r.Names()
+	}
+	for _, name := range rot.Members {
+		if err := r.Check(name); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range r.Members {
+		if !slices.Contains(rot.Members, m.Name) {
+			continue
+		}
+		if _, ok := rot.Emails[m.Name]; !ok && m.Email != "" {
+			if rot.Emails == nil {
+				rot.Emails = make(map[string]string)
+			}
+			rot.Emails[m.Name] = m.Email
+		}
+		if _, ok := rot.Colors[m.Name]; !ok && m.Color != "" {
+			if rot.Colors == nil {
+				rot.Colors = make(map[string]string)
+			}
+			rot.Colors[m.Name] = m.Color
+		}
+		if _, ok := rot.Weights[m.Name]; !ok && m.Weight > 0 {
+			if rot.Weights == nil {
+				rot.Weights = make(map[string]int)
+			}
+			rot.Weights[m.Name] = m.Weight
+		}
+		if _, ok := rot.TimeZones[m.Name]; !ok && m.TimeZone != "" {
+			if rot.TimeZones == nil {
+				rot.TimeZones = make(map[string]string)
+			}
+			rot.TimeZones[m.Name] = m.TimeZone
+		}
+		rot.Unavailable = append(rot.Unavailable, m.Unavailable...)
+	}
+	return nil
+}
@@ -0,0 +1,890 @@
+// Package rotation computes the schedule of a team rotation, where members
+// take turns in fixed-length slots that repeat once everybody has served.
+package rotation
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Orders members can take turns in.
+const (
+	OrderAlphabetical = "alphabetical"
+	OrderGiven        = "given"
+	OrderShuffle      = "shuffle"
+)
+
+// Ways the slots of members weighing more than one are laid out.
+const (
+	// WeightTurns spreads the slots over the cycle, e.g. A, B, A, C.
+	WeightTurns = "turns"
+	// WeightLength serves the slots back to back, making a single longer
+	// slot, e.g. A, A, B, C.
+	WeightLength = "length"
+)
+
+// Rotation is the definition of a team rotation.
+type Rotation struct {
+	// Name of the rotation, e.g. SRE Role.
+	Name string
+	// Members taking turns in the rotation.
+	Members []string
+	// Roles split every slot between several members, e.g. primary and
+	// secondary, the secondary of a slot being the primary of the next one.
+	// Slots have a single member when empty.
+	Roles []string
+	// Order is the order members take turns in, alphabetical when empty.
+	Order string
+	// Seed makes shuffled orders reproducible.
+	Seed int64
+	// StartWith is the member serving the first slot, the first one in
+	// Order when empty.
+	StartWith string
+	// Start is the first day of the rotation.
+	Start time.Time
+	// Cadence is the length of each member's slot.
+	Cadence Cadence
+	// Until is the last day a slot can start on. Zero repeats forever.
+	Until time.Time
+	// Count is the total number of slots, zero repeats forever.
+	Count int
+	// TimeZone is the IANA time zone of the events, UTC when empty.
+	TimeZone string
+	// Unavailable lists the periods when members can't be on rotation.
+	Unavailable []Unavailability
+	// Emails maps members to the address invited to their events.
+	Emails map[string]string
+	// InviteTeam adds the rest of the team as optional attendees.
+	InviteTeam bool
+	// Colors maps members to the Calendar color of their events, as an ID
+	// from 1 to 11 or a color name. Members without one get a color by
+	// their position, recycled in teams of more than 11 members.
+	Colors map[string]string
+	// Weights maps members to the number of slots they serve in every
+	// cycle, 1 when unset.
+	Weights map[string]int
+	// WeightBy is how the slots of members weighing more than one are laid
+	// out, WeightTurns when empty.
+	WeightBy string
+	// WeekdaysOnly limits the rotation to Monday to Friday, with slots
+	// starting on Mondays.
+	WeekdaysOnly bool
+	// HandoffDay is the day of the week slots start on, e.g. monday, the
+	// day of Start when empty.
+	HandoffDay string
+	// FirstSlot is how the first slot is laid out when Start isn't on
+	// HandoffDay, FirstSlotShorten when empty.
+	FirstSlot string
+	// HandoffTime is the time of day slots start at, e.g. 09:00, in the
+	// time zone of the incoming member. Slots last whole days when empty.
+	HandoffTime string
+	// TimeZones maps members to the IANA time zone they work from, where
+	// they take over at HandoffTime. Members without one use TimeZone.
+	TimeZones map[string]string
+	// Reminders replace the default reminders of the calendar on every
+	// event when set.
+	Reminders []Reminder
+	// Holidays are the days off of the team, e.g. public holidays. Slots
+	// starting on one start on the next business day instead, the previous
+	// slot lasting longer.
+	Holidays []time.Time
+	// SkipHolidays leaves out the slots whose business days are all
+	// Holidays, e.g. the week between Christmas and New Year. Their members
+	// serve one more slot at the end of finite rotations instead.
+	SkipHolidays bool
+	// Visibility of the events, VisibilityPublic or VisibilityPrivate, the
+	// calendar's default when empty.
+	Visibility string
+	// GuestsCanModify lets the attendees of the events edit them.
+	GuestsCanModify bool
+	// Transparency is whether members show as busy or free while on
+	// rotation, the calendar's usual one for rotations when empty.
+	Transparency string
+	// Description is a text/template of the description of the events,
+	// e.g. "{{.Member}} is on call until {{.SlotEnd}}".
+	Description string
+	// Runbooks are links listed in the description of the events, e.g. to
+	// the runbooks of the rotation.
+	Runbooks []string
+	// Materialize writes a single event per slot rather than a recurring
+	// event per member, e.g. for large teams, whose long recurrence
+	// intervals confuse some calendar clients.
+	Materialize bool
+	// Horizon is the last day a materialized slot can start on when the
+	// rotation never ends.
+	Horizon time.Time
+	// Escalation is the member to call when the one on rotation is
+	// unreachable, named in the description of the events and invited to
+	// them: EscalationNext, the default, EscalationNone or one of Members.
+	Escalation string
+	// Window limits the events to the days and hours the rotation applies
+	// to, e.g. "Mon-Fri 09:00-17:00" for business hours, in TimeZone. Slots
+	// last whole weeks and are covered all day when empty.
+	Window string
+}
+
+// Attendee is a guest invited to an event.
+type Attendee struct {
+	Email    string `json:"email"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+// Event is the recurring event of a single member in a rotation, or a single
+// occurrence when Recurrence is empty.
+type Event struct {
+	RotationID string
+	// Rotation is the name of the rotation, or of its role.
+	Rotation string
+	Member   string
+	// Slot is the index of the first slot of the event in the rotation, from
+	// 0, or -1 when unknown.
+	Slot    int
+	Summary string
+	Start   time.Time
+	End     time.Time
+	// Timed events start and end at the time of Start and End, in
+	// TimeZone, rather than lasting whole days.
+	Timed      bool
+	Recurrence []string
+	ColorID    string
+	TimeZone   string
+	Attendees  []Attendee
+	Reminders  []Reminder
+	// Description, Visibility, GuestsCanModify and Transparency are as in
+	// the rotation, the description being rendered for the event.
+	Description     string
+	Visibility      string
+	GuestsCanModify bool
+	Transparency    string
+}
+
+// Slot is a single occurrence of a member's turn in a rotation.
+type Slot struct {
+	Rotation string `json:"rotation"`
+	// RotationID is the ID the event of the slot is tagged with, when it was
+	// created by this tool. It is the ID of the rotation rather than of its
+	// name when the rotation has roles, see ID.
+	RotationID string    `json:"rotationId,omitempty"`
+	Member     string    `json:"member"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	// Link is the URL of the event of the slot, when the calendar has one.
+	Link string `json:"link,omitempty"`
+	// ColorID is the Calendar color ID of the event of the slot, when the
+	// calendar has one.
+	ColorID string `json:"colorId,omitempty"`
+	// Managed is set when the event of the slot was created by this tool,
+	// rather than by someone following the naming of the rotations.
+	Managed bool `json:"managed"`
+}
+
+// Validate checks the rotation can be planned, returning every problem
+// found rather than only the first one.
+func (r Rotation) Validate() error {
+	var errs []error
+	if r.Name == "" {
+		errs = append(errs, errors.New("rotation name is required"))
+	}
+	if len(r.Members) == 0 {
+		errs = append(errs, fmt.Errorf("rotation %q has no members", r.Name))
+	}
+	switch r.Order {
+	case "", OrderAlphabetical, OrderGiven, OrderShuffle:
+	default:
+		errs = append(errs, fmt.Errorf("rotation %q has an unknown order %q, must be one of: %s, %s, %s", r.Name, r.Order, OrderAlphabetical, OrderGiven, OrderShuffle))
+	}
+	for i, role := range r.Roles {
+		if role == "" || slices.Contains(r.Roles[:i], role) {
+			errs = append(errs, fmt.Errorf("rotation %q roles must be distinct and not empty, got %q", r.Name, r.Roles))
+			break
+		}
+	}
+	if len(r.Roles) > len(r.Members) {
+		errs = append(errs, fmt.Errorf("rotation %q needs at least %d members for its %d roles", r.Name, len(r.Roles), len(r.Roles)))
+	}
+	if r.StartWith != "" && !slices.Contains(r.Members, r.StartWith) {
+		errs = append(errs, fmt.Errorf("rotation %q has no member %q to start with", r.Name, r.StartWith))
+	}
+	if _, err := time.LoadLocation(r.TimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("rotation %q has an invalid time zone: %w", r.Name, err))
+	}
+	if r.Cadence.Count <= 0 {
+		errs = append(errs, fmt.Errorf("rotation %q slots must last a positive number of days, weeks or months, got %d", r.Name, r.Cadence.Count))
+	}
+	if !r.Until.IsZero() && r.Count > 0 {
+		errs = append(errs, fmt.Errorf("rotation %q can't have both an end date and a number of slots", r.Name))
+	}
+	if !r.Until.IsZero() && r.Until.Before(r.Start) {
+		errs = append(errs, fmt.Errorf("rotation %q ends before it starts", r.Name))
+	}
+	if r.Count < 0 {
+		errs = append(errs, fmt.Errorf("rotation %q number of slots must be positive, got %d", r.Name, r.Count))
+	}
+	// RRULEs skip the months without the day, rather than moving the slot.
+	if r.Cadence.Unit == Monthly && r.Start.Day() > 28 {
+		errs = append(errs, fmt.Errorf("rotation %q is monthly so it must start on one of the first 28 days of the month", r.Name))
+	}
+	// A recurring event has a single RRULE, which can't skip the weekends in
+	// the middle of a slot.
+	if r.WeekdaysOnly && r.Cadence != Weeks(1) {
+		errs = append(errs, fmt.Errorf("rotation %q is on weekdays only so its slots must last one week, got %s", r.Name, r.Cadence))
+	}
+	if r.HandoffDay != "" {
+		if _, err := ParseWeekday(r.HandoffDay); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q has an invalid handoff day: %w", r.Name, err))
+		}
+		if r.Cadence.Unit != Weekly {
+			errs = append(errs, fmt.Errorf("rotation %q has a handoff day so its slots must last whole weeks, got %s", r.Name, r.Cadence))
+		}
+		if r.WeekdaysOnly {
+			errs = append(errs, fmt.Errorf("rotation %q is on weekdays only so it always hands off on Mondays", r.Name))
+		}
+	}
+	if r.HandoffTime != "" {
+		if _, err := parseClock(r.HandoffTime); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q has an invalid handoff time: %w", r.Name, err))
+		}
+		if r.WeekdaysOnly {
+			errs = append(errs, fmt.Errorf("rotation %q is on weekdays only so its slots last whole days", r.Name))
+		}
+	}
+	for member, timeZone := range r.TimeZones {
+		if !slices.Contains(r.Members, member) {
+			errs = append(errs, fmt.Errorf("rotation %q has no member %q to set a time zone for", r.Name, member))
+		}
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q time zone of %q: %w", r.Name, member, err))
+		}
+	}
+	switch r.FirstSlot {
+	case "", FirstSlotShorten, FirstSlotExtend:
+	default:
+		errs = append(errs, fmt.Errorf("rotation %q has an unknown first slot layout %q, must be one of: %s, %s", r.Name, r.FirstSlot, FirstSlotShorten, FirstSlotExtend))
+	}
+	for member := range r.Emails {
+		if !slices.Contains(r.Members, member) {
+			errs = append(errs, fmt.Errorf("rotation %q has no member %q to set an email for", r.Name, member))
+		}
+	}
+	if r.InviteTeam && len(r.Emails) == 0 {
+		errs = append(errs, fmt.Errorf("rotation %q needs member emails to invite the team", r.Name))
+	}
+	for member, color := range r.Colors {
+		if !slices.Contains(r.Members, member) {
+			errs = append(errs, fmt.Errorf("rotation %q has no member %q to set a color for", r.Name, member))
+		}
+		if _, err := ColorID(color); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q color of %q: %w", r.Name, member, err))
+		}
+	}
+	switch r.WeightBy {
+	case "", WeightTurns, WeightLength:
+	default:
+		errs = append(errs, fmt.Errorf("rotation %q has an unknown weight layout %q, must be one of: %s, %s", r.Name, r.WeightBy, WeightTurns, WeightLength))
+	}
+	for member, weight := range r.Weights {
+		if !slices.Contains(r.Members, member) {
+			errs = append(errs, fmt.Errorf("rotation %q has no member %q to set a weight for", r.Name, member))
+		}
+		if weight < 1 {
+			errs = append(errs, fmt.Errorf("rotation %q weight of %q must be positive, got %d", r.Name, member, weight))
+		}
+	}
+	// Google Calendar accepts up to 5 reminders per event.
+	if len(r.Reminders) > 5 {
+		errs = append(errs, fmt.Errorf("rotation %q can have up to 5 reminders, got %d", r.Name, len(r.Reminders)))
+	}
+	for _, reminder := range r.Reminders {
+		if err := reminder.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q: %w", r.Name, err))
+		}
+	}
+	switch r.Visibility {
+	case "", VisibilityDefault, VisibilityPublic, VisibilityPrivate:
+	default:
+		errs = append(errs, fmt.Errorf("rotation %q has an unknown visibility %q, must be one of: %s, %s, %s", r.Name, r.Visibility, VisibilityDefault, VisibilityPublic, VisibilityPrivate))
+	}
+	switch r.Transparency {
+	case "", TransparencyBusy, TransparencyFree:
+	default:
+		errs = append(errs, fmt.Errorf("rotation %q has an unknown transparency %q, must be one of: %s, %s", r.Name, r.Transparency, TransparencyBusy, TransparencyFree))
+	}
+	if _, err := parseDescription(r.Description); err != nil {
+		errs = append(errs, fmt.Errorf("rotation %q: %w", r.Name, err))
+	}
+	for _, u := range r.Unavailable {
+		if !slices.Contains(r.Members, u.Member) {
+			errs = append(errs, fmt.Errorf("rotation %q has no member %q to mark as unavailable", r.Name, u.Member))
+		}
+	}
+	if r.Materialize && r.Until.IsZero() && r.Count == 0 && r.Horizon.IsZero() {
+		errs = append(errs, fmt.Errorf("rotation %q never ends, it needs a horizon to be materialized", r.Name))
+	}
+	switch r.Escalation {
+	case "", EscalationNext, EscalationNone:
+	default:
+		if !slices.Contains(r.Members, r.Escalation) {
+			errs = append(errs, fmt.Errorf("rotation %q has no member %q to escalate to, must be a member, %s or %s", r.Name, r.Escalation, EscalationNext, EscalationNone))
+		}
+	}
+	if r.Window != "" {
+		if _, err := parseWindow(r.Window); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q: %w", r.Name, err))
+		}
+		// Recurring events repeat the days of the window every week of a
+		// slot, which needs slots of whole weeks.
+		if r.Cadence.Unit != Weekly {
+			errs = append(errs, fmt.Errorf("rotation %q has a window so its slots must last whole weeks, got %s", r.Name, r.Cadence))
+		}
+		switch {
+		case r.HandoffTime != "":
+			errs = append(errs, fmt.Errorf("rotation %q has a window so its slots start on the first hours of the window, not at a handoff time", r.Name))
+		case r.WeekdaysOnly:
+			errs = append(errs, fmt.Errorf("rotation %q has a window giving the days it applies to, it can't be on weekdays only too", r.Name))
+		case r.Materialize:
+			errs = append(errs, fmt.Errorf("rotation %q has a window so it can't be materialized", r.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Plan computes the events needed for a rotation, one recurring event per
+// member, or per turn of members weighing more than one. Members take turns
+// in the rotation's order, which is deterministic for a given seed.
+//
+// Slots falling on a member's unavailability are swapped with the closest
+// later slot of someone available: the occurrences are excluded from the
+// recurring events and single events are added for the members covering them.
+//
+// Rotations with several roles get the events of each role, the member of a
+// role taking the previous role on the next slot.
+//
+// Materialized rotations get a single event per slot instead, and rotations
+// with a window timed events covering its hours.
+func Plan(r Rotation) ([]Event, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	roles, err := r.roles()
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for i, role := range roles {
+		color := ""
+		if i > 0 {
+			color = backupColor
+		}
+		switch {
+		case r.Materialize:
+			events = append(events, role.materialized(ID(r.Name), color)...)
+			continue
+		case r.Window != "":
+			events = append(events, role.windowed(ID(r.Name), color)...)
+			continue
+		}
+		events = append(events, role.plan(ID(r.Name), color)...)
+	}
+	return events, nil
+}
+
+// plan computes the events of a single role of a rotation, tagged with the
+// rotation's id, and colored by member unless color is set.
+func (r roleSchedule) plan(id, color string) []Event {
+	s, occurrence := r.s, r.occurrence
+	members := r.ordered()
+	turns := s.members
+	timeZone := r.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	overridden := make([]int, 0, len(s.overrides)+1)
+	for slot := range s.overrides {
+		overridden = append(overridden, slot)
+	}
+	// A partial first slot is a single event too, as the occurrences of a
+	// recurring event all last the same.
+	if _, ok := s.overrides[0]; s.partial() && !ok {
+		overridden = append(overridden, 0)
+	}
+	if s.handoff != nil {
+		// Occurrences drifting from their slot are replaced like the ones
+		// covered by someone else. Rotations that never end are only
+		// corrected up to timedHorizon.
+		overridden = append(overridden, s.drifted(s.start.AddDate(timedHorizon, 0, 0))...)
+	} else {
+		// So are the occurrences moved by holidays.
+		overridden = append(overridden, s.moved()...)
+	}
+	sort.Ints(overridden)
+	// Slots only covering holidays are excluded with no event replacing
+	// them.
+	excluded := slices.Clone(overridden)
+	for slot := range s.skipped {
+		excluded = append(excluded, slot)
+	}
+	sort.Ints(excluded)
+
+	// Each member's event repeats once everybody else has served, until the
+	// end of the rotation if any.
+	recurrenceRule := r.Cadence.rrule(len(turns))
+	if r.WeekdaysOnly {
+		recurrenceRule += ";BYDAY=MO,TU,WE,TH,FR"
+	}
+	if r.HandoffDay != "" {
+		day, _ := ParseWeekday(r.HandoffDay)
+		recurrenceRule += ";BYDAY=" + rruleDay(day)
+	}
+
+	// zone returns the time zone of a member's events.
+	zone := func(member string) string {
+		if tz, ok := r.TimeZones[member]; ok && s.handoff != nil {
+			return tz
+		}
+		return timeZone
+	}
+
+	// previous returns the member handing a slot off, skipping the slots
+	// nobody serves.
+	previous := func(slot int) string {
+		for p := slot - 1; p >= 0; p-- {
+			if !s.skipped[p] {
+				return s.member(p)
+			}
+		}
+		return ""
+	}
+
+	var events []Event
+	colors := r.memberColors(members, color)
+	for i, member := range turns {
+		// Finite rotations may end before everybody has served.
+		if s.slots > 0 && i >= s.slots {
+			break
+		}
+		start, end := occurrence(i)
+		escalation := r.escalation(member, s.regular(i+1))
+		description := r.description(member, previous(i), escalation, start, end, s.handoff != nil)
+		switch {
+		case s.handoff != nil:
+			start, end = s.recurring(i)
+		case i == 0 && s.partial():
+			start, end = s.series(0), s.series(1)
+		}
+		rule := recurrenceRule
+		switch {
+		case r.WeekdaysOnly && s.slots > 0:
+			// Occurrences are days rather than slots, so the series ends
+			// on the Friday of the member's last slot.
+			last := s.series(i + len(turns)*((s.slots-1-i)/len(turns)))
+			rule += ";UNTIL=" + last.AddDate(0, 0, 4).Format("20060102")
+		case !r.Until.IsZero() && s.handoff != nil:
+			// UNTIL is a UTC time for events that aren't all-day.
+			rule += ";UNTIL=" + s.at(InLocation(r.Until, s.start.Location()), member).UTC().Format("20060102T150405Z")
+		case !r.Until.IsZero():
+			rule += ";UNTIL=" + r.Until.Format("20060102")
+		case s.slots > 0:
+			rule += fmt.Sprintf(";COUNT=%d", (s.slots-i+len(turns)-1)/len(turns))
+		}
+		recurrence := []string{rule}
+		var exdates []string
+		for _, slot := range excluded {
+			// Only the series of the turn the slot belongs to skips it.
+			if slot%len(turns) != i {
+				continue
+			}
+			if s.handoff != nil {
+				excluded, _ := s.recurring(slot)
+				exdates = append(exdates, excluded.Format("20060102T150405"))
+				continue
+			}
+			if !r.WeekdaysOnly {
+				exdates = append(exdates, s.series(slot).Format("20060102"))
+				continue
+			}
+			// Every day of the occurrence is excluded, even when holidays
+			// moved the bounds of the slot.
+			first := s.series(slot)
+			if first.Before(s.begin) {
+				first = s.begin
+			}
+			for d := first; d.Before(s.series(slot).AddDate(0, 0, 5)); d = d.AddDate(0, 0, 1) {
+				exdates = append(exdates, d.Format("20060102"))
+			}
+		}
+		switch {
+		case len(exdates) > 0 && s.handoff != nil:
+			recurrence = append(recurrence, "EXDATE;TZID="+zone(member)+":"+strings.Join(exdates, ","))
+		case len(exdates) > 0:
+			recurrence = append(recurrence, "EXDATE;VALUE=DATE:"+strings.Join(exdates, ","))
+		}
+		if r.WeekdaysOnly {
+			end = start.AddDate(0, 0, 1)
+		}
+		events = append(events, Event{
+			RotationID:      id,
+			Rotation:        r.Name,
+			Member:          member,
+			Slot:            i,
+			Summary:         Summary(r.Name, member),
+			Start:           start,
+			End:             end,
+			Timed:           s.handoff != nil,
+			Recurrence:      recurrence,
+			ColorID:         colors[member],
+			TimeZone:        zone(member),
+			Attendees:       r.attendees(member, escalation, members),
+			Reminders:       r.Reminders,
+			Description:     description,
+			Visibility:      r.Visibility,
+			GuestsCanModify: r.GuestsCanModify,
+			Transparency:    r.Transparency,
+		})
+	}
+
+	for _, slot := range overridden {
+		member := s.member(slot)
+		escalation := r.escalation(member, s.next(slot))
+		start, end := occurrence(slot)
+		events = append(events, Event{
+			RotationID:      id,
+			Rotation:        r.Name,
+			Member:          member,
+			Slot:            slot,
+			Summary:         Summary(r.Name, member),
+			Start:           start,
+			End:             end,
+			Timed:           s.handoff != nil,
+			ColorID:         colors[member],
+			TimeZone:        zone(member),
+			Attendees:       r.attendees(member, escalation, members),
+			Reminders:       r.Reminders,
+			Description:     r.description(member, previous(slot), escalation, start, end, s.handoff != nil),
+			Visibility:      r.Visibility,
+			GuestsCanModify: r.GuestsCanModify,
+			Transparency:    r.Transparency,
+		})
+	}
+	return events
+}
+
+// materialized computes a single event per slot of a role of a rotation, up
+// to its end or its horizon, tagged with the rotation's id, and colored by
+// member unless color is set.
+func (r roleSchedule) materialized(id, color string) []Event {
+	s := r.s
+	members := r.ordered()
+	timeZone := r.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+
+	colors := r.memberColors(members, color)
+
+	var events []Event
+	previous := ""
+	for slot := 0; s.slots == 0 || slot < s.slots; slot++ {
+		if s.skipped[slot] {
+			continue
+		}
+		start, end := r.occurrence(slot)
+		if s.slots == 0 && civil(start).After(civil(r.Horizon)) {
+			break
+		}
+		member := s.member(slot)
+		escalation := r.escalation(member, s.next(slot))
+		zone := timeZone
+		if tz, ok := r.TimeZones[member]; ok && s.handoff != nil {
+			zone = tz
+		}
+		events = append(events, Event{
+			RotationID:      id,
+			Rotation:        r.Name,
+			Member:          member,
+			Slot:            slot,
+			Summary:         Summary(r.Name, member),
+			Start:           start,
+			End:             end,
+			Timed:           s.handoff != nil,
+			ColorID:         colors[member],
+			TimeZone:        zone,
+			Attendees:       r.attendees(member, escalation, members),
+			Reminders:       r.Reminders,
+			Description:     r.description(member, previous, escalation, start, end, s.handoff != nil),
+			Visibility:      r.Visibility,
+			GuestsCanModify: r.GuestsCanModify,
+			Transparency:    r.Transparency,
+		})
+		previous = member
+	}
+	return events
+}
+
+// memberColors returns the color IDs of the events of members, color for
+// all of them when set, or by their position unless given in Colors.
+func (r Rotation) memberColors(members []string, color string) map[string]string {
+	colors := make(map[string]string)
+	for i, member := range members {
+		colors[member] = DefaultColor(i)
+		if c, ok := r.Colors[member]; ok {
+			// Colors were validated already.
+			colors[member], _ = ColorID(c)
+		}
+		if color != "" {
+			colors[member] = color
+		}
+	}
+	return colors
+}
+
+// timedHorizon is how many years the occurrences of rotations handing off at
+// a time of day are checked for drift when they never end.
+const timedHorizon = 2
+
+// schedule assigns the members to the slots of the rotation, along with a
+// function returning the first day and the end of the events of a slot.
+// Members are on duty for span slots, one per role of the rotation.
+func (r Rotation) schedule(span int) (*schedule, func(slot int) (time.Time, time.Time), error) {
+	timeZone := r.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	// Slot boundaries are computed on calendar days of the rotation's time
+	// zone, so they stay at midnight across DST changes.
+	loc, _ := time.LoadLocation(timeZone)
+	unavailable := make([]Unavailability, 0, len(r.Unavailable))
+	for _, u := range r.Unavailable {
+		unavailable = append(unavailable, Unavailability{Member: u.Member, From: InLocation(u.From, loc), To: InLocation(u.To, loc)})
+	}
+
+	start := InLocation(r.Start, loc)
+	if r.WeekdaysOnly {
+		start = nextWeekday(start)
+	}
+	s := &schedule{
+		start:       start,
+		cadence:     r.Cadence,
+		members:     r.Cycle(),
+		unavailable: unavailable,
+		overrides:   make(map[int]string),
+		slots:       r.Count,
+		weekdays:    r.WeekdaysOnly,
+		begin:       start,
+		span:        span,
+	}
+	if r.WeekdaysOnly {
+		// Slots run from Monday to Friday, the first one being shorter when
+		// the rotation starts midweek.
+		s.start = start.AddDate(0, 0, -int(start.Weekday()-time.Monday))
+	}
+	if r.HandoffDay != "" {
+		day, _ := ParseWeekday(r.HandoffDay)
+		if s.start = anchor(start, day, r.FirstSlot); !s.start.Equal(start) {
+			s.first = start
+		}
+	}
+	if r.HandoffTime != "" {
+		// Time zones were validated already.
+		handoff, _ := parseClock(r.HandoffTime)
+		s.handoff = &handoff
+		s.zones = make(map[string]*time.Location)
+		for member, timeZone := range r.TimeZones {
+			s.zones[member], _ = time.LoadLocation(timeZone)
+		}
+	}
+	if !r.Until.IsZero() {
+		until := InLocation(r.Until, loc)
+		for start, _ := s.bounds(s.slots); !start.After(until); start, _ = s.bounds(s.slots) {
+			s.slots++
+		}
+	}
+	// Holidays only move the handoffs, not the number of slots.
+	if len(r.Holidays) > 0 {
+		s.holidays = make(map[time.Time]bool)
+		for _, day := range r.Holidays {
+			s.holidays[civil(day)] = true
+		}
+	}
+	if err := s.rebalance(); err != nil {
+		return nil, nil, fmt.Errorf("unable to schedule rotation %q: %w", r.Name, err)
+	}
+	if r.SkipHolidays {
+		s.skip()
+	}
+	return s, s.occurrence, nil
+}
+
+// Preview returns the first n slots of a rotation, fewer when it ends
+// before, as served once members covering for unavailable ones are
+// accounted for, leaving out the slots skipped for holidays. Rotations with
+// several roles return the slot of every role in turn.
+func Preview(r Rotation, n int) ([]Slot, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	roles, err := r.roles()
+	if err != nil {
+		return nil, err
+	}
+	var slots []Slot
+	s := roles[0].s
+	for i := 0; len(slots) < n*len(roles) && (s.slots == 0 || i < s.slots); i++ {
+		if s.skipped[i] {
+			continue
+		}
+		for _, role := range roles {
+			start, end := role.occurrence(i)
+			slots = append(slots, Slot{Rotation: role.Name, Member: role.s.member(i), Start: start, End: end})
+		}
+	}
+	return slots, nil
+}
+
+// ordered returns the members in the order they take turns.
+func (r Rotation) ordered() []string {
+	members := append([]string(nil), r.Members...)
+	switch r.Order {
+	case OrderGiven:
+	case OrderShuffle:
+		// Shuffling sorted members keeps the order independent of how they
+		// were given.
+		sort.Strings(members)
+		rnd := rand.New(rand.NewSource(r.Seed))
+		rnd.Shuffle(len(members), func(i, j int) {
+			members[i], members[j] = members[j], members[i]
+		})
+	default:
+		sort.Strings(members)
+	}
+	if i := slices.Index(members, r.StartWith); i > 0 {
+		members = append(members[i:], members[:i]...)
+	}
+	return members
+}
+
+// Cycle returns the members serving every slot of a cycle of the rotation,
+// in order, after which the rotation repeats.
+func (r Rotation) Cycle() []string {
+	return r.turns(r.ordered())
+}
+
+// turns returns the order members serve in every cycle of the rotation.
+// Members weighing more than one come back once per weight, spread over the
+// cycle, e.g. A, B, A, C when A weighs 2, or back to back when weighing by
+// length, e.g. A, A, B, C.
+func (r Rotation) turns(members []string) []string {
+	if r.WeightBy == WeightLength {
+		var turns []string
+		for _, member := range members {
+			weight, ok := r.Weights[member]
+			if !ok {
+				weight = 1
+			}
+			for range weight {
+				turns = append(turns, member)
+			}
+		}
+		return turns
+	}
+
+	weights := make(map[string]int, len(members))
+	n := 0
+	for _, member := range members {
+		weight, ok := r.Weights[member]
+		if !ok {
+			weight = 1
+		}
+		weights[member] = weight
+		n += weight
+	}
+	// Place the heaviest members first, every n/weight slots from the first
+	// free one, so their turns are evenly spaced around the cycle and the
+	// cycle never ends with the member it starts with when avoidable.
+	heaviest := slices.Clone(members)
+	slices.SortStableFunc(heaviest, func(a, b string) int { return weights[b] - weights[a] })
+	turns := make([]string, n)
+	free := func(i int) int {
+		for turns[i%n] != "" {
+			i++
+		}
+		return i % n
+	}
+	for _, member := range heaviest {
+		first := free(0)
+		for i := range weights[member] {
+			turns[free(first+i*n/weights[member])] = member
+		}
+	}
+	return turns
+}
+
+// attendees returns the guests of a member's event: the member and, when
+// inviting the team, everybody else as optional, or else the escalation
+// contact only.
+func (r Rotation) attendees(member, escalation string, members []string) []Attendee {
+	var attendees []Attendee
+	if email, ok := r.Emails[member]; ok {
+		attendees = append(attendees, Attendee{Email: email})
+	}
+	if r.InviteTeam {
+		for _, m := range members {
+			if email, ok := r.Emails[m]; ok && m != member {
+				attendees = append(attendees, Attendee{Email: email, Optional: true})
+			}
+		}
+	} else if email, ok := r.Emails[escalation]; ok && escalation != "" {
+		attendees = append(attendees, Attendee{Email: email, Optional: true})
+	}
+	return attendees
+}
+
+// nextWeekday returns t, or the following Monday if t falls on a weekend.
+func nextWeekday(t time.Time) time.Time {
+	switch t.Weekday() {
+	case time.Saturday:
+		return t.AddDate(0, 0, 2)
+	case time.Sunday:
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// InLocation returns midnight in loc of the same calendar day as t.
+func InLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// ID returns the stable identifier of a rotation, derived from its name,
+// e.g. sre-role for "SRE Role".
+func ID(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Summary returns the summary used for a member's event in a rotation.
+func Summary(name, member string) string {
+	return fmt.Sprintf("%s: %s", name, member)
+}
+
+// ParseSummary splits an event summary into the rotation name and member.
+func ParseSummary(summary string) (name, member string, ok bool) {
+	return strings.Cut(summary, ": ")
+}
@@ -0,0 +1,586 @@
+package rotation_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+var start = time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC)
+
+func TestPlan(t *testing.T) {
+	type planned struct {
+		summary    string
+		slot       int
+		start, end string
+		recurrence string
+	}
+	tests := []struct {
+		name string
+		r    rotation.Rotation
+		want []planned
+	}{
+		{
+			name: "weekly forever in alphabetical order",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Cesar", "Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1)},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07", "2030-01-14", "RRULE:FREQ=WEEKLY;INTERVAL=3"},
+				{"SRE Role: Bob", 1, "2030-01-14", "2030-01-21", "RRULE:FREQ=WEEKLY;INTERVAL=3"},
+				{"SRE Role: Cesar", 2, "2030-01-21", "2030-01-28", "RRULE:FREQ=WEEKLY;INTERVAL=3"},
+			},
+		},
+		{
+			name: "given order until a date",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Cesar", "Alice", "Bob"}, Order: rotation.OrderGiven, Start: start, Cadence: rotation.Weeks(1), Until: start.AddDate(0, 0, 20)},
+			want: []planned{
+				{"SRE Role: Cesar", 0, "2030-01-07", "2030-01-14", "RRULE:FREQ=WEEKLY;INTERVAL=3;UNTIL=20300127"},
+				{"SRE Role: Alice", 1, "2030-01-14", "2030-01-21", "RRULE:FREQ=WEEKLY;INTERVAL=3;UNTIL=20300127"},
+				{"SRE Role: Bob", 2, "2030-01-21", "2030-01-28", "RRULE:FREQ=WEEKLY;INTERVAL=3;UNTIL=20300127"},
+			},
+		},
+		{
+			name: "materialized slots",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Start: start, Cadence: rotation.Weeks(2), Count: 4, Materialize: true},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07", "2030-01-21", ""},
+				{"SRE Role: Bob", 1, "2030-01-21", "2030-02-04", ""},
+				{"SRE Role: Cesar", 2, "2030-02-04", "2030-02-18", ""},
+				{"SRE Role: Alice", 3, "2030-02-18", "2030-03-04", ""},
+			},
+		},
+		{
+			name: "primary and secondary roles",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Roles: []string{"primary", "secondary"}, Start: start, Cadence: rotation.Weeks(1), Count: 3},
+			want: []planned{
+				{"SRE Role (primary): Alice", 0, "2030-01-07", "2030-01-14", "RRULE:FREQ=WEEKLY;INTERVAL=3;COUNT=1"},
+				{"SRE Role (primary): Bob", 1, "2030-01-14", "2030-01-21", "RRULE:FREQ=WEEKLY;INTERVAL=3;COUNT=1"},
+				{"SRE Role (primary): Cesar", 2, "2030-01-21", "2030-01-28", "RRULE:FREQ=WEEKLY;INTERVAL=3;COUNT=1"},
+				{"SRE Role (secondary): Bob", 0, "2030-01-07", "2030-01-14", "RRULE:FREQ=WEEKLY;INTERVAL=3;COUNT=1"},
+				{"SRE Role (secondary): Cesar", 1, "2030-01-14", "2030-01-21", "RRULE:FREQ=WEEKLY;INTERVAL=3;COUNT=1"},
+				{"SRE Role (secondary): Alice", 2, "2030-01-21", "2030-01-28", "RRULE:FREQ=WEEKLY;INTERVAL=3;COUNT=1"},
+			},
+		},
+		{
+			name: "weighted turns over two cycles",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Weights: map[string]int{"Alice": 2}, Start: start, Cadence: rotation.Weeks(1), Count: 8, Materialize: true},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07", "2030-01-14", ""},
+				{"SRE Role: Bob", 1, "2030-01-14", "2030-01-21", ""},
+				{"SRE Role: Alice", 2, "2030-01-21", "2030-01-28", ""},
+				{"SRE Role: Cesar", 3, "2030-01-28", "2030-02-04", ""},
+				{"SRE Role: Alice", 4, "2030-02-04", "2030-02-11", ""},
+				{"SRE Role: Bob", 5, "2030-02-11", "2030-02-18", ""},
+				{"SRE Role: Alice", 6, "2030-02-18", "2030-02-25", ""},
+				{"SRE Role: Cesar", 7, "2030-02-25", "2030-03-04", ""},
+			},
+		},
+		{
+			name: "business hours window",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1), Count: 2, Window: "Mon-Fri 09:00-17:00", TimeZone: "Europe/Madrid"},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07T09:00:00+01:00", "2030-01-07T17:00:00+01:00", "RRULE:FREQ=WEEKLY;INTERVAL=2;WKST=MO;BYDAY=MO,TU,WE,TH,FR;UNTIL=20300113T230000Z"},
+				{"SRE Role: Bob", 1, "2030-01-14T09:00:00+01:00", "2030-01-14T17:00:00+01:00", "RRULE:FREQ=WEEKLY;INTERVAL=2;WKST=MO;BYDAY=MO,TU,WE,TH,FR;UNTIL=20300120T230000Z"},
+			},
+		},
+		{
+			name: "weekend window",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1), Count: 2, Window: "Sat-Sun 10:00-12:00"},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-12T10:00:00Z", "2030-01-12T12:00:00Z", "RRULE:FREQ=WEEKLY;INTERVAL=2;WKST=MO;BYDAY=SU,SA;UNTIL=20300114T000000Z"},
+				{"SRE Role: Bob", 1, "2030-01-19T10:00:00Z", "2030-01-19T12:00:00Z", "RRULE:FREQ=WEEKLY;INTERVAL=2;WKST=MO;BYDAY=SU,SA;UNTIL=20300121T000000Z"},
+			},
+		},
+		{
+			name: "handoff on a holiday shifted to the next business day",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Start: start, Cadence: rotation.Weeks(1), Count: 3, Materialize: true, Holidays: []time.Time{start.AddDate(0, 0, 7)}},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07", "2030-01-15", ""},
+				{"SRE Role: Bob", 1, "2030-01-15", "2030-01-21", ""},
+				{"SRE Role: Cesar", 2, "2030-01-21", "2030-01-28", ""},
+			},
+		},
+		{
+			name: "slot of holidays skipped",
+			r: rotation.Rotation{
+				Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar"}, Start: start, Cadence: rotation.Weeks(1), Count: 3, Materialize: true,
+				Holidays:     []time.Time{start.AddDate(0, 0, 7), start.AddDate(0, 0, 8), start.AddDate(0, 0, 9), start.AddDate(0, 0, 10), start.AddDate(0, 0, 11)},
+				SkipHolidays: true,
+			},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07", "2030-01-14", ""},
+				{"SRE Role: Cesar", 2, "2030-01-21", "2030-01-28", ""},
+				{"SRE Role: Bob", 3, "2030-01-28", "2030-02-04", ""},
+			},
+		},
+		{
+			name: "weekdays only from a Wednesday",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start.AddDate(0, 0, 2), Cadence: rotation.Weeks(1), Count: 3, WeekdaysOnly: true},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-09", "2030-01-10", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU,WE,TH,FR;UNTIL=20300125"},
+				{"SRE Role: Bob", 1, "2030-01-14", "2030-01-15", "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU,WE,TH,FR;UNTIL=20300118"},
+			},
+		},
+		{
+			name: "short first slot until the handoff day",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start.AddDate(0, 0, 2), Cadence: rotation.Weeks(1), Count: 3, HandoffDay: "monday", Materialize: true},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-09", "2030-01-14", ""},
+				{"SRE Role: Bob", 1, "2030-01-14", "2030-01-21", ""},
+				{"SRE Role: Alice", 2, "2030-01-21", "2030-01-28", ""},
+			},
+		},
+		{
+			name: "first slot extended past the handoff day",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start.AddDate(0, 0, 2), Cadence: rotation.Weeks(1), Count: 3, HandoffDay: "monday", FirstSlot: rotation.FirstSlotExtend, Materialize: true},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-09", "2030-01-21", ""},
+				{"SRE Role: Bob", 1, "2030-01-21", "2030-01-28", ""},
+				{"SRE Role: Alice", 2, "2030-01-28", "2030-02-04", ""},
+			},
+		},
+		{
+			name: "handoff time",
+			r:    rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1), Count: 2, HandoffTime: "09:00", TimeZone: "Europe/Madrid"},
+			want: []planned{
+				{"SRE Role: Alice", 0, "2030-01-07T09:00:00+01:00", "2030-01-14T09:00:00+01:00", "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=1"},
+				{"SRE Role: Bob", 1, "2030-01-14T09:00:00+01:00", "2030-01-21T09:00:00+01:00", "RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := rotation.Plan(tt.r)
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			var got []planned
+			for _, e := range events {
+				layout := time.DateOnly
+				if e.Timed {
+					layout = time.RFC3339
+				}
+				got = append(got, planned{e.Summary, e.Slot, e.Start.Format(layout), e.End.Format(layout), strings.Join(e.Recurrence, "\n")})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Plan() got %d events %v, want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Plan() event %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+				if i > 0 && got[i].slot > 0 && got[i].summary == got[i-1].summary {
+					t.Errorf("Plan() event %d gives %q consecutive slots", i, got[i].summary)
+				}
+			}
+		})
+	}
+}
+
+func TestCycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+	}{
+		{name: "one heavy member", weights: map[string]int{"Alice": 2}},
+		{name: "two heavy members", weights: map[string]int{"Alice": 2, "Bob": 2}},
+		{name: "uneven weights", weights: map[string]int{"Alice": 3, "Bob": 2}},
+		{name: "heavy member last", weights: map[string]int{"Dana": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob", "Cesar", "Dana"}, Weights: tt.weights}
+			cycle := r.Cycle()
+			// Two cycles in a row, so the handoff between them is checked too.
+			turns := append(slices.Clone(cycle), cycle...)
+			for i := 1; i < len(turns); i++ {
+				if turns[i] == turns[i-1] {
+					t.Errorf("Cycle() = %v gives %s consecutive slots", cycle, turns[i])
+				}
+			}
+			for member, weight := range tt.weights {
+				if got := strings.Count(strings.Join(cycle, " ")+" ", member+" "); got != weight {
+					t.Errorf("Cycle() = %v gives %s %d turns, want %d", cycle, member, got, weight)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := rotation.Rotation{Name: "SRE Role", Members: []string{"Alice", "Bob"}, Start: start, Cadence: rotation.Weeks(1)}
+	tests := []struct {
+		name    string
+		change  func(r *rotation.Rotation)
+		wantErr string
+	}{
+		{name: "valid", change: func(r *rotation.Rotation) {}},
+		{name: "no name", change: func(r *rotation.Rotation) { r.Name = "" }, wantErr: "rotation name is required"},
+		{name: "no members", change: func(r *rotation.Rotation) { r.Members = nil }, wantErr: "has no members"},
+		{name: "unknown order", change: func(r *rotation.Rotation) { r.Order = "random" }, wantErr: "unknown order"},
+		{name: "too few members for roles", change: func(r *rotation.Rotation) { r.Roles = []string{"primary", "secondary", "tertiary"} }, wantErr: "needs at least 3 members"},
+		{name: "unknown first member", change: func(r *rotation.Rotation) { r.StartWith = "Dana" }, wantErr: `no member "Dana" to start with`},
+		{name: "invalid time zone", change: func(r *rotation.Rotation) { r.TimeZone = "Mars/Olympus" }, wantErr: "invalid time zone"},
+		{name: "empty cadence", change: func(r *rotation.Rotation) { r.Cadence = rotation.Weeks(0) }, wantErr: "positive number of days"},
+		{name: "until and count", change: func(r *rotation.Rotation) { r.Until, r.Count = start.AddDate(0, 1, 0), 4 }, wantErr: "both an end date and a number of slots"},
+		{name: "ends before it starts", change: func(r *rotation.Rotation) { r.Until = start.AddDate(0, 0, -1) }, wantErr: "ends before it starts"},
+		{name: "negative count", change: func(r *rotation.Rotation) { r.Count = -1 }, wantErr: "must be positive"},
+		{name: "invalid handoff time", change: func(r *rotation.Rotation) { r.HandoffTime = "25:00" }, wantErr: "invalid handoff time"},
+		{name: "email of a stranger", change: func(r *rotation.Rotation) { r.Emails = map[string]string{"Dana": "dana@example.com"} }, wantErr: `no member "Dana" to set an email for`},
+		{name: "invite team without emails", change: func(r *rotation.Rotation) { r.InviteTeam = true }, wantErr: "needs member emails"},
+		{name: "unknown color", change: func(r *rotation.Rotation) { r.Colors = map[string]string{"Alice": "chartreuse"} }, wantErr: `color of "Alice"`},
+		{name: "zero weight", change: func(r *rotation.Rotation) { r.Weights = map[string]int{"Alice": 0} }, wantErr: `weight of "Alice" must be positive`},
+		{name: "window without hours", change: func(r *rotation.Rotation) { r.Window = "Mon-Fri" }, wantErr: `invalid window "Mon-Fri"`},
+		{name: "window of unknown days", change: func(r *rotation.Rotation) { r.Window = "Foo 09:00-17:00" }, wantErr: `invalid window "Foo 09:00-17:00"`},
+		{name: "window ending before it starts", change: func(r *rotation.Rotation) { r.Window = "Mon-Fri 17:00-09:00" }, wantErr: "must end after they start"},
+		{name: "window of invalid hours", change: func(r *rotation.Rotation) { r.Window = "Mon,Wed 09:00-25:00" }, wantErr: `invalid time of day "25:00"`},
+		{name: "window wrapping around the week", change: func(r *rotation.Rotation) { r.Window = "Fri-Mon 10:00-12:00" }},
+		{
+			name:    "every error at once",
+			change:  func(r *rotation.Rotation) { r.Name, r.Members = "", nil },
+			wantErr: "rotation name is required\nrotation \"\" has no members",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.change(&r)
+			err := r.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	// A Thursday.
+	now := time.Date(2030, time.January, 10, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		now     time.Time
+		want    string
+		wantErr string
+	}{
+		{in: "2030-03-04", want: "2030-03-04"},
+		{in: "today", want: "2030-01-10"},
+		{in: "Tomorrow", want: "2030-01-11"},
+		{in: "yesterday", want: "2030-01-09"},
+		{in: "next week", want: "2030-01-14"},
+		{in: "next month", want: "2030-02-01"},
+		{in: "thursday", want: "2030-01-10"},
+		{in: "this monday", want: "2030-01-14"},
+		{in: "next thursday", want: "2030-01-17"},
+		{in: "last thursday", want: "2030-01-03"},
+		{in: "last friday", want: "2030-01-04"},
+		{in: "in 3 days", want: "2030-01-13"},
+		{in: "in 2 weeks", want: "2030-01-24"},
+		{in: "in 1 month", want: "2030-02-10"},
+		{in: "in 1 year", want: "2031-01-10"},
+		{in: "july 1", want: "2030-07-01"},
+		{in: "1 July", want: "2030-07-01"},
+		{in: "jul 1st", want: "2030-07-01"},
+		{in: "first of july", want: "2030-07-01"},
+		{in: "1 july 2031", want: "2031-07-01"},
+		{in: "january 5", want: "2031-01-05"},
+		{in: "feb 29", now: time.Date(2031, time.March, 10, 0, 0, 0, 0, time.UTC), want: "2032-02-29"},
+		{in: "feb 29", now: time.Date(2028, time.January, 10, 0, 0, 0, 0, time.UTC), want: "2028-02-29"},
+		{in: "feb 29", wantErr: "February has no day 29 in 2030"},
+		{in: "feb 29", now: time.Date(2028, time.March, 10, 0, 0, 0, 0, time.UTC), wantErr: "February has no day 29 in 2029"},
+		{in: "february 30 2032", wantErr: "February has no day 30 in 2032"},
+		{in: "", wantErr: "unable to parse date"},
+		{in: "someday", wantErr: "unable to parse date"},
+		{in: "in -1 days", wantErr: "unable to parse date"},
+		{in: "in 2 fortnights", wantErr: "unable to parse date"},
+		{in: "every monday", wantErr: "unable to parse date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			now := now
+			if !tt.now.IsZero() {
+				now = tt.now
+			}
+			got, err := rotation.ParseDate(tt.in, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseDate(%q) = %v, %v, want an error containing %q", tt.in, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q) error = %v", tt.in, err)
+			}
+			if got.Format(time.DateOnly) != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+				t.Errorf("ParseDate(%q) = %v, want %s at midnight UTC", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCadence(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    rotation.Cadence
+		wantErr string
+	}{
+		{in: "daily", want: rotation.Cadence{Unit: rotation.Daily, Count: 1}},
+		{in: "Weekly", want: rotation.Weeks(1)},
+		{in: "biweekly", want: rotation.Weeks(2)},
+		{in: "monthly", want: rotation.Cadence{Unit: rotation.Monthly, Count: 1}},
+		{in: "P3D", want: rotation.Cadence{Unit: rotation.Daily, Count: 3}},
+		{in: "p2w", want: rotation.Weeks(2)},
+		{in: "P1M", want: rotation.Cadence{Unit: rotation.Monthly, Count: 1}},
+		{in: "P1Y", want: rotation.Cadence{Unit: rotation.Monthly, Count: 12}},
+		{in: "72h", want: rotation.Cadence{Unit: rotation.Daily, Count: 3}},
+		{in: "336h", want: rotation.Weeks(2)},
+		{in: "P0W", wantErr: "period must be positive"},
+		{in: "36h", wantErr: "whole days"},
+		{in: "-24h", wantErr: "whole days"},
+		{in: "P1W2D", wantErr: "must be daily, weekly, biweekly, monthly"},
+		{in: "fortnightly", wantErr: "must be daily, weekly, biweekly, monthly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := rotation.ParseCadence(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseCadence(%q) = %v, %v, want an error containing %q", tt.in, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseCadence(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHorizon(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    rotation.Cadence
+		wantErr bool
+	}{
+		{in: "90d", want: rotation.Cadence{Unit: rotation.Daily, Count: 90}},
+		{in: "26W", want: rotation.Weeks(26)},
+		{in: "6m", want: rotation.Cadence{Unit: rotation.Monthly, Count: 6}},
+		{in: "1y", want: rotation.Cadence{Unit: rotation.Monthly, Count: 12}},
+		{in: "P2W", want: rotation.Weeks(2)},
+		{in: "monthly", want: rotation.Cadence{Unit: rotation.Monthly, Count: 1}},
+		{in: "0d", wantErr: true},
+		{in: "6 months", wantErr: true},
+		{in: "1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := rotation.ParseHorizon(tt.in)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "invalid horizon") {
+					t.Errorf("ParseHorizon(%q) = %v, %v, want an invalid horizon error", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseHorizon(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSV(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rotation.Rotation{Name: "SRE Role", Start: start, Emails: map[string]string{"Cesar": "cesar@example.com"}, TimeZone: "Europe/Madrid"}
+	type planned struct{ summary, start, end string }
+	tests := []struct {
+		name    string
+		csv     string
+		want    []planned
+		wantErr string
+	}{
+		{
+			name: "days with a header",
+			csv:  "member,start,end\nCesar,2030-01-07,2030-01-13\nSeth,2030-01-14,2030-01-20\n",
+			want: []planned{
+				{"SRE Role: Cesar", "2030-01-07", "2030-01-14"},
+				{"SRE Role: Seth", "2030-01-14", "2030-01-21"},
+			},
+		},
+		{
+			name: "roles without a header",
+			csv:  "Cesar,2030-01-07,2030-01-13,primary\nSeth,2030-01-07,2030-01-13,secondary\n",
+			want: []planned{
+				{"SRE Role (primary): Cesar", "2030-01-07", "2030-01-14"},
+				{"SRE Role (secondary): Seth", "2030-01-07", "2030-01-14"},
+			},
+		},
+		{
+			name: "times of day",
+			csv:  "Cesar, 2030-01-07T09:00:00+01:00, 2030-01-14T09:00:00+01:00\n",
+			want: []planned{
+				{"SRE Role: Cesar", "2030-01-07T09:00:00+01:00", "2030-01-14T09:00:00+01:00"},
+			},
+		},
+		{
+			name:    "invalid rows",
+			csv:     "member,start,end\nCesar,2030-01-07\n,2030-01-07,2030-01-13\nSeth,2030-01-13,2030-01-07\nDana,2030-13-01,2030-13-07\n",
+			wantErr: "schedule.csv:4: end is before start",
+		},
+		{
+			name:    "overlapping slots",
+			csv:     "Cesar,2030-01-07,2030-01-13\nSeth,2030-01-10,2030-01-20\n",
+			wantErr: `rotation "SRE Role"`,
+		},
+		{
+			name:    "slots with and without a role",
+			csv:     "Cesar,2030-01-07,2030-01-13,primary\nSeth,2030-01-14,2030-01-20\n",
+			wantErr: "every slot needs one",
+		},
+		{
+			name:    "no slots",
+			csv:     "member,start,end\n",
+			wantErr: "has no slots",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schedule.csv")
+			if err := os.WriteFile(path, []byte(tt.csv), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			slots, err := rotation.LoadCSV(path, madrid)
+			var events []rotation.Event
+			if err == nil {
+				events, err = rotation.PlanCSV(r, slots)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadCSV() and PlanCSV() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCSV() and PlanCSV() error = %v", err)
+			}
+			var got []planned
+			for _, e := range events {
+				layout := time.DateOnly
+				if e.Timed {
+					layout = time.RFC3339
+				}
+				got = append(got, planned{e.Summary, e.Start.Format(layout), e.End.Format(layout)})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("PlanCSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoster(t *testing.T) {
+	tests := []struct {
+		name    string
+		roster  string
+		resolve map[string]string
+		wantErr string
+	}{
+		{
+			name: "names and aliases",
+			roster: `members:
+  - name: Alejandro
+    aliases: [Alex, Ale]
+    email: alejandro@example.com
+    weight: 2
+  - name: Marie
+teams:
+  platform: [alex, Marie]
+`,
+			resolve: map[string]string{"Alejandro": "Alejandro", "alex": "Alejandro", "ALE": "Alejandro", "marie": "Marie", "Alexandra": ""},
+		},
+		{
+			name: "alias of another member",
+			roster: `members:
+  - name: Alejandro
+    aliases: [Marie]
+  - name: Marie
+`,
+			wantErr: `member "Alejandro" has alias "Marie", already the name or an alias of "Marie"`,
+		},
+		{
+			name: "alias shared by two members",
+			roster: `members:
+  - name: Alejandro
+    aliases: [Al]
+  - name: Alice
+    aliases: [al]
+`,
+			wantErr: `member "Alice" has alias "al", already the name or an alias of "Alejandro"`,
+		},
+		{
+			name: "team of an unknown member",
+			roster: `members:
+  - name: Alejandro
+teams:
+  platform: [Alejandro, Jurgen]
+`,
+			wantErr: `team "platform": unknown member "Jurgen"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "roster.yaml")
+			if err := os.WriteFile(path, []byte(tt.roster), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			roster, err := rotation.LoadRoster(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadRoster() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRoster() error = %v", err)
+			}
+			for name, want := range tt.resolve {
+				got, err := roster.Resolve(name)
+				if want == "" {
+					if err == nil {
+						t.Errorf("Resolve(%q) = %q, want an error", name, got)
+					}
+					continue
+				}
+				if err != nil || got != want {
+					t.Errorf("Resolve(%q) = %q, %v, want %q", name, got, err, want)
+				}
+			}
+			if team, ok := roster.Team("Platform"); ok && !slices.Equal(team, []string{"Alejandro", "Marie"}) {
+				t.Errorf("Team(%q) = %v, want the names the aliases resolve to", "Platform", team)
+			}
+
+			r := rotation.Rotation{Name: "SRE Role", Start: start, Cadence: rotation.Weeks(1)}
+			if err := roster.Apply(&r); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !slices.Equal(r.Members, []string{"Alejandro", "Marie"}) || r.Emails["Alejandro"] != "alejandro@example.com" || r.Weights["Alejandro"] != 2 {
+				t.Errorf("Apply() = members %v, emails %v and weights %v, want the roster's", r.Members, r.Emails, r.Weights)
+			}
+			r.Members = []string{"Alex"}
+			if err := roster.Apply(&r); err == nil {
+				t.Error("Apply() of an alias succeeded, want members resolved first")
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package rotation
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// schedule is the assignment of members to consecutive slots of a rotation.
+type schedule struct {
+	start       time.Time
+	cadence     Cadence
+	members     []string
+	unavailable []Unavailability
+	// overrides maps a slot index to the member covering it when it's not
+	// the one given by the regular rotation order.
+	overrides map[int]string
+	// slots is the total number of slots, zero when the rotation never ends.
+	slots int
+	// first is the start of the first slot when it is partial, because the
+	// rotation doesn't start on its handoff day.
+	first time.Time
+	// handoff is the time of day slots start at, in the zone of the
+	// incoming member, or nil when slots last whole days.
+	handoff *clock
+	// zones maps members to their time zone, for handoffs at a time of day.
+	zones map[string]*time.Location
+	// weekdays limits the events of the slots to Monday to Friday, the
+	// first one starting on begin.
+	weekdays bool
+	begin    time.Time
+	// span is the number of consecutive slots a member is on duty for when
+	// serving a slot, one per role of the rotation.
+	span int
+	// holidays are the days off of the rotation, as civil days. Slots
+	// starting on one start on the next business day instead.
+	holidays map[time.Time]bool
+	// skipped are the slots nobody serves as they only cover holidays.
+	skipped map[int]bool
+}
+
+// occurrence returns the first day and the end of the events of a slot, or
+// their times when handing off at a time of day.
+func (s *schedule) occurrence(slot int) (time.Time, time.Time) {
+	if s.handoff != nil {
+		return s.timed(slot)
+	}
+	start, end := s.bounds(slot)
+	if !s.weekdays {
+		return start, end
+	}
+	// Slots end on Friday, or later when the next one starts after a
+	// holiday.
+	for end.AddDate(0, 0, -1).Weekday() == time.Saturday || end.AddDate(0, 0, -1).Weekday() == time.Sunday {
+		end = end.AddDate(0, 0, -1)
+	}
+	if start.Before(s.begin) {
+		start = s.begin
+	}
+	return start, end
+}
+
+// shifted returns the schedule of the members serving n slots later, e.g.
+// the schedule of a secondary role when n is 1, the members of each slot
+// being the ones serving the primary role on the next.
+func (s *schedule) shifted(n int) *schedule {
+	shifted := *s
+	shifted.members = append(slices.Clone(s.members[n%len(s.members):]), s.members[:n%len(s.members)]...)
+	shifted.overrides = make(map[int]string)
+	for slot, member := range s.overrides {
+		if slot >= n {
+			shifted.overrides[slot-n] = member
+		}
+	}
+	return &shifted
+}
+
+func (s *schedule) bounds(slot int) (time.Time, time.Time) {
+	start, end := s.handoffOn(slot), s.handoffOn(slot+1)
+	if slot == 0 && s.partial() {
+		start = s.first
+	}
+	return start, end
+}
+
+// series returns the start of the occurrence of a slot in its member's
+// recurring event, which differs from the start of a partial first slot.
+func (s *schedule) series(slot int) time.Time {
+	return s.cadence.add(s.start, slot)
+}
+
+// partial reports whether the first slot is shorter or longer than the
+// others, so it can't be an occurrence of a recurring event.
+func (s *schedule) partial() bool {
+	return !s.first.IsZero()
+}
+
+// timed returns the bounds of a slot handing off at a time of day: the
+// slot starts at that time in the zone of its member and ends when the next
+// member takes over, at that time in theirs. The last slot of a rotation ends
+// in the zone of its member.
+func (s *schedule) timed(slot int) (time.Time, time.Time) {
+	start, end := s.bounds(slot)
+	next := s.member(slot)
+	if s.slots == 0 || slot+1 < s.slots {
+		next = s.member(slot + 1)
+	}
+	start = s.at(start, s.member(slot))
+	// Events are written in the zone of their member.
+	return start, s.at(end, next).In(start.Location())
+}
+
+// recurring returns the bounds of the occurrence of a slot in the recurring
+// event of its turn, handing off at a time of day. The event repeats the
+// wall-clock times of the turn's first slot in its member's zone, so it drifts
+// from the slots when members of other zones change to or from daylight
+// saving time on other dates, or when other members cover the next slot.
+func (s *schedule) recurring(slot int) (time.Time, time.Time) {
+	turn := slot % len(s.members)
+	member := s.regular(turn)
+	next := s.regular(turn + 1)
+	if s.slots > 0 && turn+1 >= s.slots {
+		next = member
+	}
+	firstStart := s.at(s.series(turn), member)
+	firstEnd := s.at(s.series(turn+1), next).In(firstStart.Location())
+	// The first slot may end on another day in the member's zone.
+	shift := int(civil(firstEnd).Sub(civil(s.series(turn+1))) / (24 * time.Hour))
+	end := s.series(slot+1).AddDate(0, 0, shift)
+	return s.at(s.series(slot), member), time.Date(end.Year(), end.Month(), end.Day(), firstEnd.Hour(), firstEnd.Minute(), 0, 0, firstStart.Location())
+}
+
+// drifted returns the slots handing off at a time of day whose bounds differ
+// from the occurrence of their recurring event, up to horizon for rotations
+// that never end.
+func (s *schedule) drifted(horizon time.Time) []int {
+	var slots []int
+	for slot := 0; s.slots == 0 || slot < s.slots; slot++ {
+		if s.slots == 0 && s.series(slot).After(horizon) {
+			break
+		}
+		if _, ok := s.overrides[slot]; ok || (slot == 0 && s.partial()) {
+			continue
+		}
+		start, end := s.timed(slot)
+		occurrenceStart, occurrenceEnd := s.recurring(slot)
+		if !start.Equal(occurrenceStart) || !end.Equal(occurrenceEnd) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// at returns when a member takes over on a day.
+func (s *schedule) at(day time.Time, member string) time.Time {
+	loc, ok := s.zones[member]
+	if !ok {
+		loc = day.Location()
+	}
+	return s.handoff.on(day, loc)
+}
+
+func (s *schedule) regular(slot int) string {
+	return s.members[slot%len(s.members)]
+}
+
+func (s *schedule) member(slot int) string {
+	if m, ok := s.overrides[slot]; ok {
+		return m
+	}
+	return s.regular(slot)
+}
+
+// next returns the member serving the slot after the given one, leaving out
+// the slots nobody serves.
+func (s *schedule) next(slot int) string {
+	next := slot + 1
+	for s.skipped[next] {
+		next++
+	}
+	return s.member(next)
+}
+
+// available reports whether a member can serve a slot, and the slots before
+// it they are also on duty for in other roles.
+func (s *schedule) available(member string, slot int) bool {
+	start, _ := s.bounds(max(slot-s.span+1, 0))
+	_, end := s.bounds(slot)
+	for _, u := range s.unavailable {
+		if u.Member == member && u.Overlaps(start, end) {
+			return false
+		}
+	}
+	return true
+}
+
+// rebalance swaps every slot whose member is unavailable with the closest
+// later slot of a member who can cover it, so everybody still serves the
+// same number of slots.
+func (s *schedule) rebalance() error {
+	var last time.Time
+	for _, u := range s.unavailable {
+		if u.To.After(last) {
+			last = u.To
+		}
+	}
+	// Slots after the last unavailability never need to move, but they may
+	// be swapped with earlier ones.
+	slots := 0
+	for start, _ := s.bounds(slots); !start.After(last); start, _ = s.bounds(slots) {
+		slots++
+	}
+	// Members are on duty for the roles of the slots before theirs too.
+	slots += s.span - 1
+	candidates := slots + 2*len(s.members)
+	if s.slots > 0 {
+		slots = min(slots, s.slots)
+		candidates = min(candidates, s.slots)
+	}
+
+	for i := 0; i < slots; i++ {
+		member := s.member(i)
+		if s.available(member, i) {
+			continue
+		}
+		swapped := false
+		for j := i + 1; j < candidates; j++ {
+			other := s.member(j)
+			if other == member || !s.available(other, i) || !s.available(member, j) {
+				continue
+			}
+			s.overrides[i], s.overrides[j] = other, member
+			swapped = true
+			break
+		}
+		if !swapped {
+			start, end := s.bounds(i)
+			return fmt.Errorf("no member can cover %s from %s to %s", member, start.Format(time.DateOnly), end.Format(time.DateOnly))
+		}
+	}
+
+	// Drop swaps that ended up restoring the regular order.
+	for slot, member := range s.overrides {
+		if member == s.regular(slot) {
+			delete(s.overrides, slot)
+		}
+	}
+	return nil
+}
+
+// civil returns the calendar day of t as midnight UTC, to count days
+// between times of different zones.
+func civil(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
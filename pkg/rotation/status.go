@@ -0,0 +1,76 @@
+package rotation
+
+import (
+	"sort"
+	"time"
+)
+
+// Status summarizes who is on a rotation and the upcoming handoffs.
+type Status struct {
+	// ID is the stable identifier of the rotation the events of its slots
+	// are tagged with, or else the ID of its name, see ID.
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Current  *Slot  `json:"current,omitempty"`
+	Upcoming []Slot `json:"upcoming"`
+}
+
+// Covers reports whether t falls within the slot.
+func (s Slot) Covers(t time.Time) bool {
+	return !s.Start.After(t) && s.End.After(t)
+}
+
+// Statuses groups the slots by rotation and returns the status of every
+// rotation at the given time, sorted by rotation name. Consecutive slots of
+// the same member, e.g. the days of a weekday only rotation, are merged as
+// there is no handoff between them.
+func Statuses(slots []Slot, at time.Time) []Status {
+	byName := make(map[string][]Slot)
+	for _, s := range slots {
+		byName[s.Rotation] = append(byName[s.Rotation], s)
+	}
+
+	statuses := make([]Status, 0, len(byName))
+	for name, slots := range byName {
+		status := Status{ID: ID(name), Name: name}
+		for _, s := range merge(slots) {
+			if s.RotationID != "" {
+				status.ID = s.RotationID
+			}
+			switch {
+			case s.Covers(at):
+				current := s
+				status.Current = &current
+			case s.Start.After(at):
+				status.Upcoming = append(status.Upcoming, s)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// merge sorts the slots of a rotation and joins the ones of the same member
+// that follow each other, allowing for a weekend in between.
+func merge(slots []Slot) []Slot {
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+	var merged []Slot
+	for _, s := range slots {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.Member == s.Member && !s.Start.After(last.End.AddDate(0, 0, 2)) {
+				if s.End.After(last.End) {
+					last.End = s.End
+				}
+				continue
+			}
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
@@ -0,0 +1,33 @@
+package rotation_test
+
+import (
+	"testing"
+
+	"calendar/pkg/rotation"
+)
+
+func TestStatuses(t *testing.T) {
+	week := start.AddDate(0, 0, 7)
+	slots := []rotation.Slot{
+		{Rotation: "SRE Role (primary)", RotationID: "sre-role", Member: "Alice", Start: start, End: week},
+		{Rotation: "SRE Role (primary)", RotationID: "sre-role", Member: "Bob", Start: week, End: week.AddDate(0, 0, 7)},
+		{Rotation: "Support", Member: "Cesar", Start: start, End: week},
+	}
+	statuses := rotation.Statuses(slots, start.AddDate(0, 0, 1))
+	want := []struct{ id, name, current string }{
+		{"sre-role", "SRE Role (primary)", "Alice"},
+		{"support", "Support", "Cesar"},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("Statuses() = %+v, want %d rotations", statuses, len(want))
+	}
+	for i, w := range want {
+		s := statuses[i]
+		if s.ID != w.id || s.Name != w.name || s.Current == nil || s.Current.Member != w.current {
+			t.Errorf("Statuses()[%d] = %+v, want ID %q, name %q and %s on call", i, s, w.id, w.name, w.current)
+		}
+	}
+	if len(statuses[0].Upcoming) != 1 || statuses[0].Upcoming[0].Member != "Bob" {
+		t.Errorf("Statuses()[0].Upcoming = %+v, want Bob", statuses[0].Upcoming)
+	}
+}
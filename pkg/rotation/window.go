@@ -0,0 +1,203 @@
+package rotation
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// window is the part of the week a rotation applies to, e.g. business hours.
+type window struct {
+	days       []time.Weekday
+	start, end clock
+}
+
+// parseWindow parses the days and hours of a window written like
+// "Mon-Fri 09:00-17:00", days being either a range or a comma separated list,
+// e.g. "Mon,Wed,Fri 10:00-12:00".
+func parseWindow(s string) (window, error) {
+	invalid := fmt.Errorf("invalid window %q, must be days and hours like Mon-Fri 09:00-17:00", s)
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return window{}, invalid
+	}
+
+	var w window
+	for _, part := range strings.Split(fields[0], ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := ParseWeekday(from)
+		if err != nil {
+			return window{}, invalid
+		}
+		last := first
+		if isRange {
+			if last, err = ParseWeekday(to); err != nil {
+				return window{}, invalid
+			}
+		}
+		// Ranges may wrap around the week, e.g. Sat-Sun or Fri-Mon.
+		for d := first; ; d = (d + 1) % 7 {
+			if !slices.Contains(w.days, d) {
+				w.days = append(w.days, d)
+			}
+			if d == last {
+				break
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return window{}, invalid
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return window{}, err
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return window{}, err
+	}
+	if w.end.hour*60+w.end.minute <= w.start.hour*60+w.start.minute {
+		return window{}, fmt.Errorf("invalid window %q, its hours must end after they start on the same day", s)
+	}
+	return w, nil
+}
+
+// covers reports whether the window applies on the day of t.
+func (w window) covers(t time.Time) bool {
+	return slices.Contains(w.days, t.Weekday())
+}
+
+// byDay returns the BYDAY part of the RRULE of the window, e.g. MO,TU.
+func (w window) byDay() string {
+	days := slices.Clone(w.days)
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	codes := make([]string, 0, len(days))
+	for _, d := range days {
+		codes = append(codes, rruleDay(d))
+	}
+	return strings.Join(codes, ",")
+}
+
+// windowed computes the timed events of a role of a rotation applying during
+// a window only, tagged with the rotation's id, and colored by member unless
+// color is set.
+//
+// Members get a recurring event per week of their slots, the occurrences of
+// each repeating on the days of the window every time they serve again, the
+// weeks of the recurrence starting on the handoff day. Slots covered by
+// someone else or moved by holidays are excluded from the recurring events
+// and replaced by single events on the days of the window they cover.
+func (r roleSchedule) windowed(id, color string) []Event {
+	s, occurrence := r.s, r.occurrence
+	// The window was validated already.
+	w, _ := parseWindow(r.Window)
+	members := r.ordered()
+	turns := s.members
+	timeZone := r.TimeZone
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	loc, _ := time.LoadLocation(timeZone)
+	colors := r.memberColors(members, color)
+
+	previous := func(slot int) string {
+		for p := slot - 1; p >= 0; p-- {
+			if !s.skipped[p] {
+				return s.member(p)
+			}
+		}
+		return ""
+	}
+	// event returns the event of the window on day, escalating to next by
+	// default and repeating with recurrence unless empty.
+	event := func(member, next string, slot int, day time.Time, recurrence []string) Event {
+		start, end := occurrence(slot)
+		escalation := r.escalation(member, next)
+		return Event{
+			RotationID:      id,
+			Rotation:        r.Name,
+			Member:          member,
+			Slot:            slot,
+			Summary:         Summary(r.Name, member),
+			Start:           w.start.on(day, loc),
+			End:             w.end.on(day, loc),
+			Timed:           true,
+			Recurrence:      recurrence,
+			ColorID:         colors[member],
+			TimeZone:        timeZone,
+			Attendees:       r.attendees(member, escalation, members),
+			Reminders:       r.Reminders,
+			Description:     r.description(member, previous(slot), escalation, start, end, false),
+			Visibility:      r.Visibility,
+			GuestsCanModify: r.GuestsCanModify,
+			Transparency:    r.Transparency,
+		}
+	}
+
+	overridden := make([]int, 0, len(s.overrides)+1)
+	for slot := range s.overrides {
+		overridden = append(overridden, slot)
+	}
+	if _, ok := s.overrides[0]; s.partial() && !ok {
+		overridden = append(overridden, 0)
+	}
+	overridden = append(overridden, s.moved()...)
+	sort.Ints(overridden)
+	excluded := slices.Clone(overridden)
+	for slot := range s.skipped {
+		excluded = append(excluded, slot)
+	}
+	sort.Ints(excluded)
+
+	var events []Event
+	weeks := s.cadence.Count
+	for i, member := range turns {
+		if s.slots > 0 && i >= s.slots {
+			break
+		}
+		rule := fmt.Sprintf("RRULE:FREQ=WEEKLY;INTERVAL=%d;WKST=%s;BYDAY=%s", weeks*len(turns), rruleDay(s.series(i).Weekday()), w.byDay())
+		if s.slots > 0 {
+			// UNTIL is a UTC time for events that aren't all-day.
+			_, end := s.bounds(i + len(turns)*((s.slots-1-i)/len(turns)))
+			rule += ";UNTIL=" + InLocation(end, loc).UTC().Format("20060102T150405Z")
+		}
+		for week := 0; week < weeks; week++ {
+			from := s.series(i).AddDate(0, 0, 7*week)
+			first := from
+			for !w.covers(first) {
+				first = first.AddDate(0, 0, 1)
+			}
+			recurrence := []string{rule}
+			var exdates []string
+			for _, slot := range excluded {
+				if slot%len(turns) != i {
+					continue
+				}
+				from := s.series(slot).AddDate(0, 0, 7*week)
+				for day := from; day.Before(from.AddDate(0, 0, 7)); day = day.AddDate(0, 0, 1) {
+					if w.covers(day) {
+						exdates = append(exdates, w.start.on(day, loc).Format("20060102T150405"))
+					}
+				}
+			}
+			if len(exdates) > 0 {
+				recurrence = append(recurrence, "EXDATE;TZID="+timeZone+":"+strings.Join(exdates, ","))
+			}
+			events = append(events, event(member, s.regular(i+1), i, first, recurrence))
+		}
+	}
+
+	for _, slot := range overridden {
+		member := s.member(slot)
+		start, end := occurrence(slot)
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			if w.covers(day) {
+				events = append(events, event(member, s.next(slot), slot, day, nil))
+			}
+		}
+	}
+	return events
+}
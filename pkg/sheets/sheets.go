@@ -0,0 +1,93 @@
+// Package sheets writes tables to Google Sheets, so the schedules can be
+// shared with people living in spreadsheets.
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// Scope is the OAuth scope needed to write to spreadsheets.
+const Scope = sheets.SpreadsheetsScope
+
+// Client writes to spreadsheets through the Google Sheets API.
+type Client struct {
+	srv *sheets.Service
+}
+
+// New returns a Client using an already authorized HTTP client.
+func New(ctx context.Context, httpClient *http.Client) (*Client, error) {
+	srv, err := sheets.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Sheets client: %w", err)
+	}
+	return &Client{srv: srv}, nil
+}
+
+// WriteTable writes rows to a spreadsheet from the top left cell of rng, in
+// A1 notation, e.g. Schedule!A1. When rng is a single cell, the columns of the
+// table are cleared below it first, so rows left by a longer table written
+// before don't linger. Values are written as is, never parsed as formulas.
+func (c *Client) WriteTable(ctx context.Context, spreadsheetID, rng string, rows [][]string) error {
+	columns := 0
+	values := make([][]any, len(rows))
+	for i, row := range rows {
+		columns = max(columns, len(row))
+		values[i] = make([]any, len(row))
+		for j, v := range row {
+			values[i][j] = v
+		}
+	}
+
+	clear := rng
+	if cleared, ok := columnsBelow(rng, columns); ok {
+		clear = cleared
+	}
+	if _, err := c.srv.Spreadsheets.Values.Clear(spreadsheetID, clear, &sheets.ClearValuesRequest{}).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("unable to clear range %s of spreadsheet %s: %w", clear, spreadsheetID, err)
+	}
+	_, err := c.srv.Spreadsheets.Values.Update(spreadsheetID, rng, &sheets.ValueRange{Values: values}).
+		ValueInputOption("RAW").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("unable to write range %s of spreadsheet %s: %w", rng, spreadsheetID, err)
+	}
+	return nil
+}
+
+// columnsBelow returns the range of the given number of columns from the
+// single cell rng down to the last row, e.g. Schedule!B2:D for Schedule!B2
+// and 3 columns, or false if rng isn't a single cell.
+func columnsBelow(rng string, columns int) (string, bool) {
+	sheet, cell := "", rng
+	if i := strings.LastIndex(rng, "!"); i >= 0 {
+		sheet, cell = rng[:i+1], rng[i+1:]
+	}
+	letters := strings.TrimRight(strings.ToUpper(cell), "0123456789")
+	row, err := strconv.Atoi(cell[len(letters):])
+	if letters == "" || err != nil || row < 1 || columns < 1 {
+		return "", false
+	}
+	column := 0
+	for _, l := range letters {
+		if l < 'A' || l > 'Z' {
+			return "", false
+		}
+		column = column*26 + int(l-'A'+1)
+	}
+	return fmt.Sprintf("%s%s%d:%s", sheet, letters, row, columnName(column+columns-1)), true
+}
+
+// columnName returns the letters of the 1-based column, e.g. AA for 27.
+func columnName(column int) string {
+	var name []byte
+	for ; column > 0; column = (column - 1) / 26 {
+		name = append([]byte{byte('A' + (column-1)%26)}, name...)
+	}
+	return string(name)
+}
@@ -0,0 +1,352 @@
+// Package slackbot answers Slack slash commands about a rotation, e.g.
+// "/oncall who", with Block Kit messages.
+package slackbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+)
+
+// maxSkew is how old a request may be, so captured ones can't be replayed.
+const maxSkew = 5 * time.Minute
+
+// responseTimeout bounds the time spent answering a command.
+const responseTimeout = time.Minute
+
+// Calendar is the calendar holding the rotation.
+type Calendar interface {
+	// Slots returns the occurrences of every rotation overlapping the given
+	// time range.
+	Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error)
+	// Swap exchanges the slot of from covering date, or the first one after
+	// it, with the next slot of to, returning both slots after the swap.
+	Swap(ctx context.Context, name, from, to string, date time.Time) ([]rotation.Slot, error)
+}
+
+// Handler answers the slash commands sent by Slack.
+//
+// Commands are acknowledged right away and answered through their response
+// URL, as Slack gives up on commands not acknowledged within 3 seconds.
+type Handler struct {
+	// SigningSecret authenticates the requests of the Slack app.
+	SigningSecret string
+	// Rotation is the name of the rotation the commands are about.
+	Rotation string
+	// Calendar is where the rotation is.
+	Calendar Calendar
+	// Users maps members to their Slack user IDs, so they get mentioned
+	// and can be given as mentions.
+	Users map[string]string
+	// Weeks is how far ahead the schedule command looks.
+	Weeks int
+	// HTTP is the client used to respond, http.DefaultClient when nil.
+	HTTP *http.Client
+}
+
+// message is a Slack message made of Block Kit blocks, with Text as the
+// fallback of notifications.
+type message struct {
+	ResponseType string  `json:"response_type,omitempty"`
+	Text         string  `json:"text"`
+	Blocks       []block `json:"blocks,omitempty"`
+}
+
+type block struct {
+	Type     string `json:"type"`
+	Text     *text  `json:"text,omitempty"`
+	Fields   []text `json:"fields,omitempty"`
+	Elements []text `json:"elements,omitempty"`
+}
+
+type text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func mrkdwn(s string) *text {
+	return &text{Type: "mrkdwn", Text: s}
+}
+
+func plain(s string) *text {
+	return &text{Type: "plain_text", Text: s}
+}
+
+// ServeHTTP handles a slash command.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "unable to read request", http.StatusBadRequest)
+		return
+	}
+	if err := verify(h.SigningSecret, r.Header, body, time.Now()); err != nil {
+		slog.Warn("Rejected Slack request", "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	slog.Info("Slash command received", "command", form.Get("command"), "text", form.Get("text"), "user", form.Get("user_id"))
+
+	responseURL := form.Get("response_url")
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), responseTimeout)
+	go func() {
+		defer cancel()
+		msg := h.answer(ctx, form.Get("command"), form.Get("text"))
+		if err := h.respond(ctx, responseURL, msg); err != nil {
+			slog.Error("Unable to respond to Slack", "error", err)
+		}
+	}()
+	w.WriteHeader(http.StatusOK)
+}
+
+// answer runs the command given in text and returns the message answering
+// it, which explains the error when it fails.
+func (h *Handler) answer(ctx context.Context, command, text string) message {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return h.help(command)
+	}
+	var msg message
+	var err error
+	switch strings.ToLower(args[0]) {
+	case "who":
+		msg, err = h.who(ctx)
+	case "schedule":
+		msg, err = h.schedule(ctx)
+	case "swap":
+		msg, err = h.swap(ctx, args[1:])
+	default:
+		return h.help(command)
+	}
+	if err != nil {
+		slog.Error("Slash command failed", "text", text, "error", err)
+		return message{
+			ResponseType: "ephemeral",
+			Text:         err.Error(),
+			Blocks:       []block{{Type: "section", Text: mrkdwn(":warning: " + err.Error())}},
+		}
+	}
+	return msg
+}
+
+func (h *Handler) help(command string) message {
+	if command == "" {
+		command = "/oncall"
+	}
+	usage := fmt.Sprintf("*%[1]s who*: who is on %[2]s now\n"+
+		"*%[1]s schedule*: who is on %[2]s in the next %[3]d weeks\n"+
+		"*%[1]s swap @from @to [YYYY-MM-DD]*: @to takes the slot of @from covering the date, today by default, and @from takes the next slot of @to",
+		command, h.Rotation, h.Weeks)
+	return message{
+		ResponseType: "ephemeral",
+		Text:         usage,
+		Blocks:       []block{{Type: "section", Text: mrkdwn(usage)}},
+	}
+}
+
+func (h *Handler) who(ctx context.Context) (message, error) {
+	now := time.Now()
+	slots, err := h.slots(ctx, now, now.Add(time.Second))
+	if err != nil {
+		return message{}, err
+	}
+	for _, s := range slots {
+		if !s.Covers(now) {
+			continue
+		}
+		summary := fmt.Sprintf("%s is on %s until %s", h.mention(s.Member), h.Rotation, s.End.Format(time.DateOnly))
+		return message{
+			ResponseType: "in_channel",
+			Text:         summary,
+			Blocks: []block{
+				{Type: "section", Text: mrkdwn(":pager: " + summary)},
+				{Type: "context", Elements: []text{*mrkdwn("Since " + s.Start.Format(time.DateOnly))}},
+			},
+		}, nil
+	}
+	return message{}, fmt.Errorf("nobody is on %s right now", h.Rotation)
+}
+
+func (h *Handler) schedule(ctx context.Context) (message, error) {
+	now := time.Now()
+	slots, err := h.slots(ctx, now, now.AddDate(0, 0, 7*h.Weeks))
+	if err != nil {
+		return message{}, err
+	}
+	if len(slots) == 0 {
+		return message{}, fmt.Errorf("nobody is on %s in the next %d weeks", h.Rotation, h.Weeks)
+	}
+	var lines []string
+	for _, s := range slots {
+		lines = append(lines, fmt.Sprintf("`%s` to `%s`  %s", s.Start.Format(time.DateOnly), s.End.Format(time.DateOnly), h.mention(s.Member)))
+	}
+	return message{
+		ResponseType: "in_channel",
+		Text:         fmt.Sprintf("%s schedule", h.Rotation),
+		Blocks: []block{
+			{Type: "header", Text: plain(fmt.Sprintf("%s schedule", h.Rotation))},
+			{Type: "section", Text: mrkdwn(strings.Join(lines, "\n"))},
+		},
+	}, nil
+}
+
+func (h *Handler) swap(ctx context.Context, args []string) (message, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return message{}, errors.New("swap needs the two members to swap, and optionally the date of the slot to swap, e.g. swap @seth @cesar 2024-09-02")
+	}
+	date := time.Now()
+	if len(args) == 3 {
+		var err error
+		date, err = time.Parse(time.DateOnly, args[2])
+		if err != nil {
+			return message{}, fmt.Errorf("unable to parse date %q, must be like 2024-09-02", args[2])
+		}
+	}
+	from, err := h.member(args[0])
+	if err != nil {
+		return message{}, err
+	}
+	to, err := h.member(args[1])
+	if err != nil {
+		return message{}, err
+	}
+	slots, err := h.Calendar.Swap(ctx, h.Rotation, from, to, date)
+	if err != nil {
+		return message{}, err
+	}
+	var fields []text
+	for _, s := range slots {
+		fields = append(fields, *mrkdwn(fmt.Sprintf("%s\n`%s` to `%s`", h.mention(s.Member), s.Start.Format(time.DateOnly), s.End.Format(time.DateOnly))))
+	}
+	summary := fmt.Sprintf("%s and %s swapped their %s slots", h.mention(from), h.mention(to), h.Rotation)
+	return message{
+		ResponseType: "in_channel",
+		Text:         summary,
+		Blocks: []block{
+			{Type: "section", Text: mrkdwn(":repeat: " + summary)},
+			{Type: "section", Fields: fields},
+		},
+	}, nil
+}
+
+// slots returns the slots of the rotation overlapping the given time range.
+func (h *Handler) slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	all, err := h.Calendar.Slots(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var slots []rotation.Slot
+	for _, s := range all {
+		if s.Rotation == h.Rotation {
+			slots = append(slots, s)
+		}
+	}
+	return slots, nil
+}
+
+// mention returns how a member is written in messages: a mention when their
+// Slack user ID is known, their name otherwise.
+func (h *Handler) mention(member string) string {
+	if id, ok := h.Users[member]; ok {
+		return fmt.Sprintf("<@%s>", id)
+	}
+	return member
+}
+
+// mentionPattern matches the mentions Slack escapes in commands, e.g.
+// <@U0123ABCD|seth>.
+var mentionPattern = regexp.MustCompile(`^<@([A-Z0-9]+)(\|[^>]*)?>$`)
+
+// member returns the member given as a mention or a name, with or without
+// an @.
+func (h *Handler) member(arg string) (string, error) {
+	if m := mentionPattern.FindStringSubmatch(arg); m != nil {
+		for member, id := range h.Users {
+			if id == m[1] {
+				return member, nil
+			}
+		}
+		return "", fmt.Errorf("%s is not a member of %s", arg, h.Rotation)
+	}
+	name := strings.TrimPrefix(arg, "@")
+	for member := range h.Users {
+		if strings.EqualFold(member, name) {
+			return member, nil
+		}
+	}
+	// Members without a Slack user are written as named in the calendar.
+	return name, nil
+}
+
+// respond posts a message to the response URL of a command.
+func (h *Handler) respond(ctx context.Context, responseURL string, msg message) error {
+	if responseURL == "" {
+		return errors.New("the command has no response URL")
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to post to slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("slack returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// verify checks a request was signed by Slack with the signing secret of the
+// app, and recently enough.
+func verify(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", ts)
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("request timestamp is %s off", skew.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+// Package spec reads declarative rotation specs, describing the rotations
+// that should exist so the calendars can be made to match them.
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"calendar/pkg/notify"
+	"calendar/pkg/rotation"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a spec file, e.g.
+//
+//	defaults:
+//	  calendar: team-roles
+//	  members: [Cesar, Seth, Juan]
+//	  start: 2024-07-01
+//	rotations:
+//	  - name: SRE Role
+//	    cadence: weekly
+//	  - name: Release Manager
+//	    cadence: biweekly
+//	    members: [Seth, Juan]
+//	    calendars: [team-roles, seth@example.com, juan@example.com]
+//	    reminders:
+//	      - method: email
+//	        minutes: 1440
+//
+// The fields of a rotation override the defaults, except maps, which are
+// merged with the default ones.
+type File struct {
+	Defaults  Rotation   `yaml:"defaults"`
+	Rotations []Rotation `yaml:"rotations"`
+}
+
+// Rotation is the spec of a rotation. Fields match the flags defining a
+// rotation on the command line.
+type Rotation struct {
+	Name string `yaml:"name"`
+	// Calendar is the summary or ID of the calendar holding the rotation,
+	// the ones given with --calendar when empty.
+	Calendar string `yaml:"calendar"`
+	// Calendars replaces Calendar to create the rotation in several
+	// calendars, e.g. the team one and each member's.
+	Calendars []string `yaml:"calendars"`
+	Members   []string `yaml:"members"`
+	Roles     []string `yaml:"roles"`
+	// Cadence is written as accepted by rotation.ParseCadence.
+	Cadence      string                    `yaml:"cadence"`
+	Start        string                    `yaml:"start"`
+	Until        string                    `yaml:"until"`
+	Count        int                       `yaml:"count"`
+	TimeZone     string                    `yaml:"timezone"`
+	Order        string                    `yaml:"order"`
+	Seed         int64                     `yaml:"seed"`
+	StartWith    string                    `yaml:"startWith"`
+	WeekdaysOnly bool                      `yaml:"weekdaysOnly"`
+	HandoffDay   string                    `yaml:"handoffDay"`
+	FirstSlot    string                    `yaml:"firstSlot"`
+	HandoffTime  string                    `yaml:"handoffTime"`
+	Emails       map[string]string         `yaml:"emails"`
+	Colors       map[string]string         `yaml:"colors"`
+	Weights      map[string]int            `yaml:"weights"`
+	WeightBy     string                    `yaml:"weightBy"`
+	InviteTeam   bool                      `yaml:"inviteTeam"`
+	Unavailable  []rotation.Unavailability `yaml:"unavailable"`
+	Reminders    []rotation.Reminder       `yaml:"reminders"`
+	// Visibility, GuestsCanModify and Transparency are written as the
+	// flags, e.g. private, true and busy.
+	Visibility      string `yaml:"visibility"`
+	GuestsCanModify bool   `yaml:"guestsCanModify"`
+	Transparency    string `yaml:"transparency"`
+	// Description is a template of the description of the events, e.g.
+	// "{{.Member}} is on call until {{.SlotEnd}}".
+	Description string `yaml:"description"`
+	// DescriptionTemplate is a file with the template of the description
+	// instead, e.g. a handoff checklist.
+	DescriptionTemplate string `yaml:"descriptionTemplate"`
+	// Runbooks are links listed in the description of the events.
+	Runbooks []string `yaml:"runbooks"`
+	// Escalation is the member to escalate to when the one on rotation is
+	// unreachable: next, the default, none or a member.
+	Escalation string `yaml:"escalation"`
+	// Materialize creates a single event per slot, up to Horizon ahead of
+	// today when the rotation never ends, written as accepted by
+	// rotation.ParseHorizon, 12m when unset.
+	Materialize bool   `yaml:"materialize"`
+	Horizon     string `yaml:"horizon"`
+	// Window limits the events to business hours, e.g. Mon-Fri 09:00-17:00.
+	Window string `yaml:"window"`
+	// HolidayCalendar is the summary or ID of a Google calendar listing the
+	// team's holidays, e.g. a public holiday one. Slots starting on a
+	// holiday start on the next business day.
+	HolidayCalendar string `yaml:"holidayCalendar"`
+	// SkipHolidayWeeks leaves out the slots only covering holidays.
+	SkipHolidayWeeks bool `yaml:"skipHolidayWeeks"`
+	// ConfluencePage is the ID of a Confluence page the upcoming schedule
+	// of the rotation is published to on every apply.
+	ConfluencePage string `yaml:"confluencePage"`
+	// Notify lists the channels handoffs are announced to by the notify
+	// command.
+	Notify []Notification `yaml:"notify"`
+}
+
+// Types of notification channels.
+const (
+	NotifySlack   = "slack"
+	NotifyTeams   = "teams"
+	NotifyMatrix  = "matrix"
+	NotifyEmail   = "email"
+	NotifyWebhook = "webhook"
+)
+
+// Notification is a channel the handoffs of a rotation are announced to,
+// e.g.
+//
+//	notify:
+//	  - type: slack
+//	    url: https://hooks.slack.com/services/...
+//	    users: {Seth: U0123ABCD}
+//	  - type: teams
+//	    url: https://example.webhook.office.com/webhookb2/...
+//	  - type: matrix
+//	    homeserver: https://matrix.example.com
+//	    room: "!abc123:example.com"
+//	    token: ${MATRIX_TOKEN}
+//	  - type: email
+//	    smtp: smtp.example.com:587
+//	    username: rotations
+//	    password: ${SMTP_PASSWORD}
+//	    from: rotations@example.com
+//	    to: [sre@example.com]
+//
+// Environment variables in the fields are expanded, so secrets can be kept
+// out of the spec.
+type Notification struct {
+	// Type is one of slack, teams, matrix, email or webhook.
+	Type string `yaml:"type"`
+	// URL is the incoming webhook of slack and teams, or the endpoint
+	// of webhook.
+	URL string `yaml:"url"`
+	// Users maps members to their Slack or Matrix user IDs, so they get
+	// mentioned.
+	Users map[string]string `yaml:"users"`
+	// Homeserver, Room and Token are the Matrix room posted to.
+	Homeserver string `yaml:"homeserver"`
+	Room       string `yaml:"room"`
+	Token      string `yaml:"token"`
+	// SMTP is the host and port of the server emails are sent through,
+	// authenticated with Username and Password if set, from From to the
+	// incoming member and To.
+	SMTP     string   `yaml:"smtp"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// Notifier returns the notifier of the channel, emailing members at the
+// given addresses.
+func (n Notification) Notifier(emails map[string]string) (notify.Notifier, error) {
+	// The fields are checked once expanded, so a variable that isn't set
+	// counts as missing.
+	expand := os.ExpandEnv
+	n.URL, n.Homeserver, n.Room, n.Token = expand(n.URL), expand(n.Homeserver), expand(n.Room), expand(n.Token)
+	n.SMTP, n.Username, n.Password, n.From = expand(n.SMTP), expand(n.Username), expand(n.Password), expand(n.From)
+	var missing []string
+	require := func(field, value string) {
+		if value == "" {
+			missing = append(missing, field)
+		}
+	}
+	var notifier notify.Notifier
+	switch n.Type {
+	case NotifySlack:
+		require("url", n.URL)
+		notifier = &notify.Slack{WebhookURL: n.URL, Users: n.Users}
+	case NotifyTeams:
+		require("url", n.URL)
+		notifier = &notify.Teams{WebhookURL: n.URL}
+	case NotifyMatrix:
+		require("homeserver", n.Homeserver)
+		require("room", n.Room)
+		require("token", n.Token)
+		notifier = &notify.Matrix{Homeserver: n.Homeserver, Room: n.Room, Token: n.Token, Users: n.Users}
+	case NotifyEmail:
+		require("smtp", n.SMTP)
+		require("from", n.From)
+		notifier = &notify.SMTP{Address: n.SMTP, Username: n.Username, Password: n.Password, From: n.From, Emails: emails, Team: n.To}
+	case NotifyWebhook:
+		require("url", n.URL)
+		notifier = &notify.Webhook{URL: n.URL}
+	default:
+		return nil, fmt.Errorf("unknown notification type %q, must be one of: %s, %s, %s, %s, %s", n.Type, NotifySlack, NotifyTeams, NotifyMatrix, NotifyEmail, NotifyWebhook)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s notification needs %s", n.Type, strings.Join(missing, ", "))
+	}
+	return notifier, nil
+}
+
+// defaultHorizon is how far ahead the slots of materialized rotations are
+// created, when the spec doesn't say.
+var defaultHorizon = rotation.Cadence{Unit: rotation.Monthly, Count: 12}
+
+// Load reads a spec file.
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read spec file: %w", err)
+	}
+	// Rotations are decoded over a copy of the defaults, so the fields they
+	// don't set keep the default values.
+	var raw struct {
+		Defaults  Rotation    `yaml:"defaults"`
+		Rotations []yaml.Node `yaml:"rotations"`
+	}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("unable to parse spec file %s: %w", path, err)
+	}
+	if raw.Defaults.Name != "" {
+		return nil, fmt.Errorf("defaults in spec file %s can't have a name", path)
+	}
+	f := File{Defaults: raw.Defaults}
+	for _, node := range raw.Rotations {
+		r := raw.Defaults
+		r.Members = slices.Clone(r.Members)
+		r.Emails = maps.Clone(r.Emails)
+		r.Colors = maps.Clone(r.Colors)
+		r.Weights = maps.Clone(r.Weights)
+		r.Unavailable = slices.Clone(r.Unavailable)
+		if err := node.Decode(&r); err != nil {
+			return nil, fmt.Errorf("unable to parse spec file %s: %w", path, err)
+		}
+		f.Rotations = append(f.Rotations, r)
+	}
+	seen := make(map[string]bool)
+	for i, r := range f.Rotations {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rotation %d in spec file %s has no name", i+1, path)
+		}
+		id := rotation.ID(r.Name)
+		if seen[id] {
+			return nil, fmt.Errorf("rotation %q is listed twice in spec file %s", r.Name, path)
+		}
+		seen[id] = true
+	}
+	return &f, nil
+}
+
+// Rotation returns the rotation described by the spec.
+func (s Rotation) Rotation() (rotation.Rotation, error) {
+	r := rotation.Rotation{
+		Name:            s.Name,
+		Members:         s.Members,
+		Roles:           s.Roles,
+		Order:           s.Order,
+		Seed:            s.Seed,
+		StartWith:       s.StartWith,
+		Count:           s.Count,
+		TimeZone:        s.TimeZone,
+		Unavailable:     s.Unavailable,
+		Emails:          s.Emails,
+		Colors:          s.Colors,
+		Weights:         s.Weights,
+		WeightBy:        s.WeightBy,
+		Reminders:       s.Reminders,
+		InviteTeam:      s.InviteTeam,
+		WeekdaysOnly:    s.WeekdaysOnly,
+		HandoffDay:      s.HandoffDay,
+		FirstSlot:       s.FirstSlot,
+		HandoffTime:     s.HandoffTime,
+		SkipHolidays:    s.SkipHolidayWeeks,
+		Visibility:      s.Visibility,
+		GuestsCanModify: s.GuestsCanModify,
+		Transparency:    s.Transparency,
+		Description:     s.Description,
+		Runbooks:        s.Runbooks,
+		Escalation:      s.Escalation,
+		Materialize:     s.Materialize,
+		Window:          s.Window,
+	}
+
+	var errs []error
+	var err error
+	if r.Start, err = time.Parse(time.DateOnly, s.Start); err != nil {
+		errs = append(errs, fmt.Errorf("rotation %q has an invalid start: %w", s.Name, err))
+	}
+	if r.Cadence, err = rotation.ParseCadence(s.Cadence); err != nil {
+		errs = append(errs, fmt.Errorf("rotation %q has an invalid cadence: %w", s.Name, err))
+	}
+	if s.Until != "" {
+		if r.Until, err = time.Parse(time.DateOnly, s.Until); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q has an invalid until: %w", s.Name, err))
+		}
+	}
+	switch {
+	case s.DescriptionTemplate != "" && s.Description != "":
+		errs = append(errs, fmt.Errorf("rotation %q can't have both a description and a description template", s.Name))
+	case s.DescriptionTemplate != "":
+		if r.Description, err = rotation.LoadDescription(s.DescriptionTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q: %w", s.Name, err))
+		}
+	}
+	if s.Materialize {
+		horizon := defaultHorizon
+		if s.Horizon != "" {
+			if horizon, err = rotation.ParseHorizon(s.Horizon); err != nil {
+				errs = append(errs, fmt.Errorf("rotation %q: %w", s.Name, err))
+			}
+		}
+		// The horizon moves with every apply, extending the rotation.
+		from := rotation.InLocation(time.Now(), time.UTC)
+		if r.Start.After(from) {
+			from = r.Start
+		}
+		r.Horizon = horizon.After(from)
+	}
+	if s.SkipHolidayWeeks && s.HolidayCalendar == "" {
+		errs = append(errs, fmt.Errorf("rotation %q skips holiday weeks but has no holiday calendar", s.Name))
+	}
+	for _, n := range s.Notify {
+		if _, err := n.Notifier(nil); err != nil {
+			errs = append(errs, fmt.Errorf("rotation %q: %w", s.Name, err))
+		}
+	}
+	return r, errors.Join(errs...)
+}
@@ -0,0 +1,200 @@
+// Package store keeps a local copy of the slots of the calendars, so the
+// schedule can still be read offline or when the calendar provider is down.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrOffline is returned by the operations of a local copy that need the
+// calendar itself, e.g. writing events.
+var ErrOffline = errors.New("not available from the local state, the calendar must be reachable")
+
+// snapshotsBucket holds the snapshots, by calendar.
+var snapshotsBucket = []byte("snapshots")
+
+// Snapshot is the copy of the slots of a calendar over a period.
+type Snapshot struct {
+	// SyncedAt is when the slots were read from the calendar.
+	SyncedAt time.Time `json:"syncedAt"`
+	// TimeZone is the IANA time zone of the calendar.
+	TimeZone string `json:"timeZone"`
+	// From and To bound the period copied, slots outside of it being
+	// unknown.
+	From  time.Time       `json:"from"`
+	To    time.Time       `json:"to"`
+	Slots []rotation.Slot `json:"slots"`
+}
+
+// Store is a BoltDB file of snapshots, one per calendar.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating it along with its directory when
+// missing. It waits a second at most for other commands using it to be done.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create state directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open local state %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save replaces the snapshot of a calendar, returning the number of slots
+// added and removed since the previous one, over the period they share.
+func (s *Store) Save(calendar string, snapshot Snapshot) (added, removed int, err error) {
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return 0, 0, err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(snapshotsBucket)
+		if err != nil {
+			return err
+		}
+		if previous := bucket.Get([]byte(calendar)); previous != nil {
+			var old Snapshot
+			if err := json.Unmarshal(previous, &old); err == nil {
+				added, removed = diff(old, snapshot)
+			}
+		} else {
+			added = len(snapshot.Slots)
+		}
+		return bucket.Put([]byte(calendar), b)
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to save local state: %w", err)
+	}
+	return added, removed, nil
+}
+
+// Load returns the snapshot of a calendar, or an error when it was never
+// synced.
+func (s *Store) Load(calendar string) (*Snapshot, error) {
+	var snapshot *Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(snapshotsBucket)
+		if bucket == nil {
+			return nil
+		}
+		b := bucket.Get([]byte(calendar))
+		if b == nil {
+			return nil
+		}
+		snapshot = &Snapshot{}
+		return json.Unmarshal(b, snapshot)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read local state: %w", err)
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("calendar %s has no local state, run calendar sync local while it is reachable", calendar)
+	}
+	return snapshot, nil
+}
+
+// diff counts the slots of next missing from previous, and the other way
+// around, over the period both snapshots cover.
+func diff(previous, next Snapshot) (added, removed int) {
+	from, to := next.From, next.To
+	if previous.From.After(from) {
+		from = previous.From
+	}
+	if previous.To.Before(to) {
+		to = previous.To
+	}
+	key := func(s rotation.Slot) string {
+		return fmt.Sprintf("%s/%s/%d/%d", s.Rotation, s.Member, s.Start.Unix(), s.End.Unix())
+	}
+	count := func(slots []rotation.Slot) map[string]bool {
+		keys := make(map[string]bool)
+		for _, s := range slots {
+			if s.Start.Before(to) && s.End.After(from) {
+				keys[key(s)] = true
+			}
+		}
+		return keys
+	}
+	old, current := count(previous.Slots), count(next.Slots)
+	for k := range current {
+		if !old[k] {
+			added++
+		}
+	}
+	for k := range old {
+		if !current[k] {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Calendar is a calendar read from its snapshot. Only its time zone and
+// slots are available, the other operations failing with ErrOffline.
+type Calendar struct {
+	Snapshot Snapshot
+}
+
+var _ provider.CalendarProvider = (*Calendar)(nil)
+
+// TimeZone implements provider.CalendarProvider.
+func (c *Calendar) TimeZone(ctx context.Context) (string, error) {
+	return c.Snapshot.TimeZone, nil
+}
+
+// ManagedEvents implements provider.CalendarProvider.
+func (c *Calendar) ManagedEvents(ctx context.Context, rotationID string) ([]provider.Event, error) {
+	return nil, ErrOffline
+}
+
+// RotationEvents implements provider.CalendarProvider.
+func (c *Calendar) RotationEvents(ctx context.Context, name string) ([]provider.Event, error) {
+	return nil, ErrOffline
+}
+
+// Sync implements provider.CalendarProvider.
+func (c *Calendar) Sync(ctx context.Context, existing []provider.Event, planned []rotation.Event, opts provider.SyncOptions) ([]provider.Change, error) {
+	return nil, ErrOffline
+}
+
+// DeleteEvent implements provider.CalendarProvider.
+func (c *Calendar) DeleteEvent(ctx context.Context, event provider.Event) error {
+	return ErrOffline
+}
+
+// Slots implements provider.CalendarProvider. Slots outside of the period of
+// the snapshot are unknown, so they are missing.
+func (c *Calendar) Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	loc, err := time.LoadLocation(c.Snapshot.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	var slots []rotation.Slot
+	for _, s := range c.Snapshot.Slots {
+		if s.Start.Before(to) && s.End.After(from) {
+			s.Start, s.End = s.Start.In(loc), s.End.In(loc)
+			slots = append(slots, s)
+		}
+	}
+	return slots, nil
+}
@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Positions of the slots in the on-call state.
+const (
+	PositionCurrent = "current"
+	PositionNext    = "next"
+)
+
+// State is who is on call per rotation, written to a file on every run so
+// dashboards can show it without calling the calendar.
+type State struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Rotations   []RotationState `json:"rotations"`
+}
+
+// RotationState is who is on a rotation now and who is next, either unset
+// when nobody is.
+type RotationState struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	Current *rotation.Slot `json:"current,omitempty"`
+	Next    *rotation.Slot `json:"next,omitempty"`
+}
+
+// NewState returns the on-call state of the rotations at the given time.
+func NewState(statuses []rotation.Status, at time.Time) State {
+	state := State{GeneratedAt: at, Rotations: make([]RotationState, 0, len(statuses))}
+	for _, s := range statuses {
+		r := RotationState{ID: s.ID, Name: s.Name, Current: s.Current}
+		if len(s.Upcoming) > 0 {
+			r.Next = &s.Upcoming[0]
+		}
+		state.Rotations = append(state.Rotations, r)
+	}
+	return state
+}
+
+// WriteState writes the on-call state to path as JSON when it ends in
+// .json, or else in the Prometheus text format read by the textfile
+// collector of the node exporter, e.g. oncall.prom. The file is replaced
+// atomically, so the collector never reads it half written.
+func WriteState(path string, state State) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return writeJSONState(path, state)
+	}
+
+	reg := prometheus.NewRegistry()
+	oncall := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_calendar_oncall",
+		Help: "Member on call now (position current) or next (position next), by rotation, always 1.",
+	}, []string{"rotation", "member", "position"})
+	start := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_calendar_oncall_start_timestamp_seconds",
+		Help: "Unix time the slot of the member on call now or next starts, by rotation.",
+	}, []string{"rotation", "member", "position"})
+	end := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_calendar_oncall_end_timestamp_seconds",
+		Help: "Unix time the slot of the member on call now or next ends, by rotation.",
+	}, []string{"rotation", "member", "position"})
+	generated := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "team_calendar_oncall_state_timestamp_seconds",
+		Help: "Unix time the on-call state was written, to alert when it goes stale.",
+	})
+	reg.MustRegister(oncall, start, end, generated)
+
+	for _, r := range state.Rotations {
+		for position, slot := range map[string]*rotation.Slot{PositionCurrent: r.Current, PositionNext: r.Next} {
+			if slot == nil {
+				continue
+			}
+			oncall.WithLabelValues(r.Name, slot.Member, position).Set(1)
+			start.WithLabelValues(r.Name, slot.Member, position).Set(float64(slot.Start.Unix()))
+			end.WithLabelValues(r.Name, slot.Member, position).Set(float64(slot.End.Unix()))
+		}
+	}
+	generated.Set(float64(state.GeneratedAt.Unix()))
+	return prometheus.WriteToTextfile(path, reg)
+}
+
+func writeJSONState(path string, state State) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(state); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
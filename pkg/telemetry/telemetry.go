@@ -0,0 +1,129 @@
+// Package telemetry exposes Prometheus metrics and OpenTelemetry traces of
+// the calendar API calls and rotation syncs, so a long running tool can be
+// alerted on when syncing silently breaks.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the name the tool reports its traces under.
+const ServiceName = "team-calendar"
+
+var (
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "team_calendar_api_requests_total",
+		Help: "Calendar API requests sent, by host, method and status code.",
+	}, []string{"host", "method", "code"})
+	apiErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "team_calendar_api_errors_total",
+		Help: "Calendar API requests that failed or returned an error status, by host and method.",
+	}, []string{"host", "method"})
+	handoffs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "team_calendar_handoffs_total",
+		Help: "Rotation handoffs seen, by rotation.",
+	}, []string{"rotation"})
+	syncFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "team_calendar_sync_failures_total",
+		Help: "Rotation syncs that failed, by rotation.",
+	}, []string{"rotation"})
+	lastSync = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "team_calendar_last_sync_timestamp_seconds",
+		Help: "Unix time of the last successful sync, by rotation.",
+	}, []string{"rotation"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Synced records the outcome of syncing a rotation.
+func Synced(rotation string, err error) {
+	if err != nil {
+		syncFailures.WithLabelValues(rotation).Inc()
+		return
+	}
+	lastSync.WithLabelValues(rotation).SetToCurrentTime()
+}
+
+// HandedOff records a handoff of a rotation to its next member.
+func HandedOff(rotation string) {
+	handoffs.WithLabelValues(rotation).Inc()
+}
+
+// WithInstrumentation returns a copy of the HTTP client counting its
+// requests and tracing them when tracing is set up.
+func WithInstrumentation(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = otelhttp.NewTransport(&countingTransport{base: base})
+	return &c
+}
+
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	apiRequests.WithLabelValues(req.URL.Host, req.Method, code).Inc()
+	if err != nil || res.StatusCode >= 400 {
+		apiErrors.WithLabelValues(req.URL.Host, req.Method).Inc()
+	}
+	return res, err
+}
+
+// Tracer returns the tracer of the tool's spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// SetupTracing exports traces to an OTLP collector over HTTP, e.g.
+// http://localhost:4318, or to the one configured through the standard
+// OTEL_EXPORTER_OTLP_* environment variables when endpoint is empty. The
+// returned function flushes the pending spans.
+func SetupTracing(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(5*time.Second)),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/spec"
+
+	"github.com/spf13/cobra"
+)
+
+func newPlanCommand() *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the changes apply would make to the calendars",
+		Long: `Show the changes apply would make to the calendars, without making them.
+
+Events are prefixed with + when they would be created, ~ when updated and -
+when deleted, so changes to a spec file can be reviewed before applying it.`,
+		Example: `  calendar plan -f rotations.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := cmd.OutOrStdout()
+			counts := make(map[string]int)
+			err := syncSpec(cmd, file, provider.SyncOptions{DryRun: true}, func(s spec.Rotation, calendar string, existing int, changes []provider.Change, err error) {
+				if err != nil {
+					fmt.Fprintf(w, "%s (%s): failed\n", s.Name, calendar)
+					return
+				}
+				if len(changes) == 0 {
+					fmt.Fprintf(w, "%s (%s): no changes\n", s.Name, calendar)
+					return
+				}
+				fmt.Fprintf(w, "%s (%s):\n", s.Name, calendar)
+				for _, c := range changes {
+					fmt.Fprintf(w, "  %s %s\n", planSymbols[c.Action], c.Summary)
+					counts[c.Action]++
+				}
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\nPlan: %d to add, %d to change, %d to remove.\n", counts["created"], counts["updated"], counts["deleted"])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "Spec file listing the rotations")
+	cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+var planSymbols = map[string]string{
+	"created": "+",
+	"updated": "~",
+	"deleted": "-",
+}
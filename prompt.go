@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"calendar/pkg/llm"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultPromptTemplate is the text/template of the request sent to the LLM
+// for --prompt, unless --prompt-template gives another one.
+const defaultPromptTemplate = `
+I want to run a golang binary that manages team rotations in a calendar.
+When I ask you something I want you to return a JSON object with the "intent" of the request, one of:
+  "create": create a rotation, with the fields
+    "eventName": name of the rotation, e.g. SRE Role
+    "teamMembers": list of the team members, e.g. ["Cesar", "Seth"]
+    "startDate": start date of the rotation, formatted as YYYY-MM-DD
+    "duration": duration of each event in weeks, e.g. 3
+  "who": show who is on a rotation, with the fields
+    "eventName": name of the rotation
+    "date": day to look up, formatted as YYYY-MM-DD, omitted for now
+  "list": list the upcoming handoffs of every rotation, with the field
+    "weeks": number of weeks to look ahead, omitted for the default
+  "swap": exchange the slots of two members, with the fields
+    "eventName": name of the rotation
+    "from": member giving away their slot
+    "to": member taking it
+    "date": day within the slot to swap, formatted as YYYY-MM-DD
+  "override": have a member cover a rotation for some days, with the fields
+    "eventName": name of the rotation
+    "member": member covering
+    "startDate": first day, formatted as YYYY-MM-DD
+    "endDate": last day, formatted as YYYY-MM-DD
+  "delete": delete a rotation, with the field
+    "eventName": name of the rotation
+Today is {{.Today}}.
+
+The ask may be written in any language, e.g. Spanish, French or German, or mix several of them.
+Whatever the language of the ask:
+  - the intent and the field names are always in English, exactly as listed above
+  - names of rotations and members are kept exactly as written, never translated
+  - dates written in words, e.g. "1 de julio", "le 2 juillet" or "am 3. Juli", are converted to YYYY-MM-DD
+  - numbers written in words, e.g. "tres semanas", "deux semaines" or "zwei Wochen", are converted to digits
+
+E.g if I tell you "Create and event called SRE-ROLE for Cesar and Seth that repeats every three weeks starting the first of july"
+You should return:
+	{"intent": "create", "eventName": "SRE-ROLE", "teamMembers": ["Cesar", "Seth"], "startDate": "2024-07-01", "duration": 3}
+
+E.g if I tell you "Create and event called Interrupt-catcher for Mulham, Juan and Bryan that repeats every 1 week starting the second of july"
+You should return:
+	{"intent": "create", "eventName": "Interrupt-catcher", "teamMembers": ["Mulham", "Juan", "Bryan"], "startDate": "2024-07-02", "duration": 1}
+
+E.g if I tell you "Crea una rotación llamada Guardia para Cesar y Seth que se repita cada dos semanas a partir del 1 de julio"
+You should return:
+	{"intent": "create", "eventName": "Guardia", "teamMembers": ["Cesar", "Seth"], "startDate": "2024-07-01", "duration": 2}
+
+E.g if I tell you "Who is on SRE Role on Christmas?"
+You should return:
+	{"intent": "who", "eventName": "SRE Role", "date": "2024-12-25"}
+
+E.g if I tell you "Qui est d'astreinte sur SRE Role le 15 août ?"
+You should return:
+	{"intent": "who", "eventName": "SRE Role", "date": "2024-08-15"}
+
+E.g if I tell you "Juan covers the SRE Role from the 12th to the 14th of August"
+You should return:
+	{"intent": "override", "eventName": "SRE Role", "member": "Juan", "startDate": "2024-08-12", "endDate": "2024-08-14"}
+
+E.g if I tell you "Tausche am 2. September den SRE Role Dienst von Cesar mit Seth"
+You should return:
+	{"intent": "swap", "eventName": "SRE Role", "from": "Cesar", "to": "Seth", "date": "2024-09-02"}
+
+{{.Roster}}
+Make sure to return only the JSON object, with only the fields of its intent.
+No additional information or text should be returned.
+
+Now, this is the real ask: {{.Prompt}}
+`
+
+// promptData is what the template of the request sent to the LLM is
+// executed with.
+type promptData struct {
+	// Today is the date of today with its weekday, e.g. Monday 2024-07-01.
+	Today string
+	// Roster describes the members and teams of the roster, if any.
+	Roster string
+	// Prompt is the request given with --prompt.
+	Prompt string
+}
+
+// loadPromptTemplate parses the template of the request sent to the LLM
+// from a file, e.g. to write the instructions and examples in the language
+// of the team, or the built-in one when path is empty.
+func loadPromptTemplate(path string) (*template.Template, error) {
+	text := defaultPromptTemplate
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read prompt template: %w", err)
+		}
+		text = string(b)
+	}
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+	}
+	if err := tmpl.Execute(io.Discard, promptData{}); err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+	}
+	// A template leaving out the request would have the LLM answer
+	// something else entirely.
+	if !slices.ContainsFunc(tmpl.Templates(), func(t *template.Template) bool { return t.Tree != nil && usesField(t.Tree.Root, "Prompt") }) {
+		return nil, fmt.Errorf("prompt template %s must include the request as {{.Prompt}}", path)
+	}
+	return tmpl, nil
+}
+
+// usesField reports whether a node of a template refers to the field of its
+// data with the given name, e.g. .Prompt, however it is written out.
+func usesField(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		return n != nil && slices.ContainsFunc(n.Nodes, func(node parse.Node) bool { return usesField(node, name) })
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if slices.ContainsFunc(cmd.Args, func(node parse.Node) bool { return usesField(node, name) }) {
+				return true
+			}
+		}
+		return false
+	case *parse.ActionNode:
+		return usesField(n.Pipe, name)
+	case *parse.IfNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.RangeNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.WithNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.TemplateNode:
+		return usesField(n.Pipe, name)
+	case *parse.ChainNode:
+		return usesField(n.Node, name)
+	case *parse.FieldNode:
+		return n.Ident[0] == name
+	case *parse.VariableNode:
+		// $ is the data the template is executed with.
+		return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+	}
+	return false
+}
+
+// fullPrompt returns the request sent to the LLM for a prompt.
+func fullPrompt(tmpl *template.Template, actualPrompt string, today time.Time, roster *rotation.Roster) (string, error) {
+	var b strings.Builder
+	data := promptData{Today: today.Format("Monday 2006-01-02"), Roster: rosterPrompt(roster), Prompt: actualPrompt}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("unable to render prompt template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// rosterPrompt describes the members of the roster, with the other names
+// they go by, and the teams, so the LLM only answers with their names.
+func rosterPrompt(roster *rotation.Roster) string {
+	if roster == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("The team members are the following, only use their names in the answer, never the other names they go by:\n")
+	for _, m := range roster.Members {
+		fmt.Fprintf(&b, "  %s", m.Name)
+		if len(m.Aliases) > 0 {
+			fmt.Fprintf(&b, " (also called %s)", strings.Join(m.Aliases, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if len(roster.Teams) > 0 {
+		b.WriteString("A team mentioned in the ask stands for all of its members:\n")
+		teams := make([]string, 0, len(roster.Teams))
+		for team := range roster.Teams {
+			teams = append(teams, team)
+		}
+		sort.Strings(teams)
+		for _, team := range teams {
+			fmt.Fprintf(&b, "  %s: %s\n", team, strings.Join(roster.Teams[team], ", "))
+		}
+	}
+	return b.String()
+}
+
+// Intents understood from a prompt. Except for create, which is handled by
+// the root command, they map to the subcommand of the same name.
+const (
+	intentCreate   = "create"
+	intentWho      = "who"
+	intentList     = "list"
+	intentSwap     = "swap"
+	intentOverride = "override"
+	intentDelete   = "delete"
+)
+
+// promptIntent is what the LLM is asked to return: the intent of the prompt
+// and the fields it needs.
+type promptIntent struct {
+	Intent      string   `json:"intent"`
+	EventName   string   `json:"eventName"`
+	TeamMembers []string `json:"teamMembers"`
+	StartDate   string   `json:"startDate"`
+	EndDate     string   `json:"endDate"`
+	Duration    int      `json:"duration"`
+	Date        string   `json:"date"`
+	Weeks       int      `json:"weeks"`
+	From        string   `json:"from"`
+	To          string   `json:"to"`
+	Member      string   `json:"member"`
+
+	// roster, when set, holds the only members the intent may name.
+	roster *rotation.Roster
+}
+
+// Validate implements llm.Validator.
+func (p *promptIntent) Validate() error {
+	var errs []error
+	required := func(field, value string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s must be set for intent %q", field, p.Intent))
+		}
+	}
+	date := func(field, value string, optional bool) {
+		if value == "" && optional {
+			return
+		}
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %q must be formatted as YYYY-MM-DD", field, value))
+		}
+	}
+
+	switch p.Intent {
+	case intentCreate:
+		required("eventName", p.EventName)
+		if len(p.TeamMembers) == 0 || slices.Contains(p.TeamMembers, "") {
+			errs = append(errs, errors.New("teamMembers must list at least one member, with no empty names"))
+		}
+		date("startDate", p.StartDate, false)
+		if p.Duration < 1 {
+			errs = append(errs, fmt.Errorf("duration must be a positive number of weeks, got %d", p.Duration))
+		}
+	case intentWho:
+		required("eventName", p.EventName)
+		date("date", p.Date, true)
+	case intentList:
+		if p.Weeks < 0 {
+			errs = append(errs, fmt.Errorf("weeks can't be negative, got %d", p.Weeks))
+		}
+	case intentSwap:
+		required("eventName", p.EventName)
+		required("from", p.From)
+		required("to", p.To)
+		date("date", p.Date, false)
+	case intentOverride:
+		required("eventName", p.EventName)
+		required("member", p.Member)
+		date("startDate", p.StartDate, false)
+		date("endDate", p.EndDate, true)
+	case intentDelete:
+		required("eventName", p.EventName)
+	default:
+		errs = append(errs, fmt.Errorf("unknown intent %q, must be one of: %s", p.Intent, strings.Join([]string{intentCreate, intentWho, intentList, intentSwap, intentOverride, intentDelete}, ", ")))
+	}
+	if p.roster != nil {
+		// Names made up by the LLM are rejected, so it's asked again.
+		for _, name := range p.TeamMembers {
+			if _, ok := p.roster.Team(name); ok {
+				continue
+			}
+			if _, err := p.roster.Resolve(name); err != nil {
+				errs = append(errs, fmt.Errorf("teamMembers: %w", err))
+			}
+		}
+		for _, name := range []string{p.Member, p.From, p.To} {
+			if _, err := p.roster.Resolve(name); name != "" && err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveMembers replaces the names of the members of the intent with their
+// names in the roster, if any, and the teams with their members.
+func (p *promptIntent) resolveMembers() {
+	if p.roster == nil {
+		return
+	}
+	var members []string
+	for _, name := range p.TeamMembers {
+		team, ok := p.roster.Team(name)
+		if !ok {
+			// Validate checked the name already.
+			member, _ := p.roster.Resolve(name)
+			team = []string{member}
+		}
+		for _, m := range team {
+			if !slices.Contains(members, m) {
+				members = append(members, m)
+			}
+		}
+	}
+	p.TeamMembers = members
+	for _, name := range []*string{&p.Member, &p.From, &p.To} {
+		if *name != "" {
+			*name, _ = p.roster.Resolve(*name)
+		}
+	}
+}
+
+// args returns the arguments running the subcommand of the intent.
+func (p *promptIntent) args() []string {
+	args := []string{p.Intent}
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name, value)
+		}
+	}
+	switch p.Intent {
+	case intentWho:
+		flag("event-name", p.EventName)
+		flag("at", p.Date)
+	case intentList:
+		if p.Weeks > 0 {
+			flag("weeks", strconv.Itoa(p.Weeks))
+		}
+	case intentSwap:
+		flag("event-name", p.EventName)
+		flag("from", p.From)
+		flag("to", p.To)
+		flag("date", p.Date)
+	case intentOverride:
+		flag("event-name", p.EventName)
+		flag("member", p.Member)
+		flag("from", p.StartDate)
+		flag("to", p.EndDate)
+	case intentDelete:
+		flag("event-name", p.EventName)
+	}
+	return args
+}
+
+// mutating returns whether the intent changes the calendar.
+func (p *promptIntent) mutating() bool {
+	return p.Intent != intentWho && p.Intent != intentList
+}
+
+// parsePrompt asks the LLM to classify a natural language request and
+// extract its flags, asking again up to retries times when the answer is
+// invalid. With a roster, the LLM is told about its members, aliases and
+// teams, and must only answer with its members, resolved to their names.
+func parsePrompt(ctx context.Context, provider llm.Provider, tmpl *template.Template, prompt string, roster *rotation.Roster, retries int) (*promptIntent, error) {
+	full, err := fullPrompt(tmpl, prompt, time.Now(), roster)
+	if err != nil {
+		return nil, err
+	}
+	p := promptIntent{roster: roster}
+	if err := llm.CompleteJSON(ctx, provider, full, &p, retries); err != nil {
+		return nil, err
+	}
+	p.resolveMembers()
+	slog.Debug("Prompt parsed", "intent", p.Intent)
+	return &p, nil
+}
+
+// commandLine returns args as they would be typed in a shell.
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if strings.ContainsAny(a, " \t\"'$\\") {
+			a = strconv.Quote(a)
+		}
+		quoted[i] = a
+	}
+	return "calendar " + strings.Join(quoted, " ")
+}
+
+// runIntent runs the subcommand matching a prompt, once confirmed when it
+// changes the calendar.
+func runIntent(cmd *cobra.Command, intent *promptIntent, yes bool) error {
+	args := intent.args()
+	fmt.Fprintln(os.Stderr, commandLine(args))
+	if intent.mutating() && !yes {
+		printIntent(os.Stdout, intent)
+		if !confirm("Run this command?") {
+			slog.Info("Aborted, nothing was changed")
+			return nil
+		}
+	}
+	if intent.Intent == intentDelete {
+		// Already confirmed above.
+		args = append(args, "--yes")
+	}
+
+	// The persistent flags already set, e.g. --calendar, keep their values.
+	root := cmd.Root()
+	root.SetArgs(args)
+	return root.ExecuteContext(cmd.Context())
+}
+
+// printIntent shows what was understood from a prompt, so it can be checked
+// before acting on it.
+func printIntent(w io.Writer, intent *promptIntent) {
+	fmt.Fprintln(w, "Understood from the prompt:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	field := func(name, value string) {
+		if value != "" && value != "0" {
+			fmt.Fprintf(tw, "  %s:\t%s\n", name, value)
+		}
+	}
+	field("Action", intent.Intent)
+	field("Rotation", intent.EventName)
+	field("Team members", strings.Join(intent.TeamMembers, ", "))
+	field("Member", intent.Member)
+	field("Start date", intent.StartDate)
+	field("End date", intent.EndDate)
+	field("Duration (weeks)", strconv.Itoa(intent.Duration))
+	field("Date", intent.Date)
+	field("Weeks", strconv.Itoa(intent.Weeks))
+	field("From", intent.From)
+	field("To", intent.To)
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// printSchedule shows the first slots of a rotation, as who is on rotation
+// when.
+func printSchedule(w io.Writer, r rotation.Rotation) error {
+	slots, err := rotation.Preview(r, previewSlots)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Planned schedule of %q:\n", r.Name)
+	for _, s := range slots {
+		member := s.Member
+		if len(r.Roles) > 0 {
+			member = fmt.Sprintf("%s (%s)", s.Member, strings.TrimPrefix(s.Rotation, r.Name+" "))
+		}
+		fmt.Fprintf(w, "  %s → %s  %s\n", s.Start.Format(time.DateOnly), s.End.Format(time.DateOnly), member)
+	}
+	if len(slots) == previewSlots*max(len(r.Roles), 1) {
+		fmt.Fprintln(w, "  ...")
+	}
+	fmt.Fprintln(w)
+	return nil
+}
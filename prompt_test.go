@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"calendar/pkg/rotation"
+)
+
+// stubProvider is an llm.Provider answering with canned answers in turn,
+// recording the prompts it was sent.
+type stubProvider struct {
+	answers []string
+	prompts []string
+}
+
+func (s *stubProvider) Complete(ctx context.Context, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	answer := s.answers[0]
+	if len(s.answers) > 1 {
+		s.answers = s.answers[1:]
+	}
+	return answer, nil
+}
+
+func TestParsePrompt(t *testing.T) {
+	roster := &rotation.Roster{
+		Members: []rotation.Member{
+			{Name: "Alejandro", Aliases: []string{"Alex"}},
+			{Name: "Marie"},
+			{Name: "Jürgen"},
+		},
+		Teams: map[string][]string{"platform": {"Alejandro", "Marie"}},
+	}
+	tests := []struct {
+		name    string
+		prompt  string
+		answers []string
+		want    promptIntent
+	}{
+		{
+			name:    "spanish",
+			prompt:  "Crea la rotación SRE con el equipo platform desde el 1 de julio de 2024, una semana cada uno",
+			answers: []string{`{"intent": "create", "eventName": "SRE", "teamMembers": ["platform"], "startDate": "2024-07-01", "duration": 1}`},
+			want:    promptIntent{Intent: intentCreate, EventName: "SRE", TeamMembers: []string{"Alejandro", "Marie"}, StartDate: "2024-07-01", Duration: 1},
+		},
+		{
+			name:    "english",
+			prompt:  "Swap Alex and Marie on SRE the week of 2024-07-08",
+			answers: []string{"```json\n" + `{"intent": "swap", "eventName": "SRE", "from": "Alex", "to": "Marie", "date": "2024-07-08"}` + "\n```"},
+			want:    promptIntent{Intent: intentSwap, EventName: "SRE", From: "Alejandro", To: "Marie", Date: "2024-07-08"},
+		},
+		{
+			name:    "french",
+			prompt:  "Qui est d'astreinte SRE le 15 juillet 2024 ?",
+			answers: []string{`{"intent": "who", "eventName": "SRE", "date": "2024-07-15"}`},
+			want:    promptIntent{Intent: intentWho, EventName: "SRE", Date: "2024-07-15"},
+		},
+		{
+			name:   "german asked again",
+			prompt: "Jürgen übernimmt SRE vom 1. bis 3. Juli 2024",
+			answers: []string{
+				`{"intent": "override", "eventName": "SRE", "member": "Hans", "startDate": "2024-07-01", "endDate": "2024-07-03"}`,
+				`{"intent": "override", "eventName": "SRE", "member": "Jürgen", "startDate": "2024-07-01", "endDate": "2024-07-03"}`,
+			},
+			want: promptIntent{Intent: intentOverride, EventName: "SRE", Member: "Jürgen", StartDate: "2024-07-01", EndDate: "2024-07-03"},
+		},
+	}
+	tmpl, err := loadPromptTemplate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &stubProvider{answers: tt.answers}
+			got, err := parsePrompt(context.Background(), provider, tmpl, tt.prompt, roster, 1)
+			if err != nil {
+				t.Fatalf("parsePrompt() error = %v", err)
+			}
+			if len(provider.prompts) != len(tt.answers) {
+				t.Errorf("LLM asked %d times, want %d", len(provider.prompts), len(tt.answers))
+			}
+			// The request is sent as written, along with the roster.
+			if !strings.Contains(provider.prompts[0], tt.prompt) || !strings.Contains(provider.prompts[0], "Alejandro (also called Alex)") {
+				t.Errorf("prompt sent lacks the request or the roster:\n%s", provider.prompts[0])
+			}
+			got.roster = nil
+			if got.Intent != tt.want.Intent || got.EventName != tt.want.EventName || !slices.Equal(got.TeamMembers, tt.want.TeamMembers) ||
+				got.StartDate != tt.want.StartDate || got.EndDate != tt.want.EndDate || got.Duration != tt.want.Duration || got.Date != tt.want.Date ||
+				got.From != tt.want.From || got.To != tt.want.To || got.Member != tt.want.Member {
+				t.Errorf("parsePrompt() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	provider := &stubProvider{answers: []string{`{"intent": "dance"}`}}
+	if _, err := parsePrompt(context.Background(), provider, tmpl, "Let's dance", roster, 2); err == nil {
+		t.Error("parsePrompt() of an unknown intent succeeded, want an error")
+	}
+	if len(provider.prompts) != 3 {
+		t.Errorf("LLM asked %d times, want 3", len(provider.prompts))
+	}
+}
+
+func TestLoadPromptTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "field", template: "Today is {{.Today}}. {{.Prompt}}"},
+		{name: "quoted", template: `Answer this: {{printf "%q" .Prompt}}`},
+		{name: "with", template: "{{with .Prompt}}Answer this: {{.}}{{end}}"},
+		{name: "root variable", template: "{{with .Today}}Today is {{.}}. {{$.Prompt}}{{end}}"},
+		{name: "defined template", template: `{{define "ask"}}{{.Prompt}}{{end}}Answer this: {{template "ask" .}}`},
+		{name: "no prompt", template: "Today is {{.Today}}.", wantErr: true},
+		{name: "unknown field", template: "{{.Prompt}} {{.Tomorrow}}", wantErr: true},
+		{name: "invalid", template: "{{.Prompt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prompt.tmpl")
+			if err := os.WriteFile(path, []byte(tt.template), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadPromptTemplate(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadPromptTemplate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"calendar/pkg/confluence"
+	"calendar/pkg/gcal"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/spec"
+	"calendar/pkg/telemetry"
+
+	"github.com/spf13/cobra"
+)
+
+// addPublishFlags adds the flags of the Confluence site the rotations of a
+// spec file are published to.
+func addPublishFlags(cmd *cobra.Command) {
+	cmd.Flags().String("confluence-url", "", "Base URL of the Confluence site holding the confluencePage of the rotations, e.g. https://example.atlassian.net/wiki")
+	cmd.Flags().String("confluence-user", "", "Email of the Confluence Cloud user of --confluence-token, unset to use a Data Center personal access token")
+	cmd.Flags().String("confluence-token", "", "Confluence API token or personal access token, or $CONFLUENCE_TOKEN")
+	cmd.Flags().Int("publish-months", 3, "Number of months ahead of the schedule published to Confluence")
+}
+
+// checkPublish returns an error if rotations of the spec file are published
+// but the Confluence site is missing.
+func checkPublish(cmd *cobra.Command, f *spec.File) error {
+	for _, s := range f.Rotations {
+		if url, _ := cmd.Flags().GetString("confluence-url"); s.ConfluencePage != "" && url == "" {
+			return fmt.Errorf("rotation %q has a confluencePage, --confluence-url must be set", s.Name)
+		}
+	}
+	return nil
+}
+
+// publishSpec updates the Confluence page of every rotation of a spec file
+// listing one with its upcoming schedule, as rendered by the report command,
+// read from the first calendar of the rotation.
+func publishSpec(cmd *cobra.Command, f *spec.File) error {
+	client := &confluence.Client{HTTP: telemetry.WithInstrumentation(gcal.WithDebugLogging(http.DefaultClient, slog.Default()))}
+	client.URL, _ = cmd.Flags().GetString("confluence-url")
+	client.User, _ = cmd.Flags().GetString("confluence-user")
+	client.Token, _ = cmd.Flags().GetString("confluence-token")
+	months, _ := cmd.Flags().GetInt("publish-months")
+
+	ctx := cmd.Context()
+	providers := make(map[string]provider.CalendarProvider)
+	var errs []error
+	for _, s := range f.Rotations {
+		if s.ConfluencePage == "" {
+			continue
+		}
+		err := func() error {
+			cal, err := specProvider(cmd, specCalendars(cmd, s)[0], providers)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			slots, err := cal.Slots(ctx, now, now.AddDate(0, months, 0))
+			if err != nil {
+				return err
+			}
+			var schedule []rotation.Slot
+			for _, slot := range slots {
+				if slot.Rotation == s.Name {
+					schedule = append(schedule, slot)
+				}
+			}
+			var b bytes.Buffer
+			if err := printReportHTML(&b, s.Name, schedule); err != nil {
+				return err
+			}
+			return client.UpdatePage(ctx, s.ConfluencePage, b.String(), "Schedule updated by calendar apply")
+		}()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to publish rotation %q to Confluence: %w", s.Name, err))
+			continue
+		}
+		slog.Info("Schedule published", "rotation", s.Name, "page", s.ConfluencePage)
+	}
+	return errors.Join(errs...)
+}
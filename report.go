@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newReportCommand() *cobra.Command {
+	var eventName string
+	var months int
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Render the upcoming schedule of a rotation for a wiki page",
+		Long: `Render the upcoming schedule of a rotation as a table of who is on rotation
+when, to paste into a team wiki or Confluence page.
+
+The markdown table can be pasted as is, the HTML one shows every member in the
+color of their events.`,
+		Example: `  # Schedule of the SRE Role for the next quarter
+  calendar report --event-name "SRE Role" --months 3 --format markdown
+
+  # Same, colored, into a file
+  calendar report --event-name "SRE Role" --format html > schedule.html`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "markdown" && format != "html" {
+				return fmt.Errorf("unknown format %q, must be one of: markdown, html", format)
+			}
+			if months < 1 {
+				return fmt.Errorf("--months must be at least 1")
+			}
+
+			ctx := cmd.Context()
+			cal, err := newReadProvider(cmd)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			slots, err := cal.Slots(ctx, now, now.AddDate(0, months, 0))
+			if err != nil {
+				return err
+			}
+			var schedule []rotation.Slot
+			for _, s := range slots {
+				if s.Rotation == eventName {
+					schedule = append(schedule, s)
+				}
+			}
+			if len(schedule) == 0 {
+				return fmt.Errorf("rotation %q has no slots in the next %d months", eventName, months)
+			}
+			if format == "html" {
+				return printReportHTML(os.Stdout, eventName, schedule)
+			}
+			return printReportMarkdown(os.Stdout, eventName, schedule)
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().IntVar(&months, "months", 3, "Number of months ahead to report")
+	cmd.Flags().StringVar(&format, "format", "markdown", "Format of the report: markdown or html")
+	cmd.MarkFlagRequired("event-name")
+
+	return cmd
+}
+
+// reportRow is a slot as shown in a report.
+type reportRow struct {
+	Member, Start, End string
+	// Color is the RGB color of the member's events.
+	Color string
+}
+
+// reportRows returns the slots as shown in a report. All-day slots end on
+// their last day rather than on the next one, as people read them.
+func reportRows(slots []rotation.Slot) []reportRow {
+	var rows []reportRow
+	colors := make(map[string]string)
+	for _, s := range slots {
+		// Slots without a calendar color get one by member, like the
+		// rotations do by default.
+		if _, ok := colors[s.Member]; !ok {
+			colors[s.Member] = rotation.ColorHex(rotation.DefaultColor(len(colors)))
+		}
+		color := rotation.ColorHex(s.ColorID)
+		if color == "" {
+			color = colors[s.Member]
+		}
+
+		start, end := s.Start.Format(time.DateOnly), s.End.AddDate(0, 0, -1).Format(time.DateOnly)
+		if !s.Start.Equal(rotation.InLocation(s.Start, s.Start.Location())) || !s.End.Equal(rotation.InLocation(s.End, s.End.Location())) {
+			start, end = s.Start.Format("2006-01-02 15:04 MST"), s.End.Format("2006-01-02 15:04 MST")
+		}
+		rows = append(rows, reportRow{Member: s.Member, Start: start, End: end, Color: color})
+	}
+	return rows
+}
+
+// printReportMarkdown renders the schedule of a rotation as a markdown
+// table.
+func printReportMarkdown(w io.Writer, name string, slots []rotation.Slot) error {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	fmt.Fprintf(w, "## %s\n\n", escape.Replace(name))
+	fmt.Fprintln(w, "| Member | Start | End |")
+	fmt.Fprintln(w, "| --- | --- | --- |")
+	for _, r := range reportRows(slots) {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", escape.Replace(r.Member), r.Start, r.End)
+	}
+	return nil
+}
+
+// reportHTML is an HTML table of the schedule, with inline styles only as
+// wikis usually drop style sheets.
+var reportHTML = template.Must(template.New("report").Parse(`<h2>{{.Name}}</h2>
+<table style="border-collapse: collapse">
+  <thead>
+    <tr><th style="text-align: left; padding: 4px 8px">Member</th><th style="text-align: left; padding: 4px 8px">Start</th><th style="text-align: left; padding: 4px 8px">End</th></tr>
+  </thead>
+  <tbody>
+{{- range .Rows}}
+    <tr><td style="padding: 4px 8px; color: #ffffff; background-color: {{.Color}}">{{.Member}}</td><td style="padding: 4px 8px">{{.Start}}</td><td style="padding: 4px 8px">{{.End}}</td></tr>
+{{- end}}
+  </tbody>
+</table>
+`))
+
+// printReportHTML renders the schedule of a rotation as an HTML table, the
+// members in the color of their events.
+func printReportHTML(w io.Writer, name string, slots []rotation.Slot) error {
+	return reportHTML.Execute(w, struct {
+		Name string
+		Rows []reportRow
+	}{name, reportRows(slots)})
+}
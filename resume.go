@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"calendar/pkg/config"
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+// runState records a run that failed to create a rotation, so --resume can
+// pick up where it stopped with the same plan, rather than planning again
+// and e.g. shuffling the members differently.
+type runState struct {
+	Rotation string `json:"rotation"`
+	// Calendars are the calendars the rotation failed to be synced with.
+	Calendars []string         `json:"calendars"`
+	Events    []rotation.Event `json:"events"`
+	// Done are the members whose events were created before the failure.
+	Done  []string  `json:"done"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// runStatePath returns the path of the state of the last failed run of a
+// rotation, in the runs directory of the configuration directory.
+func runStatePath(name string) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "runs", rotation.ID(name)+".json"), nil
+}
+
+// loadRunState returns the state of the last failed run of a rotation, nil
+// when there is none.
+func loadRunState(name string) (*runState, error) {
+	path, err := runStatePath(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read run state: %w", err)
+	}
+	var s runState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse run state %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// recordRun saves the state of a run that failed to sync a rotation with
+// some calendars, or forgets the last failed run once none failed.
+func recordRun(name string, events []rotation.Event, failed []string, done []string, runErr error) {
+	path, err := runStatePath(name)
+	if err != nil {
+		slog.Warn("Unable to record run state", "error", err)
+		return
+	}
+	if len(failed) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Unable to remove run state", "error", err)
+		}
+		return
+	}
+
+	s := runState{Rotation: name, Calendars: failed, Events: events, Done: done, Time: time.Now().UTC()}
+	if runErr != nil {
+		s.Error = runErr.Error()
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o700)
+	}
+	if err == nil {
+		err = os.WriteFile(path, b, 0o600)
+	}
+	if err != nil {
+		slog.Warn("Unable to record run state", "error", err)
+		return
+	}
+	slog.Info("Run state saved, run again with --resume to create the remaining events", "rotation", name, "state", path)
+}
+
+// doneMembers adds the members whose events were created by the changes to
+// done.
+func doneMembers(done []string, events []rotation.Event, changes []provider.Change) []string {
+	for _, c := range changes {
+		if c.Action != "created" {
+			continue
+		}
+		i := slices.IndexFunc(events, func(e rotation.Event) bool { return e.Summary == c.Summary })
+		if i >= 0 && !slices.Contains(done, events[i].Member) {
+			done = append(done, events[i].Member)
+		}
+	}
+	return done
+}
+
+// remaining splits the events of a failed run into the existing ones that
+// weren't planned, and the planned ones that weren't created, so resuming
+// leaves the events already created alone, even with providers replacing
+// every event on sync.
+func remaining(existing []provider.Event, planned []rotation.Event) (stale []provider.Event, missing []rotation.Event) {
+	stale = slices.Clone(existing)
+	for _, e := range planned {
+		i := slices.IndexFunc(stale, func(x provider.Event) bool {
+			return x.Summary == e.Summary && strings.HasPrefix(x.Start, e.Start.Format(time.DateOnly))
+		})
+		if i < 0 {
+			missing = append(missing, e)
+			continue
+		}
+		stale = slices.Delete(stale, i, i+1)
+	}
+	return stale, missing
+}
+
+// resumeRun syncs the calendars a previous run of the rotation failed with,
+// using the plan of that run. The events it already created are left as
+// they are, so it picks up from the member it failed at.
+func resumeRun(cmd *cobra.Command, name string, atomic bool, output string) error {
+	if name == "" {
+		return fmt.Errorf("--event-name must be set to know which rotation to resume")
+	}
+	if err := checkSummaryOutput(output); err != nil {
+		return err
+	}
+	state, err := loadRunState(name)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("no failed run of rotation %q to resume", name)
+	}
+	slog.Info("Resuming rotation", "rotation", state.Rotation, "calendars", state.Calendars, "done", state.Done, "failedAt", state.Time, "error", state.Error)
+
+	ctx := cmd.Context()
+	var sum summary
+	var failed []string
+	var errs []error
+	done := state.Done
+	for i, calendarName := range state.Calendars {
+		changes, err := func() ([]provider.Change, error) {
+			cal, err := newCalendarProviderFor(cmd, calendarName)
+			if err != nil {
+				return nil, err
+			}
+			existing, err := cal.ManagedEvents(ctx, rotation.ID(state.Rotation))
+			if err != nil {
+				return nil, err
+			}
+			stale, missing := remaining(existing, state.Events)
+			return syncRotation(cmd, cal, calendarName, state.Rotation, stale, missing, atomic)
+		}()
+		sum.add(state.Rotation, calendarName, changes, err)
+		done = doneMembers(done, state.Events, changes)
+		if err != nil {
+			failed = append(failed, calendarName)
+			errs = append(errs, fmt.Errorf("calendar %s: %w", calendarName, err))
+		}
+		if ctx.Err() != nil {
+			failed = append(failed, state.Calendars[i+1:]...)
+			break
+		}
+	}
+	err = errors.Join(errs...)
+	recordRun(state.Rotation, state.Events, failed, done, err)
+	sum.print(os.Stdout, output)
+	return err
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"calendar/pkg/notify"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newRotateNowCommand() *cobra.Command {
+	var rf rotationFlags
+	var reason string
+	var keepBoundaries bool
+	var webhook, webhookURL string
+	var users map[string]string
+	var output string
+	var stateOut string
+
+	cmd := &cobra.Command{
+		Use:   "rotate-now",
+		Short: "Hand off a rotation to the next member now, from its rotation flags or config entry",
+		Long: `Hand off a rotation to the next member right away, e.g. when the member on
+rotation is sick: the slot being served ends today and the next member in
+the turns, or the one given with --start-with, serves from today on.
+
+By default the following slots are shifted, the next member serving a whole
+slot from today and the turns going on from them, the events being computed
+again as the members command does. The rotation must then be defined as when
+it was created, e.g. with --duration, --emails and --handoff-time, either by
+the rotation flags or by its entry in the config file, completing them; only
+its members and their turns are read from the calendar. With
+--keep-boundaries, the next member only covers the rest of the slot being
+served, as an override, and the following slots are left as they are, so no
+definition is needed.
+
+The handoff is announced on Slack with --slack-webhook and posted as JSON to
+--webhook-url, along with --reason.`,
+		Example: `  calendar rotate-now --event-name "SRE Role" --duration 1 --reason "primary sick"
+
+  # The rotation is defined in the config file
+  calendar rotate-now --event-name "SRE Role" --reason "primary sick"
+
+  # Juan covers the rest of the week, the schedule stays the same
+  calendar rotate-now --event-name "SRE Role" --start-with Juan --keep-boundaries --reason "primary sick"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			// The member taking over and the order of the turns are only
+			// the ones given on the command line, not the ones the rotation
+			// was created with.
+			startWith, orderSet := rf.startWith, cmd.Flags().Changed("order")
+			if !keepBoundaries {
+				found, err := rf.applyConfig(cmd)
+				if err != nil {
+					return err
+				}
+				if !found && !cmd.Flags().Changed("duration") && !cmd.Flags().Changed("cadence") {
+					return fmt.Errorf("rotation %q isn't in the config file, its flags must be given as when it was created, e.g. --duration, or use --keep-boundaries", rf.eventName)
+				}
+			}
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			timeZone, err := client.TimeZone(ctx, calendarID)
+			if err != nil {
+				return err
+			}
+			loc, err := time.LoadLocation(timeZone)
+			if err != nil {
+				return err
+			}
+			now := time.Now().In(loc)
+			today := rotation.InLocation(now, loc)
+
+			current, err := client.SlotAt(ctx, calendarID, rf.eventName, now)
+			if err != nil {
+				return err
+			}
+			if current == nil {
+				return fmt.Errorf("nobody is on rotation %q now", rf.eventName)
+			}
+			existing, err := client.ManagedEvents(ctx, calendarID, rotation.ID(rf.eventName))
+			if err != nil {
+				return err
+			}
+			if len(existing) == 0 {
+				return fmt.Errorf("rotation %q not found in the calendar", rf.eventName)
+			}
+			members := turnOrder(existing)
+			next := startWith
+			if next == "" {
+				next = nextMember(members, members, current.Member)
+			}
+			switch {
+			case next == current.Member:
+				return fmt.Errorf("%s is already on rotation %q", next, rf.eventName)
+			case !slices.Contains(members, next):
+				return fmt.Errorf("%s isn't a member of rotation %q", next, rf.eventName)
+			}
+			slog.Info("Handing off now", "rotation", rf.eventName, "from", current.Member, "to", next, "reason", reason)
+
+			var slot *rotation.Slot
+			if keepBoundaries {
+				// The override covers whole days, up to the one the slot
+				// ends on.
+				to := rotation.InLocation(current.End.Add(-time.Second), loc)
+				slots, err := client.Override(ctx, calendarID, rf.eventName, next, today, to)
+				for _, s := range slots {
+					slog.Info("Slot overridden", "rotation", rf.eventName, "member", s.Member, "start", s.Start.Format(time.DateOnly), "end", s.End.Format(time.DateOnly), "link", s.Link)
+				}
+				if err != nil {
+					return err
+				}
+				slot = &slots[0]
+			} else {
+				rf.startWith = next
+				if !orderSet {
+					rf.order = rotation.OrderGiven
+				}
+				rf.teamMembers = members
+				rf.startDate = today.Format(time.DateOnly)
+				roster, err := loadRoster(cmd)
+				if err != nil {
+					return err
+				}
+				r, err := rf.rotation(roster)
+				if err != nil {
+					return err
+				}
+				if r.TimeZone == "" {
+					r.TimeZone = timeZone
+				}
+				// Planning first leaves the calendar untouched on invalid
+				// changes.
+				events, err := rotation.Plan(r)
+				if err != nil {
+					return err
+				}
+				if len(events) == 0 {
+					return fmt.Errorf("rotation %q has no slot left from today", rf.eventName)
+				}
+				cutover := events[0].Start
+				for _, e := range events {
+					if e.Start.Before(cutover) {
+						cutover = e.Start
+					}
+				}
+
+				if _, err := client.EndSlot(ctx, calendarID, rf.eventName, cutover); err != nil {
+					return err
+				}
+				// The slot ended may be one of the events, so they are read
+				// again before being truncated.
+				if existing, err = client.ManagedEvents(ctx, calendarID, rotation.ID(rf.eventName)); err != nil {
+					return err
+				}
+				var sum summary
+				changes, err := client.Truncate(ctx, calendarID, existing, r.Start)
+				for _, c := range changes {
+					slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+				}
+				sum.add(r.Name, "", changes, err)
+				if err != nil {
+					sum.print(cmd.OutOrStdout(), output)
+					return err
+				}
+				var finish func()
+				client.Progress, finish = newProgress(cmd, "Syncing "+r.Name)
+				changes, err = client.Sync(ctx, calendarID, nil, events)
+				finish()
+				for _, c := range changes {
+					slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+				}
+				sum.add(r.Name, "", changes, err)
+				sum.print(cmd.OutOrStdout(), output)
+				if err != nil {
+					return err
+				}
+				if slot, err = client.SlotAt(ctx, calendarID, rf.eventName, cutover); err != nil {
+					return err
+				}
+				if slot == nil {
+					return fmt.Errorf("no slot of rotation %q found at %s after the handoff", rf.eventName, cutover.Format(time.DateTime))
+				}
+			}
+
+			writeState(ctx, stateOut, client.Provider(calendarID))
+
+			var notifiers []notify.EmergencyNotifier
+			if webhook != "" {
+				notifiers = append(notifiers, &notify.Slack{WebhookURL: webhook, Users: users})
+			}
+			if webhookURL != "" {
+				notifiers = append(notifiers, &notify.Webhook{URL: webhookURL})
+			}
+			// The handoff happened already, so a notifier failing doesn't
+			// stop the others.
+			var errs []error
+			for _, n := range notifiers {
+				if err := n.NotifyEmergency(ctx, *slot, reason); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				slog.Info("Notified handoff", "rotation", rf.eventName, "member", slot.Member)
+			}
+			if err := errors.Join(errs...); err != nil {
+				return fmt.Errorf("rotation handed off to %s but not announced: %w", slot.Member, err)
+			}
+			return nil
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&reason, "reason", "", "Why the rotation is handed off ahead of schedule, e.g. \"primary sick\", included in the announcements")
+	cmd.Flags().BoolVar(&keepBoundaries, "keep-boundaries", false, "Have the next member cover the rest of the slot being served, keeping the following slots as they are")
+	cmd.Flags().StringVar(&webhook, "slack-webhook", "", "Slack incoming webhook URL of the channel to announce the handoff to")
+	cmd.Flags().StringToStringVar(&users, "slack-users", nil, "Slack user IDs of the members to mention them, e.g. Seth=U0123ABCD")
+	cmd.Flags().StringVar(&webhookURL, "webhook-url", "", "URL posted a JSON payload with the rotation, the incoming member, their slot and the reason")
+	addSummaryFlag(cmd, &output)
+	addStateOutFlag(cmd, &stateOut)
+	cmd.Flags().Lookup("start-with").Usage = "Member taking over, e.g. Juan (default is the next one in the turns)"
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("reason")
+	// The members and the start of the shifted rotation come from the
+	// calendar.
+	cmd.Flags().MarkHidden("start-date")
+	cmd.Flags().MarkHidden("team-members")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"calendar/pkg/config"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// rotationFlags are the flags defining a rotation, shared by the commands
+// that compute one.
+type rotationFlags struct {
+	teamMembers      []string
+	roles            []string
+	order            string
+	seed             int64
+	startWith        string
+	startDate        string
+	duration         int
+	cadence          string
+	until            string
+	count            int
+	eventName        string
+	timezone         string
+	unavailable      []string
+	availabilityFile string
+	emails           map[string]string
+	colors           map[string]string
+	weights          map[string]int
+	weightBy         string
+	inviteTeam       bool
+	weekdaysOnly     bool
+	handoffDay       string
+	firstSlot        string
+	handoffTime      string
+	reminders        string
+	holidayCalendar  string
+	skipHolidayWeeks bool
+	visibility       string
+	guestsCanModify  bool
+	transparency     string
+	description      string
+	descriptionFile  string
+	runbooks         []string
+	escalation       string
+	materialize      bool
+	horizon          string
+	window           string
+}
+
+func (f *rotationFlags) addFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVarP(&f.teamMembers, "team-members", "t", nil, "Comma-separated list of team members")
+	fs.StringSliceVar(&f.roles, "roles", nil, "Comma-separated roles every slot is split into, e.g. primary,secondary, the secondary of a slot being the primary of the next one")
+	fs.StringVar(&f.order, "order", rotation.OrderAlphabetical, "Order members take turns in: alphabetical, given (as listed in --team-members or the roster) or shuffle")
+	fs.Int64Var(&f.seed, "seed", 0, "Seed of the --order shuffle, to get the same order again (default is random)")
+	fs.StringVar(&f.startWith, "start-with", "", "Member serving the first slot, e.g. Seth (default is the first one in --order)")
+	fs.StringVarP(&f.startDate, "start-date", "s", "", "Start date for the rotation, e.g. 2024-07-01, next monday, in 2 weeks or july 1")
+	fs.IntVarP(&f.duration, "duration", "d", 0, "Duration of each event in weeks, e.g. 3")
+	fs.StringVar(&f.cadence, "cadence", "", "Length of each member's slot instead of --duration: daily, weekly, biweekly, monthly, an ISO-8601 period like P3D or a duration like 72h")
+	fs.StringVar(&f.until, "until", "", "Last day a slot can start on, e.g. 2025-06-30 or december 31 (default is to repeat forever)")
+	fs.IntVar(&f.count, "count", 0, "Total number of slots of the rotation, across all members (default is to repeat forever)")
+	fs.StringVarP(&f.eventName, "event-name", "n", "", "Name of the event, e.g. SRE Role")
+	fs.StringVar(&f.timezone, "timezone", "", "Time zone of the rotation events, e.g. Europe/Madrid (default is the time zone of the calendar, or UTC when not using a calendar)")
+	fs.StringArrayVar(&f.unavailable, "unavailable", nil, "Period when a member can't be on rotation, e.g. Cesar=2024-08-01..2024-08-15 (can be repeated)")
+	fs.StringVar(&f.availabilityFile, "availability", "", "YAML file listing periods when members can't be on rotation")
+	fs.StringToStringVar(&f.emails, "emails", nil, "Emails of the members to invite to their events, e.g. Cesar=cesar@example.com,Seth=seth@example.com")
+	fs.StringToStringVar(&f.colors, "colors", nil, "Colors of the members' events, as Calendar color IDs from 1 to 11 or names, e.g. Cesar=tomato,Seth=7 (default is by position in --order)")
+	fs.StringToIntVar(&f.weights, "weights", nil, "Number of slots members serve in every cycle, 1 when unset, e.g. Seth=2,Cesar=1")
+	fs.StringVar(&f.weightBy, "weight-by", rotation.WeightTurns, "How the slots of members weighing more than one are laid out: turns (spread over the cycle) or length (back to back, as a longer slot)")
+	fs.BoolVar(&f.inviteTeam, "invite-team", false, "Invite the rest of the team as optional attendees of every event")
+	fs.StringVar(&f.reminders, "reminders", "", "Reminders of every event as method:minutes before the slot starts, method being email or popup, e.g. email:1440,popup:60 (default is the calendar's default reminders)")
+	fs.StringVar(&f.visibility, "visibility", "", "Visibility of the events: default, public or private (default is the calendar's default)")
+	fs.BoolVar(&f.guestsCanModify, "guests-can-modify", false, "Let the attendees of the events modify them, on Google Calendar only")
+	fs.StringVar(&f.transparency, "transparency", "", "Whether members show as busy or free while on rotation: busy or free (default is free, busy on Google Calendar)")
+	fs.StringVar(&f.description, "description", "", "Template of the description of the events, with {{.Rotation}}, {{.Member}}, {{.Email}}, {{.SlotStart}}, {{.SlotEnd}}, {{.Previous}}, {{.Escalation}} and {{.Runbooks}}, e.g. \"{{.Member}} is on call until {{.SlotEnd}}\"")
+	fs.StringVar(&f.descriptionFile, "description-template", "", "File with the template of the description of the events instead of --description, e.g. a handoff checklist in handoff.md.tmpl")
+	fs.StringArrayVar(&f.runbooks, "runbook", nil, "Link listed in the description of the events as {{.Runbooks}}, e.g. https://wiki.example.com/sre/runbook (can be repeated)")
+	fs.StringVar(&f.escalation, "escalation", rotation.EscalationNext, "Member to escalate to when the one on rotation is unreachable, named in the description and invited as optional attendee: next (the member serving the next slot), none or a member, e.g. Seth")
+	fs.BoolVar(&f.weekdaysOnly, "weekdays-only", false, "Only schedule the rotation from Monday to Friday, with weekly slots starting on Mondays")
+	fs.StringVar(&f.handoffDay, "handoff-day", "", "Day of the week slots start on, e.g. monday, for weekly cadences (default is the day of --start-date)")
+	fs.StringVar(&f.handoffTime, "handoff-time", "", "Time of day slots start at, e.g. 09:00, in the time zone of the incoming member as set in the roster, or --timezone (default is slots of whole days)")
+	fs.StringVar(&f.holidayCalendar, "holiday-calendar", "", "Google calendar listing the team's holidays, by summary or ID, e.g. \"Holidays in Spain\" or es.spain#holiday@group.v.calendar.google.com; slots starting on a holiday start on the next business day")
+	fs.BoolVar(&f.skipHolidayWeeks, "skip-holiday-weeks", false, "Skip the slots only covering holidays of --holiday-calendar, their members serving at the end of the rotation instead")
+	fs.BoolVar(&f.materialize, "materialize", false, "Create a single event per slot instead of a recurring event per member, e.g. for large teams whose long recurrences confuse some calendar clients")
+	fs.StringVar(&f.window, "window", "", "Days and hours the rotation applies to, e.g. \"Mon-Fri 09:00-17:00\" for business hours, creating timed events covering them only (default is whole days)")
+	fs.StringVar(&f.horizon, "horizon", "12m", "How far ahead of today slots are created with --materialize when the rotation never ends, e.g. 6m or 26w, extended on every sync")
+	fs.StringVar(&f.firstSlot, "first-slot", rotation.FirstSlotShorten, "How the first slot is laid out when --start-date isn't on --handoff-day: shorten (until the next handoff day) or extend (until the one after)")
+}
+
+// applyConfig sets the flags not given on the command line from the entry of
+// the rotation named with --event-name in the config file, reporting whether
+// it has one.
+func (f *rotationFlags) applyConfig(cmd *cobra.Command) (bool, error) {
+	configFile, _ := cmd.Flags().GetString("config")
+	v, err := config.Load(configFile)
+	if err != nil {
+		return false, err
+	}
+	entries, err := config.Rotations(v)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if rotation.ID(entry.GetString("event-name")) == rotation.ID(f.eventName) {
+			if err := config.ApplyToFlags(entry, cmd.Flags()); err != nil {
+				return false, fmt.Errorf("invalid rotation %q in config file: %w", f.eventName, err)
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rotation parses the flags into a valid rotation, completed with the
+// details of the members in the roster if any.
+func (f *rotationFlags) rotation(roster *rotation.Roster) (rotation.Rotation, error) {
+	if f.order == rotation.OrderShuffle && f.seed == 0 {
+		// Syncing again must find the same order, so the seed is shown to
+		// be passed from then on.
+		f.seed = time.Now().UnixNano()
+		slog.Warn("Members shuffled with a random seed, pass it with --seed to keep the same order on later runs", "seed", f.seed)
+	}
+
+	r := rotation.Rotation{
+		Name:            f.eventName,
+		Members:         f.teamMembers,
+		Roles:           f.roles,
+		Order:           f.order,
+		Seed:            f.seed,
+		StartWith:       f.startWith,
+		Cadence:         rotation.Weeks(f.duration),
+		Count:           f.count,
+		TimeZone:        f.timezone,
+		Emails:          f.emails,
+		Colors:          f.colors,
+		Weights:         f.weights,
+		WeightBy:        f.weightBy,
+		InviteTeam:      f.inviteTeam,
+		WeekdaysOnly:    f.weekdaysOnly,
+		HandoffDay:      f.handoffDay,
+		FirstSlot:       f.firstSlot,
+		HandoffTime:     f.handoffTime,
+		SkipHolidays:    f.skipHolidayWeeks,
+		Visibility:      f.visibility,
+		GuestsCanModify: f.guestsCanModify,
+		Transparency:    f.transparency,
+		Description:     f.description,
+		Runbooks:        f.runbooks,
+		Escalation:      f.escalation,
+		Materialize:     f.materialize,
+		Window:          f.window,
+	}
+
+	// Every problem is reported at once, rather than fixing them one run
+	// at a time.
+	var errs []error
+	var err error
+	if r.Start, err = parseDateFlag("start-date", f.startDate); err != nil {
+		errs = append(errs, err)
+	}
+	if f.cadence != "" {
+		if r.Cadence, err = rotation.ParseCadence(f.cadence); err != nil {
+			errs = append(errs, err)
+		}
+	} else if f.duration <= 0 {
+		errs = append(errs, fmt.Errorf("--duration must be a positive number of weeks, got %d", f.duration))
+	}
+	if f.until != "" {
+		if r.Until, err = parseDateFlag("until", f.until); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if f.materialize {
+		horizon, err := rotation.ParseHorizon(f.horizon)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		r.Horizon = materializeHorizon(r.Start, horizon)
+	}
+
+	if f.skipHolidayWeeks && f.holidayCalendar == "" {
+		errs = append(errs, errors.New("--skip-holiday-weeks needs --holiday-calendar"))
+	}
+
+	switch {
+	case f.descriptionFile != "" && f.description != "":
+		errs = append(errs, errors.New("--description and --description-template can't be used together"))
+	case f.descriptionFile != "":
+		if r.Description, err = rotation.LoadDescription(f.descriptionFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if f.reminders != "" {
+		if r.Reminders, err = rotation.ParseReminders(f.reminders); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if f.availabilityFile != "" {
+		if r.Unavailable, err = rotation.LoadUnavailabilities(f.availabilityFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, s := range f.unavailable {
+		u, err := rotation.ParseUnavailability(s)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.Unavailable = append(r.Unavailable, u)
+	}
+
+	if roster != nil {
+		if err := roster.Apply(&r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// The rotation is only checked once parsed, so values that failed to
+	// parse don't show up again as invalid.
+	if len(errs) > 0 {
+		return r, errors.Join(errs...)
+	}
+	return r, r.Validate()
+}
+
+// parseDateFlag parses the day given with a flag, either formatted as
+// YYYY-MM-DD or written like next monday, echoing the day it resolves to in
+// the latter case so it can be checked before the calendar changes.
+func parseDateFlag(name, value string) (time.Time, error) {
+	t, err := rotation.ParseDate(value, time.Now())
+	if err != nil {
+		return t, fmt.Errorf("invalid --%s: %w", name, err)
+	}
+	if value != t.Format(time.DateOnly) {
+		slog.Info("Date resolved", "flag", name, "value", value, "date", t.Format(time.DateOnly))
+	}
+	return t, nil
+}
+
+// materializeHorizon returns the last day the slots of a materialized
+// rotation starting on start are created for, the horizon ahead of today, or
+// of start when later, so syncing again keeps extending the rotation.
+func materializeHorizon(start time.Time, horizon rotation.Cadence) time.Time {
+	from := rotation.InLocation(time.Now(), time.UTC)
+	if start.After(from) {
+		from = start
+	}
+	return horizon.After(from)
+}
+
+// loadRoster reads the roster file given with --roster, or the members of
+// the GitHub team given with --github-team or the Google Workspace group
+// given with --group, returning nil when there is none.
+func loadRoster(cmd *cobra.Command) (*rotation.Roster, error) {
+	path, _ := cmd.Flags().GetString("roster")
+	team, _ := cmd.Flags().GetString("github-team")
+	group, _ := cmd.Flags().GetString("group")
+	var roster *rotation.Roster
+	if path != "" {
+		var err error
+		if roster, err = rotation.LoadRoster(path); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case team != "" && group != "":
+		return nil, errors.New("--github-team and --group can't be used together")
+	case team != "":
+		return githubRoster(cmd, team, roster)
+	case group != "":
+		return groupRoster(cmd, group, roster)
+	}
+	return roster, nil
+}
+
+// mergeRoster returns the roster of the given members, e.g. read from a team,
+// completed with the details of the members of the roster file, if any,
+// found by name or email. Details of the file take precedence, so the team
+// only decides who is in the rotation. Members sharing a name are named by
+// their email instead.
+func mergeRoster(members []rotation.Member, file *rotation.Roster) *rotation.Roster {
+	roster := &rotation.Roster{}
+	names := make(map[string]bool)
+	for _, m := range members {
+		if file != nil {
+			i := slices.IndexFunc(file.Members, func(f rotation.Member) bool {
+				return f.Name == m.Name || (m.Email != "" && strings.EqualFold(f.Email, m.Email))
+			})
+			if i >= 0 {
+				email := m.Email
+				m = file.Members[i]
+				m.Email = cmp.Or(m.Email, email)
+			}
+		}
+		if names[m.Name] && m.Email != "" {
+			m.Name = m.Email
+		}
+		names[m.Name] = true
+		roster.Members = append(roster.Members, m)
+	}
+	return roster
+}
+
+// checkMembers returns an error if any of the members is missing from the
+// roster, if any.
+func checkMembers(roster *rotation.Roster, members ...string) error {
+	if roster == nil {
+		return nil
+	}
+	for _, m := range members {
+		if err := roster.Check(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"calendar/pkg/config"
+	"calendar/pkg/gcal"
+	"calendar/pkg/notify"
+	"calendar/pkg/rotation"
+	"calendar/pkg/telemetry"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newServeCommand() *cobra.Command {
+	var schedule string
+	var webhook string
+	var users map[string]string
+	var webhookURL string
+	var grpcAddress string
+	var stateOut string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Keep the rotations of the config file synced and announce handoffs",
+		Long: `Keep the rotations of the config file synced and announce handoffs.
+
+The config file is read again on every run, so changes to the rotations or to
+the roster are applied to the calendar without restarting. Rotations are
+listed under the rotations key using the flag names as keys, e.g.
+
+  rotations:
+    - event-name: SRE Role
+      team-members: [Cesar, Seth, Juan]
+      start-date: 2024-07-01
+      duration: 1
+
+Rotations created with materialize get the slots entering their horizon on
+every run, so it keeps rolling ahead.
+
+Handoffs are announced on Slack once, on the first run of the day they
+happen, when --slack-webhook is set, and posted as JSON to --webhook-url for
+other automation, e.g. status pages or bots.
+
+Metrics of the Calendar API calls, handoffs and syncs are served with
+--metrics-address, e.g. to alert when syncing stops succeeding, and traces of
+every run are sent with --otlp-endpoint.
+
+Who is on call now and next per rotation is written to --state-out after
+every run, for the textfile collector of the node exporter when ending in
+.prom, or as JSON when ending in .json, so dashboards can show it without
+calling the calendar. The file is as fresh as the last run, so handoffs
+at a time of day call for runs shortly after them, e.g. --schedule "5 * * * *".
+
+The gRPC API defined in pkg/api/calendar.proto is served with --grpc-address
+for internal services, to create rotations, swap slots and ask who is on
+rotation, with the Go client generated in the calendar/pkg/api package. The
+API is not authenticated, so it must only be reachable from trusted
+networks.`,
+		Example: `  # Sync every morning at 6
+  calendar serve --schedule "0 6 * * *" --slack-webhook https://hooks.slack.com/services/...`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sched, err := cron.ParseStandard(schedule)
+			if err != nil {
+				return fmt.Errorf("invalid schedule %q: %w", schedule, err)
+			}
+
+			ctx := cmd.Context()
+			stop, err := startTelemetry(cmd)
+			if err != nil {
+				return err
+			}
+			defer stop()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			s := &server{
+				cmd:        cmd,
+				client:     client,
+				calendarID: calendarID,
+				notified:   make(map[string]time.Time),
+			}
+			if webhook != "" {
+				s.notifiers = append(s.notifiers, &notify.Slack{WebhookURL: webhook, Users: users})
+			}
+			if webhookURL != "" {
+				s.notifiers = append(s.notifiers, &notify.Webhook{URL: webhookURL})
+			}
+			if grpcAddress != "" {
+				stopGRPC, err := s.serveGRPC(grpcAddress)
+				if err != nil {
+					return err
+				}
+				defer stopGRPC()
+			}
+			for {
+				s.run(ctx)
+				writeState(ctx, stateOut, s.client.Provider(s.calendarID))
+				next := sched.Next(time.Now())
+				slog.Info("Waiting for next run", "at", next)
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Until(next)):
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&schedule, "schedule", "0 6 * * *", "Cron expression of when to sync the rotations, e.g. @weekly")
+	cmd.Flags().StringVar(&webhook, "slack-webhook", "", "Slack incoming webhook URL of the channel to announce handoffs to")
+	cmd.Flags().StringToStringVar(&users, "slack-users", nil, "Slack user IDs of the members to mention them, e.g. Seth=U0123ABCD")
+	cmd.Flags().StringVar(&webhookURL, "webhook-url", "", "URL posted a JSON payload with the rotation, the incoming member and their slot on every handoff")
+	cmd.Flags().StringVar(&stateOut, "state-out", "", "File to write who is on call now and next per rotation to after every run, e.g. /var/lib/node_exporter/textfile/oncall.prom, or oncall.json for JSON")
+	cmd.Flags().StringVar(&grpcAddress, "grpc-address", "", "Address to serve the gRPC API on, e.g. :9090 (default is not to serve it)")
+	addTelemetryFlags(cmd)
+
+	return cmd
+}
+
+// server syncs the rotations of the config file on every run.
+type server struct {
+	cmd        *cobra.Command
+	client     *gcal.Client
+	calendarID string
+	// notifiers announce the handoffs, e.g. on Slack and to a webhook.
+	notifiers []notify.Notifier
+	// notified holds the start of the last slot announced per rotation.
+	notified map[string]time.Time
+	// mu serializes the changes to the calendar of the runs and of the
+	// gRPC API.
+	mu sync.Mutex
+}
+
+// run syncs every rotation, logging failures so a broken rotation doesn't
+// stop the others nor the next runs.
+func (s *server) run(ctx context.Context) {
+	ctx, span := telemetry.Tracer().Start(ctx, "run")
+	defer span.End()
+
+	configFile, _ := s.cmd.Flags().GetString("config")
+	v, err := config.Load(configFile)
+	if err != nil {
+		slog.Error("Unable to load config", "error", err)
+		return
+	}
+	entries, err := config.Rotations(v)
+	if err != nil {
+		slog.Error("Unable to load rotations", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		slog.Warn("No rotations in config file")
+		return
+	}
+
+	roster, err := rosterOf(s.cmd, v)
+	if err != nil {
+		slog.Error("Unable to load roster", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.unlock(ctx)
+	for i, entry := range entries {
+		var rf rotationFlags
+		fs := pflag.NewFlagSet("rotation", pflag.ContinueOnError)
+		rf.addFlags(fs)
+		if err := config.ApplyToFlags(entry, fs); err != nil {
+			slog.Error("Invalid rotation in config file", "index", i+1, "error", err)
+			continue
+		}
+		r, err := rf.rotation(roster)
+		if err != nil {
+			slog.Error("Invalid rotation in config file", "index", i+1, "error", err)
+			continue
+		}
+		if rf.holidayCalendar != "" {
+			if err := addHolidays(ctx, s.client, &r, rf.holidayCalendar); err != nil {
+				slog.Error("Unable to read holidays", "rotation", r.Name, "error", err)
+				continue
+			}
+		}
+		err = s.sync(ctx, r)
+		telemetry.Synced(r.Name, err)
+		if err != nil {
+			slog.Error("Unable to sync rotation", "rotation", r.Name, "error", err)
+			continue
+		}
+		if err := s.announce(ctx, r.Name); err != nil {
+			slog.Error("Unable to announce handoff", "rotation", r.Name, "error", err)
+		}
+	}
+}
+
+// unlock releases the lock taken on the calendar by the changes made, so it
+// isn't held between runs.
+func (s *server) unlock(ctx context.Context) {
+	if err := s.client.Unlock(context.WithoutCancel(ctx)); err != nil {
+		slog.Error("Unable to release the lock", "error", err)
+	}
+}
+
+// roster loads the roster of the config file, as read again on every run.
+func (s *server) roster() (*rotation.Roster, error) {
+	configFile, _ := s.cmd.Flags().GetString("config")
+	v, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return rosterOf(s.cmd, v)
+}
+
+// rosterOf loads the roster given with --roster, or else under the roster
+// key of the config file, nil when there is none.
+func rosterOf(cmd *cobra.Command, v *viper.Viper) (*rotation.Roster, error) {
+	rosterFile, _ := cmd.Flags().GetString("roster")
+	if v.IsSet("roster") && !cmd.Flags().Changed("roster") {
+		rosterFile = v.GetString("roster")
+	}
+	if rosterFile == "" {
+		return nil, nil
+	}
+	return rotation.LoadRoster(rosterFile)
+}
+
+func (s *server) sync(ctx context.Context, r rotation.Rotation) (err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "sync", trace.WithAttributes(attribute.String("rotation", r.Name)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if r.TimeZone == "" {
+		r.TimeZone, err = s.client.TimeZone(ctx, s.calendarID)
+		if err != nil {
+			return err
+		}
+	}
+	events, err := rotation.Plan(r)
+	if err != nil {
+		return err
+	}
+	existing, err := s.client.ManagedEvents(ctx, s.calendarID, rotation.ID(r.Name))
+	if err != nil {
+		return err
+	}
+	changes, err := s.client.Sync(ctx, s.calendarID, existing, events)
+	for _, c := range changes {
+		slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+	}
+	return err
+}
+
+// announce counts the handoff of a rotation if it happened today and wasn't
+// seen yet, and notifies it to every notifier, e.g. Slack and the webhook.
+func (s *server) announce(ctx context.Context, name string) error {
+	now := time.Now()
+	slot, err := s.client.SlotAt(ctx, s.calendarID, name, now)
+	if err != nil || slot == nil {
+		return err
+	}
+	if now.Sub(slot.Start) >= 24*time.Hour || s.notified[name].Equal(slot.Start) {
+		return nil
+	}
+	for _, n := range s.notifiers {
+		if err := n.Notify(ctx, *slot); err != nil {
+			return err
+		}
+		slog.Info("Notified handoff", "rotation", name, "member", slot.Member)
+	}
+	s.notified[name] = slot.Start
+	telemetry.HandedOff(name)
+	return nil
+}
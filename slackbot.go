@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/rotation"
+	"calendar/pkg/slackbot"
+
+	"github.com/spf13/cobra"
+)
+
+func newSlackbotCommand() *cobra.Command {
+	var eventName string
+	var listen string
+	var secret string
+	var users map[string]string
+	var weeks int
+
+	cmd := &cobra.Command{
+		Use:   "slackbot",
+		Short: "Answer Slack slash commands about a rotation",
+		Long: `Answer Slack slash commands about a rotation, so the team can check and
+change it without leaving Slack:
+
+  /oncall who                           who is on rotation now
+  /oncall schedule                      who is on rotation in the next --weeks
+  /oncall swap @seth @cesar [date]      @cesar takes the slot of @seth
+
+Create a Slack app with a slash command whose request URL reaches --listen,
+e.g. https://oncall.example.com/, and enable escaping of users so mentions
+can be told apart. Requests are checked with the signing secret of the app.`,
+		Example: `  SLACK_SIGNING_SECRET=... calendar slackbot -c team-roles -n "SRE Role" --slack-users Cesar=U0123ABCD,Seth=U0456EFGH`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if secret == "" {
+				secret = os.Getenv("SLACK_SIGNING_SECRET")
+			}
+			if secret == "" {
+				return errors.New("either --signing-secret or SLACK_SIGNING_SECRET must be set")
+			}
+			if weeks < 1 {
+				return errors.New("--weeks must be at least 1")
+			}
+
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			h := &slackbot.Handler{
+				SigningSecret: secret,
+				Rotation:      eventName,
+				Calendar:      slackCalendar{client: client, calendarID: calendarID},
+				Users:         users,
+				Weeks:         weeks,
+			}
+
+			srv := &http.Server{Addr: listen, Handler: h, ReadHeaderTimeout: 10 * time.Second}
+			serveErr := make(chan error, 1)
+			go func() {
+				slog.Info("Listening for slash commands", "address", listen)
+				serveErr <- srv.ListenAndServe()
+			}()
+			select {
+			case err := <-serveErr:
+				return fmt.Errorf("unable to serve slash commands: %w", err)
+			case <-ctx.Done():
+				shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+				defer cancel()
+				return srv.Shutdown(shutdownCtx)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&listen, "listen", ":8080", "Address to listen on for slash commands")
+	cmd.Flags().StringVar(&secret, "signing-secret", "", "Signing secret of the Slack app (default is $SLACK_SIGNING_SECRET)")
+	cmd.Flags().StringToStringVar(&users, "slack-users", nil, "Slack user IDs of the members to mention them, e.g. Seth=U0123ABCD")
+	cmd.Flags().IntVarP(&weeks, "weeks", "w", 4, "Number of weeks ahead the schedule command shows")
+	cmd.MarkFlagRequired("event-name")
+
+	return cmd
+}
+
+// slackCalendar is the calendar of the rotation the Slack bot answers about.
+type slackCalendar struct {
+	client     *gcal.Client
+	calendarID string
+}
+
+func (c slackCalendar) Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	return c.client.Slots(ctx, c.calendarID, from, to)
+}
+
+func (c slackCalendar) Swap(ctx context.Context, name, from, to string, date time.Time) ([]rotation.Slot, error) {
+	// The bot keeps running, so the lock is released right away for the
+	// other runs.
+	defer func() {
+		if err := c.client.Unlock(context.WithoutCancel(ctx)); err != nil {
+			slog.Error("Unable to release the lock", "error", err)
+		}
+	}()
+	return c.client.Swap(ctx, c.calendarID, name, from, to, date)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/store"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/googleapi"
+)
+
+func newSyncLocalCommand() *cobra.Command {
+	var pastMonths, months int
+
+	cmd := &cobra.Command{
+		Use:   "local",
+		Short: "Copy the slots of the calendars to the local state, to read them offline",
+		Long: `Copy the slots of every rotation of the calendars given with --calendar to the
+local state, replacing the previous copy, so the who, list, history and report
+commands keep working offline, with --offline, or when the calendar can't be
+reached, falling back to it then.
+
+The copy covers --past-months before today and --months after it, and should
+be refreshed regularly while the calendar is reachable, e.g. from cron. The
+number of slots added and removed since the previous copy is logged.`,
+		Example: `  calendar sync local --calendar team-roles --months 6`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			st, err := openState(cmd)
+			if err != nil {
+				return err
+			}
+			defer st.Close()
+
+			calendarNames, _ := cmd.Flags().GetStringArray("calendar")
+			var errs []error
+			for _, name := range calendarNames {
+				err := func() error {
+					cal, err := newCalendarProviderFor(cmd, name)
+					if err != nil {
+						return err
+					}
+					tz, err := cal.TimeZone(ctx)
+					if err != nil {
+						return err
+					}
+					loc, err := time.LoadLocation(tz)
+					if err != nil {
+						return err
+					}
+					today := rotation.InLocation(time.Now().In(loc), loc)
+					snapshot := store.Snapshot{
+						SyncedAt: time.Now(),
+						TimeZone: tz,
+						From:     today.AddDate(0, -pastMonths, 0),
+						To:       today.AddDate(0, months, 0),
+					}
+					if snapshot.Slots, err = cal.Slots(ctx, snapshot.From, snapshot.To); err != nil {
+						return err
+					}
+					added, removed, err := st.Save(stateKey(cmd, name), snapshot)
+					if err != nil {
+						return err
+					}
+					slog.Info("Local state synced", "calendar", name, "slots", len(snapshot.Slots), "added", added, "removed", removed)
+					return nil
+				}()
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+
+	cmd.Flags().IntVar(&pastMonths, "past-months", 12, "Number of months before today copied, e.g. for history")
+	cmd.Flags().IntVar(&months, "months", 12, "Number of months after today copied")
+
+	return cmd
+}
+
+// statePath returns the path given with --state, or state.db of the
+// profile.
+func statePath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("state"); path != "" {
+		return path, nil
+	}
+	return profileFile(cmd, "state.db")
+}
+
+// openState opens the local state of the calendars.
+func openState(cmd *cobra.Command) (*store.Store, error) {
+	path, err := statePath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return store.Open(path)
+}
+
+// stateKey returns the key of the copy of the named calendar in the local
+// state, the same calendar name meaning different calendars with different
+// providers.
+func stateKey(cmd *cobra.Command, calendarName string) string {
+	name, _ := cmd.Flags().GetString("provider")
+	return name + "/" + calendarName
+}
+
+// localCalendar returns the copy of the named calendar in the local state.
+func localCalendar(cmd *cobra.Command, calendarName string) (*store.Calendar, error) {
+	st, err := openState(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	snapshot, err := st.Load(stateKey(cmd, calendarName))
+	if err != nil {
+		return nil, err
+	}
+	slog.Warn("Reading the local state of the calendar, which may be outdated", "calendar", calendarName, "synced", snapshot.SyncedAt.Format(time.DateTime))
+	return &store.Calendar{Snapshot: *snapshot}, nil
+}
+
+// newReadProvider returns the provider of the calendar selected with
+// --calendar for commands only reading its slots, falling back to its copy
+// in the local state when the calendar can't be reached, or right away with
+// --offline.
+func newReadProvider(cmd *cobra.Command) (provider.CalendarProvider, error) {
+	calendarName, err := singleCalendar(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if offline, _ := cmd.Flags().GetBool("offline"); offline {
+		return localCalendar(cmd, calendarName)
+	}
+	cal, err := newCalendarProviderFor(cmd, calendarName)
+	if unreachable(err) {
+		slog.Warn("Calendar unreachable", "calendar", calendarName, "error", err)
+		return localCalendar(cmd, calendarName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fallbackCalendar{CalendarProvider: cal, cmd: cmd, calendarName: calendarName}, nil
+}
+
+// fallbackCalendar reads the time zone and the slots of its local copy when
+// the calendar can't be reached.
+type fallbackCalendar struct {
+	provider.CalendarProvider
+	cmd          *cobra.Command
+	calendarName string
+}
+
+func (c *fallbackCalendar) TimeZone(ctx context.Context) (string, error) {
+	tz, err := c.CalendarProvider.TimeZone(ctx)
+	if !unreachable(err) {
+		return tz, err
+	}
+	slog.Warn("Calendar unreachable", "calendar", c.calendarName, "error", err)
+	local, err := localCalendar(c.cmd, c.calendarName)
+	if err != nil {
+		return "", err
+	}
+	c.CalendarProvider = local
+	return local.TimeZone(ctx)
+}
+
+func (c *fallbackCalendar) Slots(ctx context.Context, from, to time.Time) ([]rotation.Slot, error) {
+	slots, err := c.CalendarProvider.Slots(ctx, from, to)
+	if !unreachable(err) {
+		return slots, err
+	}
+	slog.Warn("Calendar unreachable", "calendar", c.calendarName, "error", err)
+	local, err := localCalendar(c.cmd, c.calendarName)
+	if err != nil {
+		return nil, err
+	}
+	c.CalendarProvider = local
+	return local.Slots(ctx, from, to)
+}
+
+// unreachable reports whether err means the calendar provider can't be
+// reached, e.g. no network or an outage, rather than a problem with the
+// request.
+func unreachable(err error) bool {
+	var urlErr *url.Error
+	var apiErr *googleapi.Error
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &urlErr):
+		return true
+	case errors.As(err, &apiErr):
+		return apiErr.Code >= 500
+	}
+	return false
+}
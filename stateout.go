@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/telemetry"
+
+	"github.com/spf13/cobra"
+)
+
+// stateWeeks is how far ahead the next member of a rotation is looked for
+// when writing the on-call state.
+const stateWeeks = 12
+
+// addStateOutFlag adds the --state-out flag of the commands changing the
+// rotations, the file the on-call state is written to once they did.
+func addStateOutFlag(cmd *cobra.Command, path *string) {
+	cmd.Flags().StringVar(path, "state-out", "", "File to write who is on call now and next per rotation to once the calendar changed, e.g. /var/lib/node_exporter/textfile/oncall.prom, or oncall.json for JSON")
+}
+
+// writeState writes who is on call now and next per rotation of the
+// calendars to path, if set, looking for the next handoff as far as
+// stateWeeks ahead. Failures are only logged, as the calendars changed
+// already.
+func writeState(ctx context.Context, path string, cals ...provider.CalendarProvider) {
+	if path == "" {
+		return
+	}
+	now := time.Now()
+	var slots []rotation.Slot
+	for _, cal := range cals {
+		found, err := cal.Slots(ctx, now, now.AddDate(0, 0, 7*stateWeeks))
+		if err != nil {
+			slog.Error("Unable to read on-call state", "error", err)
+			return
+		}
+		slots = append(slots, found...)
+	}
+	if err := telemetry.WriteState(path, telemetry.NewState(rotation.Statuses(slots, now), now)); err != nil {
+		slog.Error("Unable to write on-call state", "path", path, "error", err)
+		return
+	}
+	slog.Info("On-call state written", "path", path)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"calendar/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+// summary counts the events changed by a mutating command, along with the
+// rotations that failed and why, so bulk changes end with a short report
+// rather than only a wall of logs.
+type summary struct {
+	Created    int       `json:"created"`
+	Updated    int       `json:"updated"`
+	Deleted    int       `json:"deleted"`
+	RolledBack int       `json:"rolledBack"`
+	Failed     []failure `json:"failed"`
+}
+
+// failure is a rotation that couldn't be synced with a calendar.
+type failure struct {
+	Rotation string `json:"rotation"`
+	Calendar string `json:"calendar,omitempty"`
+	Error    string `json:"error"`
+}
+
+// add counts the changes made to a rotation in a calendar, and its error if
+// any.
+func (s *summary) add(rotation, calendar string, changes []provider.Change, err error) {
+	for _, c := range changes {
+		switch c.Action {
+		case "created":
+			s.Created++
+		case "updated", "truncated", "adopted":
+			s.Updated++
+		case "deleted":
+			s.Deleted++
+		case "rolled back":
+			s.RolledBack++
+		}
+	}
+	if err != nil {
+		s.Failed = append(s.Failed, failure{Rotation: rotation, Calendar: calendar, Error: err.Error()})
+	}
+}
+
+// print renders the summary as a line of counts followed by the failures, or
+// as JSON.
+func (s *summary) print(w io.Writer, output string) error {
+	if output == "json" {
+		out := *s
+		if out.Failed == nil {
+			out.Failed = []failure{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	counts := fmt.Sprintf("%d created, %d updated, %d deleted", s.Created, s.Updated, s.Deleted)
+	if s.RolledBack > 0 {
+		counts += fmt.Sprintf(", %d rolled back", s.RolledBack)
+	}
+	fmt.Fprintf(w, "\nEvents: %s, %d failed\n", counts, len(s.Failed))
+	for _, f := range s.Failed {
+		where := f.Rotation
+		if f.Calendar != "" {
+			where += " in " + f.Calendar
+		}
+		fmt.Fprintf(w, "  %s: %s\n", where, f.Error)
+	}
+	return nil
+}
+
+// checkSummaryOutput returns an error if output is not a summary format.
+func checkSummaryOutput(output string) error {
+	if output != "table" && output != "json" {
+		return fmt.Errorf("unknown output format %q, must be one of: table, json", output)
+	}
+	return nil
+}
+
+// addSummaryFlag adds the --output flag selecting the format of the summary.
+func addSummaryFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", "table", "Output format of the summary of the changes made: table or json")
+}
+
+// newProgress returns a function drawing the progress of a sync on stderr
+// and another one erasing it once done. Nothing is drawn when stderr is
+// not a terminal, e.g. in pipelines, or with --quiet, as the logs already
+// tell what happened.
+func newProgress(cmd *cobra.Command, label string) (update func(done, total int), finish func()) {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	if fi, err := os.Stderr.Stat(); quiet || err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil, func() {}
+	}
+	const width = 30
+	drawn := false
+	update = func(done, total int) {
+		if total == 0 {
+			return
+		}
+		filled := width * done / total
+		fmt.Fprintf(os.Stderr, "\r%s [%s%s] %d/%d", label, strings.Repeat("=", filled), strings.Repeat(" ", width-filled), done, total)
+		drawn = true
+	}
+	finish = func() {
+		if drawn {
+			// Erases the line, so the logs and summary start clean.
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}
+	}
+	return update, finish
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newSwapCommand() *cobra.Command {
+	var eventName string
+	var from, to string
+	var date string
+
+	cmd := &cobra.Command{
+		Use:   "swap",
+		Short: "Exchange the upcoming slots of two members of a rotation",
+		Example: `  # Seth covers Cesar's slot starting on 2024-09-02 and Cesar takes Seth's next one.
+  calendar swap --event-name "SRE Role" --from Cesar --to Seth --date 2024-09-02`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dateParsed, err := time.Parse(time.DateOnly, date)
+			if err != nil {
+				return fmt.Errorf("unable to parse date: %w", err)
+			}
+
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			if err := checkMembers(roster, from, to); err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			slots, err := client.Swap(ctx, calendarID, eventName, from, to, dateParsed)
+			if err != nil {
+				return err
+			}
+			for _, s := range slots {
+				slog.Info("Slot swapped", "rotation", eventName, "member", s.Member, "start", s.Start.Format(time.DateOnly), "end", s.End.Format(time.DateOnly))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&from, "from", "", "Member giving away the slot at --date")
+	cmd.Flags().StringVar(&to, "to", "", "Member taking the slot at --date and giving away their next one")
+	cmd.Flags().StringVar(&date, "date", "", "Date within the slot to swap, e.g. 2024-09-02")
+	cmd.MarkFlagRequired("event-name")
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+	cmd.MarkFlagRequired("date")
+
+	return cmd
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Push a rotation to other scheduling tools or to the local state",
+		Long: `Push a rotation to other scheduling tools, so incident management tools page
+whoever is on rotation in the calendar.
+
+PagerDuty schedules get the rotation as a layer. Other tools get the shifts of
+the weeks given with --weeks, consecutive slots of a member making a single
+shift, and should be synced regularly, e.g. from cron.
+
+The local state gets the slots of every rotation, to read them offline.`,
+	}
+
+	cmd.AddCommand(newSyncPagerDutyCommand())
+	cmd.AddCommand(newSyncOnCallCommand(grafanaOnCallTarget))
+	cmd.AddCommand(newSyncOnCallCommand(victorOpsTarget))
+	cmd.AddCommand(newSyncLocalCommand())
+
+	return cmd
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"calendar/pkg/oncall"
+
+	"github.com/spf13/pflag"
+)
+
+// grafanaOnCallTarget pushes rotations to Grafana OnCall.
+var grafanaOnCallTarget = onCallTarget{
+	use:   "grafana-oncall",
+	short: "Push a rotation to a Grafana OnCall schedule as shifts",
+	example: `  # Keep the SRE Role rotation and its Grafana OnCall schedule in sync for the next 8 weeks
+  GRAFANA_ONCALL_TOKEN=... calendar sync grafana-oncall \
+    --url https://oncall-prod-us-central-0.grafana.net/oncall --schedule-id SBM7DV7BKFUYU \
+    -t Cesar,Seth -s 2024-07-01 -d 1 -n "SRE Role" --emails Cesar=cesar@example.com,Seth=seth@example.com -w 8`,
+	schedule: "ID of the Grafana OnCall schedule to push the rotation to, which must be of type calendar",
+	flags: func(fs *pflag.FlagSet) func() (oncall.Provider, error) {
+		var apiURL, apiToken string
+		fs.StringVar(&apiURL, "url", "", "Base URL of the OnCall API of the Grafana stack")
+		fs.StringVar(&apiToken, "api-token", "", "Grafana OnCall API token (default is $GRAFANA_ONCALL_TOKEN)")
+		return func() (oncall.Provider, error) {
+			if apiToken == "" {
+				apiToken = os.Getenv("GRAFANA_ONCALL_TOKEN")
+			}
+			if apiToken == "" {
+				return nil, fmt.Errorf("either --api-token or GRAFANA_ONCALL_TOKEN must be set")
+			}
+			if apiURL == "" {
+				return nil, fmt.Errorf("--url must be set")
+			}
+			return &oncall.Grafana{URL: apiURL, Token: apiToken}, nil
+		}
+	},
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"calendar/pkg/oncall"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// onCallTarget is an incident management tool the sync command pushes the
+// shifts of a rotation to.
+type onCallTarget struct {
+	use     string
+	short   string
+	example string
+	// schedule describes what the --schedule-id flag identifies in the tool.
+	schedule string
+	// flags adds the flags of the tool and returns how to create its
+	// provider from them.
+	flags func(fs *pflag.FlagSet) func() (oncall.Provider, error)
+}
+
+// newSyncOnCallCommand returns the sync command of a tool consuming the
+// shifts of a rotation.
+func newSyncOnCallCommand(target onCallTarget) *cobra.Command {
+	var rf rotationFlags
+	var scheduleID string
+	var users map[string]string
+	var weeks int
+	var newProvider func() (oncall.Provider, error)
+
+	cmd := &cobra.Command{
+		Use:     target.use,
+		Short:   target.short,
+		Example: target.example,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if scheduleID == "" {
+				return fmt.Errorf("--schedule-id must be set")
+			}
+			if rf.startDate == "" || rf.eventName == "" {
+				return fmt.Errorf("--start-date, --duration (or --cadence) and --event-name must be set")
+			}
+			if weeks < 1 {
+				return fmt.Errorf("--weeks must be at least 1")
+			}
+
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			r, err := rf.rotation(roster)
+			if err != nil {
+				return err
+			}
+			if len(r.Roles) > 0 {
+				return fmt.Errorf("rotations with roles can't be pushed to a single schedule, sync one rotation per role instead")
+			}
+			p, err := newProvider()
+			if err != nil {
+				return err
+			}
+
+			// Members without a user ID are looked up by email.
+			ids := make(map[string]string, len(r.Members))
+			for _, member := range r.Members {
+				if id, ok := users[member]; ok {
+					ids[member] = id
+					continue
+				}
+				email, ok := r.Emails[member]
+				if !ok {
+					return fmt.Errorf("member %q needs either a user ID in --users or an email in --emails", member)
+				}
+				ids[member], err = p.UserID(ctx, email)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Slots last a day at least, so previewing a slot per day is
+			// enough to cover the weeks pushed.
+			slots, err := rotation.Preview(r, 7*weeks)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			horizon := now.AddDate(0, 0, 7*weeks)
+			var upcoming []rotation.Slot
+			for _, s := range slots {
+				if s.End.After(now) && s.Start.Before(horizon) {
+					upcoming = append(upcoming, s)
+				}
+			}
+			shifts, err := oncall.Shifts(upcoming, ids)
+			if err != nil {
+				return err
+			}
+
+			result, err := p.Sync(ctx, scheduleID, r.Name, shifts)
+			if err != nil {
+				return err
+			}
+			slog.Info("Schedule synced", "schedule", scheduleID, "rotation", r.Name, "shiftsCreated", result.Created, "shiftsDeleted", result.Deleted)
+			return nil
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	newProvider = target.flags(cmd.Flags())
+	cmd.Flags().StringVar(&scheduleID, "schedule-id", "", target.schedule)
+	cmd.Flags().StringToStringVar(&users, "users", nil, "User IDs of the members in the tool, e.g. Cesar=U1,Seth=U2 (default is to look them up by --emails)")
+	cmd.Flags().IntVarP(&weeks, "weeks", "w", 12, "Number of weeks ahead to push")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"calendar/pkg/pagerduty"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newSyncPagerDutyCommand() *cobra.Command {
+	var rf rotationFlags
+	var apiToken string
+	var scheduleID string
+	var users map[string]string
+
+	cmd := &cobra.Command{
+		Use:   "pagerduty",
+		Short: "Push a rotation to a PagerDuty schedule as a layer and overrides",
+		Example: `  # Keep the SRE Role rotation of the calendar and its PagerDuty schedule in sync
+  PAGERDUTY_TOKEN=... calendar sync pagerduty --schedule-id PABC123 \
+    -t Cesar,Seth -s 2024-07-01 -d 1 -n "SRE Role" --users Cesar=PUSER1,Seth=PUSER2`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if apiToken == "" {
+				apiToken = os.Getenv("PAGERDUTY_TOKEN")
+			}
+			if apiToken == "" {
+				return fmt.Errorf("either --api-token or PAGERDUTY_TOKEN must be set")
+			}
+			if scheduleID == "" {
+				return fmt.Errorf("--schedule-id must be set")
+			}
+			if rf.startDate == "" || rf.eventName == "" {
+				return fmt.Errorf("--start-date, --duration (or --cadence) and --event-name must be set")
+			}
+
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			r, err := rf.rotation(roster)
+			if err != nil {
+				return err
+			}
+
+			client := &pagerduty.Client{Token: apiToken}
+			schedule, err := client.Schedule(ctx, scheduleID)
+			if err != nil {
+				return err
+			}
+			// The rotation follows the schedule's time zone unless told otherwise.
+			if r.TimeZone == "" {
+				r.TimeZone = schedule.TimeZone
+			}
+
+			// Members without a PagerDuty user ID are looked up by email.
+			ids := make(map[string]string, len(r.Members))
+			for _, member := range r.Members {
+				if id, ok := users[member]; ok {
+					ids[member] = id
+					continue
+				}
+				email, ok := r.Emails[member]
+				if !ok {
+					return fmt.Errorf("member %q needs either a PagerDuty user ID in --users or an email in --emails", member)
+				}
+				ids[member], err = client.UserID(ctx, email)
+				if err != nil {
+					return err
+				}
+			}
+
+			events, err := rotation.Plan(r)
+			if err != nil {
+				return err
+			}
+			layer, err := pagerduty.NewLayer(r, events, ids)
+			if err != nil {
+				return err
+			}
+			overrides, err := pagerduty.NewOverrides(events, ids)
+			if err != nil {
+				return err
+			}
+
+			created, err := client.Sync(ctx, schedule, layer, overrides)
+			if err != nil {
+				return err
+			}
+			slog.Info("Schedule synced", "schedule", schedule.Name, "layer", layer.Name, "overridesCreated", created)
+			return nil
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&apiToken, "api-token", "", "PagerDuty REST API key (default is $PAGERDUTY_TOKEN)")
+	cmd.Flags().StringVar(&scheduleID, "schedule-id", "", "ID of the PagerDuty schedule to push the rotation to")
+	cmd.Flags().StringToStringVar(&users, "users", nil, "PagerDuty user IDs of the members, e.g. Cesar=PUSER1,Seth=PUSER2 (default is to look them up by --emails)")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"calendar/pkg/oncall"
+
+	"github.com/spf13/pflag"
+)
+
+// victorOpsTarget pushes rotations to Splunk On-Call.
+var victorOpsTarget = onCallTarget{
+	use:   "victorops",
+	short: "Push a rotation to a Splunk On-Call (VictorOps) escalation policy as overrides",
+	example: `  # Page the SRE Role members through the sre-primary policy, which pages a
+  # rotation of the sre-placeholder user when nobody is overriding it
+  VICTOROPS_API_ID=... VICTOROPS_API_KEY=... calendar sync victorops \
+    --schedule-id sre-primary --placeholder sre-placeholder \
+    -t Cesar,Seth -s 2024-07-01 -d 1 -n "SRE Role" --users Cesar=cesar,Seth=seth`,
+	schedule: "Slug of the Splunk On-Call escalation policy to push the rotation to",
+	flags: func(fs *pflag.FlagSet) func() (oncall.Provider, error) {
+		var apiID, apiKey, placeholder string
+		fs.StringVar(&apiID, "api-id", "", "Splunk On-Call API ID (default is $VICTOROPS_API_ID)")
+		fs.StringVar(&apiKey, "api-key", "", "Splunk On-Call API key (default is $VICTOROPS_API_KEY)")
+		fs.StringVar(&placeholder, "placeholder", "", "Username of the user the escalation policy pages when not overridden, whose shifts are overridden by the members")
+		return func() (oncall.Provider, error) {
+			if apiID == "" {
+				apiID = os.Getenv("VICTOROPS_API_ID")
+			}
+			if apiKey == "" {
+				apiKey = os.Getenv("VICTOROPS_API_KEY")
+			}
+			if apiID == "" || apiKey == "" {
+				return nil, fmt.Errorf("either --api-id and --api-key or VICTOROPS_API_ID and VICTOROPS_API_KEY must be set")
+			}
+			if placeholder == "" {
+				return nil, fmt.Errorf("--placeholder must be set")
+			}
+			return &oncall.VictorOps{APIID: apiID, APIKey: apiKey, Placeholder: placeholder}, nil
+		}
+	},
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"calendar/pkg/telemetry"
+
+	"github.com/spf13/cobra"
+)
+
+// addTelemetryFlags adds the flags exposing metrics and traces of a long
+// running command.
+func addTelemetryFlags(cmd *cobra.Command) {
+	cmd.Flags().String("metrics-address", "", "Address to serve Prometheus metrics on at /metrics, e.g. :9090, none when unset")
+	cmd.Flags().String("otlp-endpoint", "", "URL of the OTLP collector to send traces of the Calendar API calls to, e.g. http://localhost:4318, none when unset unless $OTEL_EXPORTER_OTLP_ENDPOINT is set")
+}
+
+// startTelemetry serves the metrics and sets up tracing as asked with the
+// telemetry flags, returning a function stopping both.
+func startTelemetry(cmd *cobra.Command) (stop func(), err error) {
+	ctx := cmd.Context()
+	var stops []func(context.Context) error
+
+	endpoint, _ := cmd.Flags().GetString("otlp-endpoint")
+	if endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		shutdown, err := telemetry.SetupTracing(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, shutdown)
+	}
+
+	if address, _ := cmd.Flags().GetString("metrics-address"); address != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", telemetry.Handler())
+		srv := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			slog.Info("Serving metrics", "address", address)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Unable to serve metrics", "error", err)
+			}
+		}()
+		stops = append(stops, srv.Shutdown)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		for _, stop := range stops {
+			if err := stop(ctx); err != nil {
+				slog.Warn("Unable to stop telemetry", "error", err)
+			}
+		}
+	}, nil
+}
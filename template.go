@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"calendar/pkg/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// templatePlaceholders are the rotation flags templates leave out, given
+// when creating a rotation from them.
+var templatePlaceholders = []string{"team-members", "start-date"}
+
+func newTemplateCommand(root *cobra.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Save common rotation setups and create rotations from them",
+		Long: `Save common rotation setups, e.g. a weekly interrupt catcher or a two week
+primary and secondary SRE rotation, as templates of the rotation flags.
+
+Templates leave the team members and the start date out, as placeholders
+given when creating a rotation from them, along with its name. They are kept
+in the templates directory of $HOME/.config/team-calendar.`,
+		Example: `  # Save a two week primary and secondary rotation handing off on Mondays at 9
+  calendar template save sre-primary-secondary --cadence biweekly --roles primary,secondary \
+    --handoff-day monday --handoff-time 09:00 --reminders popup:60
+
+  # Create a rotation from it
+  calendar template create-from sre-primary-secondary -n "SRE" -t Cesar,Seth,Juan -s 2024-07-01`,
+	}
+	cmd.AddCommand(newTemplateSaveCommand())
+	cmd.AddCommand(newTemplateListCommand())
+	cmd.AddCommand(newTemplateCreateFromCommand(root))
+	return cmd
+}
+
+func newTemplateSaveCommand() *cobra.Command {
+	var rf rotationFlags
+
+	cmd := &cobra.Command{
+		Use:   "save NAME",
+		Short: "Save the rotation flags given as a template",
+		Long: `Save the rotation flags given as a template, replacing the template of the
+same name if any. Only the flags given are saved, the others keep their
+defaults when creating a rotation from the template.`,
+		Args: cobra.ExactArgs(1),
+		// Only the flags given are saved, so the config file isn't applied
+		// to them.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return setupLogging(cmd)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range templatePlaceholders {
+				if cmd.Flags().Changed(name) {
+					return fmt.Errorf("--%s is given when creating a rotation from the template, not saved in it", name)
+				}
+			}
+			flags := templateFlags(cmd)
+			if len(flags) == 0 {
+				return fmt.Errorf("no rotation flags given to save in template %s", args[0])
+			}
+			if err := config.SaveTemplate(args[0], flags); err != nil {
+				return err
+			}
+			slog.Info("Template saved", "template", args[0], "flags", len(flags))
+			return nil
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
+
+// templateFlags returns the values of the local flags of a command given on
+// the command line, as written in a config file.
+func templateFlags(cmd *cobra.Command) map[string]any {
+	flags := make(map[string]any)
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if cmd.LocalFlags().Lookup(f.Name) == nil {
+			return
+		}
+		switch v := f.Value.(type) {
+		case pflag.SliceValue:
+			flags[f.Name] = v.GetSlice()
+		default:
+			switch f.Value.Type() {
+			case "stringToString", "stringToInt":
+				// Maps are written as key=value entries, so viper keeps
+				// their keys as written.
+				var pairs []string
+				if s := strings.Trim(f.Value.String(), "[]"); s != "" {
+					pairs = strings.Split(s, ",")
+				}
+				sort.Strings(pairs)
+				flags[f.Name] = pairs
+			default:
+				flags[f.Name] = f.Value.String()
+			}
+		}
+	})
+	return flags
+}
+
+func newTemplateListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the saved templates and their flags",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := config.Templates()
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				fmt.Println("No templates saved, save one with the template save command.")
+				return nil
+			}
+			return printTemplates(os.Stdout, names)
+		},
+	}
+}
+
+// printTemplates renders the named templates along with their flags.
+func printTemplates(w io.Writer, names []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEMPLATE\tFLAGS")
+	for _, name := range names {
+		v, err := config.LoadTemplate(name)
+		if err != nil {
+			return err
+		}
+		keys := v.AllKeys()
+		slices.Sort(keys)
+		var flags []string
+		for _, key := range keys {
+			value := v.Get(key)
+			if list, ok := value.([]any); ok {
+				var items []string
+				for _, item := range list {
+					items = append(items, fmt.Sprint(item))
+				}
+				value = strings.Join(items, ",")
+			}
+			flags = append(flags, fmt.Sprintf("--%s=%v", key, value))
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(flags, " "))
+	}
+	return tw.Flush()
+}
+
+// newTemplateCreateFromCommand returns the command creating a rotation from
+// a template, which takes the flags of the root command creating rotations
+// and runs it.
+func newTemplateCreateFromCommand(root *cobra.Command) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-from NAME",
+		Short: "Create a rotation from a template",
+		Long: `Create a rotation from a template, given its name, members and start date.
+
+Every flag of the command creating rotations is accepted, and overrides the
+one saved in the template, e.g. --dry-run to preview the rotation first.`,
+		Example: `  calendar template create-from weekly-interrupt -n "Interrupt Catcher" -t Cesar,Seth -s 2024-07-01 --dry-run`,
+		Args:    cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The template is applied before the config file, so it
+			// overrides the defaults set there.
+			v, err := config.LoadTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			if err := config.ApplyToFlags(v, cmd.Flags()); err != nil {
+				return fmt.Errorf("template %s: %w", args[0], err)
+			}
+			return root.PersistentPreRunE(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return root.RunE(cmd, nil)
+		},
+	}
+
+	// The flags are shared with the root command, so its run reads them.
+	cmd.Flags().AddFlagSet(root.Flags())
+
+	return cmd
+}
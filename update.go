@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/rotation"
+
+	"github.com/spf13/cobra"
+)
+
+func newUpdateCommand() *cobra.Command {
+	var rf rotationFlags
+	var from string
+	var output string
+	var stateOut string
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Change the parameters of a rotation from a date on",
+		Long: `Change the parameters of a rotation from a date on.
+
+The existing events of the rotation stop repeating the day before --from and
+are kept as history, while the events of the updated rotation are created
+from --from on. Members already in the rotation are kept unless
+--team-members is given.
+
+Only the events created by the tool, as told by their extended properties,
+are updated: events named like the rotation by people are left alone.`,
+		Example: `  # Switch the SRE Role to two week slots from October on
+  calendar update --event-name "SRE Role" --duration 2 --from 2024-10-01`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" || rf.eventName == "" {
+				return fmt.Errorf("--event-name and --from must be set")
+			}
+			if err := checkSummaryOutput(output); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			client, calendarID, err := newCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			existing, err := client.ManagedEvents(ctx, calendarID, rotation.ID(rf.eventName))
+			if err != nil {
+				return err
+			}
+			if len(existing) == 0 {
+				return fmt.Errorf("rotation %q not found in the calendar", rf.eventName)
+			}
+
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			if len(rf.teamMembers) == 0 && roster == nil {
+				for _, event := range existing {
+					if event.ExtendedProperties == nil {
+						continue
+					}
+					member := event.ExtendedProperties.Private[gcal.PropertyMember]
+					if member != "" && !slices.Contains(rf.teamMembers, member) {
+						rf.teamMembers = append(rf.teamMembers, member)
+					}
+				}
+			}
+
+			// The updated rotation starts at the cut-over.
+			rf.startDate = from
+			r, err := rf.rotation(roster)
+			if err != nil {
+				return err
+			}
+			if r.TimeZone == "" {
+				r.TimeZone, err = client.TimeZone(ctx, calendarID)
+				if err != nil {
+					return err
+				}
+			}
+			// Planning first leaves the calendar untouched on invalid updates.
+			events, err := rotation.Plan(r)
+			if err != nil {
+				return err
+			}
+
+			var sum summary
+			changes, err := client.Truncate(ctx, calendarID, existing, r.Start)
+			for _, c := range changes {
+				slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+			}
+			sum.add(r.Name, "", changes, err)
+			if err != nil {
+				sum.print(cmd.OutOrStdout(), output)
+				return err
+			}
+
+			var finish func()
+			client.Progress, finish = newProgress(cmd, "Syncing "+r.Name)
+			changes, err = client.Sync(ctx, calendarID, nil, events)
+			finish()
+			for _, c := range changes {
+				slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+			}
+			sum.add(r.Name, "", changes, err)
+			writeState(ctx, stateOut, client.Provider(calendarID))
+			sum.print(cmd.OutOrStdout(), output)
+			return err
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringVar(&from, "from", "", "First day of the updated rotation, e.g. 2024-10-01")
+	addSummaryFlag(cmd, &output)
+	addStateOutFlag(cmd, &stateOut)
+	// The updated rotation starts at --from.
+	cmd.Flags().MarkHidden("start-date")
+	cmd.MarkFlagsMutuallyExclusive("duration", "cadence")
+	cmd.MarkFlagsMutuallyExclusive("until", "count")
+
+	return cmd
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+	"calendar/pkg/spec"
+
+	"github.com/spf13/cobra"
+)
+
+func newVerifyCommand() *cobra.Command {
+	var rf rotationFlags
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the calendars still hold the expected schedule of the rotations",
+		Long: `Check the calendars still hold the expected schedule of the rotations, e.g.
+nightly in CI, failing with a drift report when events were edited or deleted
+by hand.
+
+The expected schedule is computed again from the rotations of the spec file
+given with -f, only the one named with --event-name if set, or otherwise from
+the rotation flags, completed with the entry of the rotations of the config
+file of the same --event-name, as used by serve.
+
+Events are prefixed with + when missing from the calendar, ~ when modified
+and - when unexpected.`,
+		Example: `  # Check every rotation of a spec file
+  calendar verify -f rotations.yaml
+
+  # Check a rotation of the config file
+  calendar verify --event-name "SRE Role"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := cmd.OutOrStdout()
+			drifted := 0
+			report := func(name, calendar string, changes []provider.Change, err error) {
+				switch {
+				case err != nil:
+					fmt.Fprintf(w, "%s (%s): failed\n", name, calendar)
+				case len(changes) == 0:
+					fmt.Fprintf(w, "%s (%s): as expected\n", name, calendar)
+				default:
+					drifted++
+					printDrift(w, name, calendar, changes)
+				}
+			}
+
+			if file != "" {
+				err := syncSpec(cmd, file, provider.SyncOptions{DryRun: true}, func(s spec.Rotation, calendar string, existing int, changes []provider.Change, err error) {
+					if rf.eventName == "" || rotation.ID(s.Name) == rotation.ID(rf.eventName) {
+						report(s.Name, calendar, changes, err)
+					}
+				})
+				if err != nil {
+					return err
+				}
+			} else {
+				if rf.eventName == "" {
+					return fmt.Errorf("either --filename or --event-name must be set")
+				}
+				if err := verifyRotation(cmd, &rf, report); err != nil {
+					return err
+				}
+			}
+			if drifted > 0 {
+				return fmt.Errorf("%d rotations drifted from the expected schedule", drifted)
+			}
+			return nil
+		},
+	}
+
+	rf.addFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "Spec file listing the rotations")
+
+	return cmd
+}
+
+// verifyRotation compares the calendars given with --calendar with the
+// rotation of the flags, completed with its entry in the config file.
+func verifyRotation(cmd *cobra.Command, rf *rotationFlags, report func(name, calendar string, changes []provider.Change, err error)) error {
+	ctx := cmd.Context()
+	if _, err := rf.applyConfig(cmd); err != nil {
+		return err
+	}
+	if rf.startDate == "" {
+		return fmt.Errorf("rotation %q isn't in the config file, its flags must be given, e.g. --start-date", rf.eventName)
+	}
+
+	roster, err := loadRoster(cmd)
+	if err != nil {
+		return err
+	}
+	r, err := rf.rotation(roster)
+	if err != nil {
+		return err
+	}
+	if rf.holidayCalendar != "" {
+		client, err := newGoogleCalendarClient(cmd)
+		if err != nil {
+			return err
+		}
+		if err := addHolidays(ctx, client, &r, rf.holidayCalendar); err != nil {
+			return err
+		}
+	}
+
+	calendarNames, _ := cmd.Flags().GetStringArray("calendar")
+	for _, calendarName := range calendarNames {
+		changes, err := func() ([]provider.Change, error) {
+			cal, err := newCalendarProviderFor(cmd, calendarName)
+			if err != nil {
+				return nil, err
+			}
+			r := r
+			if r.TimeZone == "" {
+				if r.TimeZone, err = cal.TimeZone(ctx); err != nil {
+					return nil, err
+				}
+			}
+			events, err := rotation.Plan(r)
+			if err != nil {
+				return nil, err
+			}
+			existing, err := cal.ManagedEvents(ctx, rotation.ID(r.Name))
+			if err != nil {
+				return nil, err
+			}
+			return cal.Sync(ctx, existing, events, provider.SyncOptions{DryRun: true})
+		}()
+		report(r.Name, calendarName, changes, err)
+		if err != nil {
+			return fmt.Errorf("unable to verify rotation %q in calendar %s: %w", r.Name, calendarName, err)
+		}
+	}
+	return nil
+}
+
+// printDrift writes the events of a rotation that don't match the expected
+// schedule, as the changes syncing it would make.
+func printDrift(w io.Writer, name, calendar string, changes []provider.Change) {
+	fmt.Fprintf(w, "%s (%s): drifted\n", name, calendar)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s %s (%s)\n", planSymbols[c.Action], c.Summary, driftNames[c.Action])
+	}
+}
+
+// driftNames describe the changes syncing a rotation would make as what is
+// wrong with the calendar.
+var driftNames = map[string]string{
+	"created": "missing",
+	"updated": "modified",
+	"deleted": "unexpected",
+}
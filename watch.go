@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"calendar/pkg/gcal"
+	"calendar/pkg/provider"
+	"calendar/pkg/spec"
+	"calendar/pkg/telemetry"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/calendar/v3"
+)
+
+func newWatchCommand() *cobra.Command {
+	var file string
+	var address, listen string
+	var certFile, keyFile string
+	var ttl time.Duration
+	var reconcile bool
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Detect changes made to the rotations outside of the tool",
+		Long: `Detect changes made to the rotations outside of the tool, e.g. events edited
+in the calendar UI.
+
+The calendars of the spec file are watched for changes with Google Calendar
+push notifications, received on --listen. Google only sends them to a public
+HTTPS URL with a valid certificate, given with --address, which must reach
+the listening address, e.g. through a TLS terminating proxy, or directly with
+--tls-cert and --tls-key.
+
+On every change the calendars are compared with the spec, like the plan
+command does, and the drift is logged. With --reconcile the spec is applied
+again to undo the drift. Channels are renewed before they expire.
+
+Metrics of the Calendar API calls and syncs are served with --metrics-address,
+e.g. to alert when the calendars stop matching the spec, and traces of every
+check are sent with --otlp-endpoint.`,
+		Example: `  calendar watch -f rotations.yaml --address https://calendar-watch.example.com/ --reconcile`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name, _ := cmd.Flags().GetString("provider"); name != provider.Google {
+				return fmt.Errorf("%s is only supported with the %s provider", cmd.CommandPath(), provider.Google)
+			}
+			if (certFile == "") != (keyFile == "") {
+				return errors.New("--tls-cert and --tls-key must be set together")
+			}
+
+			ctx := cmd.Context()
+			stop, err := startTelemetry(cmd)
+			if err != nil {
+				return err
+			}
+			defer stop()
+			f, err := spec.Load(file)
+			if err != nil {
+				return err
+			}
+			client, err := newGoogleCalendarClient(cmd)
+			if err != nil {
+				return err
+			}
+			var calendarIDs []string
+			for _, s := range f.Rotations {
+				for _, name := range specCalendars(cmd, s) {
+					id, err := client.CalendarID(ctx, name)
+					if err != nil {
+						return err
+					}
+					if !slices.Contains(calendarIDs, id) {
+						calendarIDs = append(calendarIDs, id)
+					}
+				}
+			}
+
+			token, err := randomToken()
+			if err != nil {
+				return err
+			}
+			w := &watcher{
+				cmd:       cmd,
+				file:      file,
+				reconcile: reconcile,
+				token:     token,
+				changed:   make(chan struct{}, 1),
+			}
+			srv := &http.Server{Addr: listen, Handler: w}
+			serveErr := make(chan error, 1)
+			go func() {
+				slog.Info("Listening for notifications", "address", listen)
+				if certFile != "" {
+					serveErr <- srv.ListenAndServeTLS(certFile, keyFile)
+				} else {
+					serveErr <- srv.ListenAndServe()
+				}
+			}()
+			defer srv.Shutdown(context.WithoutCancel(ctx))
+
+			channels, err := w.watch(ctx, client, calendarIDs, address, ttl)
+			// Channels are stopped even when interrupted.
+			defer func() { w.stop(context.WithoutCancel(ctx), client, channels) }()
+			if err != nil {
+				return err
+			}
+
+			// The calendars may have drifted while nobody was watching.
+			w.check(ctx)
+			for {
+				renew := time.NewTimer(renewIn(channels))
+				select {
+				case <-ctx.Done():
+					renew.Stop()
+					return nil
+				case err := <-serveErr:
+					renew.Stop()
+					return fmt.Errorf("unable to receive notifications: %w", err)
+				case <-w.changed:
+					renew.Stop()
+					w.check(ctx)
+				case <-renew.C:
+					// New channels are opened before the old ones are
+					// stopped, so no change goes unnoticed.
+					renewed, err := w.watch(ctx, client, calendarIDs, address, ttl)
+					w.stop(ctx, client, channels)
+					channels = renewed
+					if err != nil {
+						return err
+					}
+				}
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "filename", "f", "", "Spec file listing the rotations")
+	cmd.Flags().StringVar(&address, "address", "", "Public HTTPS URL Google sends the notifications to, reaching --listen")
+	cmd.Flags().StringVar(&listen, "listen", ":8443", "Address to listen on for notifications")
+	cmd.Flags().StringVar(&certFile, "tls-cert", "", "Certificate file to serve HTTPS with, plain HTTP is served when unset")
+	cmd.Flags().StringVar(&keyFile, "tls-key", "", "Private key file of --tls-cert")
+	cmd.Flags().DurationVar(&ttl, "ttl", 24*time.Hour, "Time to live of the notification channels, renewed before they expire")
+	cmd.Flags().BoolVar(&reconcile, "reconcile", false, "Apply the spec file again when the calendars drifted from it")
+	addTelemetryFlags(cmd)
+	cmd.MarkFlagRequired("filename")
+	cmd.MarkFlagRequired("address")
+
+	return cmd
+}
+
+// watcher receives the notifications of the channels watching the calendars
+// of a spec file, and compares them with the spec on every change.
+type watcher struct {
+	cmd       *cobra.Command
+	file      string
+	reconcile bool
+	// token authenticates the notifications of the channels.
+	token string
+	// changed holds a pending change, so changes notified while checking
+	// the calendars lead to a single check.
+	changed chan struct{}
+}
+
+// ServeHTTP handles a push notification.
+func (w *watcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(gcal.HeaderChannelToken)), []byte(w.token)) != 1 {
+		http.Error(rw, "invalid channel token", http.StatusForbidden)
+		return
+	}
+	state := r.Header.Get(gcal.HeaderResourceState)
+	slog.Debug("Notification received", "channel", r.Header.Get(gcal.HeaderChannelID), "state", state)
+	if state != gcal.ResourceStateSync {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+// watch opens a channel for each calendar, returning the ones opened so far
+// on error.
+func (w *watcher) watch(ctx context.Context, client *gcal.Client, calendarIDs []string, address string, ttl time.Duration) ([]*calendar.Channel, error) {
+	var channels []*calendar.Channel
+	for _, id := range calendarIDs {
+		channel, err := client.Watch(ctx, id, address, w.token, ttl)
+		if err != nil {
+			return channels, err
+		}
+		slog.Info("Watching calendar", "calendar", id, "channel", channel.Id, "expiration", gcal.ChannelExpiration(channel))
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// stop stops the channels, logging failures as they expire anyway.
+func (w *watcher) stop(ctx context.Context, client *gcal.Client, channels []*calendar.Channel) {
+	for _, channel := range channels {
+		if err := client.StopWatch(ctx, channel); err != nil {
+			slog.Warn("Unable to stop channel", "channel", channel.Id, "error", err)
+		}
+	}
+}
+
+// check compares the calendars with the spec file, applying it again when
+// they drifted and reconciling. Rotations matching the spec, or reconciled
+// with it, count as synced.
+func (w *watcher) check(ctx context.Context) {
+	// The syncs read the context of the command, which holds the span for
+	// the time of the check.
+	ctx, span := telemetry.Tracer().Start(ctx, "check")
+	defer span.End()
+	parent := w.cmd.Context()
+	w.cmd.SetContext(ctx)
+	defer w.cmd.SetContext(parent)
+
+	drifted := make(map[string]bool)
+	err := syncSpec(w.cmd, w.file, provider.SyncOptions{DryRun: true}, func(s spec.Rotation, calendar string, existing int, changes []provider.Change, err error) {
+		if err != nil {
+			telemetry.Synced(s.Name, err)
+			return
+		}
+		for _, c := range changes {
+			drifted[s.Name] = true
+			slog.Warn("Rotation drifted from spec", "rotation", s.Name, "calendar", calendar, "fix", c.Action, "summary", c.Summary)
+		}
+		if !drifted[s.Name] {
+			telemetry.Synced(s.Name, nil)
+		}
+	})
+	if err != nil {
+		slog.Error("Unable to compare calendars with spec", "error", err)
+		return
+	}
+	if len(drifted) == 0 {
+		slog.Info("Calendars match spec")
+		return
+	}
+	if !w.reconcile {
+		return
+	}
+	err = syncSpec(w.cmd, w.file, provider.SyncOptions{}, func(s spec.Rotation, calendar string, existing int, changes []provider.Change, err error) {
+		for _, c := range changes {
+			slog.Info("Event "+c.Action, "rotation", s.Name, "calendar", calendar, "summary", c.Summary, "link", c.Link)
+		}
+		if drifted[s.Name] {
+			telemetry.Synced(s.Name, err)
+		}
+	})
+	if err != nil {
+		slog.Error("Unable to reconcile calendars with spec", "error", err)
+	}
+}
+
+// renewIn returns how long until the channels must be renewed, an hour
+// before the first one expires.
+func renewIn(channels []*calendar.Channel) time.Duration {
+	// Channels may be opened without expiration, in which case they are
+	// renewed daily anyway.
+	renew := 24 * time.Hour
+	for _, channel := range channels {
+		if expiration := gcal.ChannelExpiration(channel); !expiration.IsZero() {
+			renew = min(renew, time.Until(expiration)-time.Hour)
+		}
+	}
+	return max(renew, time.Minute)
+}
+
+// randomToken returns a random hex string, hard to guess.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
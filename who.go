@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"calendar/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+func newWhoCommand() *cobra.Command {
+	var eventName string
+	var at string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "who",
+		Short: "Show who is on rotation",
+		Example: `  # Who is on the SRE Role right now?
+  calendar who --event-name "SRE Role"
+
+  # Who is on the SRE Role on Christmas, for scripts?
+  calendar who --event-name "SRE Role" --at 2024-12-25 --output json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "table" && output != "json" {
+				return fmt.Errorf("unknown output format %q, must be one of: table, json", output)
+			}
+
+			ctx := cmd.Context()
+			cal, err := newReadProvider(cmd)
+			if err != nil {
+				return err
+			}
+
+			when := time.Now()
+			if at != "" {
+				// Dates are days of the calendar's time zone, like the events.
+				tz, err := cal.TimeZone(ctx)
+				if err != nil {
+					return err
+				}
+				loc, err := time.LoadLocation(tz)
+				if err != nil {
+					return fmt.Errorf("calendar has an unknown time zone: %w", err)
+				}
+				when, err = time.ParseInLocation(time.DateOnly, at, loc)
+				if err != nil {
+					return fmt.Errorf("unable to parse date: %w", err)
+				}
+			}
+
+			slot, err := provider.SlotAt(ctx, cal, eventName, when)
+			if err != nil {
+				return err
+			}
+			if slot == nil {
+				return fmt.Errorf("nobody is on rotation %q on %s", eventName, when.Format(time.DateOnly))
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(slot)
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ROTATION\tMEMBER\tSINCE\tUNTIL")
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", slot.Rotation, slot.Member, slot.Start.Format(time.DateOnly), slot.End.Format(time.DateOnly))
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().StringVarP(&eventName, "event-name", "n", "", "Name of the rotation, e.g. SRE Role")
+	cmd.Flags().StringVar(&at, "at", "", "Date to look up instead of now, e.g. 2024-12-25")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: table or json")
+	cmd.MarkFlagRequired("event-name")
+
+	return cmd
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"calendar/pkg/provider"
+	"calendar/pkg/rotation"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+// previewSlots is the number of slots shown in the wizard preview.
+const previewSlots = 8
+
+func newWizardCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wizard",
+		Short: "Create a rotation interactively",
+		Long: `Create a rotation interactively.
+
+The calendar, members, start date and cadence of the rotation are asked for
+one after the other, with a preview of the upcoming slots updated as they are
+typed. The rotation is only created once confirmed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			roster, err := loadRoster(cmd)
+			if err != nil {
+				return err
+			}
+			calendarName, err := singleCalendar(cmd)
+			if err != nil {
+				return err
+			}
+			var members []string
+			if roster != nil {
+				members = roster.Names()
+			}
+
+			m := newWizardModel(calendarName, members, time.Now())
+			final, err := tea.NewProgram(m, tea.WithContext(cmd.Context())).Run()
+			if err != nil {
+				return fmt.Errorf("unable to run wizard: %w", err)
+			}
+			m = final.(wizardModel)
+			if !m.confirmed {
+				return errors.New("aborted, no rotation created")
+			}
+
+			r, err := m.rotation()
+			if err != nil {
+				return err
+			}
+			if roster != nil {
+				if err := roster.Apply(&r); err != nil {
+					return err
+				}
+			}
+
+			ctx := cmd.Context()
+			cal, err := newCalendarProviderFor(cmd, m.inputs[wizardCalendar].Value())
+			if err != nil {
+				return err
+			}
+			r.TimeZone, err = cal.TimeZone(ctx)
+			if err != nil {
+				return err
+			}
+			events, err := rotation.Plan(r)
+			if err != nil {
+				return err
+			}
+			existing, err := cal.ManagedEvents(ctx, rotation.ID(r.Name))
+			if err != nil {
+				return err
+			}
+			if len(existing) > 0 {
+				return fmt.Errorf("rotation %q already exists, use the update command to change it", r.Name)
+			}
+			progress, finish := newProgress(cmd, "Creating "+r.Name)
+			changes, err := cal.Sync(ctx, nil, events, provider.SyncOptions{Atomic: true, Progress: progress})
+			finish()
+			for _, c := range changes {
+				slog.Info("Event "+c.Action, "summary", c.Summary, "link", c.Link)
+			}
+			var sum summary
+			sum.add(r.Name, "", changes, err)
+			sum.print(cmd.OutOrStdout(), "table")
+			return err
+		},
+	}
+	return cmd
+}
+
+// Fields of the wizard, in the order they are asked for.
+const (
+	wizardCalendar = iota
+	wizardName
+	wizardMembers
+	wizardStart
+	wizardCadence
+	wizardFields
+)
+
+var wizardLabels = [wizardFields]string{
+	wizardCalendar: "Calendar",
+	wizardName:     "Rotation name",
+	wizardMembers:  "Members",
+	wizardStart:    "Start date",
+	wizardCadence:  "Cadence",
+}
+
+// wizardModel is the state of the wizard: the answers typed so far, the
+// field being edited, and whether the rotation was confirmed.
+type wizardModel struct {
+	inputs     [wizardFields]textinput.Model
+	focus      int
+	confirming bool
+	confirmed  bool
+}
+
+func newWizardModel(calendarName string, members []string, now time.Time) wizardModel {
+	var m wizardModel
+	placeholders := [wizardFields]string{
+		wizardCalendar: "primary",
+		wizardName:     "SRE Role",
+		wizardMembers:  "Cesar, Seth, Juan",
+		wizardStart:    "YYYY-MM-DD",
+		wizardCadence:  "weekly, biweekly, monthly, P3D...",
+	}
+	values := [wizardFields]string{
+		wizardCalendar: calendarName,
+		wizardMembers:  strings.Join(members, ", "),
+		wizardStart:    now.Format(time.DateOnly),
+		wizardCadence:  "weekly",
+	}
+	for i := range m.inputs {
+		m.inputs[i] = textinput.New()
+		m.inputs[i].Prompt = ""
+		m.inputs[i].Placeholder = placeholders[i]
+		m.inputs[i].SetValue(values[i])
+	}
+	m.inputs[m.focus].Focus()
+	return m
+}
+
+func (m wizardModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m wizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if ok && (key.Type == tea.KeyCtrlC || key.Type == tea.KeyEsc) {
+		return m, tea.Quit
+	}
+	if m.confirming {
+		if !ok {
+			return m, nil
+		}
+		switch strings.ToLower(key.String()) {
+		case "y":
+			m.confirmed = true
+			return m, tea.Quit
+		case "n":
+			m.confirming = false
+		}
+		return m, nil
+	}
+
+	if ok {
+		switch key.Type {
+		case tea.KeyEnter, tea.KeyTab, tea.KeyDown:
+			if m.focus == wizardFields-1 && key.Type == tea.KeyEnter {
+				if _, err := m.preview(); err == nil {
+					m.confirming = true
+				}
+				return m, nil
+			}
+			return m, m.move(1)
+		case tea.KeyShiftTab, tea.KeyUp:
+			return m, m.move(-1)
+		}
+	}
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	return m, cmd
+}
+
+// move focuses the field delta positions away from the current one.
+func (m *wizardModel) move(delta int) tea.Cmd {
+	m.inputs[m.focus].Blur()
+	m.focus = (m.focus + delta + wizardFields) % wizardFields
+	return m.inputs[m.focus].Focus()
+}
+
+func (m wizardModel) View() string {
+	var b strings.Builder
+	b.WriteString("Create a rotation (tab to move between fields, enter on the last one to confirm, esc to abort)\n\n")
+	for i, input := range m.inputs {
+		cursor := "  "
+		if i == m.focus && !m.confirming {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "%s%-14s %s\n", cursor, wizardLabels[i]+":", input.View())
+	}
+
+	b.WriteString("\nPreview:\n")
+	slots, err := m.preview()
+	if err != nil {
+		fmt.Fprintf(&b, "  %s\n", err)
+	}
+	for _, s := range slots {
+		fmt.Fprintf(&b, "  %s → %s  %s\n", s.Start.Format(time.DateOnly), s.End.Format(time.DateOnly), s.Member)
+	}
+	if len(slots) == previewSlots {
+		b.WriteString("  ...\n")
+	}
+
+	if m.confirming {
+		fmt.Fprintf(&b, "\nCreate rotation %q in calendar %q? (y/n)\n", m.inputs[wizardName].Value(), m.inputs[wizardCalendar].Value())
+	}
+	return b.String()
+}
+
+// preview returns the first slots of the rotation as currently typed.
+func (m wizardModel) preview() ([]rotation.Slot, error) {
+	r, err := m.rotation()
+	if err != nil {
+		return nil, err
+	}
+	return rotation.Preview(r, previewSlots)
+}
+
+// rotation returns the rotation described by the answers.
+func (m wizardModel) rotation() (rotation.Rotation, error) {
+	r := rotation.Rotation{Name: strings.TrimSpace(m.inputs[wizardName].Value())}
+	if m.inputs[wizardCalendar].Value() == "" {
+		return r, errors.New("calendar is required")
+	}
+	for _, member := range strings.Split(m.inputs[wizardMembers].Value(), ",") {
+		if member = strings.TrimSpace(member); member != "" {
+			r.Members = append(r.Members, member)
+		}
+	}
+	var err error
+	if r.Start, err = time.Parse(time.DateOnly, m.inputs[wizardStart].Value()); err != nil {
+		return r, fmt.Errorf("invalid start date: %w", err)
+	}
+	if r.Cadence, err = rotation.ParseCadence(m.inputs[wizardCadence].Value()); err != nil {
+		return r, err
+	}
+	return r, r.Validate()
+}